@@ -0,0 +1,216 @@
+// Package apierror defines the error type of the API and the fiber error handler,
+// that turns every error returned by a handler into the documented error envelope:
+//
+//	{ "status": "error", "code": "not_found", "message": "Content not found", "details": [...], "request_id": "..." }
+package apierror
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Stable, machine-readable error codes
+const (
+	CodeInvalidInput     = "invalid_input"     // request body can not be parsed or required fields are missing
+	CodeInvalidID        = "invalid_id"        // ID in the route or body is not a valid ObjectID
+	CodeInvalidQuery     = "invalid_query"     // query parameters can not be parsed
+	CodeValidationFailed = "validation_failed" // input is well-formed, but violates rules; see details
+	CodeUnauthorized     = "unauthorized"      // missing, malformed, invalid or expired token, or wrong credentials
+	CodeForbidden        = "forbidden"         // authenticated, but not allowed
+	CodeNotFound         = "not_found"         // requested document does not exist
+	CodeRouteNotFound    = "route_not_found"   // no route matches the request
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeAlreadyExists    = "already_exists"      // document with the same name, tag or email exists
+	CodeDuplicateKey     = "duplicate_key"       // value of a unique field or index is already in use
+	CodeConflict         = "conflict"            // request conflicts with the current state, e.g. a running job
+	CodePayloadTooLarge  = "payload_too_large"   // request body exceeds the limit
+	CodeTimeout          = "timeout"             // database or request timed out
+	CodeInternal         = "internal_error"      // unexpected error; the cause is logged, but not returned
+	CodeNotSupported     = "not_supported"       // feature is not available with the configured storage backend
+	CodeUnavailable      = "service_unavailable" // database is not reachable
+)
+
+// Error returned by handlers and middlewares
+type Error struct {
+	Status  int      `json:"-"`
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Details []Detail `json:"details,omitempty"`
+	Err     error    `json:"-"` // cause, only logged
+}
+
+// Field-level detail of an error
+type Detail struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %s", e.Code, e.Message, e.Err.Error())
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Adds field-level details
+func (e *Error) WithDetails(details ...Detail) *Error {
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+// Adds the cause. Messages of causes are returned as detail for client errors and only logged for server errors.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	if err != nil && e.Status < fiber.StatusInternalServerError {
+		e.Details = append(e.Details, Detail{Message: err.Error()})
+	}
+	return e
+}
+
+// Creates a new error
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// 400 with code `invalid_input`
+func InvalidInput(message string) *Error {
+	return New(fiber.StatusBadRequest, CodeInvalidInput, message)
+}
+
+// 400 with code `invalid_id`
+func InvalidID(err error) *Error {
+	return New(fiber.StatusBadRequest, CodeInvalidID, "Invalid ID").Wrap(err)
+}
+
+// 400 with code `invalid_query`
+func InvalidQuery(message string) *Error {
+	return New(fiber.StatusBadRequest, CodeInvalidQuery, message)
+}
+
+// 422 with code `validation_failed`
+func ValidationFailed(message string, details ...Detail) *Error {
+	return New(fiber.StatusUnprocessableEntity, CodeValidationFailed, message).WithDetails(details...)
+}
+
+// 401 with code `unauthorized`
+func Unauthorized(message string) *Error {
+	return New(fiber.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// 403 with code `forbidden`
+func Forbidden(message string) *Error {
+	return New(fiber.StatusForbidden, CodeForbidden, message)
+}
+
+// 404 with code `not_found`
+func NotFound(message string) *Error {
+	return New(fiber.StatusNotFound, CodeNotFound, message)
+}
+
+// 409 with code `already_exists`
+func AlreadyExists(message string) *Error {
+	return New(fiber.StatusConflict, CodeAlreadyExists, message)
+}
+
+// 409 with code `conflict`
+func Conflict(message string) *Error {
+	return New(fiber.StatusConflict, CodeConflict, message)
+}
+
+// 500 with code `internal_error`. The message is returned, the cause is only logged.
+func Internal(message string, err error) *Error {
+	return New(fiber.StatusInternalServerError, CodeInternal, message).Wrap(err)
+}
+
+// Like `From`, but returns 404 with the provided message, if no document was found
+func NotFoundFrom(err error, message string) *Error {
+	if errors.Is(err, store.ErrNotFound) {
+		return NotFound(message)
+	}
+	return From(err)
+}
+
+// Maps any error to an API error:
+// Known errors of fiber and the stores get a matching status and code, all others are internal errors.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fromStatus(fiberErr.Code, fiberErr.Message)
+	}
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return NotFound("Not found")
+	case isInvalidHex(err):
+		return InvalidID(err)
+	case errors.Is(err, store.ErrNotSupported):
+		return New(fiber.StatusNotImplemented, CodeNotSupported, "Not supported by the storage backend").Wrap(err)
+	case errors.Is(err, store.ErrDuplicateKey):
+		return New(fiber.StatusConflict, CodeDuplicateKey, "Value of unique field already in use").Wrap(err)
+	case errors.Is(err, store.ErrTimeout):
+		return New(fiber.StatusGatewayTimeout, CodeTimeout, "Database timeout").Wrap(err)
+	case errors.Is(err, store.ErrUnavailable):
+		return New(fiber.StatusServiceUnavailable, CodeUnavailable, "Database not reachable").Wrap(err)
+	}
+	return Internal("Internal Server Error", err)
+}
+
+// Returns true, if the error was returned by `primitive.ObjectIDFromHex`
+func isInvalidHex(err error) bool {
+	var byteErr hex.InvalidByteError
+	return errors.Is(err, primitive.ErrInvalidHex) || errors.Is(err, hex.ErrLength) || errors.As(err, &byteErr)
+}
+
+// Maps errors, that fiber creates itself, like unknown routes or too large bodies
+func fromStatus(status int, message string) *Error {
+	code := CodeInternal
+	switch status {
+	case fiber.StatusBadRequest:
+		code = CodeInvalidInput
+	case fiber.StatusUnauthorized:
+		code = CodeUnauthorized
+	case fiber.StatusForbidden:
+		code = CodeForbidden
+	case fiber.StatusNotFound:
+		code = CodeRouteNotFound
+	case fiber.StatusMethodNotAllowed:
+		code = CodeMethodNotAllowed
+	case fiber.StatusRequestEntityTooLarge:
+		code = CodePayloadTooLarge
+	case fiber.StatusRequestTimeout, fiber.StatusGatewayTimeout:
+		code = CodeTimeout
+	case fiber.StatusServiceUnavailable:
+		code = CodeUnavailable
+	}
+	return New(status, code, message)
+}
+
+// Fiber error handler, that writes the error envelope with the request ID. The errors are logged with the request by `middleware.Logger`.
+func Handler(c *fiber.Ctx, err error) error {
+	e := From(err)
+	requestID, _ := c.Locals("requestid").(string)
+	details := e.Details
+	if details == nil {
+		details = make([]Detail, 0)
+	}
+	return c.Status(e.Status).JSON(fiber.Map{
+		"status":     "error",
+		"code":       e.Code,
+		"message":    e.Message,
+		"details":    details,
+		"request_id": requestID,
+	})
+}
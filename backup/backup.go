@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Version of the archive layout. Restore rejects archives with a newer version.
+const FormatVersion = 1
+
+// Name of the manifest file in the archive
+const manifestName = "manifest.json"
+
+// Describes the content of a backup archive
+type Manifest struct {
+	FormatVersion int                  `json:"format_version"`
+	CreatedAt     time.Time            `json:"created_at"`
+	Collections   []CollectionManifest `json:"collections"`
+}
+
+// Describes the dump of a single collection in the archive.
+// The file contains the documents as concatenated BSON like the output of `mongodump`, independent of the storage backend.
+type CollectionManifest struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Documents int    `json:"documents"`
+	SHA256    string `json:"sha256"`
+}
+
+// Writes a gzipped tar archive with the roles, users, content types and all content collections
+// referenced by a content type to w. The manifest is written last, after all checksums are known.
+// Media files are not part of the archive, because the fiber-backend does not store any.
+// The documents are read through the stores of s, so backups work with every storage backend.
+func Create(ctx context.Context, w io.Writer, s *store.Store) (*Manifest, error) {
+	collections, err := backupCollections(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	for _, coll := range collections {
+		cm, err := writeCollection(ctx, tw, coll)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Collections = append(manifest.Collections, *cm)
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(tw, manifestName, b); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+// Returns all collections that are backed up: the system collections and the collections of all content types including the trash
+func backupCollections(ctx context.Context, s *store.Store) ([]*collection, error) {
+	var collections []*collection
+	for _, name := range store.SystemCollections {
+		collections = append(collections, collectionOf(s, name))
+	}
+	contentTypes, err := s.ContentTypes.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	for _, ct := range contentTypes {
+		collections = append(collections, collectionOf(s, ct.Collection))
+	}
+	return collections, nil
+}
+
+// Dumps all documents of the collection into the archive.
+// The documents are spooled to a temporary file first, because tar headers need the size of the file.
+func writeCollection(ctx context.Context, tw *tar.Writer, coll *collection) (*CollectionManifest, error) {
+	tmp, err := ioutil.TempFile("", "fiber-backend-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	out := io.MultiWriter(tmp, hash)
+	cm := &CollectionManifest{Name: coll.name, File: "collections/" + coll.name + ".bson"}
+	err = coll.each(ctx, func(doc interface{}) error {
+		b, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(b); err != nil {
+			return err
+		}
+		cm.Documents++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cm.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hdr := &tar.Header{Name: cm.File, Mode: 0600, Size: size, ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tw, tmp); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+func writeFile(tw *tar.Writer, name string, b []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(b)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
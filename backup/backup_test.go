@@ -0,0 +1,277 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/backup"
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/database"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/bolt"
+	"github.com/D-Bald/fiber-backend/store/memory"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var backends = map[string]func(t *testing.T) *store.Store{
+	"memory": func(t *testing.T) *store.Store { return memory.New() },
+	"bolt": func(t *testing.T) *store.Store {
+		db, err := database.OpenBolt(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return bolt.New(db)
+	},
+}
+
+// Instance with a role, a user, a content type with two entries and an audit entry
+type fixture struct {
+	s     *store.Store
+	ctrl  *controller.Controller
+	post  *model.Content
+	draft *model.Content
+}
+
+func newFixture(t *testing.T, open func(t *testing.T) *store.Store) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	s := open(t)
+	f := &fixture{s: s, ctrl: controller.New(s)}
+	t.Cleanup(func() { f.ctrl.Shutdown(context.Background()) })
+
+	role := &model.Role{ID: primitive.NewObjectID(), Tag: "editor", Name: "Editor"}
+	user := &model.User{ID: primitive.NewObjectID(), Username: "jane", Email: "jane@sample.com", Password: "hash", Roles: []primitive.ObjectID{role.ID}}
+	ct := &model.ContentType{ID: primitive.NewObjectID(), TypeName: "post", Collection: "posts", FieldSchema: map[string]interface{}{"body": "string"}}
+	f.post = &model.Content{ID: primitive.NewObjectID(), ContentTypeID: ct.ID, Title: "Hello", Tags: []string{}, Fields: map[string]interface{}{"body": "original"}}
+	f.draft = &model.Content{ID: primitive.NewObjectID(), ContentTypeID: ct.ID, Title: "Draft", Tags: []string{}, Fields: map[string]interface{}{"body": "draft"}}
+	entry := &model.AuditEntry{ID: primitive.NewObjectID(), Time: time.Now().UTC(), Action: model.AuditCreate, Collection: "posts", TargetID: f.post.ID.Hex()}
+
+	if _, err := s.Roles.Insert(ctx, role); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Users.Insert(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ContentTypes.Insert(ctx, ct); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*model.Content{f.post, f.draft} {
+		if _, err := s.Content.Insert(ctx, ct.Collection, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.AuditLog.Insert(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fixture) backup(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := backup.Create(context.Background(), &buf, f.s); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func (f *fixture) restore(t *testing.T, archive []byte, mode string) *backup.Manifest {
+	t.Helper()
+	manifest, err := backup.Restore(context.Background(), bytes.NewReader(archive), mode, f.ctrl, f.s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+// Changes the body of the post, adds an entry and an audit entry after the backup
+func (f *fixture) change(t *testing.T) (added *model.Content) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := f.s.Content.Update(ctx, "posts", bson.M{"_id": f.post.ID}, bson.M{"$set": bson.M{"body": "changed"}}); err != nil {
+		t.Fatal(err)
+	}
+	added = &model.Content{ID: primitive.NewObjectID(), Title: "Added", Tags: []string{}, Fields: map[string]interface{}{"body": "added"}}
+	if _, err := f.s.Content.Insert(ctx, "posts", added); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.s.AuditLog.Insert(ctx, &model.AuditEntry{ID: primitive.NewObjectID(), Time: time.Now().UTC(), Action: model.AuditUpdate, Collection: "posts"}); err != nil {
+		t.Fatal(err)
+	}
+	return added
+}
+
+func (f *fixture) count(t *testing.T, coll string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	var n int64
+	var err error
+	switch coll {
+	case store.CollectionAuditLog:
+		n, err = f.s.AuditLog.Count(ctx, bson.M{})
+	default:
+		n, err = f.s.Content.Count(ctx, coll, bson.M{})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func (f *fixture) body(t *testing.T, id primitive.ObjectID) interface{} {
+	t.Helper()
+	entry, err := f.s.Content.FindOne(context.Background(), "posts", bson.M{"_id": id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry.Fields["body"]
+}
+
+func TestCreate(t *testing.T) {
+	f := newFixture(t, backends["memory"])
+	var buf bytes.Buffer
+	manifest, err := backup.Create(context.Background(), &buf, f.s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.FormatVersion != backup.FormatVersion {
+		t.Errorf("expected format version %d, got %d", backup.FormatVersion, manifest.FormatVersion)
+	}
+	want := map[string]int{
+		store.CollectionRoles: 1, store.CollectionUsers: 1, store.CollectionContentTypes: 1,
+		store.CollectionMigrations: 0, store.CollectionAuditLog: 1, "posts": 2,
+	}
+	if len(manifest.Collections) != len(want) {
+		t.Fatalf("expected %d collections, got %+v", len(want), manifest.Collections)
+	}
+	for _, cm := range manifest.Collections {
+		if n, ok := want[cm.Name]; !ok || cm.Documents != n {
+			t.Errorf("expected %d documents in %s, got %d", n, cm.Name, cm.Documents)
+		}
+	}
+
+	// The archive contains one file per collection and the manifest
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	files := 0
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files++
+	}
+	if files != len(want)+1 {
+		t.Errorf("expected %d files, got %d", len(want)+1, files)
+	}
+}
+
+func TestRestoreMerge(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			f := newFixture(t, open)
+			archive := f.backup(t)
+			added := f.change(t)
+
+			f.restore(t, archive, backup.ModeMerge)
+			if body := f.body(t, f.post.ID); body != "original" {
+				t.Errorf("expected the archived body, got %v", body)
+			}
+			if body := f.body(t, added.ID); body != "added" {
+				t.Errorf("expected the added entry to be kept, got %v", body)
+			}
+			// Entries of the audit log are neither duplicated nor removed
+			if n := f.count(t, store.CollectionAuditLog); n != 2 {
+				t.Errorf("expected 2 audit entries, got %d", n)
+			}
+			users, err := f.s.Users.Find(context.Background(), bson.M{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != 1 || users[0].Password != "hash" {
+				t.Errorf("expected the archived user, got %+v", users)
+			}
+		})
+	}
+}
+
+func TestRestoreReplace(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			f := newFixture(t, open)
+			archive := f.backup(t)
+			added := f.change(t)
+
+			f.restore(t, archive, backup.ModeReplace)
+			if body := f.body(t, f.post.ID); body != "original" {
+				t.Errorf("expected the archived body, got %v", body)
+			}
+			if _, err := f.s.Content.FindOne(context.Background(), "posts", bson.M{"_id": added.ID}); err != store.ErrNotFound {
+				t.Errorf("expected the added entry to be deleted, got %v", err)
+			}
+			if n := f.count(t, "posts"); n != 2 {
+				t.Errorf("expected 2 entries, got %d", n)
+			}
+			// The audit log is kept, including the entries written after the backup
+			if n := f.count(t, store.CollectionAuditLog); n != 2 {
+				t.Errorf("expected 2 audit entries, got %d", n)
+			}
+		})
+	}
+}
+
+// Archives are independent of the storage backend
+func TestRestoreOnOtherBackend(t *testing.T) {
+	src := newFixture(t, backends["memory"])
+	archive := src.backup(t)
+
+	s := backends["bolt"](t)
+	dst := &fixture{s: s, ctrl: controller.New(s)}
+	t.Cleanup(func() { dst.ctrl.Shutdown(context.Background()) })
+	dst.restore(t, archive, backup.ModeReplace)
+
+	if body := dst.body(t, src.post.ID); body != "original" {
+		t.Errorf("expected the archived body, got %v", body)
+	}
+	if n := dst.count(t, "posts"); n != 2 {
+		t.Errorf("expected 2 entries, got %d", n)
+	}
+	if n := dst.count(t, store.CollectionAuditLog); n != 1 {
+		t.Errorf("expected 1 audit entry, got %d", n)
+	}
+}
+
+func TestRestoreCorrupted(t *testing.T) {
+	f := newFixture(t, backends["memory"])
+	archive := f.backup(t)
+	f.change(t)
+
+	if _, err := backup.Restore(context.Background(), bytes.NewReader(archive[:len(archive)/2]), backup.ModeReplace, f.ctrl, f.s); err == nil {
+		t.Fatal("expected an error for a truncated archive")
+	}
+	if _, err := backup.Restore(context.Background(), bytes.NewReader(archive), "overwrite", f.ctrl, f.s); err == nil {
+		t.Fatal("expected an error for an invalid mode")
+	}
+	// Nothing was written
+	if body := f.body(t, f.post.ID); body != "changed" {
+		t.Errorf("expected the unchanged body, got %v", body)
+	}
+	if n := f.count(t, "posts"); n != 3 {
+		t.Errorf("expected 3 entries, got %d", n)
+	}
+}
@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Collection of the archive and the store, that its documents are read from and written to
+type collection struct {
+	name string
+	// Calls fn for every document of the collection
+	each func(ctx context.Context, fn func(doc interface{}) error) error
+	// Decodes a document of the archive and inserts it
+	insert func(ctx context.Context, doc bson.Raw) error
+	// Deletes the first document, that matches the filter. It is nil for the append-only audit log.
+	delete func(ctx context.Context, filter interface{}) (*store.DeleteResult, error)
+	// Counts the documents, that match the filter. It is only set for the audit log, whose entries are never replaced.
+	count func(ctx context.Context, filter interface{}) (int64, error)
+	// Removes all documents at once, nil if they have to be deleted one by one
+	drop func(ctx context.Context) error
+}
+
+// Returns the collection with the name. All names, that are not system collections, are collections of content.
+func collectionOf(s *store.Store, name string) *collection {
+	c := &collection{name: name}
+	switch name {
+	case store.CollectionUsers:
+		c.each = func(ctx context.Context, fn func(interface{}) error) error {
+			users, err := s.Users.Find(ctx, bson.M{})
+			if err != nil {
+				return err
+			}
+			for _, u := range users {
+				if err := fn(u); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.insert = func(ctx context.Context, doc bson.Raw) error {
+			var u model.User
+			if err := bson.Unmarshal(doc, &u); err != nil {
+				return err
+			}
+			_, err := s.Users.Insert(ctx, &u)
+			return err
+		}
+		c.delete = s.Users.Delete
+	case store.CollectionRoles:
+		c.each = func(ctx context.Context, fn func(interface{}) error) error {
+			roles, err := s.Roles.Find(ctx, bson.M{})
+			if err != nil {
+				return err
+			}
+			for _, r := range roles {
+				if err := fn(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.insert = func(ctx context.Context, doc bson.Raw) error {
+			var r model.Role
+			if err := bson.Unmarshal(doc, &r); err != nil {
+				return err
+			}
+			_, err := s.Roles.Insert(ctx, &r)
+			return err
+		}
+		c.delete = s.Roles.Delete
+	case store.CollectionContentTypes:
+		c.each = func(ctx context.Context, fn func(interface{}) error) error {
+			contentTypes, err := s.ContentTypes.Find(ctx, bson.M{})
+			if err != nil {
+				return err
+			}
+			for _, ct := range contentTypes {
+				if err := fn(ct); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.insert = func(ctx context.Context, doc bson.Raw) error {
+			var ct model.ContentType
+			if err := bson.Unmarshal(doc, &ct); err != nil {
+				return err
+			}
+			_, err := s.ContentTypes.Insert(ctx, &ct)
+			return err
+		}
+		c.delete = s.ContentTypes.Delete
+	case store.CollectionMigrations:
+		c.each = func(ctx context.Context, fn func(interface{}) error) error {
+			migrations, err := s.Migrations.Find(ctx, bson.M{})
+			if err != nil {
+				return err
+			}
+			for _, m := range migrations {
+				if err := fn(m); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.insert = func(ctx context.Context, doc bson.Raw) error {
+			var m model.Migration
+			if err := bson.Unmarshal(doc, &m); err != nil {
+				return err
+			}
+			_, err := s.Migrations.Insert(ctx, &m)
+			return err
+		}
+		c.delete = s.Migrations.Delete
+	case store.CollectionAuditLog:
+		c.each = func(ctx context.Context, fn func(interface{}) error) error {
+			return s.AuditLog.Stream(ctx, bson.M{}, func(entry *model.AuditEntry) error {
+				return fn(entry)
+			})
+		}
+		c.insert = func(ctx context.Context, doc bson.Raw) error {
+			var entry model.AuditEntry
+			if err := bson.Unmarshal(doc, &entry); err != nil {
+				return err
+			}
+			_, err := s.AuditLog.Insert(ctx, &entry)
+			return err
+		}
+		c.count = s.AuditLog.Count
+	default:
+		c.each = func(ctx context.Context, fn func(interface{}) error) error {
+			return s.Content.Stream(ctx, name, bson.M{}, func(entry *model.Content) error {
+				return fn(entry)
+			})
+		}
+		c.insert = func(ctx context.Context, doc bson.Raw) error {
+			var entry model.Content
+			if err := bson.Unmarshal(doc, &entry); err != nil {
+				return err
+			}
+			_, err := s.Content.Insert(ctx, name, &entry)
+			return err
+		}
+		c.delete = func(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+			return s.Content.Delete(ctx, name, filter)
+		}
+		c.drop = func(ctx context.Context) error {
+			return s.Content.Drop(ctx, name)
+		}
+	}
+	return c
+}
+
+// Deletes all documents of the collection. The audit log is never cleared.
+func (c *collection) clear(ctx context.Context) error {
+	if c.drop != nil {
+		return c.drop(ctx)
+	}
+	if c.delete == nil {
+		return nil
+	}
+	for {
+		res, err := c.delete(ctx, bson.M{})
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount == 0 {
+			return nil
+		}
+	}
+}
+
+// Writes the document of the archive. In merge mode a document with the same ID is replaced,
+// entries of the audit log are only inserted, if they are missing.
+func (c *collection) restore(ctx context.Context, doc bson.Raw, mode string) error {
+	id := bson.M{"_id": doc.Lookup("_id")}
+	if c.delete == nil {
+		n, err := c.count(ctx, id)
+		if err != nil || n > 0 {
+			return err
+		}
+	} else if mode == ModeMerge {
+		if _, err := c.delete(ctx, id); err != nil {
+			return err
+		}
+	}
+	return c.insert(ctx, doc)
+}
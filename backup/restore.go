@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Restore modes
+const (
+	// Documents of the archive replace documents with the same ID. Other documents are kept.
+	ModeMerge = "merge"
+	// All collections of the archive and all current content collections are dropped before the restore.
+	// The audit log is never dropped, in both modes only its missing entries are restored.
+	ModeReplace = "replace"
+)
+
+// Extracted backup archive
+type archive struct {
+	dir      string
+	manifest *Manifest
+	files    map[string]string // archive file name to extracted path
+}
+
+// Restores a backup archive created by `Create`.
+// The whole archive is extracted and verified before anything is written, so a corrupted archive leaves the database untouched.
+// The documents are written through the stores of s, so restores work with every storage backend.
+// After the restore the indexes of all content types are reconciled through ctrl.
+func Restore(ctx context.Context, r io.Reader, mode string, ctrl *controller.Controller, s *store.Store) (*Manifest, error) {
+	if mode != ModeMerge && mode != ModeReplace {
+		return nil, fmt.Errorf("invalid restore mode: %s", mode)
+	}
+
+	a, err := extract(r)
+	if a != nil {
+		defer os.RemoveAll(a.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := a.verify(); err != nil {
+		return nil, err
+	}
+
+	if mode == ModeReplace {
+		collections, err := backupCollections(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		for _, cm := range a.manifest.Collections {
+			collections = append(collections, collectionOf(s, cm.Name))
+		}
+		for _, coll := range collections {
+			if err := coll.clear(ctx); err != nil {
+				return nil, fmt.Errorf("collection %s: %s", coll.name, err.Error())
+			}
+		}
+	}
+
+	for _, cm := range a.manifest.Collections {
+		if err := restoreCollection(ctx, collectionOf(s, cm.Name), a.files[cm.File], mode); err != nil {
+			return nil, fmt.Errorf("collection %s: %s", cm.Name, err.Error())
+		}
+	}
+
+	// Indexes are not part of the archive, but declared in the content types
+	contentTypes, err := ctrl.GetContentTypesIncludingTrash(ctx, bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+	for _, ct := range contentTypes {
+		if err := ctrl.ReconcileIndexes(ctx, ct); err != nil {
+			return nil, fmt.Errorf("indexes of %s: %s", ct.Collection, err.Error())
+		}
+	}
+	return a.manifest, nil
+}
+
+// Extracts all files of the archive into a temporary directory and reads the manifest
+func extract(r io.Reader) (*archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %s", err.Error())
+	}
+	dir, err := ioutil.TempDir("", "fiber-backend-restore-")
+	if err != nil {
+		return nil, err
+	}
+	a := &archive{dir: dir, files: make(map[string]string)}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return a, fmt.Errorf("invalid archive: %s", err.Error())
+		}
+		if hdr.Name == manifestName {
+			a.manifest = new(Manifest)
+			if err := json.NewDecoder(tr).Decode(a.manifest); err != nil {
+				return a, fmt.Errorf("invalid manifest: %s", err.Error())
+			}
+			continue
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%d", len(a.files)))
+		f, err := os.Create(path)
+		if err != nil {
+			return a, err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return a, fmt.Errorf("invalid archive: %s", err.Error())
+		}
+		a.files[hdr.Name] = path
+	}
+
+	if a.manifest == nil {
+		return a, fmt.Errorf("invalid archive: %s missing", manifestName)
+	}
+	return a, nil
+}
+
+// Checks the format version, the checksums and the number of valid BSON documents of all collection files
+func (a *archive) verify() error {
+	if a.manifest.FormatVersion < 1 || a.manifest.FormatVersion > FormatVersion {
+		return fmt.Errorf("unsupported archive format version %d", a.manifest.FormatVersion)
+	}
+	for _, cm := range a.manifest.Collections {
+		path, ok := a.files[cm.File]
+		if !ok {
+			return fmt.Errorf("archive incomplete: %s missing", cm.File)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		count := 0
+		err = readDocuments(io.TeeReader(f, hash), func(doc bson.Raw) error {
+			count++
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s corrupted: %s", cm.File, err.Error())
+		}
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != cm.SHA256 {
+			return fmt.Errorf("%s corrupted: checksum mismatch", cm.File)
+		}
+		if count != cm.Documents {
+			return fmt.Errorf("%s corrupted: expected %d documents, found %d", cm.File, cm.Documents, count)
+		}
+	}
+	return nil
+}
+
+// Writes all documents of the file into the collection
+func restoreCollection(ctx context.Context, coll *collection, path string, mode string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readDocuments(f, func(doc bson.Raw) error {
+		return coll.restore(ctx, doc, mode)
+	})
+}
+
+// Calls fn for every valid BSON document read from r
+func readDocuments(r io.Reader, fn func(bson.Raw) error) error {
+	br := bufio.NewReader(r)
+	for {
+		doc, err := bson.NewFromIOReader(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := doc.Validate(); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Creates a new user with the admin and default role
+func createAdminCmd(args []string) error {
+	fs := newFlagSet("create-admin")
+	username := fs.String("username", "", "username of the new admin (required)")
+	email := fs.String("email", "", "email of the new admin (required)")
+	names := fs.String("names", "", "names of the new admin")
+	password := fs.String("password", "", "password of the new admin (read from stdin if omitted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" || *email == "" {
+		fs.Usage()
+		return fmt.Errorf("username and email required")
+	}
+	ctrl, _, err := connect()
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	if u, _ := ctrl.GetUserByUsername(ctx, *username); u != nil {
+		return fmt.Errorf("username already taken: %s", *username)
+	}
+	if u, _ := ctrl.GetUserByEmail(ctx, *email); u != nil {
+		return fmt.Errorf("user with given email already exists: %s", *email)
+	}
+	if *password == "" {
+		var err error
+		if *password, err = readPassword(); err != nil {
+			return err
+		}
+	}
+
+	var roles []primitive.ObjectID
+	for _, tag := range []string{"admin", "default"} {
+		r, err := ctrl.GetRoleByTag(ctx, tag)
+		if err != nil {
+			return fmt.Errorf("role with tag %q not found, run `seed` first: %s", tag, err.Error())
+		}
+		roles = append(roles, r.ID)
+	}
+
+	user := &model.User{Username: *username, Email: *email, Names: *names, Password: *password, Roles: roles}
+	if _, err := ctrl.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	fmt.Printf("Created admin %s (%s)\n", user.Username, user.ID.Hex())
+	return nil
+}
+
+// Sets a new password for a user
+func resetPasswordCmd(args []string) error {
+	fs := newFlagSet("reset-password")
+	password := fs.String("password", "", "new password (read from stdin if omitted)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("user required")
+	}
+	ctrl, _, err := connect()
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	user, err := findUser(ctx, ctrl, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *password == "" {
+		if *password, err = readPassword(); err != nil {
+			return err
+		}
+	}
+	if _, err := ctrl.UpdateUser(ctx, user.ID.Hex(), &model.UserUpdate{Password: *password}); err != nil {
+		return err
+	}
+	fmt.Printf("Password of %s updated\n", user.Username)
+	return nil
+}
+
+// Adds a role to or removes a role from a user
+func assignRoleCmd(args []string) error {
+	fs := newFlagSet("assign-role")
+	remove := fs.Bool("remove", false, "remove the role instead of adding it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("user and role required")
+	}
+	ctrl, _, err := connect()
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	user, err := findUser(ctx, ctrl, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	role, err := ctrl.GetRoleByName(ctx, fs.Arg(1))
+	if err == store.ErrNotFound {
+		role, err = ctrl.GetRoleByTag(ctx, fs.Arg(1))
+	}
+	if err != nil {
+		return fmt.Errorf("role not found: %s", fs.Arg(1))
+	}
+
+	if *remove {
+		if _, err := ctrl.DeleteRoleFromUser(ctx, role.ID, user); err != nil {
+			return err
+		}
+		fmt.Printf("Removed role %s from %s\n", role.Name, user.Username)
+		return nil
+	}
+	for _, r := range user.Roles {
+		if r == role.ID {
+			fmt.Printf("%s already has role %s\n", user.Username, role.Name)
+			return nil
+		}
+	}
+	roles, err := ctrl.GetRoleNames(ctx, user.Roles)
+	if err != nil {
+		return err
+	}
+	if _, err := ctrl.UpdateUser(ctx, user.ID.Hex(), &model.UserUpdate{Roles: append(roles, role.Name)}); err != nil {
+		return err
+	}
+	fmt.Printf("Added role %s to %s\n", role.Name, user.Username)
+	return nil
+}
+
+// Prints all users
+func listUsersCmd(args []string) error {
+	fs := newFlagSet("list-users")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctrl, _, err := connect()
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	users, err := ctrl.GetUsers(ctx, bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLES")
+	for _, u := range users {
+		roles, err := ctrl.GetRoleNames(ctx, u.Roles)
+		if err != nil {
+			roles = []string{fmt.Sprintf("(invalid roles: %s)", err.Error())}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.ID.Hex(), u.Username, u.Email, strings.Join(roles, ", "))
+	}
+	return w.Flush()
+}
+
+// Prints all content types
+func listContentTypesCmd(args []string) error {
+	fs := newFlagSet("list-content-types")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctrl, _, err := connect()
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	contentTypes, err := ctrl.GetContentTypes(ctx, bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPENAME\tCOLLECTION\tFIELDS")
+	for _, ct := range contentTypes {
+		var fields []string
+		for name, def := range ct.FieldDefinitions() {
+			fields = append(fields, fmt.Sprintf("%s:%s", name, def.Type))
+		}
+		sort.Strings(fields)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ct.ID.Hex(), ct.TypeName, ct.Collection, strings.Join(fields, ", "))
+	}
+	return w.Flush()
+}
+
+// Creates the preset roles, content types and admin user, if they are missing.
+// This is done on every server start as well.
+func seedCmd(args []string) error {
+	fs := newFlagSet("seed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctrl, _, err := connect()
+	if err != nil {
+		return err
+	}
+	ctx := commandContext()
+
+	if err := ctrl.InitRoles(ctx); err != nil {
+		return err
+	}
+	if err := ctrl.InitContentTypes(ctx); err != nil {
+		return err
+	}
+	if err := ctrl.InitAdminUser(ctx); err != nil {
+		return err
+	}
+	fmt.Println("Roles, content types and admin user seeded")
+	return nil
+}
+
+// Returns the user with the provided username, email or ID
+func findUser(ctx context.Context, ctrl *controller.Controller, identity string) (*model.User, error) {
+	if u, err := ctrl.GetUserByUsername(ctx, identity); err == nil {
+		return u, nil
+	}
+	if u, err := ctrl.GetUserByEmail(ctx, identity); err == nil {
+		return u, nil
+	}
+	if u, err := ctrl.GetUserById(ctx, identity); err == nil {
+		return u, nil
+	}
+	return nil, fmt.Errorf("user not found: %s", identity)
+}
+
+// Reads a password from the first line of stdin
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("could not read password: %s", err.Error())
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password must not be empty")
+	}
+	return password, nil
+}
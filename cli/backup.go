@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/backup"
+	"github.com/D-Bald/fiber-backend/model"
+)
+
+// Writes a backup archive of the whole instance
+func backupCmd(args []string) error {
+	fs := newFlagSet("backup")
+	out := fs.String("o", fmt.Sprintf("fiber-backend-%s.tar.gz", time.Now().UTC().Format("20060102-150405")), "archive file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	_, s, err := connect()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	manifest, err := backup.Create(commandContext(), f, s)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	for _, cm := range manifest.Collections {
+		fmt.Printf("%-30s %d documents\n", cm.Name, cm.Documents)
+	}
+	fmt.Printf("Backup written to %s\n", *out)
+	return nil
+}
+
+// Restores a backup archive
+func restoreCmd(args []string) error {
+	fs := newFlagSet("restore")
+	mode := fs.String("mode", backup.ModeMerge, "'merge' replaces documents with the same ID, 'replace' drops all collections first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("archive file required")
+	}
+	ctrl, s, err := connect()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx := commandContext()
+	manifest, err := backup.Restore(ctx, f, *mode, ctrl, s)
+	if err != nil {
+		return err
+	}
+	audit(ctx, ctrl, model.AuditRestore, "", "", nil, map[string]interface{}{"archive": fs.Arg(0), "mode": *mode, "created_at": manifest.CreatedAt})
+	for _, cm := range manifest.Collections {
+		fmt.Printf("%-30s %d documents\n", cm.Name, cm.Documents)
+	}
+	fmt.Printf("Backup from %s restored (%s)\n", manifest.CreatedAt.Format(time.RFC3339), *mode)
+	return nil
+}
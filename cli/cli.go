@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"sort"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/database"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+)
+
+// Subcommand of the fiber-backend binary
+type command struct {
+	usage string
+	run   func(args []string) error
+	// The command runs with an invalid configuration as well
+	unvalidated bool
+}
+
+// All subcommands by name
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"backup":             {usage: "backup [-o file]", run: backupCmd},
+		"restore":            {usage: "restore [-mode merge|replace] file", run: restoreCmd},
+		"create-admin":       {usage: "create-admin -username name -email email [-names names] [-password password]", run: createAdminCmd},
+		"reset-password":     {usage: "reset-password [-password password] user", run: resetPasswordCmd},
+		"assign-role":        {usage: "assign-role [-remove] user role", run: assignRoleCmd},
+		"list-users":         {usage: "list-users", run: listUsersCmd},
+		"list-content-types": {usage: "list-content-types", run: listContentTypesCmd},
+		"seed":               {usage: "seed", run: seedCmd},
+		"migrate-storage":    {usage: "migrate-storage -from mongodb|postgres|bolt -to mongodb|postgres|bolt [-file path]", run: migrateStorageCmd},
+		"config":             {usage: "config print", run: configCmd, unvalidated: true},
+	}
+}
+
+// Runs the subcommand named by the first argument.
+// The commands use the same configuration as the server and connect to the database on their own,
+// so they can run next to a running instance, e.g. with `docker exec`.
+func Run(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+	if !cmd.unvalidated {
+		if err := config.Get().Validate(); err != nil {
+			return err
+		}
+	}
+	defer closeBackends()
+	return cmd.run(args[1:])
+}
+
+// Prints all subcommands
+func usage(w io.Writer) {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "Usage: fiber-backend [flags] [command]")
+	fmt.Fprintln(w, "Without command the server is started. Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+}
+
+// Returns a flag set for a subcommand, that prints its usage on errors
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: fiber-backend %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Backends opened by the running command. They are closed by `Run`.
+var opened []*database.Backend
+
+// Opens the storage backend like the server does and returns a controller on top of it and the stores of the backend
+func connect() (*controller.Controller, *store.Store, error) {
+	backend := config.Get().Storage.Backend
+	if backend == database.BackendMemory {
+		return nil, nil, fmt.Errorf("commands need a persistent storage backend, not %s", backend)
+	}
+	b, err := database.Open(backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	opened = append(opened, b)
+	return controller.New(b.Store), b.Store, nil
+}
+
+// Closes the backends opened by the command
+func closeBackends() {
+	for _, b := range opened {
+		if err := b.Close(context.Background()); err != nil {
+			log.Printf("Could not close the storage backend: %s", err.Error())
+		}
+	}
+	opened = nil
+}
+
+// Returns the context of a command. Its audited actions are recorded with the operating system user as actor.
+func commandContext() context.Context {
+	source := controller.AuditSource{Actor: model.AuditActor{Type: model.ActorCLI}}
+	if u, err := user.Current(); err == nil {
+		source.Actor.Name = u.Username
+	}
+	return controller.WithAuditSource(context.Background(), source)
+}
+
+// Writes an entry to the audit log for actions, that are not recorded by the controller, like the restore of a backup
+func audit(ctx context.Context, ctrl *controller.Controller, action string, coll string, id string, before interface{}, after interface{}) {
+	entry := &model.AuditEntry{
+		Actor:      controller.AuditSourceFrom(ctx).Actor,
+		Action:     action,
+		Collection: coll,
+		TargetID:   id,
+		Changes:    controller.AuditDiff(before, after),
+	}
+	if err := ctrl.RecordAudit(ctx, entry); err != nil {
+		log.Printf("Could not write audit log entry: %s", err.Error())
+	}
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Prints the configuration loaded from all sources as YAML with redacted secrets.
+// Problems of the configuration are printed as well, but do not fail the command.
+func configCmd(args []string) error {
+	fs := newFlagSet("config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "print" {
+		fs.Usage()
+		return fmt.Errorf("unknown config command")
+	}
+
+	cfg := config.Get()
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/database"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Copies all data between MongoDB, PostgreSQL and the embedded storage. The server must not run on either of them.
+func migrateStorageCmd(args []string) error {
+	fs := newFlagSet("migrate-storage")
+	from := fs.String("from", "", "storage backend to read: 'mongodb', 'postgres' or 'bolt'")
+	to := fs.String("to", "", "empty storage backend to write: 'mongodb', 'postgres' or 'bolt'")
+	file := fs.String("file", database.BoltFile(), "data file of the embedded storage")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == *to || !persistentBackend(*from) || !persistentBackend(*to) {
+		fs.Usage()
+		return fmt.Errorf("-from and -to have to be different backends")
+	}
+
+	src, err := openBackend(*from, *file)
+	if err != nil {
+		return err
+	}
+	dst, err := openBackend(*to, *file)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	stats, err := store.Copy(ctx, dst, src)
+	for _, s := range stats {
+		fmt.Printf("%-30s %d documents\n", s.Collection, s.Documents)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Indexes are not copied, but declared in the content types
+	ctrl := controller.New(dst)
+	contentTypes, err := ctrl.GetContentTypesIncludingTrash(ctx, bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, ct := range contentTypes {
+		if err := ctrl.ReconcileIndexes(ctx, ct); err != nil {
+			return fmt.Errorf("indexes of %s: %s", ct.Collection, err.Error())
+		}
+	}
+	fmt.Printf("Data migrated from %s to %s\n", *from, *to)
+	return nil
+}
+
+// Returns true for the backends, that keep their data
+func persistentBackend(name string) bool {
+	return name == database.BackendMongoDB || name == database.BackendPostgres || name == database.BackendBolt
+}
+
+// Opens the backend with the data file of the embedded storage at file
+func openBackend(name string, file string) (*store.Store, error) {
+	var b *database.Backend
+	var err error
+	if name == database.BackendBolt {
+		b, err = database.OpenBoltBackend(file)
+	} else {
+		b, err = database.Open(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	opened = append(opened, b)
+	return b.Store, nil
+}
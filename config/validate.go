@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Minimum length of the secret, that signs the tokens. HS256 keys should have at least 256 bits.
+const minSecretLength = 32
+
+// Minimum length of the password of the preset admin user
+const minAdminPasswordLength = 12
+
+// Values of the samples and documentation, that must not be used in production
+var sampleSecrets = []string{"ForPasswordHash", "secret", "changeme"}
+var sampleAdminPasswords = []string{"ForInitialAdminUserOfTheFiberBackend", "admin", "password", "changeme"}
+
+// Names of the storage backends
+var backends = []string{"mongodb", "postgres", "bolt", "memory"}
+
+// Read preferences of MongoDB
+var readPreferences = []string{"primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest"}
+
+// Exporters of the traces
+var tracingExporters = []string{"none", "otlp", "stdout"}
+
+// Levels and formats of the logs
+var logLevels = []string{"debug", "info", "warn", "error"}
+var logFormats = []string{"json", "console"}
+
+// Backends of the response cache
+var responseCacheBackends = []string{"memory", "redis"}
+
+// Error with all invalid settings
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+// Returns a ValidationError, if settings are missing or invalid.
+// Weak secrets and the admin password of the samples are rejected as well.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port: %d is not a valid port", c.Server.Port)
+	}
+	if c.Server.RequestTimeout <= 0 {
+		add("server.request_timeout: must be positive")
+	}
+	for route, timeout := range c.Server.RouteTimeouts {
+		if parts := strings.Fields(route); len(parts) == 0 || len(parts) > 2 || !strings.HasPrefix(parts[len(parts)-1], "/") {
+			add("server.route_timeouts: %q is not a route like GET /api/:content/export", route)
+		} else if timeout <= 0 {
+			add("server.route_timeouts: timeout of %q must be positive", route)
+		}
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		add("server.shutdown_timeout: must not be negative")
+	}
+
+	switch {
+	case c.Auth.Secret == "":
+		add("auth.secret: is not set")
+	case contains(sampleSecrets, c.Auth.Secret):
+		add("auth.secret: the value of the samples must not be used")
+	case len(c.Auth.Secret) < minSecretLength:
+		add("auth.secret: needs at least %d characters", minSecretLength)
+	}
+	switch {
+	case c.Auth.AdminPassword == "":
+		add("auth.admin_password: is not set")
+	case contains(sampleAdminPasswords, c.Auth.AdminPassword):
+		add("auth.admin_password: the default password must not be used")
+	case len(c.Auth.AdminPassword) < minAdminPasswordLength:
+		add("auth.admin_password: needs at least %d characters", minAdminPasswordLength)
+	}
+	// bcrypt accepts costs from 4 to 31
+	if c.Auth.PasswordHashCost < 4 || c.Auth.PasswordHashCost > 31 {
+		add("auth.password_hash_cost: %d is not between 4 and 31", c.Auth.PasswordHashCost)
+	}
+
+	switch c.Storage.Backend {
+	case "mongodb":
+		c.MongoDB.validate(add)
+	case "postgres":
+		if c.Storage.PostgresURL == "" {
+			add("storage.postgres_url: is not set")
+		}
+	case "bolt":
+		if c.Storage.BoltFile == "" {
+			add("storage.bolt_file: is not set")
+		}
+	case "memory":
+	default:
+		add("storage.backend: %q is not one of %s", c.Storage.Backend, strings.Join(backends, ", "))
+	}
+
+	if len(c.Content.Locales) == 0 {
+		add("content.locales: needs at least one locale")
+	}
+	if c.Content.CollectionAliasDays < 0 {
+		add("content.collection_alias_days: must not be negative")
+	}
+	if c.Content.TrashRetentionDays < 0 {
+		add("content.trash_retention_days: must not be negative")
+	}
+
+	if c.Metrics.Enabled {
+		if !strings.HasPrefix(c.Metrics.Path, "/") || strings.HasPrefix(c.Metrics.Path, "/api") {
+			add("metrics.path: %q does not start with / or is part of the API", c.Metrics.Path)
+		}
+		if c.Metrics.Port < 0 || c.Metrics.Port > 65535 || c.Metrics.Port == c.Server.Port {
+			add("metrics.port: %d is not a valid port or the port of the API", c.Metrics.Port)
+		}
+		// The port of the API is public, so the metrics are only served there with a token
+		if c.Metrics.Port == 0 && c.Metrics.Token == "" {
+			add("metrics.token: required, if the metrics are served on the port of the API; set it or metrics.port")
+		}
+	}
+
+	switch {
+	case !contains(tracingExporters, c.Tracing.Exporter):
+		add("tracing.exporter: %q is not one of %s", c.Tracing.Exporter, strings.Join(tracingExporters, ", "))
+	case c.Tracing.Exporter == "otlp":
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("tracing.endpoint: %q is not a URL like http://localhost:4318", c.Tracing.Endpoint)
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio: %g is not between 0 and 1", c.Tracing.SampleRatio)
+	}
+
+	if !contains(logLevels, c.Logging.Level) {
+		add("logging.level: %q is not one of %s", c.Logging.Level, strings.Join(logLevels, ", "))
+	}
+	if !contains(logFormats, c.Logging.Format) {
+		add("logging.format: %q is not one of %s", c.Logging.Format, strings.Join(logFormats, ", "))
+	}
+
+	if c.Cache.TTL < 0 {
+		add("cache.ttl: must not be negative")
+	}
+
+	if c.ResponseCache.Enabled {
+		c.ResponseCache.validate(add)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (m *MongoDB) validate(add func(format string, args ...interface{})) {
+	switch {
+	case m.URI != "":
+		if !strings.HasPrefix(m.URI, "mongodb://") && !strings.HasPrefix(m.URI, "mongodb+srv://") {
+			add("mongodb.uri: does not start with mongodb:// or mongodb+srv://")
+		}
+	case m.Host == "ATLAS":
+		add("mongodb.host: ATLAS is not supported anymore, set mongodb.uri to the connection string of the cluster")
+	case m.Host == "":
+		add("mongodb.host: is not set")
+	case m.Port < 1 || m.Port > 65535:
+		add("mongodb.port: %d is not a valid port", m.Port)
+	}
+	if m.Name == "" {
+		add("mongodb.name: is not set")
+	}
+	if m.TLSKeyFile != "" && m.TLSCertFile == "" {
+		add("mongodb.tls_key_file: needs mongodb.tls_cert_file")
+	}
+	if m.MinPoolSize < 0 || m.MaxPoolSize < 0 || (m.MaxPoolSize > 0 && m.MinPoolSize > m.MaxPoolSize) {
+		add("mongodb.min_pool_size: %d and mongodb.max_pool_size: %d are not a valid range", m.MinPoolSize, m.MaxPoolSize)
+	}
+	if m.ConnectTimeout < 0 || m.ServerSelectionTimeout < 0 || m.OperationTimeout < 0 {
+		add("mongodb: timeouts must not be negative")
+	}
+	if m.ReadPreference != "" && !contains(readPreferences, m.ReadPreference) {
+		add("mongodb.read_preference: %q is not one of %s", m.ReadPreference, strings.Join(readPreferences, ", "))
+	}
+}
+
+func (r *ResponseCache) validate(add func(format string, args ...interface{})) {
+	switch r.Backend {
+	case "memory":
+		if r.MaxEntries <= 0 {
+			add("response_cache.max_entries: must be positive")
+		}
+	case "redis":
+		if u, err := url.Parse(r.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			add("response_cache.redis_url: is not a URL like redis://localhost:6379/0")
+		}
+	default:
+		add("response_cache.backend: %q is not one of %s", r.Backend, strings.Join(responseCacheBackends, ", "))
+	}
+	if r.TTL <= 0 {
+		add("response_cache.ttl: must be positive")
+	}
+	if r.MaxAge < 0 || r.SharedMaxAge < 0 {
+		add("response_cache: max ages must not be negative")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
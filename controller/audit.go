@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Fields whose values are never written to the audit log
+var redactedAuditFields = map[string]bool{"password": true}
+
+// Placeholder for the values of redacted fields
+const redactedValue = "[redacted]"
+
+// Time to record an audit log entry. Entries are recorded after the action, when the request may already be cancelled.
+const auditTimeout = 5 * time.Second
+
+// Origin of the audited actions of a request or command
+type AuditSource struct {
+	Actor     model.AuditActor
+	IP        string
+	RequestID string
+}
+
+type auditSourceKey struct{}
+
+// Returns a context, whose audited actions are recorded with the source
+func WithAuditSource(ctx context.Context, source AuditSource) context.Context {
+	return context.WithValue(ctx, auditSourceKey{}, source)
+}
+
+// Returns the source of the audited actions of the context.
+// Actions without source, like the ones of background jobs, are recorded with the system as actor.
+func AuditSourceFrom(ctx context.Context) AuditSource {
+	if source, ok := ctx.Value(auditSourceKey{}).(AuditSource); ok {
+		return source
+	}
+	return AuditSource{Actor: model.AuditActor{Type: model.ActorSystem}}
+}
+
+// Appends an entry to the audit log. Entries are never updated or deleted.
+func (ctrl *Controller) RecordAudit(ctx context.Context, entry *model.AuditEntry) error {
+	if ctrl.store.AuditLog == nil {
+		return store.ErrNotSupported
+	}
+	entry.Init()
+
+	ctx, cancel := context.WithTimeout(ctx, auditTimeout)
+	defer cancel()
+
+	_, err := ctrl.store.AuditLog.Insert(ctx, entry)
+	return err
+}
+
+// Returns an audit log entry of the action of the source of ctx with the changes between before and after
+func newAuditEntry(ctx context.Context, action string, coll string, id string, before interface{}, after interface{}) *model.AuditEntry {
+	source := AuditSourceFrom(ctx)
+	return &model.AuditEntry{
+		Actor:      source.Actor,
+		Action:     action,
+		Collection: coll,
+		TargetID:   id,
+		Changes:    AuditDiff(before, after),
+		IP:         source.IP,
+		RequestID:  source.RequestID,
+	}
+}
+
+// Records the entries in the audit log. The action already happened, so errors are only logged
+// and the entries are recorded even if ctx is cancelled in the meantime.
+func (ctrl *Controller) recordAudits(ctx context.Context, entries ...*model.AuditEntry) {
+	for _, entry := range entries {
+		if err := ctrl.RecordAudit(context.Background(), entry); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Str("action", entry.Action).Str("collection", entry.Collection).Str("target_id", entry.TargetID).Msg("Could not write audit log entry")
+		}
+	}
+}
+
+// Records the action of the source of ctx on the document with the ID in the collection
+func (ctrl *Controller) audit(ctx context.Context, action string, coll string, id string, before interface{}, after interface{}) {
+	ctrl.recordAudits(ctx, newAuditEntry(ctx, action, coll, id, before, after))
+}
+
+// Runs the update of the document with the ID in the collection and records the action with its changes, if the update matched the document.
+// find has to return the document.
+func (ctrl *Controller) auditedUpdate(ctx context.Context, action string, coll string, id primitive.ObjectID, find func() (interface{}, error), update func() (*store.UpdateResult, error)) (*store.UpdateResult, error) {
+	before, _ := find()
+	result, err := update()
+	if err != nil {
+		return result, err
+	}
+	if result.MatchedCount > 0 {
+		after, _ := find()
+		ctrl.audit(ctx, action, coll, id.Hex(), before, after)
+	}
+	return result, nil
+}
+
+// Runs the deletion of the document with the ID in the collection and records the action, if the document was deleted.
+// find has to return the document.
+func (ctrl *Controller) auditedDelete(ctx context.Context, action string, coll string, id primitive.ObjectID, find func() (interface{}, error), del func() (*store.DeleteResult, error)) (*store.DeleteResult, error) {
+	before, _ := find()
+	result, err := del()
+	if err != nil {
+		return result, err
+	}
+	if result.DeletedCount > 0 {
+		ctrl.audit(ctx, action, coll, id.Hex(), before, nil)
+	}
+	return result, nil
+}
+
+// Returns the fields, that differ between before and after. Both have to be documents or `nil`.
+// The update timestamp is left out and the values of sensitive fields like passwords are redacted.
+func AuditDiff(before interface{}, after interface{}) map[string]model.AuditChange {
+	b, a := auditDocument(before), auditDocument(after)
+	changes := make(map[string]model.AuditChange)
+	for k, v := range b {
+		if !reflect.DeepEqual(v, a[k]) {
+			changes[k] = model.AuditChange{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok && v != nil {
+			changes[k] = model.AuditChange{Before: nil, After: v}
+		}
+	}
+	delete(changes, "updated_at")
+	for k, c := range changes {
+		if redactedAuditFields[k] {
+			if c.Before != nil {
+				c.Before = redactedValue
+			}
+			if c.After != nil {
+				c.After = redactedValue
+			}
+			changes[k] = c
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func auditDocument(v interface{}) bson.M {
+	doc := make(bson.M)
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return doc
+	}
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return doc
+	}
+	bson.Unmarshal(b, &doc)
+	return doc
+}
+
+// Return the audit log entries, that match the filter, newest first, and the total number of matching entries
+func (ctrl *Controller) GetAuditLog(ctx context.Context, filter interface{}, skip int64, limit int64) ([]*model.AuditEntry, int64, error) {
+
+	total, err := ctrl.store.AuditLog.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	result, err := ctrl.store.AuditLog.Find(ctx, filter, store.SortBy("time", true), store.Skip(skip), store.Limit(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
+}
+
+// Calls fn for every audit log entry, that matches the filter, oldest first
+func (ctrl *Controller) StreamAuditLog(ctx context.Context, filter interface{}, fn func(*model.AuditEntry) error) error {
+	return ctrl.store.AuditLog.Stream(ctx, filter, fn)
+}
+
+// Creates the indexes used by the filters of the audit log
+func (ctrl *Controller) InitAuditLog(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return ctrl.store.AuditLog.Init(ctx)
+}
@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Time between attempts to watch the changes of the storage after the watch failed
+const watchRetryInterval = 5 * time.Second
+
+// Longest time content types and roles are cached. Zero disables the cache.
+func cacheTTL() time.Duration {
+	return config.Get().Cache.TTL
+}
+
+// Content types and roles, that are looked up by every request
+type cache struct {
+	contentTypes cachedCollection
+	roles        cachedCollection
+}
+
+// All documents of a collection, that are loaded at once and kept until they expire or a write invalidates them
+type cachedCollection struct {
+	mu     sync.Mutex
+	docs   interface{}
+	loaded time.Time
+	// Incremented by every invalidation, so that documents loaded before a write are not kept
+	version uint64
+}
+
+// Returns the cached documents or loads them, if they are missing or expired
+func (c *cachedCollection) get(ctx context.Context, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if c.docs != nil && time.Since(c.loaded) < cacheTTL() {
+		docs := c.docs
+		c.mu.Unlock()
+		return docs, nil
+	}
+	version := c.version
+	c.mu.Unlock()
+
+	docs, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.version == version {
+		c.docs, c.loaded = docs, time.Now()
+	}
+	c.mu.Unlock()
+	return docs, nil
+}
+
+func (c *cachedCollection) invalidate() {
+	c.mu.Lock()
+	c.docs = nil
+	c.version++
+	c.mu.Unlock()
+}
+
+// Drops the cached documents of the collection, an empty collection drops all of them
+func (ctrl *Controller) invalidateCache(collection string) {
+	switch collection {
+	case store.CollectionContentTypes:
+		ctrl.cache.contentTypes.invalidate()
+		ctrl.contentChanged(ctrl.bg, "")
+	case store.CollectionRoles:
+		ctrl.cache.roles.invalidate()
+	case "":
+		ctrl.cache.contentTypes.invalidate()
+		ctrl.cache.roles.invalidate()
+		ctrl.contentChanged(ctrl.bg, "")
+	}
+}
+
+// Keeps the cache consistent with the writes of other servers by watching the changes of the storage.
+// If the storage can not be watched, e.g. MongoDB without replica set, their writes are seen when the cache expires.
+func (ctrl *Controller) WatchCache(watch store.WatchFunc) {
+	if cacheTTL() == 0 {
+		return
+	}
+	ctrl.goBackground(func(ctx context.Context) {
+		for {
+			err := watch(ctx, ctrl.invalidateCache)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == store.ErrNotSupported {
+				logging.Ctx(ctx).Info().Dur("ttl", cacheTTL()).Msg("Changes of other servers can not be watched, cached content types and roles are used until they expire")
+				return
+			}
+			// Changes are missed until the watch is restarted
+			ctrl.invalidateCache("")
+			logging.Ctx(ctx).Warn().Err(err).Msg("Could not watch the changes of content types and roles")
+			select {
+			case <-time.After(watchRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// Returns a copy of the first cached content type, that matches, or `store.ErrNotFound`.
+// Content types in the trash are cached as well.
+func (ctrl *Controller) cachedContentType(ctx context.Context, match func(ct *model.ContentType) bool) (*model.ContentType, error) {
+	docs, err := ctrl.cache.contentTypes.get(ctx, func(ctx context.Context) (interface{}, error) {
+		return ctrl.store.ContentTypes.Find(ctx, bson.M{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, ct := range docs.([]*model.ContentType) {
+		if match(ct) {
+			// Fields of the copy can be replaced, but its maps and slices are shared and must not be modified
+			c := *ct
+			return &c, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+// Returns all cached roles
+func (ctrl *Controller) cachedRoles(ctx context.Context) ([]*model.Role, error) {
+	docs, err := ctrl.cache.roles.get(ctx, func(ctx context.Context) (interface{}, error) {
+		return ctrl.store.Roles.Find(ctx, bson.M{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs.([]*model.Role), nil
+}
+
+// Returns a copy of the first cached role, that matches, or `store.ErrNotFound`
+func (ctrl *Controller) cachedRole(ctx context.Context, match func(r *model.Role) bool) (*model.Role, error) {
+	roles, err := ctrl.cachedRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		if match(r) {
+			c := *r
+			return &c, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+// Store of the content types, that drops the cached content types and all cached responses after every write
+type invalidatingContentTypes struct {
+	store.ContentTypeStore
+	ctrl *Controller
+}
+
+func (s invalidatingContentTypes) changed(ctx context.Context) {
+	s.ctrl.cache.contentTypes.invalidate()
+	s.ctrl.contentChanged(ctx, "")
+}
+
+func (s invalidatingContentTypes) Insert(ctx context.Context, ct *model.ContentType) (*store.InsertResult, error) {
+	defer s.changed(ctx)
+	return s.ContentTypeStore.Insert(ctx, ct)
+}
+
+func (s invalidatingContentTypes) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	defer s.changed(ctx)
+	return s.ContentTypeStore.Update(ctx, filter, update)
+}
+
+func (s invalidatingContentTypes) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	defer s.changed(ctx)
+	return s.ContentTypeStore.Delete(ctx, filter)
+}
+
+// Store of the roles, that drops the cached roles after every write
+type invalidatingRoles struct {
+	store.RoleStore
+	cache *cachedCollection
+}
+
+func (s invalidatingRoles) Insert(ctx context.Context, r *model.Role) (*store.InsertResult, error) {
+	defer s.cache.invalidate()
+	return s.RoleStore.Insert(ctx, r)
+}
+
+func (s invalidatingRoles) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	defer s.cache.invalidate()
+	return s.RoleStore.Update(ctx, filter, update)
+}
+
+func (s invalidatingRoles) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	defer s.cache.invalidate()
+	return s.RoleStore.Delete(ctx, filter)
+}
+
+// Returns the roles with the provided IDs by their ID. Roles, that are not cached, are loaded with a single query.
+func (ctrl *Controller) rolesByID(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*model.Role, error) {
+	byID := make(map[primitive.ObjectID]*model.Role, len(ids))
+	if cacheTTL() > 0 {
+		roles, err := ctrl.cachedRoles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range roles {
+			byID[r.ID] = r
+		}
+	}
+	var missing []primitive.ObjectID
+	for _, id := range ids {
+		if _, ok := byID[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return byID, nil
+	}
+	roles, err := ctrl.store.Roles.Find(ctx, bson.M{"_id": bson.M{"$in": missing}})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		byID[r.ID] = r
+	}
+	return byID, nil
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Deletions of roles and content types cascade to the documents, that refer to them.
+// On backends with transactions, e.g. MongoDB replica sets and sharded clusters, the cascade of a role runs in a transaction.
+// Otherwise the deleted document is marked with `deleting_at` first: if the cascade fails, the changed documents are restored
+// and the mark is removed again, if the server crashes, `ResumeCascades` finishes the deletion on the next start.
+
+// Time to restore the documents changed by a failed cascade or collection move. The request may already be cancelled.
+const compensationTimeout = 30 * time.Second
+
+// Field, that marks roles and content types, whose deletion was started
+const deletingField = "deleting_at"
+
+// Reverts a change of a cascade
+type compensation func(ctx context.Context) error
+
+// Reverts the changes of a failed cascade in reverse order. Returns false, if a change could not be reverted.
+// The changes are reverted with a detached context, because the cascade may have failed due to the cancellation of ctx.
+func compensate(ctx context.Context, changes []compensation) bool {
+	revertCtx, cancel := context.WithTimeout(context.Background(), compensationTimeout)
+	defer cancel()
+	ok := true
+	for i := len(changes) - 1; i >= 0; i-- {
+		if err := changes[i](revertCtx); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Could not revert a change of the failed deletion")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Deletes the role after removing it from all users and content type permissions
+func (ctrl *Controller) deleteRole(ctx context.Context, role *model.Role) (*store.DeleteResult, error) {
+	filter := bson.M{"_id": role.ID}
+	// The changes of the cascade are recorded in the audit log after the deletion succeeded
+	var audits []*model.AuditEntry
+	if ctrl.store.Transactions.SupportsTransactions(ctx) {
+		var result *store.DeleteResult
+		err := ctrl.store.Transactions.WithTransaction(ctx, func(ctx context.Context) error {
+			audits = nil
+			if err := ctrl.removeRoleReferences(ctx, role.ID, nil, &audits); err != nil {
+				return err
+			}
+			var err error
+			result, err = ctrl.store.Roles.Delete(ctx, filter)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+		ctrl.recordAudits(ctx, append(audits, newAuditEntry(ctx, model.AuditDelete, store.CollectionRoles, role.ID.Hex(), role, nil))...)
+		return result, nil
+	}
+
+	if _, err := ctrl.store.Roles.Update(ctx, filter, bson.M{"$currentDate": bson.M{deletingField: true}}); err != nil {
+		return nil, err
+	}
+	var changes []compensation
+	if err := ctrl.removeRoleReferences(ctx, role.ID, &changes, &audits); err != nil {
+		// The role stays marked, if a change could not be reverted, so that its deletion is finished on the next start
+		if compensate(ctx, changes) {
+			compensate(ctx, []compensation{func(ctx context.Context) error {
+				_, err := ctrl.store.Roles.Update(ctx, filter, bson.M{"$unset": bson.M{deletingField: ""}})
+				return err
+			}})
+		}
+		return nil, err
+	}
+	result, err := ctrl.store.Roles.Delete(ctx, filter)
+	// The references are removed, even if the role could not be deleted
+	ctrl.recordAudits(ctx, audits...)
+	if err != nil {
+		return result, err
+	}
+	ctrl.audit(ctx, model.AuditDelete, store.CollectionRoles, role.ID.Hex(), role, nil)
+	return result, nil
+}
+
+// Removes the role from the users and the permissions of the content types including those in the trash.
+// The reverts of the changes are added to changes, if it is not nil, and the audit log entries of the changes to audits.
+func (ctrl *Controller) removeRoleReferences(ctx context.Context, rID primitive.ObjectID, changes *[]compensation, audits *[]*model.AuditEntry) error {
+	users, err := ctrl.GetUsers(ctx, bson.M{"roles": rID})
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, u := range users {
+		if _, err := ctrl.removeRoleFromUser(ctx, rID, u); err != nil {
+			return err
+		}
+		after, _ := ctrl.store.Users.FindOne(ctx, bson.M{"_id": u.ID})
+		*audits = append(*audits, newAuditEntry(ctx, model.AuditUpdate, store.CollectionUsers, u.ID.Hex(), u, after))
+		if changes != nil {
+			// Only the removed role is added again, so that concurrent changes of the other roles are kept
+			id := u.ID
+			*changes = append(*changes, func(ctx context.Context) error {
+				_, err := ctrl.store.Users.Update(ctx, bson.M{"_id": id}, bson.M{"$addToSet": bson.M{"roles": rID}})
+				return err
+			})
+		}
+	}
+
+	// It is possible, that one ore more permission have no roles left after.
+	contentTypes, err := ctrl.GetContentTypesIncludingTrash(ctx, bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, ct := range contentTypes {
+		if !hasPermission(ct, rID) {
+			continue
+		}
+		if _, err := ctrl.removeRoleFromPermissions(ctx, rID, ct); err != nil {
+			return err
+		}
+		after, _ := ctrl.store.ContentTypes.FindOne(ctx, bson.M{"_id": ct.ID})
+		*audits = append(*audits, newAuditEntry(ctx, model.AuditUpdate, store.CollectionContentTypes, ct.ID.Hex(), ct, after))
+		if changes != nil {
+			id, restore := ct.ID, bson.M{}
+			for method, roles := range ct.Permissions {
+				for _, r := range roles {
+					if r == rID {
+						restore["permissions."+method] = rID
+					}
+				}
+			}
+			*changes = append(*changes, func(ctx context.Context) error {
+				_, err := ctrl.store.ContentTypes.Update(ctx, bson.M{"_id": id}, bson.M{"$addToSet": restore})
+				return err
+			})
+		}
+	}
+	return nil
+}
+
+// Returns true, if one of the permissions of the content type contains the role
+func hasPermission(ct *model.ContentType, rID primitive.ObjectID) bool {
+	for _, roles := range ct.Permissions {
+		for _, r := range roles {
+			if r == rID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Deletes the content type and drops its collection. Collections can not be dropped in transactions,
+// so the content type is marked first and only deleted after the collection was dropped.
+// If the drop fails, the mark is removed again.
+func (ctrl *Controller) purgeContentType(ctx context.Context, ct *model.ContentType) (*store.DeleteResult, error) {
+	filter := bson.M{"_id": ct.ID}
+	if _, err := ctrl.store.ContentTypes.Update(ctx, filter, bson.M{"$currentDate": bson.M{deletingField: true}}); err != nil {
+		return nil, err
+	}
+	if err := ctrl.store.Content.Drop(ctx, ct.Collection); err != nil {
+		compensate(ctx, []compensation{func(ctx context.Context) error {
+			_, err := ctrl.store.ContentTypes.Update(ctx, filter, bson.M{"$unset": bson.M{deletingField: ""}})
+			return err
+		}})
+		return nil, err
+	}
+	result, err := ctrl.store.ContentTypes.Delete(ctx, filter)
+	if err != nil {
+		return result, err
+	}
+	ctrl.audit(ctx, model.AuditPurge, store.CollectionContentTypes, ct.ID.Hex(), ct, nil)
+	return result, nil
+}
+
+// Finishes the deletions of roles and content types, that were interrupted by a crash
+func (ctrl *Controller) ResumeCascades(ctx context.Context) error {
+	marked := bson.M{deletingField: bson.M{"$exists": true}}
+	roles, err := ctrl.store.Roles.Find(ctx, marked)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, r := range roles {
+		logging.Ctx(ctx).Info().Str("role", r.Name).Msg("Resuming the deletion of the role")
+		if _, err := ctrl.deleteRole(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	contentTypes, err := ctrl.store.ContentTypes.Find(ctx, marked)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, ct := range contentTypes {
+		logging.Ctx(ctx).Info().Str("collection", ct.Collection).Msg("Resuming the purge of the content type")
+		if _, err := ctrl.purgeContentType(ctx, ct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Returns the content type, that has `coll` as alias, that is not expired yet
+func (ctrl *Controller) GetContentTypeByAlias(ctx context.Context, coll string) (*model.ContentType, error) {
+	if cacheTTL() > 0 {
+		now := time.Now()
+		return ctrl.cachedContentType(ctx, func(ct *model.ContentType) bool {
+			if ct.DeletedAt != nil {
+				return false
+			}
+			for _, a := range ct.Aliases {
+				if a.Collection == coll && a.ExpiresAt.After(now) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return ctrl.GetContentType(ctx, bson.M{"aliases": bson.M{"$elemMatch": bson.M{
+		"collection": coll,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}}})
+}
+
+// Time the previous collection of a content type redirects to the new one
+func collectionAliasTTL() time.Duration {
+	return time.Duration(config.Get().Content.CollectionAliasDays) * 24 * time.Hour
+}
+
+// Returns the aliases of the content type after its collection changed to `coll`:
+// The previous collection is added, expired aliases and the new collection are removed.
+func moveAliases(ct *model.ContentType, coll string) []model.CollectionAlias {
+	aliases := make([]model.CollectionAlias, 0)
+	now := time.Now()
+	for _, a := range ct.Aliases {
+		if a.ExpiresAt.After(now) && a.Collection != coll && a.Collection != ct.Collection {
+			aliases = append(aliases, a)
+		}
+	}
+	if ttl := collectionAliasTTL(); ttl > 0 {
+		aliases = append(aliases, model.CollectionAlias{Collection: ct.Collection, ExpiresAt: now.Add(ttl)})
+	}
+	return aliases
+}
+
+// Returned for writes of entries of a collection, that is being moved, and for a second move of the collection
+var ErrCollectionMoving = errors.New("the collection is being moved to another collection, try again later")
+
+// Returned for moves to a collection, that is used by the system or another content type
+var ErrCollectionInUse = errors.New("collection is in use")
+
+// Collections with a running move on this instance and the number of running writes of their entries
+type moves struct {
+	mu     sync.Mutex
+	moving map[string]bool
+	writes map[string]*sync.WaitGroup
+}
+
+// Registers a write of the entries of the collection. done has to be called, when the write returned.
+func (m *moves) startWrite(coll string) (done func(), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.moving[coll] {
+		return nil, ErrCollectionMoving
+	}
+	if m.writes == nil {
+		m.writes = make(map[string]*sync.WaitGroup)
+	}
+	wg, ok := m.writes[coll]
+	if !ok {
+		wg = new(sync.WaitGroup)
+		m.writes[coll] = wg
+	}
+	wg.Add(1)
+	return wg.Done, nil
+}
+
+// Rejects new writes of the entries of the collection and waits until the running ones returned.
+// unblock has to be called, when the move finished.
+func (m *moves) block(coll string) (unblock func(), err error) {
+	m.mu.Lock()
+	if m.moving[coll] {
+		m.mu.Unlock()
+		return nil, ErrCollectionMoving
+	}
+	if m.moving == nil {
+		m.moving = make(map[string]bool)
+	}
+	m.moving[coll] = true
+	wg := m.writes[coll]
+	m.mu.Unlock()
+
+	if wg != nil {
+		wg.Wait()
+	}
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.moving, coll)
+	}, nil
+}
+
+// Store of the content, that rejects writes of entries of collections, while they are moved.
+// Otherwise writes after the entries were copied or renamed would be lost.
+type movingContent struct {
+	store.ContentStore
+	moves *moves
+}
+
+func (s movingContent) Insert(ctx context.Context, coll string, content *model.Content) (*store.InsertResult, error) {
+	done, err := s.moves.startWrite(coll)
+	if err != nil {
+		return new(store.InsertResult), err
+	}
+	defer done()
+	return s.ContentStore.Insert(ctx, coll, content)
+}
+
+func (s movingContent) Update(ctx context.Context, coll string, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	done, err := s.moves.startWrite(coll)
+	if err != nil {
+		return new(store.UpdateResult), err
+	}
+	defer done()
+	return s.ContentStore.Update(ctx, coll, filter, update)
+}
+
+func (s movingContent) Delete(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	done, err := s.moves.startWrite(coll)
+	if err != nil {
+		return new(store.DeleteResult), err
+	}
+	defer done()
+	return s.ContentStore.Delete(ctx, coll, filter)
+}
+
+func (s movingContent) DeleteMany(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	done, err := s.moves.startWrite(coll)
+	if err != nil {
+		return new(store.DeleteResult), err
+	}
+	defer done()
+	return s.ContentStore.DeleteMany(ctx, coll, filter)
+}
+
+func (s movingContent) BulkUpdate(ctx context.Context, coll string, updates []store.EntryUpdate) (*store.UpdateResult, error) {
+	done, err := s.moves.startWrite(coll)
+	if err != nil {
+		return new(store.UpdateResult), err
+	}
+	defer done()
+	return s.ContentStore.BulkUpdate(ctx, coll, updates)
+}
+
+// Moves all entries of the content type to the collection `to` and calls `swap`, which has to point the content type to the new collection.
+// Writes of entries of the old collection are rejected with ErrCollectionMoving until the move finished.
+// The collection is renamed by the content store. If this is not possible, e.g. on sharded clusters or because of missing privileges,
+// the entries are copied to the new collection with `moveEntries`.
+// On failure the entries stay in the old collection: A rename is reverted and a partial copy is dropped.
+func (ctrl *Controller) moveCollection(ctx context.Context, ct *model.ContentType, to string, swap func() error) error {
+	from := ct.Collection
+	unblock, err := ctrl.moves.block(from)
+	if err != nil {
+		return err
+	}
+	defer unblock()
+
+	if err := ctrl.checkTargetCollection(ctx, ct, to); err != nil {
+		return err
+	}
+
+	err = ctrl.store.Content.Rename(ctx, from, to)
+	if errors.Is(err, store.ErrNotSupported) {
+		return ctrl.moveEntries(ctx, ct, to, swap)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := swap(); err != nil {
+		revertCtx, cancel := cleanupContext()
+		defer cancel()
+		if revertErr := ctrl.store.Content.Rename(revertCtx, to, from); revertErr != nil {
+			logging.Logger().Error().Err(revertErr).Str("collection", to).Str("to", from).Msg("Could not revert the rename of the collection")
+		}
+		return err
+	}
+	return nil
+}
+
+// Checks that the target collection is not used by the system or another content type, also as alias, and does not contain any documents.
+// Empty collections are dropped, so they can be replaced.
+func (ctrl *Controller) checkTargetCollection(ctx context.Context, ct *model.ContentType, coll string) error {
+	if store.IsSystemCollection(coll) {
+		return fmt.Errorf("%w: %s is a system collection", ErrCollectionInUse, coll)
+	}
+	others, err := ctrl.GetContentTypesIncludingTrash(ctx, bson.M{
+		"_id": bson.M{"$ne": ct.ID},
+		"$or": bson.A{
+			bson.M{"collection": coll},
+			bson.M{"aliases": bson.M{"$elemMatch": bson.M{
+				"collection": coll,
+				"expires_at": bson.M{"$gt": time.Now()},
+			}}},
+		},
+	})
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if len(others) > 0 {
+		return fmt.Errorf("%w: %s is used by the content type %s", ErrCollectionInUse, coll, others[0].TypeName)
+	}
+
+	count, err := ctrl.store.Content.Count(ctx, coll, bson.M{})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s already contains %d documents", ErrCollectionInUse, coll, count)
+	}
+	return ctrl.store.Content.Drop(ctx, coll)
+}
+
+// Moves the entries of the content type to the collection `to` through the content store.
+// The entries are copied before the swap and the old collection is dropped after it.
+// The indexes are built from the declaration, because they are not copied.
+func (ctrl *Controller) moveEntries(ctx context.Context, ct *model.ContentType, to string, swap func() error) error {
+	from := ct.Collection
+
+	err := ctrl.store.Content.Stream(ctx, from, bson.M{}, func(e *model.Content) error {
+		_, err := ctrl.store.Content.Insert(ctx, to, e)
+		return err
+	})
+	if err == nil {
+		err = swap()
+	}
+	if err != nil {
+		dropCtx, cancel := cleanupContext()
+		defer cancel()
+		if dropErr := ctrl.store.Content.Drop(dropCtx, to); dropErr != nil {
+			logging.Ctx(ctx).Error().Err(dropErr).Str("collection", from).Str("copy", to).Msg("Could not drop the partial copy of the collection")
+		}
+		return err
+	}
+
+	moved := *ct
+	moved.Collection = to
+	if err := ctrl.ReconcileIndexes(ctx, &moved); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str("collection", to).Msg("Could not build the indexes of the collection")
+	}
+	dropCtx, cancel := cleanupContext()
+	defer cancel()
+	if err := ctrl.store.Content.Drop(dropCtx, from); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str("collection", from).Str("to", to).Msg("Could not drop the collection after moving it")
+	}
+	return nil
+}
+
+// Returns the context for cleaning up after a move of a collection. It is detached from the request,
+// so that a move cancelled by a disconnect of the client does not leave the entries in two collections.
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), compensationTimeout)
+}
+
+// Updates stored references to the old collection
+func (ctrl *Controller) moveCollectionReferences(ctx context.Context, ct *model.ContentType, to string) error {
+	_, err := ctrl.store.Migrations.UpdateMany(ctx,
+		bson.M{"content_type_id": ct.ID},
+		bson.M{"$set": bson.M{"collection": to}})
+	return err
+}
@@ -2,100 +2,105 @@ package controller
 
 import (
 	"context"
-	"time"
 
-	"github.com/D-Bald/fiber-backend/database"
 	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// Return all content entries from collection coll that match the filter
-func GetContent(coll string, filter interface{}) ([]*model.Content, error) {
-	var result []*model.Content
+// Return all content entries from collection coll that match the filter. Entries in the trash are excluded.
+func (ctrl *Controller) GetContent(ctx context.Context, coll string, filter interface{}) ([]*model.Content, error) {
+	return ctrl.findContent(ctx, coll, notTrashed(filter))
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Return all content entries in the trash of collection coll that match the filter, recently deleted first
+func (ctrl *Controller) GetTrashedContent(ctx context.Context, coll string, filter interface{}) ([]*model.Content, error) {
+	return ctrl.findContent(ctx, coll, trashed(filter), store.SortBy("deleted_at", true))
+}
 
-	cursor, err := database.DB.Collection(coll).Find(ctx, filter)
+// Return a single content entry from collection coll that matches the filter. Filter must be structured in bson types.
+// Entries in the trash are excluded.
+func (ctrl *Controller) GetContentEntry(ctx context.Context, coll string, filter interface{}) (*model.Content, error) {
+	return ctrl.store.Content.FindOne(ctx, coll, notTrashed(filter))
+}
+
+// Return Content from collection coll with provided ID, even if it is in the trash
+func (ctrl *Controller) GetContentByIdIncludingTrash(ctx context.Context, coll string, id string) (*model.Content, error) {
+	cID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var con model.Content
-		err := cursor.Decode(&con)
-		if err != nil {
-			return nil, err
-		}
-
-		result = append(result, &con)
-	}
+	return ctrl.store.Content.FindOne(ctx, coll, bson.M{"_id": cID})
+}
 
-	if err := cursor.Err(); err != nil {
+func (ctrl *Controller) findContent(ctx context.Context, coll string, filter interface{}, opts ...store.FindOption) ([]*model.Content, error) {
+	result, err := ctrl.store.Content.Find(ctx, coll, filter, opts...)
+	if err != nil {
 		return nil, err
 	}
 
 	if len(result) == 0 {
-		return result, mongo.ErrNoDocuments
+		return result, store.ErrNotFound
 	}
 
 	return result, nil
 }
 
-// Return a single content entry from collection coll that matches the filter. Filter must be structured in bson types.
-func GetContentEntry(coll string, filter interface{}) (*model.Content, error) {
-	var c *model.Content
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err := database.DB.Collection(coll).FindOne(ctx, filter).Decode(&c)
-	if err != nil {
-		return nil, err
-	}
-	return c, nil
-}
-
 // Return Content from collection coll with provided ID
-func GetContentById(coll string, id string) (*model.Content, error) {
+func (ctrl *Controller) GetContentById(ctx context.Context, coll string, id string) (*model.Content, error) {
 	cID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
 
 	filter := bson.M{"_id": cID}
-	return GetContentEntry(coll, filter)
+	return ctrl.GetContentEntry(ctx, coll, filter)
 }
 
 // Insert content entry in collection coll with provided Parameters
-func CreateContent(coll string, content *model.Content) (*mongo.InsertOneResult, error) {
+func (ctrl *Controller) CreateContent(ctx context.Context, coll string, content *model.Content) (*store.InsertResult, error) {
 	// Get corresponding content type set the ContentTypeID reference.
 	// ct's FieldSchema could be accessed for validation
-	ct, err := GetContentType(bson.M{"collection": coll})
+	ct, err := ctrl.GetContentType(ctx, bson.M{"collection": coll})
 	if err != nil {
-		return new(mongo.InsertOneResult), err
+		return new(store.InsertResult), err
 	}
 
 	// Initialize metadata
 	content.Init(*ct)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// Generate slugs
+	if err := ctrl.setSlugs(ctx, ct, content); err != nil {
+		return new(store.InsertResult), err
+	}
 
-	return database.DB.Collection(coll).InsertOne(ctx, content)
+	result, err := ctrl.store.Content.Insert(ctx, coll, content)
+	if err != nil {
+		return result, err
+	}
+	ctrl.audit(ctx, model.AuditCreate, coll, content.ID.Hex(), nil, content)
+	return result, nil
 }
 
 // Update content entry in collection coll with provided parameters
-func UpdateContent(coll string, id string, input *model.ContentUpdate) (*mongo.UpdateResult, error) {
+func (ctrl *Controller) UpdateContent(ctx context.Context, coll string, id string, input *model.ContentUpdate) (*store.UpdateResult, error) {
 	cID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return new(mongo.UpdateResult), err
+		return new(store.UpdateResult), err
 	}
+
+	// Normalize slugs
+	ct, err := ctrl.GetContentTypeByCollection(ctx, coll)
+	if err != nil {
+		return new(store.UpdateResult), err
+	}
+	if err := ctrl.updateSlugs(ctx, ct, cID, input); err != nil {
+		return new(store.UpdateResult), err
+	}
+
 	// Update content with provided ID and sets field value `updatet_at`
-	filter := bson.M{"_id": cID}
+	filter := bson.M{"_id": cID, "deleted_at": bson.M{"$exists": false}}
 	update := bson.D{
 		{Key: "$set", Value: *input},
 		{Key: "$currentDate", Value: bson.M{
@@ -103,22 +108,59 @@ func UpdateContent(coll string, id string, input *model.ContentUpdate) (*mongo.U
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	return ctrl.updateContent(ctx, model.AuditUpdate, coll, cID, filter, update)
+}
+
+// Move content entry with provided ID to the trash
+func (ctrl *Controller) DeleteContent(ctx context.Context, coll string, id string) (*store.UpdateResult, error) {
+	cID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"_id": cID, "deleted_at": bson.M{"$exists": false}}
+	update := bson.M{"$currentDate": bson.M{"deleted_at": true, "updated_at": true}}
 
-	return database.DB.Collection(coll).UpdateOne(ctx, filter, update)
+	return ctrl.updateContent(ctx, model.AuditDelete, coll, cID, filter, update)
 }
 
-// Delete content entry provided ID in DB
-func DeleteContent(coll string, id string) (*mongo.DeleteResult, error) {
+// Restore content entry with provided ID from the trash
+func (ctrl *Controller) RestoreContent(ctx context.Context, coll string, id string) (*store.UpdateResult, error) {
 	cID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
-	filter := bson.M{"_id": cID}
+	filter := bson.M{"_id": cID, "deleted_at": bson.M{"$exists": true}}
+	update := bson.D{
+		{Key: "$unset", Value: bson.M{"deleted_at": ""}},
+		{Key: "$currentDate", Value: bson.M{"updated_at": true}},
+	}
+
+	return ctrl.updateContent(ctx, model.AuditRestore, coll, cID, filter, update)
+}
+
+// Permanently delete content entry with provided ID from the trash
+func (ctrl *Controller) PurgeContent(ctx context.Context, coll string, id string) (*store.DeleteResult, error) {
+	cID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"_id": cID, "deleted_at": bson.M{"$exists": true}}
+
+	return ctrl.auditedDelete(ctx, model.AuditPurge, coll, cID, ctrl.findContentForAudit(ctx, coll, cID), func() (*store.DeleteResult, error) {
+		return ctrl.store.Content.Delete(ctx, coll, filter)
+	})
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Updates the content entry with the ID, if it matches the filter, and records the action with the changes in the audit log
+func (ctrl *Controller) updateContent(ctx context.Context, action string, coll string, id primitive.ObjectID, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return ctrl.auditedUpdate(ctx, action, coll, id, ctrl.findContentForAudit(ctx, coll, id), func() (*store.UpdateResult, error) {
+		return ctrl.store.Content.Update(ctx, coll, filter, update)
+	})
+}
 
-	return database.DB.Collection(coll).DeleteOne(ctx, filter)
+// Returns a function, that finds the content entry with the ID including the trash
+func (ctrl *Controller) findContentForAudit(ctx context.Context, coll string, id primitive.ObjectID) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return ctrl.store.Content.FindOne(ctx, coll, bson.M{"_id": id})
+	}
 }
@@ -2,175 +2,187 @@ package controller
 
 import (
 	"context"
-	"time"
+	"errors"
 
-	"github.com/D-Bald/fiber-backend/database"
+	"github.com/D-Bald/fiber-backend/logging"
 	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Initialize collection ContentTypes with 'blogposts' and 'events'
-func InitContentTypes() error {
-	// Checks if blogpost exists
-	_, err := GetContentType(bson.D{{Key: "typename", Value: "blogpost"}})
-	if err != nil && err == mongo.ErrNoDocuments {
-		// Get Roles
-		var roles []primitive.ObjectID
-		if user, err := GetRoleByTag("default"); err != nil {
-			return err
-		} else {
-			roles = append(roles, user.ID)
-		}
-		if admin, err := GetRoleByTag("admin"); err != nil {
-			return err
-		} else {
-			roles = append(roles, admin.ID)
-		}
-		// define blogpost document
-		blogpost := bson.D{
-			{Key: "typename", Value: "blogpost"},
-			{Key: "collection", Value: "blogposts"},
-			{Key: "permissions", Value: bson.M{
-				"GET":    roles,
-				"POST":   roles,
-				"PATCH":  roles,
-				"DELETE": roles,
-			}},
-			{Key: "field_schema", Value: bson.M{
+func (ctrl *Controller) InitContentTypes(ctx context.Context) error {
+	presets := []model.ContentType{
+		{
+			TypeName:   "blogpost",
+			Collection: "blogposts",
+			FieldSchema: map[string]interface{}{
 				"description": "string",
 				"text":        "string",
-			}},
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if _, err := database.DB.Collection("contenttypes").InsertOne(ctx, blogpost); err != nil {
-			return err
-		}
+			},
+		},
+		{
+			TypeName:   "event",
+			Collection: "events",
+			FieldSchema: map[string]interface{}{
+				"description": "string",
+				"date":        "time.Time",
+				"place":       "string",
+			},
+		},
 	}
-	// checks if event exists
-	_, err = GetContentType(bson.D{{Key: "typename", Value: "event"}})
-	if err != nil && err == mongo.ErrNoDocuments {
+	for _, preset := range presets {
+		// Checks if the content type exists
+		_, err := ctrl.store.ContentTypes.FindOne(ctx, bson.M{"typename": preset.TypeName})
+		if err != store.ErrNotFound {
+			continue
+		}
 		// Get Roles
 		var roles []primitive.ObjectID
-		if user, err := GetRoleByTag("default"); err != nil {
+		if user, err := ctrl.GetRoleByTag(ctx, "default"); err != nil {
 			return err
 		} else {
 			roles = append(roles, user.ID)
 		}
-		if admin, err := GetRoleByTag("admin"); err != nil {
+		if admin, err := ctrl.GetRoleByTag(ctx, "admin"); err != nil {
 			return err
 		} else {
 			roles = append(roles, admin.ID)
 		}
-		// define blogpost document
-		event := bson.D{
-			{Key: "typename", Value: "event"},
-			{Key: "collection", Value: "events"},
-			{Key: "permissions", Value: bson.M{
-				"GET":    roles,
-				"POST":   roles,
-				"PATCH":  roles,
-				"DELETE": roles,
-			}},
-			{Key: "field_schema", Value: bson.M{
-				"description": "string",
-				"date":        "time.Time",
-				"place":       "string",
-			},
-			},
+		ct := preset
+		ct.Init()
+		ct.Permissions = map[string][]primitive.ObjectID{
+			"GET":    roles,
+			"POST":   roles,
+			"PATCH":  roles,
+			"DELETE": roles,
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if _, err := database.DB.Collection("contenttypes").InsertOne(ctx, event); err != nil {
+		if _, err := ctrl.store.ContentTypes.Insert(ctx, &ct); err != nil {
 			return err
 		}
 	}
-	err = nil
-	return err
+	ctrl.seeded(SeedContentTypes)
+	return nil
+}
+
+// Return all ContentTypes that match the filter. Content types in the trash are excluded.
+func (ctrl *Controller) GetContentTypes(ctx context.Context, filter interface{}) ([]*model.ContentType, error) {
+	return ctrl.findContentTypes(ctx, notTrashed(filter))
 }
 
-// Return all ContentTypes that match the filter
-func GetContentTypes(filter interface{}) ([]*model.ContentType, error) {
-	// A slice of tasks for storing the decoded documents
-	var result []*model.ContentType
+// Return all ContentTypes in the trash that match the filter, recently deleted first
+func (ctrl *Controller) GetTrashedContentTypes(ctx context.Context, filter interface{}) ([]*model.ContentType, error) {
+	return ctrl.findContentTypes(ctx, trashed(filter), store.SortBy("deleted_at", true))
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Return all ContentTypes that match the filter including the ones in the trash
+func (ctrl *Controller) GetContentTypesIncludingTrash(ctx context.Context, filter interface{}) ([]*model.ContentType, error) {
+	return ctrl.findContentTypes(ctx, filter)
+}
 
-	cursor, err := database.DB.Collection("contenttypes").Find(ctx, filter)
-	if err != nil {
-		return result, err
-	}
-	defer cursor.Close(ctx)
+// Return a single ContentType that matches the filter. Content types in the trash are excluded.
+func (ctrl *Controller) GetContentType(ctx context.Context, filter interface{}) (*model.ContentType, error) {
+	return ctrl.store.ContentTypes.FindOne(ctx, notTrashed(filter))
+}
 
-	for cursor.Next(ctx) {
-		var ct model.ContentType
-		err := cursor.Decode(&ct)
-		if err != nil {
-			return result, err
-		}
+// Return a single ContentType that matches the filter including the ones in the trash
+func (ctrl *Controller) GetContentTypeIncludingTrash(ctx context.Context, filter interface{}) (*model.ContentType, error) {
+	return ctrl.store.ContentTypes.FindOne(ctx, filter)
+}
 
-		result = append(result, &ct)
+// Return a single ContentType with provided ID, even if it is in the trash
+func (ctrl *Controller) GetContentTypeByIdIncludingTrash(ctx context.Context, id string) (*model.ContentType, error) {
+	ctID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
 	}
+	return ctrl.store.ContentTypes.FindOne(ctx, bson.M{"_id": ctID})
+}
 
-	if err := cursor.Err(); err != nil {
+func (ctrl *Controller) findContentTypes(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.ContentType, error) {
+	result, err := ctrl.store.ContentTypes.Find(ctx, filter, opts...)
+	if err != nil {
 		return result, err
 	}
 
 	if len(result) == 0 {
-		return result, mongo.ErrNoDocuments
+		return result, store.ErrNotFound
 	}
 
 	return result, nil
 }
 
-// Return a single ContentType that matches the filter
-func GetContentType(filter interface{}) (*model.ContentType, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	var ct *model.ContentType
-	err := database.DB.Collection("contenttypes").FindOne(ctx, filter).Decode(&ct)
-	if err != nil {
-		return nil, err
-	}
-
-	return ct, nil
-}
-
 // Return a single ContentType with provided ID
-func GetContentTypeById(id string) (*model.ContentType, error) {
+func (ctrl *Controller) GetContentTypeById(ctx context.Context, id string) (*model.ContentType, error) {
 	ctID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
+	if cacheTTL() > 0 {
+		return ctrl.cachedContentType(ctx, func(ct *model.ContentType) bool {
+			return ct.ID == ctID && ct.DeletedAt == nil
+		})
+	}
 	filter := bson.M{"_id": ctID}
-	return GetContentType(filter)
+	return ctrl.GetContentType(ctx, filter)
 }
 
+// Returned for content types, whose collection is one of the system collections
+var ErrReservedCollection = errors.New("collection name is reserved")
+
 // Insert content type with provided Parameters in DB
-func CreateContentType(ct *model.ContentType) (*mongo.InsertOneResult, error) {
+func (ctrl *Controller) CreateContentType(ctx context.Context, ct *model.ContentType) (*store.InsertResult, error) {
+	if store.IsSystemCollection(ct.Collection) {
+		return new(store.InsertResult), ErrReservedCollection
+	}
+
 	// Initialize metadata
 	ct.Init()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// Create declared indexes and indexes for unique fields
+	if err := ctrl.ReconcileIndexes(ctx, ct); err != nil {
+		return new(store.InsertResult), err
+	}
 
-	return database.DB.Collection("contenttypes").InsertOne(ctx, ct)
+	result, err := ctrl.store.ContentTypes.Insert(ctx, ct)
+	if err != nil {
+		return result, err
+	}
+	ctrl.audit(ctx, model.AuditCreate, store.CollectionContentTypes, ct.ID.Hex(), nil, ct)
+	return result, nil
 }
 
 // Update content type with provided parameters
-func UpdateContentType(id string, input *model.ContentTypeUpdate) (*mongo.UpdateResult, error) {
+func (ctrl *Controller) UpdateContentType(ctx context.Context, id string, input *model.ContentTypeUpdate) (*store.UpdateResult, error) {
+	if store.IsSystemCollection(input.Collection) {
+		return new(store.UpdateResult), ErrReservedCollection
+	}
+	ctID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return new(store.UpdateResult), err
+	}
+	return ctrl.auditedUpdate(ctx, model.AuditUpdate, store.CollectionContentTypes, ctID, ctrl.findContentTypeForAudit(ctx, ctID), func() (*store.UpdateResult, error) {
+		return ctrl.updateContentType(ctx, id, input)
+	})
+}
+
+// Returns a function, that finds the content type with the ID including the trash
+func (ctrl *Controller) findContentTypeForAudit(ctx context.Context, id primitive.ObjectID) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return ctrl.store.ContentTypes.FindOne(ctx, bson.M{"_id": id})
+	}
+}
+
+func (ctrl *Controller) updateContentType(ctx context.Context, id string, input *model.ContentTypeUpdate) (*store.UpdateResult, error) {
 	// Struct similar to `ContentTypeUpdate` but with ObjectIDs of roles instead of string role names
 	type mongoContentTypeUpdate struct {
 		TypeName    string                          `bson:"typename,omitempty"`
 		Collection  string                          `bson:"collection,omitempty"`
 		Permissions map[string][]primitive.ObjectID `bson:"permissions,omitempty"`
 		FieldSchema map[string]interface{}          `bson:"field_schema,omitempty"`
+		Indexes     *[]model.IndexDefinition        `bson:"indexes,omitempty"`
+		Aliases     []model.CollectionAlias         `bson:"aliases,omitempty"`
 	}
 
 	// create Object with ObjectIDs as Roles
@@ -179,15 +191,16 @@ func UpdateContentType(id string, input *model.ContentTypeUpdate) (*mongo.Update
 		Collection:  input.Collection,
 		Permissions: make(map[string][]primitive.ObjectID),
 		FieldSchema: input.FieldSchema,
+		Indexes:     input.Indexes,
 	}
 
 	// Parse role name strings in Permissionsto role ObjectIDs
 	for key, val := range input.Permissions {
 		var roleObjectIDs []primitive.ObjectID
 		for _, r := range val {
-			rObj, err := GetRoleByName(r)
+			rObj, err := ctrl.GetRoleByName(ctx, r)
 			if err != nil {
-				return new(mongo.UpdateResult), err
+				return new(store.UpdateResult), err
 			}
 			roleObjectIDs = append(roleObjectIDs, rObj.ID)
 		}
@@ -196,45 +209,129 @@ func UpdateContentType(id string, input *model.ContentTypeUpdate) (*mongo.Update
 
 	ctID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return new(mongo.UpdateResult), err
+		return new(store.UpdateResult), err
 	}
+
 	// Update content type with provided ID and sets field value for `updatet_at`
-	filter := bson.M{"_id": ctID}
-	update := bson.D{
-		{Key: "$set", Value: ctUpdate},
-		{Key: "$currentDate", Value: bson.M{
-			"updated_at": true},
-		},
+	update := func() (*store.UpdateResult, error) {
+		filter := bson.M{"_id": ctID}
+		update := bson.D{
+			{Key: "$set", Value: ctUpdate},
+			{Key: "$currentDate", Value: bson.M{
+				"updated_at": true},
+			},
+		}
+		return ctrl.store.ContentTypes.Update(ctx, filter, update)
+	}
+
+	if ctUpdate.FieldSchema == nil && ctUpdate.Collection == "" && ctUpdate.Indexes == nil {
+		return update()
+	}
+
+	old, err := ctrl.GetContentTypeById(ctx, id)
+	if err != nil {
+		return new(store.UpdateResult), err
+	}
+	ct := *old
+	if ctUpdate.FieldSchema != nil {
+		ct.FieldSchema = ctUpdate.FieldSchema
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return database.DB.Collection("contenttypes").UpdateOne(ctx, filter, update)
+	if ctUpdate.Indexes != nil {
+		ct.Indexes = *ctUpdate.Indexes
+	}
+
+	// Reconcile indexes before the update, so that existing duplicates of unique fields prevent the update
+	if ctUpdate.FieldSchema != nil || ctUpdate.Indexes != nil {
+		if err := ctrl.ReconcileIndexes(ctx, &ct); err != nil {
+			// restore the indexes of the unchanged content type
+			ctrl.rollbackIndexes(ctx, old)
+			return new(store.UpdateResult), err
+		}
+	}
+
+	if ctUpdate.Collection == "" || ctUpdate.Collection == old.Collection {
+		return update()
+	}
+
+	// Move the entries to the new collection and keep the old one as alias
+	if active, err := ctrl.hasActiveMigration(ctx, ct.ID); err != nil {
+		return new(store.UpdateResult), err
+	} else if active {
+		return new(store.UpdateResult), ErrMigrationRunning
+	}
+	ctUpdate.Aliases = moveAliases(old, ctUpdate.Collection)
+	var result *store.UpdateResult
+	err = ctrl.moveCollection(ctx, &ct, ctUpdate.Collection, func() error {
+		var err error
+		result, err = update()
+		return err
+	})
+	if err != nil {
+		if ctUpdate.FieldSchema != nil || ctUpdate.Indexes != nil {
+			ctrl.rollbackIndexes(ctx, old)
+		}
+		return new(store.UpdateResult), err
+	}
+	if err := ctrl.moveCollectionReferences(ctx, old, ctUpdate.Collection); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str("collection", old.Collection).Msg("Could not update references to the collection")
+	}
+	return result, nil
 }
 
-// Delete content type with provided ID in DB
-func DeleteContentType(id string) (*mongo.DeleteResult, error) {
+// Move content type with provided ID to the trash. Its entries are kept, but can not be reached until the content type is restored.
+func (ctrl *Controller) DeleteContentType(ctx context.Context, id string) (*store.UpdateResult, error) {
 	ctID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
-	// Drop corresponding collection
-	filter := bson.M{"_id": ctID}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	ct, err := GetContentType(filter)
+	filter := bson.M{"_id": ctID, "deleted_at": bson.M{"$exists": false}}
+	update := bson.M{"$currentDate": bson.M{"deleted_at": true}}
+
+	return ctrl.auditedUpdate(ctx, model.AuditDelete, store.CollectionContentTypes, ctID, ctrl.findContentTypeForAudit(ctx, ctID), func() (*store.UpdateResult, error) {
+		return ctrl.store.ContentTypes.Update(ctx, filter, update)
+	})
+}
+
+// Restore content type with provided ID from the trash
+func (ctrl *Controller) RestoreContentType(ctx context.Context, id string) (*store.UpdateResult, error) {
+	ctID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"_id": ctID, "deleted_at": bson.M{"$exists": true}}
+	update := bson.D{
+		{Key: "$unset", Value: bson.M{"deleted_at": ""}},
+		{Key: "$currentDate", Value: bson.M{"updated_at": true}},
+	}
+
+	return ctrl.auditedUpdate(ctx, model.AuditRestore, store.CollectionContentTypes, ctID, ctrl.findContentTypeForAudit(ctx, ctID), func() (*store.UpdateResult, error) {
+		return ctrl.store.ContentTypes.Update(ctx, filter, update)
+	})
+}
+
+// Permanently delete content type with provided ID from the trash
+// **Watch out: Also drops the collection with all content entries of this content type.**
+func (ctrl *Controller) PurgeContentType(ctx context.Context, id string) (*store.DeleteResult, error) {
+	ctID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
-	err = database.DB.Collection(ct.Collection).Drop(ctx)
+	ct, err := ctrl.store.ContentTypes.FindOne(ctx, trashed(bson.M{"_id": ctID}))
 	if err != nil {
 		return nil, err
 	}
-	// Delete content type
-	return database.DB.Collection("contenttypes").DeleteOne(ctx, filter)
+	return ctrl.purgeContentType(ctx, ct)
 }
 
 // Delete one role from content type permissions.
-func DeleteRoleFromPermissions(rID primitive.ObjectID, ct *model.ContentType) (*mongo.UpdateResult, error) {
+func (ctrl *Controller) DeleteRoleFromPermissions(ctx context.Context, rID primitive.ObjectID, ct *model.ContentType) (*store.UpdateResult, error) {
+	return ctrl.auditedUpdate(ctx, model.AuditUpdate, store.CollectionContentTypes, ct.ID, ctrl.findContentTypeForAudit(ctx, ct.ID), func() (*store.UpdateResult, error) {
+		return ctrl.removeRoleFromPermissions(ctx, rID, ct)
+	})
+}
+
+// Removes the role from the permissions of the content type without recording it in the audit log
+func (ctrl *Controller) removeRoleFromPermissions(ctx context.Context, rID primitive.ObjectID, ct *model.ContentType) (*store.UpdateResult, error) {
 	permissions := make(map[string][]primitive.ObjectID)
 	for permission, roles := range ct.Permissions {
 		for _, r := range roles {
@@ -253,16 +350,18 @@ func DeleteRoleFromPermissions(rID primitive.ObjectID, ct *model.ContentType) (*
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	return database.DB.Collection("contenttypes").UpdateOne(ctx, filter, update)
+	return ctrl.store.ContentTypes.Update(ctx, filter, update)
 }
 
 // Returns a content type basing on a collection
-func GetContentTypeByCollection(coll string) (*model.ContentType, error) {
+func (ctrl *Controller) GetContentTypeByCollection(ctx context.Context, coll string) (*model.ContentType, error) {
+	if cacheTTL() > 0 {
+		return ctrl.cachedContentType(ctx, func(ct *model.ContentType) bool {
+			return ct.Collection == coll && ct.DeletedAt == nil
+		})
+	}
 	filter := bson.M{"collection": coll}
-	if ct, err := GetContentType(filter); err != nil {
+	if ct, err := ctrl.GetContentType(ctx, filter); err != nil {
 		return nil, err
 	} else {
 		return ct, nil
@@ -270,9 +369,8 @@ func GetContentTypeByCollection(coll string) (*model.ContentType, error) {
 }
 
 // Returns true if the a contenttype with exists, where the `collection` field value is `coll`
-func IsValidContentCollection(coll string) bool {
-	filter := bson.M{"collection": coll}
-	if _, err := GetContentType(filter); err != nil {
+func (ctrl *Controller) IsValidContentCollection(ctx context.Context, coll string) bool {
+	if _, err := ctrl.GetContentTypeByCollection(ctx, coll); err != nil {
 		return false
 	} else {
 		return true
@@ -281,9 +379,8 @@ func IsValidContentCollection(coll string) bool {
 
 // Returns the Custom fields of a contenttype as map
 // Takes the collection of a content type as input
-func GetCustomFields(coll string) (map[string]interface{}, error) {
-	filter := bson.M{"collection": coll}
-	if ct, err := GetContentType(filter); ct != nil {
+func (ctrl *Controller) GetCustomFields(ctx context.Context, coll string) (map[string]interface{}, error) {
+	if ct, err := ctrl.GetContentTypeByCollection(ctx, coll); ct != nil {
 		return ct.FieldSchema, nil
 	} else {
 		return nil, err
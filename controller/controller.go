@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"github.com/D-Bald/fiber-backend/store"
+)
+
+// Controller implements the operations of the API on top of a storage backend
+type Controller struct {
+	store *store.Store
+
+	// Context of the background workers like migrations, index builds and the trash purge.
+	// It is detached from the requests, that start the workers, and is cancelled by `Shutdown`.
+	bg      context.Context
+	stop    context.CancelFunc
+	workers sync.WaitGroup
+
+	health    health
+	cache     cache
+	responses responses
+	moves     moves
+	indexes   indexBuilds
+}
+
+// Returns a controller, that reads and writes through the stores
+func New(s *store.Store) *Controller {
+	bg, stop := context.WithCancel(context.Background())
+	ctrl := &Controller{bg: bg, stop: stop}
+	// Writes of content types and roles drop their cache, writes of content and content types purge the cached responses.
+	// Writes of content are rejected, while its collection is moved.
+	cached := *s
+	cached.ContentTypes = invalidatingContentTypes{s.ContentTypes, ctrl}
+	cached.Roles = invalidatingRoles{s.Roles, &ctrl.cache.roles}
+	cached.Content = movingContent{invalidatingContent{s.Content, ctrl}, &ctrl.moves}
+	ctrl.store = &cached
+	return ctrl
+}
+
+// Runs fn in the background. Its context is cancelled, when the controller shuts down.
+func (ctrl *Controller) goBackground(fn func(ctx context.Context)) {
+	ctrl.workers.Add(1)
+	go func() {
+		defer ctrl.workers.Done()
+		fn(ctrl.bg)
+	}()
+}
+
+// Stops the background workers and waits until they returned or the context is done.
+// Interrupted migrations are resumed on the next start.
+// It has to be called after the server stopped handling requests, which start new workers.
+func (ctrl *Controller) Shutdown(ctx context.Context) error {
+	ctrl.stop()
+	done := make(chan struct{})
+	go func() {
+		ctrl.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Time a dependency has to answer a health check
+const healthCheckTimeout = 2 * time.Second
+
+// Steps of the startup seeding, that have to finish before the server is ready
+const (
+	SeedRoles        = "roles"
+	SeedContentTypes = "content_types"
+	SeedAdminUser    = "admin_user"
+)
+
+// All steps of the seeding in the order of the startup
+var SeedSteps = []string{SeedRoles, SeedContentTypes, SeedAdminUser}
+
+// Health of a dependency like the database
+type DependencyHealth struct {
+	Name      string  `json:"name"`
+	Up        bool    `json:"up"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Result of the health checks. The server is ready, if the seeding finished and all dependencies are up.
+type Health struct {
+	Ready        bool               `json:"ready"`
+	Seeding      map[string]bool    `json:"seeding"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
+
+// Seeding state and dependencies of the health checks
+type health struct {
+	mu           sync.Mutex
+	seeded       map[string]bool
+	dependencies []dependency
+}
+
+type dependency struct {
+	name string
+	ping func(ctx context.Context) error
+}
+
+// Adds a dependency, that has to be up for the server to be ready, e.g. the storage backend
+func (ctrl *Controller) AddDependency(name string, ping func(ctx context.Context) error) {
+	ctrl.health.mu.Lock()
+	defer ctrl.health.mu.Unlock()
+	ctrl.health.dependencies = append(ctrl.health.dependencies, dependency{name: name, ping: ping})
+}
+
+// Records, that a step of the seeding finished
+func (ctrl *Controller) seeded(step string) {
+	ctrl.health.mu.Lock()
+	defer ctrl.health.mu.Unlock()
+	if ctrl.health.seeded == nil {
+		ctrl.health.seeded = make(map[string]bool)
+	}
+	ctrl.health.seeded[step] = true
+}
+
+// Pings all dependencies in parallel and returns their health with the state of the seeding
+func (ctrl *Controller) CheckHealth(ctx context.Context) *Health {
+	ctrl.health.mu.Lock()
+	h := &Health{Ready: true, Seeding: make(map[string]bool), Dependencies: make([]DependencyHealth, len(ctrl.health.dependencies))}
+	for _, step := range SeedSteps {
+		h.Seeding[step] = ctrl.health.seeded[step]
+		h.Ready = h.Ready && h.Seeding[step]
+	}
+	dependencies := ctrl.health.dependencies
+	ctrl.health.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, d := range dependencies {
+		wg.Add(1)
+		go func(i int, d dependency) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := d.ping(ctx)
+			h.Dependencies[i] = DependencyHealth{
+				Name:      d.name,
+				Up:        err == nil,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				h.Dependencies[i].Error = err.Error()
+			}
+		}(i, d)
+	}
+	wg.Wait()
+
+	for _, d := range h.Dependencies {
+		h.Ready = h.Ready && d.Up
+	}
+	return h
+}
@@ -0,0 +1,350 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Time that requests wait for index builds. Builds that take longer keep running in the background.
+const indexBuildWait = 5 * time.Second
+
+// Index build states
+const (
+	IndexBuilding = "building"
+	IndexReady    = "ready"
+	IndexFailed   = "failed"
+	IndexMissing  = "missing"
+	// Indexes that exist on the collection, but are not declared. Indexes created by hand are kept,
+	// indexes of previous declarations are dropped on the next reconciliation.
+	IndexUnmanaged = "unmanaged"
+)
+
+// Status of the last index reconciliation of a content type on this instance
+type IndexBuild struct {
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Status of a single index on the collection of a content type
+type IndexStatus struct {
+	Name   string `json:"name"`
+	Source string `json:"source"` // "indexes", "field_schema" or "" for unmanaged indexes
+	Status string `json:"status"`
+}
+
+// Current index state of a content type's collection
+type IndexState struct {
+	Indexes []IndexStatus `json:"indexes"`
+	Build   *IndexBuild   `json:"build,omitempty"`
+}
+
+// Index reconciliations of this instance
+type indexBuilds struct {
+	mu sync.Mutex
+	// Builds by content type ID, so that the state is kept, when the collection is renamed during a build
+	builds map[string]*IndexBuild
+	// Locks by collection, so that only one reconciliation runs on a collection at a time
+	locks map[string]*sync.Mutex
+}
+
+// Index that should exist on a collection
+type wantedIndex struct {
+	model.IndexDefinition
+	source string
+}
+
+// Reconciles the indexes of the content type's collection with the declared indexes and unique fields:
+// Undeclared or changed indexes, that were created by a reconciliation, are dropped and missing ones are created.
+// Their names have the ManagedIndexPrefix, so that indexes created by hand are never dropped.
+// The reconciliation runs in the background and is not cancelled with ctx, because index builds can take longer than the request.
+// Its error is returned if it finishes within a few seconds, `nil` otherwise. If ctx is done before, its error is returned.
+func (ctrl *Controller) ReconcileIndexes(ctx context.Context, ct *model.ContentType) error {
+	wanted, err := wantedIndexes(ct)
+	if err != nil {
+		return err
+	}
+	coll, key, started := ct.Collection, ct.ID.Hex(), time.Now()
+
+	ctrl.indexes.mu.Lock()
+	if ctrl.indexes.builds == nil {
+		ctrl.indexes.builds = make(map[string]*IndexBuild)
+		ctrl.indexes.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := ctrl.indexes.locks[coll]
+	if !ok {
+		lock = new(sync.Mutex)
+		ctrl.indexes.locks[coll] = lock
+	}
+	ctrl.indexes.builds[key] = &IndexBuild{State: IndexBuilding, StartedAt: started}
+	ctrl.indexes.mu.Unlock()
+
+	done := make(chan error, 1)
+	ctrl.goBackground(func(ctx context.Context) {
+		lock.Lock()
+		defer lock.Unlock()
+		err := ctrl.reconcileIndexes(ctx, coll, wanted)
+
+		finished := time.Now()
+		build := &IndexBuild{State: IndexReady, StartedAt: started, FinishedAt: &finished}
+		if err != nil {
+			build.State = IndexFailed
+			build.Error = err.Error()
+		}
+		ctrl.indexes.mu.Lock()
+		// A newer reconciliation of the content type reports its own state
+		if b, ok := ctrl.indexes.builds[key]; !ok || !b.StartedAt.After(started) {
+			ctrl.indexes.builds[key] = build
+		}
+		ctrl.indexes.mu.Unlock()
+		done <- err
+	})
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(indexBuildWait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Returns the state of all declared and existing indexes of the content type's collection
+func (ctrl *Controller) GetIndexState(ctx context.Context, ct *model.ContentType) (*IndexState, error) {
+	wanted, err := wantedIndexes(ct)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := ctrl.listIndexes(ctx, ct.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(IndexState)
+	ctrl.indexes.mu.Lock()
+	if b, ok := ctrl.indexes.builds[ct.ID.Hex()]; ok {
+		build := *b
+		state.Build = &build
+	}
+	ctrl.indexes.mu.Unlock()
+
+	for _, w := range wanted {
+		status := IndexStatus{Name: w.Name, Source: w.source, Status: IndexMissing}
+		if e, ok := existing[managedIndexName(w.Name)]; ok && sameIndex(e, w.IndexDefinition) {
+			status.Status = IndexReady
+		} else if state.Build != nil && state.Build.State != IndexReady {
+			status.Status = state.Build.State
+		}
+		state.Indexes = append(state.Indexes, status)
+	}
+	for name := range existing {
+		if _, ok := wanted[strings.TrimPrefix(name, model.ManagedIndexPrefix)]; !ok || !isManagedIndex(name) {
+			state.Indexes = append(state.Indexes, IndexStatus{Name: name, Status: IndexUnmanaged})
+		}
+	}
+	sort.Slice(state.Indexes, func(i, j int) bool { return state.Indexes[i].Name < state.Indexes[j].Name })
+	return state, nil
+}
+
+func (ctrl *Controller) reconcileIndexes(ctx context.Context, coll string, wanted map[string]wantedIndex) error {
+	existing, err := ctrl.listIndexes(ctx, coll)
+	if err != nil {
+		return err
+	}
+
+	// Drop indexes that are not declared anymore or have changed. Indexes created by hand are kept.
+	for name, e := range existing {
+		if !isManagedIndex(name) {
+			continue
+		}
+		declared := strings.TrimPrefix(name, model.ManagedIndexPrefix)
+		if w, ok := wanted[declared]; ok && sameIndex(e, w.IndexDefinition) {
+			delete(wanted, declared)
+			continue
+		}
+		if err := ctrl.store.Indexes.Drop(ctx, coll, name); err != nil {
+			return err
+		}
+	}
+
+	// Create missing indexes
+	for _, name := range sortedNames(wanted) {
+		idx := wanted[name].IndexDefinition
+		idx.Name = managedIndexName(name)
+		if err := ctrl.store.Indexes.Create(ctx, coll, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reconciles the indexes of the content type again after a failed update changed them.
+// The error is only logged, so that the error of the update is returned.
+func (ctrl *Controller) rollbackIndexes(ctx context.Context, ct *model.ContentType) {
+	if err := ctrl.ReconcileIndexes(ctx, ct); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str("collection", ct.Collection).Msg("Could not restore the indexes of the content type")
+	}
+}
+
+// Returns the name of the index on the collection for the declared or unique field index
+func managedIndexName(name string) string {
+	return model.ManagedIndexPrefix + name
+}
+
+// Returns true, if the index was created for the declaration of a content type
+func isManagedIndex(name string) bool {
+	return strings.HasPrefix(name, model.ManagedIndexPrefix)
+}
+
+// Returns the existing indexes of a collection by name
+func (ctrl *Controller) listIndexes(ctx context.Context, coll string) (map[string]model.IndexDefinition, error) {
+	list, err := ctrl.store.Indexes.List(ctx, coll)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]model.IndexDefinition)
+	for _, idx := range list {
+		existing[idx.Name] = idx
+	}
+	return existing, nil
+}
+
+// Returns the indexes that should exist on the collection of the content type by name:
+// The declared indexes and one unique index per unique field.
+func wantedIndexes(ct *model.ContentType) (map[string]wantedIndex, error) {
+	wanted := make(map[string]wantedIndex)
+	for _, f := range ct.UniqueFields() {
+		wanted[model.UniqueIndexPrefix+f] = wantedIndex{
+			IndexDefinition: model.IndexDefinition{
+				Name:          model.UniqueIndexPrefix + f,
+				Keys:          []model.IndexKey{{Field: f, Type: model.IndexAsc}},
+				Unique:        true,
+				PartialFilter: map[string]interface{}{f: bson.M{"$exists": true}},
+			},
+			source: "field_schema",
+		}
+	}
+
+	declared := append([]model.IndexDefinition(nil), ct.Indexes...)
+	if err := model.ValidateIndexes(declared); err != nil {
+		return nil, err
+	}
+	for _, d := range declared {
+		wanted[d.Name] = wantedIndex{IndexDefinition: d, source: "indexes"}
+	}
+	return wanted, nil
+}
+
+// Returns true if the existing index matches the wanted one
+func sameIndex(existing model.IndexDefinition, wanted model.IndexDefinition) bool {
+	if existing.Unique != wanted.Unique || existing.Sparse != wanted.Sparse {
+		return false
+	}
+	if (existing.ExpireAfterSeconds == nil) != (wanted.ExpireAfterSeconds == nil) ||
+		existing.ExpireAfterSeconds != nil && *existing.ExpireAfterSeconds != *wanted.ExpireAfterSeconds {
+		return false
+	}
+	e, w := canonicalKeys(existing.Keys), canonicalKeys(wanted.Keys)
+	if len(e) != len(w) {
+		return false
+	}
+	for i := range e {
+		if e[i] != w[i] {
+			return false
+		}
+	}
+	return sameValue(existing.PartialFilter, wanted.PartialFilter)
+}
+
+// Returns the keys with default types. The text keys are combined into one index key, so their order does not matter:
+// They are sorted by field at the position of the first text key.
+func canonicalKeys(keys []model.IndexKey) []model.IndexKey {
+	var out []model.IndexKey
+	var text []string
+	textAt := -1
+	for _, k := range keys {
+		switch k.Type {
+		case model.IndexText:
+			if textAt < 0 {
+				textAt = len(out)
+			}
+			text = append(text, k.Field)
+		case "":
+			out = append(out, model.IndexKey{Field: k.Field, Type: model.IndexAsc})
+		default:
+			out = append(out, k)
+		}
+	}
+	if textAt < 0 {
+		return out
+	}
+	sort.Strings(text)
+	rest := append([]model.IndexKey(nil), out[textAt:]...)
+	out = out[:textAt]
+	for _, f := range text {
+		out = append(out, model.IndexKey{Field: f, Type: model.IndexText})
+	}
+	return append(out, rest...)
+}
+
+// Compares two values after normalizing their types by a roundtrip through bson
+func sameValue(a interface{}, b interface{}) bool {
+	normalize := func(v interface{}) interface{} {
+		raw, err := bson.Marshal(bson.M{"v": v})
+		if err != nil {
+			return v
+		}
+		var out bson.M
+		if err := bson.Unmarshal(raw, &out); err != nil {
+			return v
+		}
+		return normalizeNumbers(out["v"])
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// Converts all numbers to float64, so that equal numbers of different bson types are equal
+func normalizeNumbers(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case bson.M:
+		if len(n) == 0 {
+			return nil
+		}
+		out := make(map[string]interface{})
+		for k, e := range n {
+			out[k] = normalizeNumbers(e)
+		}
+		return out
+	case bson.A:
+		var out []interface{}
+		for _, e := range n {
+			out = append(out, normalizeNumbers(e))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func sortedNames(m map[string]wantedIndex) []string {
+	var names []string
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/model"
+)
+
+// Returns the configured content locales. The first one is the default locale.
+// Set `content.locales` to a list like "en,de". Defaults to "en".
+func Locales() []string {
+	var locales []string
+	for _, l := range config.Get().Content.Locales {
+		if l = normalizeLocale(l); l != "" {
+			locales = append(locales, l)
+		}
+	}
+	if len(locales) == 0 {
+		locales = append(locales, "en")
+	}
+	return locales
+}
+
+// Returns the default locale, which is the last element of every fallback chain
+func DefaultLocale() string {
+	return Locales()[0]
+}
+
+// Returns the normalized locale and true, if it is supported:
+// It is one of the configured locales, has a fallback rule or is a regional variant of a configured language like `de-at`.
+func ParseLocale(l string) (string, bool) {
+	l = normalizeLocale(l)
+	if l == "" {
+		return "", false
+	}
+	if _, ok := localeFallbacks()[l]; ok {
+		return l, true
+	}
+	language := l
+	if i := strings.Index(l, "-"); i > 0 {
+		language = l[:i]
+	}
+	for _, locale := range Locales() {
+		if l == locale || language == locale {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// Returns the configured fallbacks per locale.
+// Set `content.locale_fallback` to rules like "de-at:de;de:en,fr".
+func localeFallbacks() map[string][]string {
+	fallbacks := make(map[string][]string)
+	for l, rule := range config.Get().Content.LocaleFallback {
+		locale := normalizeLocale(l)
+		for _, fb := range rule {
+			if fb = normalizeLocale(fb); fb != "" {
+				fallbacks[locale] = append(fallbacks[locale], fb)
+			}
+		}
+	}
+	return fallbacks
+}
+
+// Returns the ordered list of locales that are tried when resolving values for the requested locale:
+// The locale itself, its configured fallbacks (recursively), its base language and finally the default locale.
+func LocaleChain(locale string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	fallbacks := localeFallbacks()
+
+	var add func(l string)
+	add = func(l string) {
+		if l == "" || seen[l] {
+			return
+		}
+		seen[l] = true
+		chain = append(chain, l)
+		for _, fb := range fallbacks[l] {
+			add(fb)
+		}
+		if i := strings.Index(l, "-"); i > 0 {
+			add(l[:i])
+		}
+	}
+
+	add(normalizeLocale(locale))
+	add(DefaultLocale())
+	return chain
+}
+
+// Replaces the per-locale values of localized fields with the value of the first locale in the fallback chain that has one.
+// Fields without a value in any locale of the chain are set to `nil`.
+func ResolveLocale(ct *model.ContentType, entries []*model.Content, locale string) {
+	chain := LocaleChain(locale)
+	localized := ct.LocalizedFields()
+	for _, e := range entries {
+		for _, f := range localized {
+			values, ok := localeValues(e.Fields[f])
+			if !ok {
+				continue
+			}
+			e.Fields[f] = nil
+			for _, l := range chain {
+				if v, ok := values[l]; ok && v != nil && v != "" {
+					e.Fields[f] = v
+					break
+				}
+			}
+		}
+	}
+}
+
+// Returns the configured locales that have no value per localized field of the entry.
+// Fields without missing locales are omitted.
+func MissingLocales(ct *model.ContentType, entry *model.Content) map[string][]string {
+	missing := make(map[string][]string)
+	for _, f := range ct.LocalizedFields() {
+		values, _ := localeValues(entry.Fields[f])
+		for _, l := range Locales() {
+			if v, ok := values[l]; !ok || v == nil || v == "" {
+				missing[f] = append(missing[f], l)
+			}
+		}
+	}
+	return missing
+}
+
+// Brings input values of localized fields into the per-locale shape.
+// Plain values are stored for the provided locale or for the default locale, if `locale` is empty.
+// On updates (`merge` is true) dot-notation keys are used, so values of other locales are kept.
+func LocalizeInput(ct *model.ContentType, fields map[string]interface{}, locale string, merge bool) map[string]interface{} {
+	if fields == nil {
+		return nil
+	}
+	if locale = normalizeLocale(locale); locale == "" {
+		locale = DefaultLocale()
+	}
+	out := make(map[string]interface{})
+	for f, v := range fields {
+		if !ct.IsLocalized(f) {
+			out[f] = v
+			continue
+		}
+		values, ok := localeValues(v)
+		if !ok {
+			values = map[string]interface{}{locale: v}
+		}
+		normalized := make(map[string]interface{})
+		for l, lv := range values {
+			normalized[normalizeLocale(l)] = lv
+		}
+		if merge {
+			for l, lv := range normalized {
+				out[f+"."+l] = lv
+			}
+		} else {
+			out[f] = normalized
+		}
+	}
+	return out
+}
+
+// Returns the value of a localized field as map of locale to value
+func localeValues(v interface{}) (map[string]interface{}, bool) {
+	switch values := v.(type) {
+	case map[string]interface{}:
+		return values, true
+	default:
+		return nil, false
+	}
+}
+
+func normalizeLocale(l string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(l), "_", "-"))
+}
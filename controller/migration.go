@@ -0,0 +1,515 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Number of entries migrated at once, if the input does not specify it
+const defaultMigrationBatchSize = 500
+
+// Number of entries shown in the preview of a dry run
+const migrationPreviewSize = 10
+
+// Returned if a migration of the content type is already pending or running
+var ErrMigrationRunning = errors.New("another migration of this content type is not finished yet")
+
+// Returned if a field should be renamed to a field, that some entries already have
+var ErrMigrationTargetExists = errors.New("field already exists in some entries")
+
+// Fields of every content entry, that can not be migrated
+var presetFields = map[string]bool{
+	"_id": true, "created_at": true, "updated_at": true, "content_type_id": true,
+	"title": true, "published": true, "tags": true, "deleted_at": true,
+}
+
+// Result of a dry run
+type MigrationPreview struct {
+	Affected int64                   `json:"affected"`
+	Sample   []MigrationPreviewEntry `json:"sample"`
+}
+
+// Value of the migrated field of a single entry before and after the migration
+type MigrationPreviewEntry struct {
+	ID     primitive.ObjectID `json:"_id"`
+	Before interface{}        `json:"before"`
+	After  interface{}        `json:"after"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// Checks if the migration input is valid for the content type
+func ValidateMigration(ct *model.ContentType, input *model.MigrationInput) error {
+	if input.Field == "" {
+		return fmt.Errorf("'field' required")
+	}
+	if presetFields[input.Field] {
+		return fmt.Errorf("preset field %q can not be migrated", input.Field)
+	}
+	_, declared := ct.FieldSchema[input.Field]
+	switch input.Operation {
+	case model.MigrationRename:
+		if input.To == "" {
+			return fmt.Errorf("'to' required")
+		}
+		if _, ok := ct.FieldSchema[input.To]; ok || presetFields[input.To] {
+			return fmt.Errorf("field %q already exists", input.To)
+		}
+	case model.MigrationDrop:
+	case model.MigrationSetDefault:
+		if input.Value == nil {
+			return fmt.Errorf("'value' required")
+		}
+		if !declared && input.Type == "" {
+			return fmt.Errorf("'type' required for fields, that are not declared in field_schema")
+		}
+	case model.MigrationConvert:
+		if !isConvertibleType(input.Type) {
+			return fmt.Errorf("invalid 'type': %q", input.Type)
+		}
+	default:
+		return fmt.Errorf("invalid 'operation': %q", input.Operation)
+	}
+	return nil
+}
+
+// Returns the number of entries, that are affected by the migration, and the effect on some of them
+func (ctrl *Controller) PreviewMigration(ctx context.Context, ct *model.ContentType, input *model.MigrationInput) (*MigrationPreview, error) {
+	m := newMigration(ct, input)
+	if err := ctrl.checkMigrationTarget(ctx, m); err != nil {
+		return nil, err
+	}
+
+	affected, err := ctrl.store.Content.Count(ctx, ct.Collection, migrationSelector(m))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ctrl.store.Content.Find(ctx, ct.Collection, migrationSelector(m), store.SortBy("_id", false), store.Limit(migrationPreviewSize))
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+
+	preview := &MigrationPreview{Affected: affected, Sample: make([]MigrationPreviewEntry, 0)}
+	for _, e := range entries {
+		entry := MigrationPreviewEntry{ID: e.ID, Before: e.Fields[m.Field]}
+		switch m.Operation {
+		case model.MigrationRename:
+			entry.After = bson.M{m.To: e.Fields[m.Field]}
+		case model.MigrationDrop:
+			entry.After = nil
+		case model.MigrationSetDefault:
+			entry.After = m.Value
+		case model.MigrationConvert:
+			after, err := convertFieldValue(e.Fields[m.Field], m.Type)
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			entry.After = after
+		}
+		preview.Sample = append(preview.Sample, entry)
+	}
+	return preview, nil
+}
+
+// Creates a migration job and runs it in the background
+func (ctrl *Controller) StartMigration(ctx context.Context, ct *model.ContentType, input *model.MigrationInput) (*model.Migration, error) {
+	if active, err := ctrl.hasActiveMigration(ctx, ct.ID); err != nil {
+		return nil, err
+	} else if active {
+		return nil, ErrMigrationRunning
+	}
+
+	m := newMigration(ct, input)
+	if err := ctrl.checkMigrationTarget(ctx, m); err != nil {
+		return nil, err
+	}
+	// The unique index on `active` rejects the job, if another one was started since the check above
+	if _, err := ctrl.store.Migrations.Insert(ctx, m); errors.Is(err, store.ErrDuplicateKey) {
+		return nil, ErrMigrationRunning
+	} else if err != nil {
+		return nil, err
+	}
+	ctrl.audit(ctx, model.AuditMigrate, store.CollectionMigrations, m.ID.Hex(), nil, m)
+
+	// The changes of the migration are recorded with the source of the request.
+	// The job runs on a copy, so that the returned migration is not changed while it is written to the response.
+	source, job := AuditSourceFrom(ctx), *m
+	ctrl.goBackground(func(ctx context.Context) { ctrl.runMigration(WithAuditSource(ctx, source), &job) })
+	return m, nil
+}
+
+// Checks that no entry has the target field of a rename, because it would be overwritten.
+// Fields, that are not declared in the field schema, are still stored in the entries.
+func (ctrl *Controller) checkMigrationTarget(ctx context.Context, m *model.Migration) error {
+	if m.Operation != model.MigrationRename {
+		return nil
+	}
+	count, err := ctrl.store.Content.Count(ctx, m.Collection, bson.M{m.To: bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %q is set in %d entries", ErrMigrationTargetExists, m.To, count)
+	}
+	return nil
+}
+
+// Creates the unique index, that allows only one active migration per content type
+func (ctrl *Controller) InitMigrations(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return ctrl.store.Migrations.Init(ctx)
+}
+
+// Restarts migration jobs, that were interrupted by a shutdown
+func (ctrl *Controller) ResumeMigrations(ctx context.Context) error {
+	migrations, err := ctrl.GetMigrations(ctx, bson.M{"status": bson.M{"$in": bson.A{model.MigrationPending, model.MigrationRunning}}})
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, m := range migrations {
+		m := m
+		ctrl.goBackground(func(ctx context.Context) { ctrl.runMigration(ctx, m) })
+	}
+	return nil
+}
+
+// Return all migration jobs that match the filter, newest first
+func (ctrl *Controller) GetMigrations(ctx context.Context, filter interface{}) ([]*model.Migration, error) {
+
+	result, err := ctrl.store.Migrations.Find(ctx, filter, store.SortBy("created_at", true))
+	if err != nil {
+		return result, err
+	}
+
+	if len(result) == 0 {
+		return result, store.ErrNotFound
+	}
+
+	return result, nil
+}
+
+// Return a single migration job that matches the filter
+func (ctrl *Controller) GetMigration(ctx context.Context, filter interface{}) (*model.Migration, error) {
+
+	return ctrl.store.Migrations.FindOne(ctx, filter)
+}
+
+// Return the migration job with provided ID
+func (ctrl *Controller) GetMigrationById(ctx context.Context, id string) (*model.Migration, error) {
+	mID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return ctrl.GetMigration(ctx, bson.M{"_id": mID})
+}
+
+// Returns true if a migration of the content type is pending or running
+func (ctrl *Controller) hasActiveMigration(ctx context.Context, ctID primitive.ObjectID) (bool, error) {
+	_, err := ctrl.GetMigration(ctx, bson.M{
+		"content_type_id": ctID,
+		"status":          bson.M{"$in": bson.A{model.MigrationPending, model.MigrationRunning}},
+	})
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func newMigration(ct *model.ContentType, input *model.MigrationInput) *model.Migration {
+	m := &model.Migration{
+		ContentTypeID: ct.ID,
+		Collection:    ct.Collection,
+		Operation:     input.Operation,
+		Field:         input.Field,
+		To:            input.To,
+		Value:         input.Value,
+		Type:          input.Type,
+		BatchSize:     input.BatchSize,
+	}
+	if m.BatchSize <= 0 {
+		m.BatchSize = defaultMigrationBatchSize
+	}
+	m.Init()
+	active := ct.ID
+	m.Active = &active
+	return m
+}
+
+// Selects the entries, that are affected by the migration
+func migrationSelector(m *model.Migration) bson.M {
+	switch m.Operation {
+	case model.MigrationSetDefault:
+		return bson.M{m.Field: bson.M{"$exists": false}}
+	case model.MigrationConvert:
+		return bson.M{m.Field: bson.M{"$exists": true, "$ne": nil}}
+	default:
+		return bson.M{m.Field: bson.M{"$exists": true}}
+	}
+}
+
+// Migrates all affected entries in batches ordered by ID and saves the progress after each batch.
+// Entries, that can not be migrated, are skipped and reported. The field schema is updated after the last batch.
+// A migration interrupted by the cancellation of ctx keeps its state and is resumed on the next start.
+func (ctrl *Controller) runMigration(ctx context.Context, m *model.Migration) {
+	fail := func(err error) {
+		if ctx.Err() != nil {
+			logging.Ctx(ctx).Info().Str("migration", m.ID.Hex()).Int64("processed", m.Processed).Msg("Migration interrupted")
+			return
+		}
+		finished := time.Now()
+		m.Status = model.MigrationFailed
+		m.Error = err.Error()
+		m.FinishedAt = &finished
+		m.Active = nil
+		ctrl.saveMigration(ctx, m)
+	}
+
+	if m.Status == model.MigrationPending {
+		started := time.Now()
+		m.StartedAt = &started
+		m.Status = model.MigrationRunning
+		total, err := ctrl.store.Content.Count(ctx, m.Collection, migrationSelector(m))
+		if err != nil {
+			fail(err)
+			return
+		}
+		m.Total = total
+		if err := ctrl.saveMigration(ctx, m); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	for {
+		filter := migrationSelector(m)
+		if !m.LastID.IsZero() {
+			filter["_id"] = bson.M{"$gt": m.LastID}
+		}
+		entries, err := ctrl.store.Content.Find(ctx, m.Collection, filter, store.SortBy("_id", false), store.Limit(int64(m.BatchSize)))
+		if err == store.ErrNotFound || err == nil && len(entries) == 0 {
+			break
+		}
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		var updates []store.EntryUpdate
+		for _, e := range entries {
+			update, err := migrationUpdate(m, e)
+			if err != nil {
+				m.FailedCount++
+				if len(m.Failures) < model.MaxMigrationFailures {
+					m.Failures = append(m.Failures, model.MigrationFailure{ID: e.ID, Error: err.Error()})
+				}
+				continue
+			}
+			updates = append(updates, store.EntryUpdate{Filter: bson.M{"_id": e.ID}, Update: update})
+		}
+		if len(updates) > 0 {
+			result, err := ctrl.store.Content.BulkUpdate(ctx, m.Collection, updates)
+			if err != nil {
+				fail(err)
+				return
+			}
+			m.Modified += result.ModifiedCount
+		}
+		m.Processed += int64(len(entries))
+		m.LastID = entries[len(entries)-1].ID
+		if err := ctrl.saveMigration(ctx, m); err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	if err := ctrl.migrateFieldSchema(ctx, m); err != nil {
+		fail(err)
+		return
+	}
+	finished := time.Now()
+	m.Status = model.MigrationCompleted
+	m.FinishedAt = &finished
+	m.Active = nil
+	ctrl.saveMigration(ctx, m)
+}
+
+// Returns the update for a single entry
+func migrationUpdate(m *model.Migration, e *model.Content) (bson.M, error) {
+	switch m.Operation {
+	case model.MigrationRename:
+		return bson.M{"$rename": bson.M{m.Field: m.To}}, nil
+	case model.MigrationDrop:
+		return bson.M{"$unset": bson.M{m.Field: ""}}, nil
+	case model.MigrationSetDefault:
+		return bson.M{"$set": bson.M{m.Field: m.Value}}, nil
+	case model.MigrationConvert:
+		v, err := convertFieldValue(e.Fields[m.Field], m.Type)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$set": bson.M{m.Field: v}}, nil
+	default:
+		return nil, fmt.Errorf("invalid operation: %s", m.Operation)
+	}
+}
+
+// Applies the migration to the field schema of the content type and reconciles its indexes
+func (ctrl *Controller) migrateFieldSchema(ctx context.Context, m *model.Migration) error {
+	ct, err := ctrl.store.ContentTypes.FindOne(ctx, bson.M{"_id": m.ContentTypeID})
+	if err != nil {
+		return err
+	}
+	schema := make(map[string]interface{})
+	for k, v := range ct.FieldSchema {
+		schema[k] = v
+	}
+
+	switch m.Operation {
+	case model.MigrationRename:
+		if def, ok := schema[m.Field]; ok {
+			schema[m.To] = def
+			delete(schema, m.Field)
+		}
+	case model.MigrationDrop:
+		delete(schema, m.Field)
+	case model.MigrationSetDefault:
+		if _, ok := schema[m.Field]; !ok {
+			schema[m.Field] = m.Type
+		}
+	case model.MigrationConvert:
+		if def, ok := schema[m.Field].(map[string]interface{}); ok {
+			converted := make(map[string]interface{})
+			for k, v := range def {
+				converted[k] = v
+			}
+			converted["type"] = m.Type
+			schema[m.Field] = converted
+		} else {
+			schema[m.Field] = m.Type
+		}
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: bson.M{"field_schema": schema}},
+		{Key: "$currentDate", Value: bson.M{"updated_at": true}},
+	}
+	if _, err := ctrl.store.ContentTypes.Update(ctx, bson.M{"_id": ct.ID}, update); err != nil {
+		return err
+	}
+	before := *ct
+	ct.FieldSchema = schema
+	ctrl.audit(ctx, model.AuditUpdate, store.CollectionContentTypes, ct.ID.Hex(), &before, ct)
+	return ctrl.ReconcileIndexes(ctx, ct)
+}
+
+func (ctrl *Controller) saveMigration(ctx context.Context, m *model.Migration) error {
+	return ctrl.store.Migrations.Replace(ctx, m)
+}
+
+func isConvertibleType(t string) bool {
+	switch t {
+	case "string", "int", "integer", "float", "float64", "number", "bool", "boolean", "time.Time", "date", "datetime":
+		return true
+	default:
+		return false
+	}
+}
+
+// Converts a field value to the provided type. Values of localized fields are converted per locale.
+func convertFieldValue(v interface{}, fieldType string) (interface{}, error) {
+	if values, ok := localeValues(v); ok {
+		converted := make(map[string]interface{})
+		for l, lv := range values {
+			c, err := convertFieldValue(lv, fieldType)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", l, err.Error())
+			}
+			converted[l] = c
+		}
+		return converted, nil
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case "string":
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case primitive.DateTime:
+			return val.Time().UTC().Format(time.RFC3339), nil
+		default:
+			return fmt.Sprint(val), nil
+		}
+	case "int", "integer":
+		f, err := toNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(f), nil
+	case "float", "float64", "number":
+		return toNumber(v)
+	case "bool", "boolean":
+		switch val := v.(type) {
+		case bool:
+			return val, nil
+		case string:
+			return strconv.ParseBool(val)
+		default:
+			f, err := toNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			return f != 0, nil
+		}
+	case "time.Time", "date", "datetime":
+		switch val := v.(type) {
+		case primitive.DateTime:
+			return val, nil
+		case string:
+			for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+				if t, err := time.Parse(layout, val); err == nil {
+					return t, nil
+				}
+			}
+			return nil, fmt.Errorf("%q is not a date", val)
+		default:
+			return nil, fmt.Errorf("%v is not a date", v)
+		}
+	default:
+		return nil, fmt.Errorf("invalid type: %s", fieldType)
+	}
+}
+
+func toNumber(v interface{}) (float64, error) {
+	if f, ok := toFloat(v); ok {
+		return f, nil
+	}
+	switch val := v.(type) {
+	case string:
+		return strconv.ParseFloat(val, 64)
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+}
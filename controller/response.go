@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/responsecache"
+	"github.com/D-Bald/fiber-backend/store"
+)
+
+// Cached responses of anonymous content requests. Writes of content purge the responses of its collection,
+// writes of content types purge all responses.
+type responses struct {
+	cache responsecache.Cache
+}
+
+// Caches the responses of anonymous content requests in c. It has to be called before the server starts.
+func (ctrl *Controller) UseResponseCache(c responsecache.Cache) {
+	ctrl.responses.cache = c
+}
+
+// Returns the cache of the responses, nil if responses are not cached
+func (ctrl *Controller) ResponseCache() responsecache.Cache {
+	return ctrl.responses.cache
+}
+
+// Removes the cached responses with one of the tags
+func (ctrl *Controller) PurgeResponses(ctx context.Context, tags ...string) error {
+	if ctrl.responses.cache == nil {
+		return nil
+	}
+	return ctrl.responses.cache.Purge(ctx, tags...)
+}
+
+// Purges the responses of the collection after its content changed, an empty collection purges all responses.
+// Failures are only logged, the responses expire after their TTL.
+func (ctrl *Controller) contentChanged(ctx context.Context, coll string) {
+	tag := responsecache.TagAll
+	if coll != "" {
+		tag = responsecache.CollectionTag(coll)
+	}
+	if err := ctrl.PurgeResponses(ctx, tag); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str("collection", coll).Msg("Could not purge the cached responses")
+	}
+}
+
+// Store of the content, that purges the cached responses of the collection after every write
+type invalidatingContent struct {
+	store.ContentStore
+	ctrl *Controller
+}
+
+func (s invalidatingContent) Insert(ctx context.Context, coll string, content *model.Content) (*store.InsertResult, error) {
+	defer s.ctrl.contentChanged(ctx, coll)
+	return s.ContentStore.Insert(ctx, coll, content)
+}
+
+func (s invalidatingContent) Update(ctx context.Context, coll string, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	defer s.ctrl.contentChanged(ctx, coll)
+	return s.ContentStore.Update(ctx, coll, filter, update)
+}
+
+func (s invalidatingContent) Delete(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	defer s.ctrl.contentChanged(ctx, coll)
+	return s.ContentStore.Delete(ctx, coll, filter)
+}
+
+func (s invalidatingContent) DeleteMany(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	defer s.ctrl.contentChanged(ctx, coll)
+	return s.ContentStore.DeleteMany(ctx, coll, filter)
+}
+
+func (s invalidatingContent) BulkUpdate(ctx context.Context, coll string, updates []store.EntryUpdate) (*store.UpdateResult, error) {
+	defer s.ctrl.contentChanged(ctx, coll)
+	return s.ContentStore.BulkUpdate(ctx, coll, updates)
+}
+
+func (s invalidatingContent) Drop(ctx context.Context, coll string) error {
+	defer s.ctrl.contentChanged(ctx, coll)
+	return s.ContentStore.Drop(ctx, coll)
+}
+
+func (s invalidatingContent) Rename(ctx context.Context, from string, to string) error {
+	defer s.ctrl.contentChanged(ctx, to)
+	defer s.ctrl.contentChanged(ctx, from)
+	return s.ContentStore.Rename(ctx, from, to)
+}
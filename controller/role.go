@@ -2,134 +2,109 @@ package controller
 
 import (
 	"context"
-	"time"
 
-	"github.com/D-Bald/fiber-backend/database"
 	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Init roles: 'admin' and 'user'
 var (
-	defaultRole = bson.D{
-		{Key: "tag", Value: "default"},
-		{Key: "name", Value: "User"},
+	defaultRole = model.Role{
+		Tag:  "default",
+		Name: "User",
 	}
-	admin = bson.D{
-		{Key: "tag", Value: "admin"},
-		{Key: "name", Value: "Administrator"},
+	admin = model.Role{
+		Tag:  "admin",
+		Name: "Administrator",
 	}
 )
 
 // Initialize collection roles with 'admin' and 'user'
-func InitRoles() error {
-	_, err := GetRoleByTag("default")
-	if err != nil && err == mongo.ErrNoDocuments {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if _, err := database.DB.Collection("roles").InsertOne(ctx, defaultRole); err != nil {
-			return err
+func (ctrl *Controller) InitRoles(ctx context.Context) error {
+	for _, preset := range []model.Role{defaultRole, admin} {
+		_, err := ctrl.GetRoleByTag(ctx, preset.Tag)
+		if err != nil && err == store.ErrNotFound {
+			r := preset
+			r.Init()
+			if _, err := ctrl.store.Roles.Insert(ctx, &r); err != nil {
+				return err
+			}
 		}
 	}
-	_, err = GetRoleByTag("admin")
-	if err != nil && err == mongo.ErrNoDocuments {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if _, err := database.DB.Collection("roles").InsertOne(ctx, admin); err != nil {
-			return err
-		}
-	}
-	err = nil
-	return err
+	ctrl.seeded(SeedRoles)
+	return nil
 }
 
 // Return all roles that match the filter
-func GetRoles(filter interface{}) ([]*model.Role, error) {
-	// A slice of tasks for storing the decoded documents
-	var result []*model.Role
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	cursor, err := database.DB.Collection("roles").Find(ctx, filter)
+func (ctrl *Controller) GetRoles(ctx context.Context, filter interface{}) ([]*model.Role, error) {
+	result, err := ctrl.store.Roles.Find(ctx, filter)
 	if err != nil {
 		return result, err
 	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var r model.Role
-		err := cursor.Decode(&r)
-		if err != nil {
-			return result, err
-		}
-
-		result = append(result, &r)
-	}
-
-	if err := cursor.Err(); err != nil {
-		return result, err
-	}
 
 	if len(result) == 0 {
-		return result, mongo.ErrNoDocuments
+		return result, store.ErrNotFound
 	}
 
 	return result, nil
 }
 
 // Returns first role that matches the filter
-func GetRole(filter interface{}) (*model.Role, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	var r *model.Role
-	err := database.DB.Collection("roles").FindOne(ctx, filter).Decode(&r)
-	if err != nil {
-		return nil, err
-	}
-	return r, nil
+func (ctrl *Controller) GetRole(ctx context.Context, filter interface{}) (*model.Role, error) {
+	return ctrl.store.Roles.FindOne(ctx, filter)
 }
 
 // Returns the role with provided role name
-func GetRoleByName(name string) (*model.Role, error) {
+func (ctrl *Controller) GetRoleByName(ctx context.Context, name string) (*model.Role, error) {
+	if cacheTTL() > 0 {
+		return ctrl.cachedRole(ctx, func(r *model.Role) bool { return r.Name == name })
+	}
 	filter := bson.M{"name": name}
-	return GetRole(filter)
+	return ctrl.GetRole(ctx, filter)
 }
 
 // Return the role with provided role tag
-func GetRoleByTag(tag string) (*model.Role, error) {
+func (ctrl *Controller) GetRoleByTag(ctx context.Context, tag string) (*model.Role, error) {
+	if cacheTTL() > 0 {
+		return ctrl.cachedRole(ctx, func(r *model.Role) bool { return r.Tag == tag })
+	}
 	filter := bson.M{"tag": tag}
-	return GetRole(filter)
+	return ctrl.GetRole(ctx, filter)
 }
 
 // Returns the role Object with provided ID
-func GetRoleById(id string) (*model.Role, error) {
+func (ctrl *Controller) GetRoleById(ctx context.Context, id string) (*model.Role, error) {
 	rID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
+	if cacheTTL() > 0 {
+		return ctrl.cachedRole(ctx, func(r *model.Role) bool { return r.ID == rID })
+	}
 	filter := bson.M{"_id": rID}
-	return GetRole(filter)
+	return ctrl.GetRole(ctx, filter)
 }
 
 // Insert role with provided Parameters in DB
-func CreateRole(r *model.Role) (*mongo.InsertOneResult, error) {
+func (ctrl *Controller) CreateRole(ctx context.Context, r *model.Role) (*store.InsertResult, error) {
 	// Initialize metadata
 	r.Init()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	return database.DB.Collection("roles").InsertOne(ctx, r)
+	result, err := ctrl.store.Roles.Insert(ctx, r)
+	if err != nil {
+		return result, err
+	}
+	ctrl.audit(ctx, model.AuditCreate, store.CollectionRoles, r.ID.Hex(), nil, r)
+	return result, nil
 }
 
 // Update role with provided parameters
-func UpdateRole(id string, input *model.Role) (*mongo.UpdateResult, error) {
+func (ctrl *Controller) UpdateRole(ctx context.Context, id string, input *model.Role) (*store.UpdateResult, error) {
 	rID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return new(mongo.UpdateResult), err
+		return new(store.UpdateResult), err
 	}
 	filter := bson.M{"_id": rID}
 	update := bson.D{
@@ -138,15 +113,18 @@ func UpdateRole(id string, input *model.Role) (*mongo.UpdateResult, error) {
 			"updated_at": true},
 		},
 	}
+	find := func() (interface{}, error) {
+		return ctrl.store.Roles.FindOne(ctx, filter)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	return database.DB.Collection("roles").UpdateOne(ctx, filter, update)
+	return ctrl.auditedUpdate(ctx, model.AuditUpdate, store.CollectionRoles, rID, find, func() (*store.UpdateResult, error) {
+		return ctrl.store.Roles.Update(ctx, filter, update)
+	})
 }
 
-// Delete role with provided filter in DB
-func DeleteRole(id string) (*mongo.DeleteResult, error) {
+// Delete role with provided ID in DB. It is removed from all users and content type permissions first.
+// Returns the first error of these updates, the role is kept then.
+func (ctrl *Controller) DeleteRole(ctx context.Context, id string) (*store.DeleteResult, error) {
 	rID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
@@ -154,51 +132,37 @@ func DeleteRole(id string) (*mongo.DeleteResult, error) {
 
 	// Get role properties
 	filter := bson.M{"_id": rID}
-	role, err := GetRole(filter)
+	role, err := ctrl.GetRole(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
-	// Delete role from all users whose `roles` field contain it
-	users, err := GetUsers(bson.M{"roles": role.ID})
-	if err != nil && err != mongo.ErrNoDocuments {
-		return nil, err
-	}
-	if err != mongo.ErrNoDocuments {
-		for _, u := range users {
-			DeleteRoleFromUser(rID, u)
-		}
-	}
-	// Delete role from all content type permissions. It is possible, that one ore more permission have no roles left after.
-	allContentTypes, err := GetContentTypes(bson.M{})
-	if err != nil && err != mongo.ErrNoDocuments {
-		return nil, err
-	}
-	for _, u := range allContentTypes {
-		DeleteRoleFromPermissions(rID, u)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	return database.DB.Collection("roles").DeleteOne(ctx, filter)
+	return ctrl.deleteRole(ctx, role)
 }
 
 // Return true if the a role with given string role name exists
-func IsValidRole(role string) bool {
-	if _, err := GetRoleByName(role); err != nil {
+func (ctrl *Controller) IsValidRole(ctx context.Context, role string) bool {
+	if _, err := ctrl.GetRoleByName(ctx, role); err != nil {
 		return false
 	} else {
 		return true
 	}
 }
 
-// Returns slice of role names of provided role ObjectsIDs
-func GetRoleNames(roleIDs []primitive.ObjectID) ([]string, error) {
+// Returns slice of role names of provided role ObjectsIDs.
+// The roles are taken from the cache or loaded with a single query.
+func (ctrl *Controller) GetRoleNames(ctx context.Context, roleIDs []primitive.ObjectID) ([]string, error) {
+	if len(roleIDs) == 0 {
+		return nil, nil
+	}
+	roles, err := ctrl.rolesByID(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
 	var output []string
 	for _, r := range roleIDs {
-		rObj, err := GetRoleById(r.Hex())
-		if err != nil {
-			return nil, err
+		rObj, ok := roles[r]
+		if !ok {
+			return nil, store.ErrNotFound
 		}
 		output = append(output, rObj.Name)
 	}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sets all slug fields of a new content entry.
+// Slugs that are provided in the input are normalized, missing ones are generated from their source field.
+func (ctrl *Controller) setSlugs(ctx context.Context, ct *model.ContentType, content *model.Content) error {
+	for _, f := range ct.SlugFields() {
+		source, _ := content.Fields[f].(string)
+		if source == "" {
+			source = slugSource(ct, content, f)
+		}
+		slug, err := ctrl.uniqueSlug(ctx, ct.Collection, f, source, content.ID)
+		if err != nil {
+			return err
+		}
+		if content.Fields == nil {
+			content.Fields = make(map[string]interface{})
+		}
+		content.Fields[f] = slug
+	}
+	return nil
+}
+
+// Normalizes slugs that are explicitly set on updates of the content entry with provided ID.
+func (ctrl *Controller) updateSlugs(ctx context.Context, ct *model.ContentType, id primitive.ObjectID, input *model.ContentUpdate) error {
+	for _, f := range ct.SlugFields() {
+		source, ok := input.Fields[f].(string)
+		if !ok || source == "" {
+			continue
+		}
+		slug, err := ctrl.uniqueSlug(ctx, ct.Collection, f, source, id)
+		if err != nil {
+			return err
+		}
+		input.Fields[f] = slug
+	}
+	return nil
+}
+
+// Returns the value of the source field of a slug as string. Localized sources use the default locale.
+func slugSource(ct *model.ContentType, content *model.Content, field string) string {
+	from := model.ParseFieldDefinition(ct.FieldSchema[field]).From
+	if from == "title" {
+		return content.Title
+	}
+	v := content.Fields[from]
+	if values, ok := localeValues(v); ok {
+		v = values[DefaultLocale()]
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// Returns a slug of the value that is not used by any other entry in the collection.
+// If the slug is taken, a number is appended like "my-title-2".
+func (ctrl *Controller) uniqueSlug(ctx context.Context, coll string, field string, value string, id primitive.ObjectID) (string, error) {
+	base := utils.Slugify(value)
+	if base == "" {
+		base = id.Hex()
+	}
+
+	filter := bson.M{
+		field: bson.M{"$regex": fmt.Sprintf("^%s(-[0-9]+)?$", regexp.QuoteMeta(base))},
+		"_id": bson.M{"$ne": id},
+	}
+	entries, err := ctrl.store.Content.Find(ctx, coll, filter)
+	if err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool)
+	for _, e := range entries {
+		if s, ok := e.Fields[field].(string); ok {
+			taken[s] = true
+		}
+	}
+
+	slug := base
+	for i := 2; taken[slug]; i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	return slug, nil
+}
@@ -0,0 +1,470 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Supported formats for import and export of content entries
+const (
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+)
+
+// Separator of tags in CSV files
+const csvTagSeparator = ";"
+
+// Returns true if the format is supported for import and export
+func IsValidTransferFormat(format string) bool {
+	return format == FormatNDJSON || format == FormatJSON || format == FormatCSV
+}
+
+// Calls fn for every content entry in collection coll that matches the filter. Entries in the trash are excluded.
+// Entries are decoded one by one, so that large collections can be streamed.
+func (ctrl *Controller) StreamContent(ctx context.Context, coll string, filter interface{}, fn func(*model.Content) error) error {
+	return ctrl.store.Content.Stream(ctx, coll, notTrashed(filter), fn)
+}
+
+// Writes all content entries of the content type, that match the filter, in the requested format to w.
+// If a locale is provided, localized fields are resolved.
+func (ctrl *Controller) ExportContent(ctx context.Context, w io.Writer, format string, ct *model.ContentType, filter interface{}, locale string) error {
+	resolve := func(con *model.Content) {
+		if locale != "" {
+			ResolveLocale(ct, []*model.Content{con}, locale)
+		}
+	}
+
+	switch format {
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		return ctrl.StreamContent(ctx, ct.Collection, filter, func(con *model.Content) error {
+			resolve(con)
+			return enc.Encode(con)
+		})
+	case FormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		first := true
+		err := ctrl.StreamContent(ctx, ct.Collection, filter, func(con *model.Content) error {
+			resolve(con)
+			b, err := json.Marshal(con)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err = w.Write(b)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "]")
+		return err
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		columns := csvColumns(ct, locale == "")
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+		err := ctrl.StreamContent(ctx, ct.Collection, filter, func(con *model.Content) error {
+			resolve(con)
+			return cw.Write(toCSVRecord(con, columns))
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// Options of a content import
+type ImportOptions struct {
+	Format string
+	DryRun bool
+	// Field to match existing entries for upserts: "_id" or a unique field. Empty to always create new entries.
+	UpsertBy string
+	// Locale of plain values of localized fields
+	Locale string
+}
+
+// Result of the import of a single row
+type ImportRowResult struct {
+	Row    int          `json:"row"`
+	ID     string       `json:"_id,omitempty"`
+	Action string       `json:"action"` // "created", "updated" or "failed"
+	Error  string       `json:"error,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// Result of a content import
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+// Row of an import: the parsed content entry or the error, that prevented parsing it
+type ImportRow struct {
+	Content *model.Content
+	Err     error
+}
+
+// Parses content entries from body in the provided format.
+// Malformed rows are returned with their error, so that they are reported and the other rows are imported.
+// An error is only returned, if the body as a whole can not be read, like a JSON body, that is not an array.
+func ParseImport(format string, ct *model.ContentType, body []byte) ([]ImportRow, error) {
+	var rows []ImportRow
+	switch format {
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			con := new(model.Content)
+			if err := json.Unmarshal(scanner.Bytes(), con); err != nil {
+				rows = append(rows, ImportRow{Err: fmt.Errorf("line %d: %s", line, err.Error())})
+				continue
+			}
+			rows = append(rows, ImportRow{Content: con})
+		}
+		return rows, scanner.Err()
+	case FormatJSON:
+		var raw []json.RawMessage
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, err
+		}
+		for _, r := range raw {
+			con := new(model.Content)
+			if err := json.Unmarshal(r, con); err != nil {
+				rows = append(rows, ImportRow{Err: err})
+				continue
+			}
+			rows = append(rows, ImportRow{Content: con})
+		}
+		return rows, nil
+	case FormatCSV:
+		r := csv.NewReader(bytes.NewReader(body))
+		header, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defs := ct.FieldDefinitions()
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				return rows, nil
+			}
+			var con *model.Content
+			if err == nil {
+				con, err = fromCSVRecord(header, rec, defs)
+			}
+			rows = append(rows, ImportRow{Content: con, Err: err})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// Validates and inserts or updates all rows in the collection of the content type.
+// Malformed and invalid rows are reported and skipped. In a dry run nothing is written.
+func (ctrl *Controller) ImportContent(ctx context.Context, ct *model.ContentType, rows []ImportRow, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{DryRun: opts.DryRun, Rows: make([]ImportRowResult, 0, len(rows))}
+	for i, row := range rows {
+		var result ImportRowResult
+		if row.Err != nil {
+			result = ImportRowResult{Action: "failed", Error: row.Err.Error()}
+		} else {
+			result = ctrl.importRow(ctx, ct, row.Content, opts)
+		}
+		result.Row = i + 1
+		switch result.Action {
+		case "created":
+			report.Created++
+		case "updated":
+			report.Updated++
+		default:
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+	if !opts.DryRun {
+		ctrl.audit(ctx, model.AuditImport, ct.Collection, "", nil, bson.M{"created": report.Created, "updated": report.Updated, "failed": report.Failed})
+	}
+	return report, nil
+}
+
+func (ctrl *Controller) importRow(ctx context.Context, ct *model.ContentType, row *model.Content, opts ImportOptions) ImportRowResult {
+	failed := func(err error) ImportRowResult {
+		return ImportRowResult{ID: hexID(row.ID), Action: "failed", Error: err.Error()}
+	}
+
+	if errs := ValidateContent(ct, row); len(errs) > 0 {
+		return ImportRowResult{ID: hexID(row.ID), Action: "failed", Error: "validation failed", Fields: errs}
+	}
+
+	// Find existing entry to update
+	var existing *model.Content
+	if opts.UpsertBy != "" {
+		var key interface{}
+		if opts.UpsertBy == "_id" {
+			if !row.ID.IsZero() {
+				key = row.ID
+			}
+		} else {
+			key = row.Fields[opts.UpsertBy]
+		}
+		if key != nil {
+			e, err := ctrl.GetContentEntry(ctx, ct.Collection, bson.M{opts.UpsertBy: key})
+			if err != nil && err != store.ErrNotFound {
+				return failed(err)
+			}
+			existing = e
+		}
+	}
+
+	if existing != nil {
+		if opts.DryRun {
+			if err := ctrl.checkUniqueFields(ctx, ct, row, existing.ID); err != nil {
+				return failed(err)
+			}
+			return ImportRowResult{ID: hexID(existing.ID), Action: "updated"}
+		}
+		update := &model.ContentUpdate{
+			Title:     row.Title,
+			Published: row.Published,
+			Tags:      row.Tags,
+			Fields:    LocalizeInput(ct, row.Fields, opts.Locale, true),
+		}
+		if _, err := ctrl.UpdateContent(ctx, ct.Collection, existing.ID.Hex(), update); err != nil {
+			return failed(err)
+		}
+		return ImportRowResult{ID: hexID(existing.ID), Action: "updated"}
+	}
+
+	// Insert new entry and keep provided ID and creation date
+	id, created := row.ID, row.CreatedAt
+	row.Init(*ct)
+	if !id.IsZero() {
+		row.ID = id
+	}
+	if !created.IsZero() {
+		row.CreatedAt = created
+	}
+	row.Fields = LocalizeInput(ct, row.Fields, opts.Locale, false)
+	if opts.DryRun {
+		if err := ctrl.checkUniqueFields(ctx, ct, row, row.ID); err != nil {
+			return failed(err)
+		}
+		return ImportRowResult{ID: hexID(row.ID), Action: "created"}
+	}
+	if err := ctrl.setSlugs(ctx, ct, row); err != nil {
+		return failed(err)
+	}
+	if _, err := ctrl.store.Content.Insert(ctx, ct.Collection, row); err != nil {
+		return failed(err)
+	}
+	ctrl.audit(ctx, model.AuditCreate, ct.Collection, row.ID.Hex(), nil, row)
+	return ImportRowResult{ID: hexID(row.ID), Action: "created"}
+}
+
+// Returns the hex representation of the ID or an empty string for the zero value
+func hexID(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+// Returns an error if another entry than the one with provided ID uses the value of a unique field of the row.
+// Slugs are not checked, because they are deduplicated on write.
+func (ctrl *Controller) checkUniqueFields(ctx context.Context, ct *model.ContentType, row *model.Content, id primitive.ObjectID) error {
+	defs := ct.FieldDefinitions()
+	for _, f := range ct.UniqueFields() {
+		if defs[f].Type == model.FieldTypeSlug {
+			continue
+		}
+		var v interface{} = row.Fields[f]
+		if f == "title" {
+			v = row.Title
+		}
+		if v == nil {
+			continue
+		}
+		// entries in the trash keep their values in the unique index
+		_, err := ctrl.store.Content.FindOne(ctx, ct.Collection, bson.M{f: v, "_id": bson.M{"$ne": id}})
+		if err == nil {
+			return fmt.Errorf("value of unique field %q already in use", f)
+		}
+		if err != store.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the CSV header: preset fields and custom fields in alphabetical order.
+// Localized fields get one column per locale like "description.de", if `perLocale` is true.
+func csvColumns(ct *model.ContentType, perLocale bool) []string {
+	columns := []string{"_id", "created_at", "updated_at", "title", "published", "tags"}
+	var custom []string
+	for f, def := range ct.FieldDefinitions() {
+		if def.Localized && perLocale {
+			for _, l := range Locales() {
+				custom = append(custom, f+"."+l)
+			}
+		} else {
+			custom = append(custom, f)
+		}
+	}
+	sort.Strings(custom)
+	return append(columns, custom...)
+}
+
+func toCSVRecord(con *model.Content, columns []string) []string {
+	rec := make([]string, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "_id":
+			rec[i] = con.ID.Hex()
+		case "created_at":
+			rec[i] = con.CreatedAt.Format(time.RFC3339)
+		case "updated_at":
+			rec[i] = con.UpdatedAt.Format(time.RFC3339)
+		case "title":
+			rec[i] = con.Title
+		case "published":
+			if con.Published != nil {
+				rec[i] = strconv.FormatBool(*con.Published)
+			}
+		case "tags":
+			rec[i] = strings.Join(con.Tags, csvTagSeparator)
+		default:
+			parts := strings.SplitN(col, ".", 2)
+			v := con.Fields[parts[0]]
+			if values, ok := localeValues(v); ok && len(parts) == 2 {
+				v = values[parts[1]]
+			}
+			rec[i] = csvValue(v)
+		}
+	}
+	return rec
+}
+
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case primitive.DateTime:
+		return val.Time().Format(time.RFC3339)
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	}
+}
+
+// Makes a content entry from a CSV record. Values of custom fields are converted to the declared type.
+// Columns of the form "field.locale" set the value of a localized field for one locale.
+func fromCSVRecord(header []string, rec []string, defs map[string]model.FieldDefinition) (*model.Content, error) {
+	con := &model.Content{Fields: make(map[string]interface{})}
+	for i, col := range header {
+		if i >= len(rec) || rec[i] == "" {
+			continue
+		}
+		val := rec[i]
+		switch col {
+		case "_id":
+			id, err := primitive.ObjectIDFromHex(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid _id: %s", err.Error())
+			}
+			con.ID = id
+		case "created_at":
+			t, err := time.Parse(time.RFC3339, val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid created_at: %s", err.Error())
+			}
+			con.CreatedAt = t
+		case "updated_at":
+			// set automatically
+		case "title":
+			con.Title = val
+		case "published":
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid published: %s", err.Error())
+			}
+			con.Published = &b
+		case "tags":
+			con.Tags = strings.Split(val, csvTagSeparator)
+		default:
+			parts := strings.SplitN(col, ".", 2)
+			def := defs[parts[0]]
+			if len(parts) == 2 && def.Localized {
+				values, ok := con.Fields[parts[0]].(map[string]interface{})
+				if !ok {
+					values = make(map[string]interface{})
+					con.Fields[parts[0]] = values
+				}
+				values[parts[1]] = fromCSVValue(def.Type, val)
+			} else {
+				con.Fields[col] = fromCSVValue(def.Type, val)
+			}
+		}
+	}
+	return con, nil
+}
+
+// Converts a CSV value to the field type. Values, that can not be converted, are kept as string to be reported by the validation.
+func fromCSVValue(fieldType string, val string) interface{} {
+	switch fieldType {
+	case "int", "integer", "float", "float64", "number":
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	case "bool", "boolean":
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return val
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Time between two automatic purges of the trash
+const trashPurgeInterval = time.Hour
+
+// Extends the filter to exclude documents in the trash
+func notTrashed(filter interface{}) bson.M {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{"deleted_at": bson.M{"$exists": false}}}}
+}
+
+// Extends the filter to match only documents in the trash
+func trashed(filter interface{}) bson.M {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	return bson.M{"$and": bson.A{filter, bson.M{"deleted_at": bson.M{"$exists": true}}}}
+}
+
+// Time content entries and content types stay in the trash. Zero disables the automatic purge.
+func TrashRetention() time.Duration {
+	return time.Duration(config.Get().Content.TrashRetentionDays) * 24 * time.Hour
+}
+
+// Permanently deletes all content types and content entries, that were moved to the trash before the provided time
+func (ctrl *Controller) PurgeTrash(ctx context.Context, before time.Time) error {
+	expired := bson.M{"deleted_at": bson.M{"$lt": before}}
+
+	contentTypes, err := ctrl.findContentTypes(ctx, expired)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, ct := range contentTypes {
+		if _, err := ctrl.purgeContentType(ctx, ct); err != nil {
+			return err
+		}
+	}
+
+	contentTypes, err = ctrl.findContentTypes(ctx, bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	for _, ct := range contentTypes {
+		if err := ctrl.purgeContent(ctx, ct.Collection, expired); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Permanently deletes the entries of the collection, that match the filter, and records their purge in the audit log
+func (ctrl *Controller) purgeContent(ctx context.Context, coll string, filter interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	entries, err := ctrl.store.Content.Find(ctx, coll, filter)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	ids := make(bson.A, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	if _, err := ctrl.store.Content.DeleteMany(ctx, coll, bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$in": ids}}}}); err != nil {
+		return err
+	}
+	audits := make([]*model.AuditEntry, len(entries))
+	for i, e := range entries {
+		audits[i] = newAuditEntry(ctx, model.AuditPurge, coll, e.ID.Hex(), e, nil)
+	}
+	ctrl.recordAudits(ctx, audits...)
+	return nil
+}
+
+// Purges the trash periodically in the background, unless the retention is zero
+func (ctrl *Controller) StartTrashPurge() {
+	retention := TrashRetention()
+	if retention == 0 {
+		return
+	}
+	ctrl.goBackground(func(ctx context.Context) {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if err := ctrl.PurgeTrash(ctx, time.Now().Add(-retention)); err != nil && ctx.Err() == nil {
+				logging.Ctx(ctx).Error().Err(err).Msg("Could not purge the trash")
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
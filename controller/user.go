@@ -2,27 +2,25 @@ package controller
 
 import (
 	"context"
-	"time"
 
 	"github.com/D-Bald/fiber-backend/config"
-	"github.com/D-Bald/fiber-backend/database"
 	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Initialize Users with an admin user
-func InitAdminUser() error {
-	adminRole, err := GetRoleByTag("admin")
+func (ctrl *Controller) InitAdminUser(ctx context.Context) error {
+	adminRole, err := ctrl.GetRoleByTag(ctx, "admin")
 	if err != nil {
 		return err
 	}
 	// Checks, if a user with a role with tag 'admin' exists, if not, create one
-	_, err = GetUser(bson.M{"roles": adminRole.ID})
-	if err != nil && err == mongo.ErrNoDocuments {
-		hash, err := hashPassword(config.Config("FIBER_ADMIN_PASSWORD"))
+	_, err = ctrl.GetUser(ctx, bson.M{"roles": adminRole.ID})
+	if err != nil && err == store.ErrNotFound {
+		hash, err := hashPassword(config.Get().Auth.AdminPassword)
 		if err != nil {
 			return err
 		}
@@ -30,148 +28,108 @@ func InitAdminUser() error {
 		// add admin and default roles to admin user
 		var roles []primitive.ObjectID
 		roles = append(roles, adminRole.ID)
-		if userRole, err := GetRoleByTag("default"); err != nil {
+		if userRole, err := ctrl.GetRoleByTag(ctx, "default"); err != nil {
 			return err
 		} else {
 			roles = append(roles, userRole.ID)
 		}
 
-		adminUser := bson.D{
-			{Key: "username", Value: "adminUser"},
-			{Key: "email", Value: "admin@sample.com"},
-			{Key: "password", Value: hash},
-			{Key: "names", Value: "admin user"},
-			{Key: "roles", Value: roles},
+		adminUser := &model.User{
+			Username: "adminUser",
+			Email:    "admin@sample.com",
+			Password: hash,
+			Names:    "admin user",
+			Roles:    roles,
 		}
+		adminUser.Init()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		if _, err := database.DB.Collection("users").InsertOne(ctx, adminUser); err != nil {
+		if _, err := ctrl.store.Users.Insert(ctx, adminUser); err != nil {
 			return err
 		}
 	}
 
+	// The admin user is only known to exist, if the lookup succeeded
+	if err == nil || err == store.ErrNotFound {
+		ctrl.seeded(SeedAdminUser)
+	}
 	err = nil
 	return err
 }
 
 // Return all users from DB with provided Filter
-func GetUsers(filter interface{}) ([]*model.User, error) {
-	// A slice of tasks for storing the decoded documents
-	var users []*model.User
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	cursor, err := database.DB.Collection("users").Find(ctx, filter)
+func (ctrl *Controller) GetUsers(ctx context.Context, filter interface{}) ([]*model.User, error) {
+	users, err := ctrl.store.Users.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var u model.User
-		err := cursor.Decode(&u)
-		if err != nil {
-			return nil, err
-		}
-
-		users = append(users, &u)
-	}
-
-	if err := cursor.Err(); err != nil {
-		return users, err
-	}
 
 	if len(users) == 0 {
-		return users, mongo.ErrNoDocuments
+		return users, store.ErrNotFound
 	}
 
 	return users, nil
 }
 
 // Return a single user that matches the filter
-func GetUser(filter interface{}) (*model.User, error) {
-	var user *model.User
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err := database.DB.Collection("users").FindOne(ctx, filter).Decode(&user)
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
+func (ctrl *Controller) GetUser(ctx context.Context, filter interface{}) (*model.User, error) {
+	return ctrl.store.Users.FindOne(ctx, filter)
 }
 
 // Return a single user that matches the id input
-func GetUserById(id string) (*model.User, error) {
+func (ctrl *Controller) GetUserById(ctx context.Context, id string) (*model.User, error) {
 	uID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
 
 	filter := bson.M{"_id": uID}
-	return GetUser(filter)
+	return ctrl.GetUser(ctx, filter)
 }
 
 // Return a single user that matches the email input
-func GetUserByEmail(e string) (*model.User, error) {
+func (ctrl *Controller) GetUserByEmail(ctx context.Context, e string) (*model.User, error) {
 	filter := bson.M{"email": e}
-	return GetUser(filter)
+	return ctrl.GetUser(ctx, filter)
 }
 
 // Return a single user that matches the username input
-func GetUserByUsername(u string) (*model.User, error) {
+func (ctrl *Controller) GetUserByUsername(ctx context.Context, u string) (*model.User, error) {
 	filter := bson.M{"username": u}
-	return GetUser(filter)
+	return ctrl.GetUser(ctx, filter)
 }
 
 // Return hashed Password of User with provided ID
-func GetUserPasswordHash(id string) (string, error) {
-	type userPassword struct {
-		Password string `bson:"password"`
-	}
-	var userPW *userPassword
-
-	uID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return bson.TypeNull.String(), err
-	}
-	filter := bson.M{"_id": uID}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = database.DB.Collection("users").FindOne(ctx, filter).Decode(&userPW)
+func (ctrl *Controller) GetUserPasswordHash(ctx context.Context, id string) (string, error) {
+	user, err := ctrl.GetUserById(ctx, id)
 	if err != nil {
 		return bson.TypeNull.String(), err
 	}
 
-	return userPW.Password, nil
+	return user.Password, nil
 }
 
 // Insert user with provided Parameters in DB
-func CreateUser(user *model.User) (*mongo.InsertOneResult, error) {
+func (ctrl *Controller) CreateUser(ctx context.Context, user *model.User) (*store.InsertResult, error) {
 	// Initialize metadata
 	user.Init()
 
 	// Hash the password before saving the user
 	hash, err := hashPassword(user.Password)
 	if err != nil {
-		return new(mongo.InsertOneResult), err
+		return new(store.InsertResult), err
 	}
 	user.Password = hash
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return database.DB.Collection("users").InsertOne(ctx, user)
+	result, err := ctrl.store.Users.Insert(ctx, user)
+	if err != nil {
+		return result, err
+	}
+	ctrl.audit(ctx, model.AuditCreate, store.CollectionUsers, user.ID.Hex(), nil, user)
+	return result, nil
 }
 
 // Update user with provided Parameters in DB
-func UpdateUser(id string, input *model.UserUpdate) (*mongo.UpdateResult, error) {
+func (ctrl *Controller) UpdateUser(ctx context.Context, id string, input *model.UserUpdate) (*store.UpdateResult, error) {
 	// Struct similar to `UserUpdate` but with ObjectIDs of roles instead of string role names
 	type mongoUserUpdate struct {
 		Username string               `bson:"username,omitempty"`
@@ -194,23 +152,23 @@ func UpdateUser(id string, input *model.UserUpdate) (*mongo.UpdateResult, error)
 	if input.Password != "" {
 		hash, err := hashPassword(input.Password)
 		if err != nil {
-			return new(mongo.UpdateResult), err
+			return new(store.UpdateResult), err
 		}
 		userUpdate.Password = hash
 	}
 
 	// Parse role name strings to role ObjectIDs
 	for _, r := range input.Roles {
-		rObj, err := GetRoleByName(r)
+		rObj, err := ctrl.GetRoleByName(ctx, r)
 		if err != nil {
-			return new(mongo.UpdateResult), err
+			return new(store.UpdateResult), err
 		}
 		userUpdate.Roles = append(userUpdate.Roles, rObj.ID)
 	}
 
 	userID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return new(mongo.UpdateResult), err
+		return new(store.UpdateResult), err
 	}
 	// Update user with provided ID and sets field value for `updatet_at`
 	filter := bson.M{"_id": userID}
@@ -220,28 +178,41 @@ func UpdateUser(id string, input *model.UserUpdate) (*mongo.UpdateResult, error)
 			"updated_at": true},
 		},
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
 
-	return database.DB.Collection("users").UpdateOne(ctx, filter, update)
+	return ctrl.auditedUpdate(ctx, model.AuditUpdate, store.CollectionUsers, userID, ctrl.findUserForAudit(ctx, userID), func() (*store.UpdateResult, error) {
+		return ctrl.store.Users.Update(ctx, filter, update)
+	})
 }
 
 // Delete user with provided ID in DB
-func DeleteUser(id string) (*mongo.DeleteResult, error) {
+func (ctrl *Controller) DeleteUser(ctx context.Context, id string) (*store.DeleteResult, error) {
 	uID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, err
 	}
 	filter := bson.M{"_id": uID}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	return database.DB.Collection("users").DeleteOne(ctx, filter)
+	return ctrl.auditedDelete(ctx, model.AuditDelete, store.CollectionUsers, uID, ctrl.findUserForAudit(ctx, uID), func() (*store.DeleteResult, error) {
+		return ctrl.store.Users.Delete(ctx, filter)
+	})
 }
 
 // Delete only one role from user.
-func DeleteRoleFromUser(rID primitive.ObjectID, user *model.User) (*mongo.UpdateResult, error) {
+func (ctrl *Controller) DeleteRoleFromUser(ctx context.Context, rID primitive.ObjectID, user *model.User) (*store.UpdateResult, error) {
+	return ctrl.auditedUpdate(ctx, model.AuditUpdate, store.CollectionUsers, user.ID, ctrl.findUserForAudit(ctx, user.ID), func() (*store.UpdateResult, error) {
+		return ctrl.removeRoleFromUser(ctx, rID, user)
+	})
+}
+
+// Returns a function, that finds the user with the ID
+func (ctrl *Controller) findUserForAudit(ctx context.Context, id primitive.ObjectID) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return ctrl.store.Users.FindOne(ctx, bson.M{"_id": id})
+	}
+}
+
+// Removes the role from the roles of the user without recording it in the audit log
+func (ctrl *Controller) removeRoleFromUser(ctx context.Context, rID primitive.ObjectID, user *model.User) (*store.UpdateResult, error) {
 	roles := make([]primitive.ObjectID, 0)
 	for _, r := range user.Roles {
 		if r != rID {
@@ -257,14 +228,23 @@ func DeleteRoleFromUser(rID primitive.ObjectID, user *model.User) (*mongo.Update
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	return database.DB.Collection("users").UpdateOne(ctx, filter, update)
+	return ctrl.store.Users.Update(ctx, filter, update)
 }
 
+// Cost of bcrypt password hashes, if `PASSWORD_HASH_COST` is not set
+const defaultPasswordHashCost = 14
+
 // Hashes password string with bcrypt
 func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost())
 	return string(bytes), err
 }
+
+// Cost of new password hashes. Existing hashes keep their cost.
+func passwordHashCost() int {
+	cost := config.Get().Auth.PasswordHashCost
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = defaultPasswordHashCost
+	}
+	return cost
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/utils"
+)
+
+// Validation error of a single field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validates a content entry against the field schema of its content type:
+// The title is required, custom fields have to be declared and their values have to match the declared type.
+// Values of localized fields can be a plain value or an object with one value per locale.
+func ValidateContent(ct *model.ContentType, content *model.Content) []FieldError {
+	var errs []FieldError
+	if content.Title == "" {
+		errs = append(errs, FieldError{Field: "title", Message: "required"})
+	}
+
+	defs := ct.FieldDefinitions()
+	names := make([]string, 0, len(content.Fields))
+	for f := range content.Fields {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	for _, f := range names {
+		def, ok := defs[f]
+		if !ok {
+			errs = append(errs, FieldError{Field: f, Message: "not declared in field_schema"})
+			continue
+		}
+		v := content.Fields[f]
+		if values, ok := localeValues(v); ok && def.Localized {
+			for _, l := range utils.SortedKeys(values) {
+				if msg := checkType(def.Type, values[l]); msg != "" {
+					errs = append(errs, FieldError{Field: f + "." + l, Message: msg})
+				}
+			}
+			continue
+		}
+		if msg := checkType(def.Type, v); msg != "" {
+			errs = append(errs, FieldError{Field: f, Message: msg})
+		}
+	}
+	return errs
+}
+
+// Returns an error message if the value does not match the field type, an empty string otherwise.
+// Unknown field types accept any value.
+func checkType(fieldType string, v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	switch fieldType {
+	case "string", model.FieldTypeSlug:
+		if _, ok := v.(string); !ok {
+			return "must be a string"
+		}
+	case "int", "integer":
+		if f, ok := toFloat(v); !ok || f != math.Trunc(f) {
+			return "must be an integer"
+		}
+	case "float", "float64", "number":
+		if _, ok := toFloat(v); !ok {
+			return "must be a number"
+		}
+	case "bool", "boolean":
+		if _, ok := v.(bool); !ok {
+			return "must be a boolean"
+		}
+	case "time.Time", "date", "datetime":
+		switch t := v.(type) {
+		case time.Time:
+		case string:
+			if _, err := time.Parse(time.RFC3339, t); err != nil {
+				return fmt.Sprintf("must be a RFC 3339 date: %s", err.Error())
+			}
+		default:
+			return "must be a RFC 3339 date"
+		}
+	}
+	return ""
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+
+	"go.etcd.io/bbolt"
+)
+
+// Returns the path of the data file of the embedded storage
+func BoltFile() string {
+	return config.Get().Storage.BoltFile
+}
+
+// Opens the data file of the embedded storage. The file is created if it does not exist.
+// Only one process can open the file at a time, so admin commands can not run next to a running server.
+func OpenBolt(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err == bbolt.ErrTimeout {
+		return nil, fmt.Errorf("data file %s is used by another process", path)
+	}
+	return db, err
+}
@@ -2,48 +2,165 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"time"
+	"os"
 
 	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/metrics"
+	"github.com/D-Bald/fiber-backend/tracing"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-// Database settings (insert your own database host, name and user data)
-var mongoHost = config.Config("DB_HOST")
-var dbName = config.Config("DB_NAME")
-var dbUser = config.Config("DB_USER")
-var dbUserPassword = config.Config("DB_USER_PASSWORD")
+// Connects to the MongoDB server. The client has to be disconnected, when it is not needed anymore.
+func Connect(ctx context.Context, cfg config.MongoDB) (*mongo.Client, error) {
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.NewClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+	}
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Returns the options of the client: the options of the URI or host, port and user,
+// overridden by the settings, that are set
+func clientOptions(cfg config.MongoDB) (*options.ClientOptions, error) {
+	uri := cfg.URI
+	if uri == "" {
+		uri = fmt.Sprintf("mongodb://%s:%d/", cfg.Host, cfg.Port)
+	}
+	opts := options.Client().ApplyURI(uri)
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	// Records the latency and errors of all commands in the metrics and a span for each of them.
+	// The client takes a single monitor, so both are combined.
+	opts.SetMonitor(commandMonitors(metrics.CommandMonitor(), tracing.CommandMonitor()))
+
+	if cfg.URI == "" && cfg.User != "" {
+		opts.SetAuth(options.Credential{Username: cfg.User, Password: cfg.Password, AuthSource: cfg.AuthSource})
+	} else if cfg.AuthSource != "" {
+		if opts.Auth == nil {
+			return nil, fmt.Errorf("mongodb.auth_source is set, but there is no user")
+		}
+		opts.Auth.AuthSource = cfg.AuthSource
+	}
+	if cfg.ReplicaSet != "" {
+		opts.SetReplicaSet(cfg.ReplicaSet)
+	}
+
+	if cfg.TLS || cfg.TLSCAFile != "" || cfg.TLSCertFile != "" {
+		tlsConfig, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
 
-func Connect() error {
-	var client *mongo.Client
-	var err error
+	if cfg.MinPoolSize > 0 {
+		opts.SetMinPoolSize(uint64(cfg.MinPoolSize))
+	}
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(uint64(cfg.MaxPoolSize))
+	}
+	if cfg.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(cfg.ConnectTimeout)
+	}
+	if cfg.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(cfg.ServerSelectionTimeout)
+	}
+	if cfg.RetryWrites != nil {
+		opts.SetRetryWrites(*cfg.RetryWrites)
+	}
+	if cfg.RetryReads != nil {
+		opts.SetRetryReads(*cfg.RetryReads)
+	}
+	return opts, nil
+}
 
-	// Distinguish between Atlas and Docker hostet mongo databases
-	if mongoHost == "ATLAS" {
-		mongoURI := fmt.Sprintf("mongodb+srv://%s:%s@fiber-backend.kooym.mongodb.net/%s?retryWrites=true&w=majority", dbUser, dbUserPassword, dbName)
-		client, err = mongo.NewClient(options.Client().ApplyURI(mongoURI))
+// Returns the TLS config with the CA certificates and the client certificate of the settings.
+// Without CA file the certificates of the system are trusted.
+func tlsConfig(cfg config.MongoDB) (*tls.Config, error) {
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s contains no PEM certificates", cfg.TLSCAFile)
 		}
-	} else {
-		mongoURI := fmt.Sprintf("mongodb://%s:%s@%s:%s/", dbUser, dbUserPassword, mongoHost, config.Config("DB_PORT"))
-		client, err = mongo.NewClient(options.Client().ApplyURI(mongoURI))
+	}
+	if cfg.TLSCertFile != "" {
+		// the key may be in the same file as the certificate
+		keyFile := cfg.TLSKeyFile
+		if keyFile == "" {
+			keyFile = cfg.TLSCertFile
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, keyFile)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		c.Certificates = []tls.Certificate{cert}
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	return c, nil
+}
 
-	err = client.Connect(ctx)
+// Returns the read preference for replica reads, nil if all reads use the primary
+func readPreference(cfg config.MongoDB) (*readpref.ReadPref, error) {
+	if cfg.ReadPreference == "" {
+		return nil, nil
+	}
+	mode, err := readpref.ModeFromString(cfg.ReadPreference)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if mode == readpref.PrimaryMode {
+		return nil, nil
+	}
+	return readpref.New(mode)
+}
 
-	DB = client.Database(dbName)
-
-	return nil
+// Returns a monitor, that passes the events to all monitors in their order
+func commandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
 }
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestClientOptions(t *testing.T) {
+	cfg := config.Default().MongoDB
+	cfg.User, cfg.Password, cfg.AuthSource = "admin", "secret", "admin"
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Hosts) != 1 || opts.Hosts[0] != "localhost:27017" || opts.Auth.Username != "admin" || opts.Auth.AuthSource != "admin" {
+		t.Errorf("unexpected options from host and user: %v %+v", opts.Hosts, opts.Auth)
+	}
+	if *opts.ConnectTimeout != 10*time.Second || opts.MaxPoolSize != nil {
+		t.Errorf("unexpected defaults: %v, %v", *opts.ConnectTimeout, opts.MaxPoolSize)
+	}
+
+	// The settings override the options of the URI, the user of the URI is kept
+	retry := false
+	cfg = config.Default().MongoDB
+	cfg.URI = "mongodb://alice:pw@db1:27017,db2:27017/?replicaSet=rs0&maxPoolSize=50&retryWrites=true"
+	cfg.ReplicaSet, cfg.MaxPoolSize, cfg.MinPoolSize, cfg.RetryWrites = "rs1", 20, 5, &retry
+	cfg.ServerSelectionTimeout = 3 * time.Second
+	if opts, err = clientOptions(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Hosts) != 2 || opts.Auth.Username != "alice" || *opts.ReplicaSet != "rs1" {
+		t.Errorf("unexpected options from URI: %v %+v %v", opts.Hosts, opts.Auth, *opts.ReplicaSet)
+	}
+	if *opts.MaxPoolSize != 20 || *opts.MinPoolSize != 5 || *opts.RetryWrites || *opts.ServerSelectionTimeout != 3*time.Second {
+		t.Errorf("settings did not override the URI: %v %v %v %v", *opts.MaxPoolSize, *opts.MinPoolSize, *opts.RetryWrites, *opts.ServerSelectionTimeout)
+	}
+
+	cfg = config.Default().MongoDB
+	cfg.URI = "mongodb://db1/?connectTimeoutMS=not-a-number"
+	if _, err := clientOptions(cfg); err == nil {
+		t.Error("expected an error for an invalid URI")
+	}
+	cfg = config.Default().MongoDB
+	cfg.AuthSource = "admin"
+	if _, err := clientOptions(cfg); err == nil {
+		t.Error("expected an error for an auth source without user")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default().MongoDB
+	cfg.TLS = true
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.TLSConfig == nil || opts.TLSConfig.RootCAs != nil {
+		t.Errorf("TLS without CA file should trust the system certificates: %+v", opts.TLSConfig)
+	}
+
+	cfg.TLSCAFile = filepath.Join(dir, "missing.pem")
+	if _, err := clientOptions(cfg); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+	cfg.TLSCAFile = filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(cfg.TLSCAFile, []byte("no certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientOptions(cfg); err == nil {
+		t.Error("expected an error for a CA file without certificates")
+	}
+}
+
+func TestReadPreference(t *testing.T) {
+	cfg := config.Default().MongoDB
+	for pref, want := range map[string]readpref.Mode{
+		"secondaryPreferred": readpref.SecondaryPreferredMode,
+		"nearest":            readpref.NearestMode,
+	} {
+		cfg.ReadPreference = pref
+		rp, err := readPreference(cfg)
+		if err != nil || rp == nil || rp.Mode() != want {
+			t.Errorf("read preference %s is %v, %v", pref, rp, err)
+		}
+	}
+	for _, pref := range []string{"", "primary"} {
+		cfg.ReadPreference = pref
+		if rp, err := readPreference(cfg); err != nil || rp != nil {
+			t.Errorf("read preference %q should read from the primary: %v, %v", pref, rp, err)
+		}
+	}
+}
@@ -1,6 +0,0 @@
-package database
-
-import "go.mongodb.org/mongo-driver/mongo"
-
-// mongoDB Database connection
-var DB *mongo.Database
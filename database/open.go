@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/bolt"
+	"github.com/D-Bald/fiber-backend/store/memory"
+	"github.com/D-Bald/fiber-backend/store/mongodb"
+	"github.com/D-Bald/fiber-backend/store/postgres"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Names of the storage backends
+const (
+	BackendMongoDB  = "mongodb"
+	BackendBolt     = "bolt"
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Opened storage backend. It has to be closed, when the server or command ends.
+type Backend struct {
+	// Name of the backend like "mongodb"
+	Name  string
+	Store *store.Store
+	// Watches the changes of other servers, nil for the embedded backends, that are used by a single server
+	Watch store.WatchFunc
+	ping  func(ctx context.Context) error
+	close func(ctx context.Context) error
+}
+
+// Checks, that the database is reachable. Backends without database always succeed.
+func (b *Backend) Ping(ctx context.Context) error {
+	if b.ping == nil {
+		return nil
+	}
+	return b.ping(ctx)
+}
+
+// Closes the connections or files of the backend
+func (b *Backend) Close(ctx context.Context) error {
+	if b.close == nil {
+		return nil
+	}
+	return b.close(ctx)
+}
+
+// Opens the storage backend with the given name. An empty name selects MongoDB.
+func Open(backend string) (*Backend, error) {
+	switch backend {
+	case "", BackendMongoDB:
+		return OpenMongoDB(context.Background(), config.Get().MongoDB)
+	case BackendBolt:
+		return OpenBoltBackend(BoltFile())
+	case BackendPostgres:
+		db, err := OpenPostgres()
+		if err != nil {
+			return nil, err
+		}
+		return &Backend{
+			Name:  BackendPostgres,
+			Store: postgres.New(db),
+			Watch: postgres.Watch(config.Get().Storage.PostgresURL),
+			ping:  db.PingContext,
+			close: func(context.Context) error { return db.Close() },
+		}, nil
+	case BackendMemory:
+		logging.Logger().Warn().Msg("Development mode: all data is kept in memory and lost on shutdown")
+		return &Backend{Name: BackendMemory, Store: memory.New()}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// Connects to MongoDB with the settings
+func OpenMongoDB(ctx context.Context, cfg config.MongoDB) (*Backend, error) {
+	rp, err := readPreference(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := client.Database(cfg.Name)
+	return &Backend{
+		Name:  BackendMongoDB,
+		Store: mongodb.New(db, mongodb.Options{OperationTimeout: cfg.OperationTimeout, ReadPreference: rp}),
+		Watch: mongodb.Watch(db),
+		ping:  func(ctx context.Context) error { return client.Ping(ctx, readpref.Primary()) },
+		close: client.Disconnect,
+	}, nil
+}
+
+// Opens the data file of the embedded storage at path
+func OpenBoltBackend(path string) (*Backend, error) {
+	db, err := OpenBolt(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{
+		Name:  BackendBolt,
+		Store: bolt.New(db),
+		// Fails, once the file is closed
+		ping:  func(context.Context) error { return db.View(func(*bbolt.Tx) error { return nil }) },
+		close: func(context.Context) error { return db.Close() },
+	}, nil
+}
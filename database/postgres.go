@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/store/postgres"
+
+	// Driver of the PostgreSQL storage
+	_ "github.com/lib/pq"
+)
+
+// Opens the PostgreSQL database at `storage.postgres_url` and migrates its schema to the current version
+func OpenPostgres() (*sql.DB, error) {
+	url := config.Get().Storage.PostgresURL
+	if url == "" {
+		return nil, fmt.Errorf("storage.postgres_url is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := postgres.Migrate(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -3,11 +3,24 @@ module github.com/D-Bald/fiber-backend
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.14.3
 	github.com/form3tech-oss/jwt-go v3.2.2+incompatible
+	github.com/go-redis/redis/v8 v8.11.4
 	github.com/gofiber/fiber/v2 v2.6.0
 	github.com/gofiber/jwt/v2 v2.2.0
 	github.com/joho/godotenv v1.3.0
-	github.com/valyala/fasthttp v1.34.0 // indirect
+	github.com/lib/pq v1.10.9
+	github.com/prometheus/client_golang v1.11.1
+	github.com/rs/zerolog v1.26.1
+	github.com/valyala/fasthttp v1.34.0
+	go.etcd.io/bbolt v1.3.6
 	go.mongodb.org/mongo-driver v1.5.1
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.3.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
 	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	golang.org/x/text v0.3.7
+	gopkg.in/yaml.v3 v3.0.1
 )
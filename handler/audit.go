@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+
+	"bufio"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/middleware"
+	"github.com/D-Bald/fiber-backend/model"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Page size of the audit log, if the `limit` query parameter is not set
+const defaultAuditLimit = 50
+
+// Maximum page size of the audit log
+const maxAuditLimit = 500
+
+// GetAuditLog query the audit log with filters and pagination
+func (h *Handler) GetAuditLog(c *fiber.Ctx) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return apierror.InvalidQuery("Invalid query").Wrap(err)
+	}
+
+	page, err := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		return apierror.InvalidQuery("Invalid query").WithDetails(apierror.Detail{Field: "page", Message: "must be a positive number"})
+	}
+	limit, err := strconv.ParseInt(c.Query("limit", strconv.Itoa(defaultAuditLimit)), 10, 64)
+	if err != nil || limit < 1 || limit > maxAuditLimit {
+		return apierror.InvalidQuery("Invalid query").WithDetails(apierror.Detail{Field: "limit", Message: "must be between 1 and 500"})
+	}
+
+	entries, total, err := h.ctrl.GetAuditLog(middleware.Context(c), filter, (page-1)*limit, limit)
+	if err != nil {
+		return apierror.From(err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Audit log", "audit": entries, "total": total, "page": page, "limit": limit})
+}
+
+// ExportAuditLog streams all audit log entries, that match the filters, as NDJSON file
+func (h *Handler) ExportAuditLog(c *fiber.Ctx) error {
+	filter, err := auditFilter(c)
+	if err != nil {
+		return apierror.InvalidQuery("Invalid query").Wrap(err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit_log.ndjson"`)
+	ctx, cancel := middleware.StreamContext(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		enc := json.NewEncoder(w)
+		err := h.ctrl.StreamAuditLog(ctx, filter, func(entry *model.AuditEntry) error {
+			return enc.Encode(entry)
+		})
+		if err != nil {
+			logging.Ctx(ctx).Error().Err(err).Msg("Could not export the audit log")
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// Makes a filter for audit log entries from the query params
+func auditFilter(c *fiber.Ctx) (bson.M, error) {
+	filter := bson.M{}
+	for param, field := range map[string]string{
+		"actor":      "actor.id",
+		"actor_type": "actor.type",
+		"action":     "action",
+		"collection": "collection",
+		"target_id":  "target_id",
+		"request_id": "request_id",
+		"ip":         "ip",
+	} {
+		if v := c.Query(param); v != "" {
+			filter[field] = v
+		}
+	}
+
+	period := bson.M{}
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, err
+		}
+		period["$gte"] = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return nil, err
+		}
+		period["$lt"] = t
+	}
+	if len(period) > 0 {
+		filter["time"] = period
+	}
+	return filter, nil
+}
+
+// Makes an audit log entry with actor, client IP and request ID of the request
+func newAuditEntry(c *fiber.Ctx, action string, coll string, id string) *model.AuditEntry {
+	entry := &model.AuditEntry{
+		Actor:      model.AuditActor{Type: model.ActorAnonymous},
+		Action:     action,
+		Collection: coll,
+		TargetID:   id,
+		IP:         c.IP(),
+	}
+	if rid, ok := c.Locals("requestid").(string); ok {
+		entry.RequestID = rid
+	}
+	if token, ok := c.Locals("user").(*jwt.Token); ok {
+		claims := token.Claims.(jwt.MapClaims)
+		entry.Actor.Type = model.ActorUser
+		entry.Actor.ID, _ = claims["user_id"].(string)
+		entry.Actor.Name, _ = claims["username"].(string)
+	}
+	return entry
+}
+
+func (h *Handler) saveAudit(ctx context.Context, entry *model.AuditEntry) {
+	if err := h.ctrl.RecordAudit(ctx, entry); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str("action", entry.Action).Str("collection", entry.Collection).Str("target_id", entry.TargetID).Msg("Could not write audit log entry")
+	}
+}
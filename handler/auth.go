@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"context"
+
 	"time"
 
+	"github.com/D-Bald/fiber-backend/apierror"
 	"github.com/D-Bald/fiber-backend/config"
-	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/metrics"
+	"github.com/D-Bald/fiber-backend/middleware"
 	"github.com/D-Bald/fiber-backend/model"
 
 	"github.com/form3tech-oss/jwt-go"
@@ -12,7 +16,7 @@ import (
 )
 
 // Login get user and password
-func Login(c *fiber.Ctx) error {
+func (h *Handler) Login(c *fiber.Ctx) error {
 	type LoginInput struct {
 		Identity string `json:"identity" xml:"identity" form:"identity"`
 		Password string `json:"password" xml:"password" form:"password"`
@@ -20,22 +24,23 @@ func Login(c *fiber.Ctx) error {
 	input := new(LoginInput)
 
 	if err := c.BodyParser(input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Error on login request", "token": nil, "user": nil})
+		return apierror.InvalidInput("Error on login request").Wrap(err)
 	}
 
 	identity := input.Identity
 	if identity == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "No Identity provided on Login", "token": nil, "user": nil})
+		return apierror.InvalidInput("No Identity provided on Login")
 	}
 	pass := input.Password
 
-	email, _ := controller.GetUserByEmail(identity)
+	email, _ := h.ctrl.GetUserByEmail(middleware.Context(c), identity)
 
-	username, _ := controller.GetUserByUsername(identity)
+	username, _ := h.ctrl.GetUserByUsername(middleware.Context(c), identity)
 
 	var user model.User
 	if email == nil && username == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "User not found", "token": nil, "user": nil})
+		h.loginFailed(c, identity, "")
+		return apierror.Unauthorized("Invalid identity or password")
 	}
 
 	if email != nil {
@@ -44,19 +49,20 @@ func Login(c *fiber.Ctx) error {
 		user = *username
 	}
 
-	pw, err := controller.GetUserPasswordHash(user.ID.Hex())
+	pw, err := h.ctrl.GetUserPasswordHash(middleware.Context(c), user.ID.Hex())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not validate user", "token": nil, "user": nil})
+		return apierror.Internal("Could not validate user", err)
 	}
 
 	if !checkPasswordHash(pass, pw) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "invalid password", "token": nil, "user": nil})
+		h.loginFailed(c, identity, user.ID.Hex())
+		return apierror.Unauthorized("Invalid identity or password")
 	}
 
 	// Checks, if user is admin
-	isAdmin, err := isAdmin(user)
+	isAdmin, err := h.isAdmin(middleware.Context(c), user)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not check user roles", "token": nil, "user": nil})
+		return apierror.Internal("Could not check user roles", err)
 	}
 
 	// Creates token
@@ -69,25 +75,38 @@ func Login(c *fiber.Ctx) error {
 	claims["exp"] = time.Now().Add(time.Hour * 72).Unix()
 
 	// Signs token
-	t, err := token.SignedString([]byte(config.Config("SECRET")))
+	t, err := token.SignedString([]byte(config.Get().Auth.Secret))
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return apierror.Internal("Could not sign token", err)
 	}
 
+	metrics.ObserveLogin(true)
+	entry := newAuditEntry(c, model.AuditLogin, "users", user.ID.Hex())
+	entry.Actor = model.AuditActor{Type: model.ActorUser, ID: user.ID.Hex(), Name: user.Username}
+	h.saveAudit(middleware.Context(c), entry)
+
 	// Returns a subset of fields in readable format
-	userOutput, err := toUserOutput(&user)
+	userOutput, err := h.toUserOutput(middleware.Context(c), &user)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error on parsing user roles", "token": nil, "user": nil})
+		return apierror.Internal("Error on parsing user roles", err)
 	}
 
 	// return c.JSON(fiber.Map{"status": "success", "message": "Success login", "data": fiber.Map{"token": t, "user": ud}})
 	return c.JSON(fiber.Map{"status": "success", "message": "Success login", "token": t, "user": userOutput})
 }
 
+// Writes a failed login attempt to the audit log and the metrics. The ID is only known if the user exists.
+func (h *Handler) loginFailed(c *fiber.Ctx, identity string, id string) {
+	metrics.ObserveLogin(false)
+	entry := newAuditEntry(c, model.AuditLoginFailed, "users", id)
+	entry.Actor.Name = identity
+	h.saveAudit(middleware.Context(c), entry)
+}
+
 // returns true, if user has a role with tag 'admin', returns false otherwise
-func isAdmin(user model.User) (bool, error) {
+func (h *Handler) isAdmin(ctx context.Context, user model.User) (bool, error) {
 	for _, rID := range user.Roles {
-		role, err := controller.GetRoleById(rID.Hex())
+		role, err := h.ctrl.GetRoleById(ctx, rID.Hex())
 		if err != nil {
 			return false, err
 		}
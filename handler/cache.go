@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/middleware"
+	"github.com/D-Bald/fiber-backend/responsecache"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purge the cached responses of all content, of the collection or of the entry in the route
+func (h *Handler) PurgeResponseCache(c *fiber.Ctx) error {
+	tag := responsecache.TagAll
+	if coll := c.Params("collection"); coll != "" {
+		if !h.ctrl.IsValidContentCollection(middleware.Context(c), coll) {
+			return apierror.NotFound("Content Type not found")
+		}
+		tag = responsecache.CollectionTag(coll)
+		if id := c.Params("id"); id != "" {
+			if _, err := primitive.ObjectIDFromHex(id); err != nil {
+				return apierror.InvalidID(err)
+			}
+			tag = responsecache.EntryTag(coll, id)
+		}
+	}
+
+	if err := h.ctrl.PurgeResponses(middleware.Context(c), tag); err != nil {
+		return apierror.From(err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Cached responses purged", "tag": tag})
+}
@@ -1,107 +1,443 @@
 package handler
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/apierror"
 	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/middleware"
 	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/responsecache"
+	"github.com/D-Bald/fiber-backend/store"
 	"github.com/D-Bald/fiber-backend/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Query content entries with filter provided in query params
-func GetContent(c *fiber.Ctx) error {
+func (h *Handler) GetContent(c *fiber.Ctx) error {
+	coll := c.Params("content")
+
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	locale, err := requestLocale(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := contentFilter(c, ct, locale)
+	if err != nil {
+		return apierror.InvalidQuery("Invalid query").Wrap(err)
+	}
+
+	// get content from DB
+	result, err := h.ctrl.GetContent(middleware.Context(c), coll, filter)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "No match found")
+	}
+
+	// Resolve localized fields if a locale is requested
+	if locale != "" {
+		controller.ResolveLocale(ct, result, locale)
+		c.Set(fiber.HeaderContentLanguage, locale)
+	}
+	middleware.AddSurrogateKeys(c, entryTags(coll, result...)...)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Content found", "content": result})
+}
+
+// Export content entries that match the filter provided in query params.
+// The entries are streamed in the format provided by the `format` query param.
+func (h *Handler) ExportContent(c *fiber.Ctx) error {
+	coll := c.Params("content")
+	format := c.Query("format", controller.FormatNDJSON)
+	if !controller.IsValidTransferFormat(format) {
+		return apierror.InvalidQuery(fmt.Sprintf("Unsupported format: %s", format))
+	}
+
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	locale, err := requestLocale(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := contentFilter(c, ct, locale)
+	if err != nil {
+		return apierror.InvalidQuery("Invalid query").Wrap(err)
+	}
+
+	c.Set(fiber.HeaderContentType, transferContentTypes[format])
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", coll+"."+format))
+	if locale != "" {
+		c.Set(fiber.HeaderContentLanguage, locale)
+	}
+	// The body is written after the handler returned, so errors can only be logged
+	ctx, cancel := middleware.StreamContext(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		if err := h.ctrl.ExportContent(ctx, w, format, ct, filter, locale); err != nil {
+			logging.Ctx(ctx).Error().Err(err).Str("collection", coll).Msg("Export failed")
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// Import content entries from the request body in the format provided by the `format` query param or the content type header.
+// Set `dry_run=true` to only validate the rows and `upsert=<field>` to update existing entries, that match `_id` or a unique field.
+func (h *Handler) ImportContent(c *fiber.Ctx) error {
 	coll := c.Params("content")
+	format := c.Query("format")
+	if format == "" {
+		for f, mime := range transferContentTypes {
+			if strings.HasPrefix(c.Get(fiber.HeaderContentType), mime) {
+				format = f
+			}
+		}
+	}
+	if !controller.IsValidTransferFormat(format) {
+		return apierror.InvalidInput("Review your input: 'format' must be one of ndjson, json or csv")
+	}
+
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+
+	locale, err := queryLocale(c)
+	if err != nil {
+		return err
+	}
+	opts := controller.ImportOptions{
+		Format:   format,
+		DryRun:   c.Query("dry_run") == "true",
+		UpsertBy: c.Query("upsert"),
+		Locale:   locale,
+	}
+	if opts.UpsertBy != "" && opts.UpsertBy != "_id" && !isUniqueField(ct, opts.UpsertBy) {
+		return apierror.InvalidQuery(fmt.Sprintf("Upsert field is not unique: %s", opts.UpsertBy))
+	}
+
+	rows, err := controller.ParseImport(format, ct, c.Body())
+	if err != nil {
+		return apierror.InvalidInput("Could not parse import").Wrap(err)
+	}
+
+	report, err := h.ctrl.ImportContent(middleware.Context(c), ct, rows, opts)
+	if err != nil {
+		return apierror.From(err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Content imported", "report": report})
+}
+
+// Mime types of the import and export formats
+var transferContentTypes = map[string]string{
+	controller.FormatNDJSON: "application/x-ndjson",
+	controller.FormatJSON:   fiber.MIMEApplicationJSON,
+	controller.FormatCSV:    "text/csv",
+}
+
+// Makes a filter for content entries from the query params.
+// Localized fields are matched against the value of the requested locale.
+func contentFilter(c *fiber.Ctx, ct *model.ContentType, locale string) (map[string]interface{}, error) {
 	parseObject := new(model.Content)
 
 	// parse input
 	if err := c.QueryParser(parseObject); err != nil && err.Error() != "schema: converter not found for primitive.ObjectID" {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No match found", "data": err.Error()})
+		return nil, err
 	}
 	// parse ID manually because fiber's QueryParser has no converter for this type.
 	if id := string(c.Request().URI().QueryArgs().Peek("id")); id != "" {
 		cID, err := primitive.ObjectIDFromHex(id)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Invalid ID", "data": err.Error()})
+			return nil, err
 		}
 		parseObject.ID = cID
 	}
 
 	// Parse custom fields manually
-	fields, err := controller.GetCustomFields(coll) // Initialize fields map to avoid nil map error
-	parseObject.Fields = make(map[string]interface{})
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Custom fields not found", "data": err.Error()})
-	}
-	for f := range fields {
+	parseObject.Fields = make(map[string]interface{}) // Initialize fields map to avoid nil map error
+	for f := range ct.FieldSchema {
 		if fValue := c.Request().URI().QueryArgs().Peek(f); fValue != nil {
-			parseObject.Fields[f] = string(fValue)
+			if ct.IsLocalized(f) {
+				l := locale
+				if l == "" {
+					l = controller.DefaultLocale()
+				}
+				parseObject.Fields[f+"."+l] = string(fValue)
+			} else {
+				parseObject.Fields[f] = string(fValue)
+			}
 		}
 	}
 
 	// Make filter where slices, maps and structs are parsed inline
-	filter, err := utils.MakeQueryFilterFromStruct(parseObject)
+	return utils.MakeQueryFilterFromStruct(parseObject)
+}
+
+// Query a single content entry by the value of a unique field like a slug
+func (h *Handler) GetContentByField(c *fiber.Ctx) error {
+	coll := c.Params("content")
+	field := c.Params("field")
+	value, err := url.PathUnescape(c.Params("value"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Custom fields not found", "data": err.Error()})
+		return apierror.InvalidInput("Invalid value").Wrap(err)
 	}
 
-	// get content from DB
-	result, err := controller.GetContent(coll, filter)
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No match found", "data": err.Error()})
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	if !isUniqueField(ct, field) {
+		return apierror.InvalidInput(fmt.Sprintf("Field is not unique: %s", field))
+	}
+	locale, err := requestLocale(c)
+	if err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Content found", "content": result})
+	entry, err := h.ctrl.GetContentEntry(middleware.Context(c), coll, bson.M{field: value})
+	if err != nil {
+		return apierror.NotFoundFrom(err, "No match found")
+	}
+
+	// Resolve localized fields if a locale is requested
+	if locale != "" {
+		controller.ResolveLocale(ct, []*model.Content{entry}, locale)
+		c.Set(fiber.HeaderContentLanguage, locale)
+	}
+	middleware.AddSurrogateKeys(c, entryTags(coll, entry)...)
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Content found", "content": entry})
 }
 
 // CreateContent new content
 // Collection is created by mongoDB automatically on first insert call
-func CreateContent(c *fiber.Ctx) error {
+func (h *Handler) CreateContent(c *fiber.Ctx) error {
 	content := new(model.Content)
 	// Parse input
-	if err := c.BodyParser(content); err != nil || content.Title == "" || content.Fields == nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create content", "content": err.Error()})
+	if err := c.BodyParser(content); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+	if content.Title == "" || content.Fields == nil {
+		return apierror.InvalidInput("Review your input: 'title' and 'fields' required")
 	}
 
 	// Get collection from route params
 	coll := c.Params("content")
 
-	if _, err := controller.CreateContent(coll, content); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create Content", "content": err.Error()})
+	// Store values of localized fields per locale
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	locale, err := queryLocale(c)
+	if err != nil {
+		return err
+	}
+	content.Fields = controller.LocalizeInput(ct, content.Fields, locale, false)
+
+	if _, err := h.ctrl.CreateContent(middleware.Context(c), coll, content); err != nil {
+		return contentWriteError(err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "Created content", "content": content})
 }
 
 // Update content entry with parameters from request body
 // Update user with parameters from request body
-func UpdateContent(c *fiber.Ctx) error {
+func (h *Handler) UpdateContent(c *fiber.Ctx) error {
 	coll := c.Params("content")
 	id := c.Params("id")
 
 	uci := new(model.ContentUpdate)
-	if err := c.BodyParser(uci); err != nil || uci == nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "result": err.Error()})
+	if err := c.BodyParser(uci); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+
+	// Update values of localized fields only for the provided locale
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	locale, err := queryLocale(c)
+	if err != nil {
+		return err
 	}
+	uci.Fields = controller.LocalizeInput(ct, uci.Fields, locale, true)
 
-	result, err := controller.UpdateContent(coll, id, uci)
+	if _, err := h.ctrl.GetContentById(middleware.Context(c), coll, id); err != nil {
+		return apierror.NotFoundFrom(err, "Content not found")
+	}
+	result, err := h.ctrl.UpdateContent(middleware.Context(c), coll, id, uci)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not update content entry", "result": err.Error()})
+		return contentWriteError(err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "Content successfully updated", "result": result})
 }
 
 // DeleteContent delete content
-func DeleteContent(c *fiber.Ctx) error {
+func (h *Handler) DeleteContent(c *fiber.Ctx) error {
 	coll := c.Params("content")
 	id := c.Params("id")
-	if _, err := controller.GetContentById(coll, id); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Content not found", "result": err.Error()})
+	if _, err := h.ctrl.GetContentById(middleware.Context(c), coll, id); err != nil {
+		return apierror.NotFoundFrom(err, "Content not found")
+	}
+
+	result, err := h.ctrl.DeleteContent(middleware.Context(c), coll, id)
+	if err != nil {
+		return contentWriteError(err)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Content moved to trash", "result": result})
+}
+
+// GetTrashedContent query all content entries in the trash
+func (h *Handler) GetTrashedContent(c *fiber.Ctx) error {
+	result, err := h.ctrl.GetTrashedContent(middleware.Context(c), c.Params("content"), bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return apierror.From(err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Content in trash", "content": result})
+}
+
+// RestoreContent restore content entry from the trash
+func (h *Handler) RestoreContent(c *fiber.Ctx) error {
+	coll := c.Params("content")
+	id := c.Params("id")
+	result, err := h.ctrl.RestoreContent(middleware.Context(c), coll, id)
+	if err != nil {
+		return contentWriteError(err)
+	}
+	if result.MatchedCount == 0 {
+		return apierror.NotFound("Content not found in trash")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Content successfully restored", "result": result})
+}
+
+// PurgeContent permanently delete content entry from the trash
+func (h *Handler) PurgeContent(c *fiber.Ctx) error {
+	coll := c.Params("content")
+	id := c.Params("id")
+	result, err := h.ctrl.PurgeContent(middleware.Context(c), coll, id)
+	if err != nil {
+		return contentWriteError(err)
+	}
+	if result.DeletedCount == 0 {
+		return apierror.NotFound("Content not found in trash")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Content permanently deleted", "result": result})
+}
+
+// Query entries with missing translations of localized fields
+func (h *Handler) GetMissingLocales(c *fiber.Ctx) error {
+	coll := c.Params("content")
+
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	entries, err := h.ctrl.GetContent(middleware.Context(c), coll, bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return apierror.From(err)
+	}
+
+	result := make([]missingLocalesOutput, 0)
+	for _, e := range entries {
+		if missing := controller.MissingLocales(ct, e); len(missing) > 0 {
+			result = append(result, missingLocalesOutput{ID: e.ID, Title: e.Title, Missing: missing})
+		}
 	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Entries with missing locales", "locales": controller.Locales(), "content": result})
+}
+
+// Query missing translations of localized fields of the content entry with provided ID
+func (h *Handler) GetEntryMissingLocales(c *fiber.Ctx) error {
+	coll := c.Params("content")
 
-	result, err := controller.DeleteContent(coll, id)
+	ct, err := h.ctrl.GetContentTypeByCollection(middleware.Context(c), coll)
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	entry, err := h.ctrl.GetContentById(middleware.Context(c), coll, c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not delete Content", "result": err.Error()})
+		return apierror.NotFoundFrom(err, "Content not found")
+	}
+
+	result := missingLocalesOutput{ID: entry.ID, Title: entry.Title, Missing: controller.MissingLocales(ct, entry)}
+	return c.JSON(fiber.Map{"status": "success", "message": "Missing locales of content entry", "locales": controller.Locales(), "content": result})
+}
+
+// Returns the requested locale from the `locale` query parameter or the `Accept-Language` header.
+// Returns an empty string, if no locale is requested.
+func requestLocale(c *fiber.Ctx) (string, error) {
+	if c.Query("locale") != "" {
+		return queryLocale(c)
 	}
+	if c.Get(fiber.HeaderAcceptLanguage) != "" {
+		if l := c.AcceptsLanguages(controller.Locales()...); l != "" {
+			return l, nil
+		}
+		return controller.DefaultLocale(), nil
+	}
+	return "", nil
+}
+
+// Returns the normalized `locale` query parameter or an empty string, if it is not set.
+// Returns 400, if the locale is not configured.
+func queryLocale(c *fiber.Ctx) (string, error) {
+	l := c.Query("locale")
+	if l == "" {
+		return "", nil
+	}
+	locale, ok := controller.ParseLocale(l)
+	if !ok {
+		return "", apierror.InvalidQuery(fmt.Sprintf("Unknown locale: %s", l))
+	}
+	return locale, nil
+}
+
+// Maps errors of writes of content entries to API errors
+func contentWriteError(err error) error {
+	if errors.Is(err, controller.ErrCollectionMoving) {
+		return apierror.Conflict("Could not write content").Wrap(err)
+	}
+	return apierror.From(err)
+}
+
+// Returns the tags of the entries, that purge cached responses containing them
+func entryTags(coll string, entries ...*model.Content) []string {
+	tags := make([]string, len(entries))
+	for i, e := range entries {
+		tags[i] = responsecache.EntryTag(coll, e.ID.Hex())
+	}
+	return tags
+}
+
+// Returns true if the field is declared as unique field or slug in the field schema of the content type
+func isUniqueField(ct *model.ContentType, field string) bool {
+	for _, f := range ct.UniqueFields() {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Content successfully deleted", "result": result})
+// Fields that are returned when querying missing locales
+type missingLocalesOutput struct {
+	ID      primitive.ObjectID  `bson:"_id" json:"_id" xml:"_id" form:"_id"`
+	Title   string              `bson:"title" json:"title" xml:"title" form:"title"`
+	Missing map[string][]string `bson:"missing" json:"missing" xml:"missing" form:"missing"`
 }
@@ -1,10 +1,16 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/D-Bald/fiber-backend/apierror"
 	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/middleware"
 	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
@@ -12,18 +18,18 @@ import (
 )
 
 // GetAll query all Content Types
-func GetAllContentTypes(c *fiber.Ctx) error {
-	contentTypes, err := controller.GetContentTypes(bson.M{})
+func (h *Handler) GetAllContentTypes(c *fiber.Ctx) error {
+	contentTypes, err := h.ctrl.GetContentTypes(middleware.Context(c), bson.M{})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Internal Server Error", "contenttype": err.Error()})
+		return apierror.From(err)
 	}
 
 	// Return a subset of fields in readable format
 	result := make([]contentTypeOutput, 0)
 	for _, ct := range contentTypes {
-		out, err := toContentTypeOutput(ct)
+		out, err := h.toContentTypeOutput(middleware.Context(c), ct)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error on parsing permissions", "data": err.Error()})
+			return apierror.Internal("Error on parsing permissions", err)
 		}
 		result = append(result, *out)
 	}
@@ -31,40 +37,53 @@ func GetAllContentTypes(c *fiber.Ctx) error {
 }
 
 // GetContentType query contenttypes by ID
-func GetContentType(c *fiber.Ctx) error {
-	ct, err := controller.GetContentTypeById(c.Params("id"))
+func (h *Handler) GetContentType(c *fiber.Ctx) error {
+	ct, err := h.ctrl.GetContentTypeById(middleware.Context(c), c.Params("id"))
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Content Type not found", "contenttype": err.Error()})
-
+		return apierror.NotFoundFrom(err, "Content Type not found")
 	}
 	// Return a subset of fields in readable format
-	ctOutput, err := toContentTypeOutput(ct)
+	ctOutput, err := h.toContentTypeOutput(middleware.Context(c), ct)
+	if err != nil {
+		return apierror.Internal("Error on parsing permissions", err)
+	}
+	// Add current state of the indexes
+	ctOutput.IndexState, err = h.ctrl.GetIndexState(middleware.Context(c), ct)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error on parsing permissions", "user": err.Error()})
+		return apierror.Internal("Error on reading indexes", err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "Content Type found", "contenttype": ctOutput})
 }
 
 // CreateContentType
-func CreateContentType(c *fiber.Ctx) error {
+func (h *Handler) CreateContentType(c *fiber.Ctx) error {
 	type newContentType struct {
-		TypeName    string                 `bson:"typename" json:"typename"`
-		Collection  string                 `bson:"collection" json:"collection"`
-		Permissions map[string][]string    `bson:"permissions" json:"permissions"`
-		FieldSchema map[string]interface{} `bson:"field_schema" json:"field_schema"`
+		TypeName    string                  `bson:"typename" json:"typename"`
+		Collection  string                  `bson:"collection" json:"collection"`
+		Permissions map[string][]string     `bson:"permissions" json:"permissions"`
+		FieldSchema map[string]interface{}  `bson:"field_schema" json:"field_schema"`
+		Indexes     []model.IndexDefinition `bson:"indexes" json:"indexes"`
 	}
 
 	ctInput := new(newContentType)
 	// Parse input
-	if err := c.BodyParser(ctInput); err != nil || ctInput.TypeName == "" || ctInput.Collection == "" {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Review your input: 'typename' and 'collection' required", "contenttype": err.Error()})
+	if err := c.BodyParser(ctInput); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+	if ctInput.TypeName == "" || ctInput.Collection == "" {
+		return apierror.InvalidInput("Review your input: 'typename' and 'collection' required")
+	}
+
+	// Check if declared indexes are valid
+	if err := model.ValidateIndexes(ctInput.Indexes); err != nil {
+		return apierror.ValidationFailed("Review your input: invalid index", apierror.Detail{Field: "indexes", Message: err.Error()})
 	}
 
 	// Check if content type already exists
-	checkTypeName, _ := controller.GetContentType(bson.M{"typename": ctInput.TypeName})
-	checkCollection, _ := controller.GetContentType(bson.M{"collection": ctInput.Collection})
+	checkTypeName, _ := h.ctrl.GetContentTypeIncludingTrash(middleware.Context(c), bson.M{"typename": ctInput.TypeName})
+	checkCollection, _ := h.ctrl.GetContentTypeIncludingTrash(middleware.Context(c), bson.M{"collection": ctInput.Collection})
 	if checkTypeName != nil || checkCollection != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Content Type already exists", "contenttype": nil})
+		return apierror.AlreadyExists("Content Type already exists")
 	}
 
 	// Check if all roles are valid and parse them to Object IDs
@@ -73,12 +92,12 @@ func CreateContentType(c *fiber.Ctx) error {
 		for key, val := range ctInput.Permissions {
 			var roleObjectIDs []primitive.ObjectID
 			for _, role := range val {
-				if !controller.IsValidRole(role) {
-					return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Role not found: %s", role), "result": nil})
+				if !h.ctrl.IsValidRole(middleware.Context(c), role) {
+					return apierror.ValidationFailed("Review your input", apierror.Detail{Field: "permissions." + key, Message: fmt.Sprintf("Role not found: %s", role)})
 				} else {
-					rObj, err := controller.GetRoleByName(role)
+					rObj, err := h.ctrl.GetRoleByName(middleware.Context(c), role)
 					if err != nil {
-						return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Could not create content type", "result": nil})
+						return apierror.From(err)
 					}
 					roleObjectIDs = append(roleObjectIDs, rObj.ID)
 				}
@@ -93,92 +112,167 @@ func CreateContentType(c *fiber.Ctx) error {
 		Collection:  ctInput.Collection,
 		Permissions: permissions,
 		FieldSchema: ctInput.FieldSchema,
+		Indexes:     ctInput.Indexes,
 	}
 
 	// Insert in DB
-	if _, err := controller.CreateContentType(&ct); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create Content Type", "contenttype": err.Error()})
+	if _, err := h.ctrl.CreateContentType(middleware.Context(c), &ct); err != nil {
+		if err == controller.ErrReservedCollection {
+			return apierror.ValidationFailed("Review your input", apierror.Detail{Field: "collection", Message: err.Error()})
+		}
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return apierror.New(fiber.StatusConflict, apierror.CodeDuplicateKey, "Existing entries violate unique fields").Wrap(err)
+		}
+		return apierror.From(err)
 	}
 
 	// Return a subset of fields in readable format
-	ctOutput, err := toContentTypeOutput(&ct)
+	ctOutput, err := h.toContentTypeOutput(middleware.Context(c), &ct)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error on parsing permissions", "user": err.Error()})
+		return apierror.Internal("Error on parsing permissions", err)
 	}
 
 	return c.JSON(fiber.Map{"status": "success", "message": "Created Content Type", "contenttype": ctOutput})
 }
 
 // Update content type with parameters from request body
-func UpdateContentType(c *fiber.Ctx) error {
+func (h *Handler) UpdateContentType(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	ctui := new(model.ContentTypeUpdate)
 	// Parse input
-	if err := c.BodyParser(ctui); err != nil || ctui == nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "result": err.Error()})
+	if err := c.BodyParser(ctui); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+
+	if _, err := h.ctrl.GetContentTypeById(middleware.Context(c), id); err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
 	}
 
 	// Checks if content type already exists
 	if ctui.TypeName != "" {
-		checkTypeName, _ := controller.GetContentType(bson.M{"typename": ctui.TypeName})
+		checkTypeName, _ := h.ctrl.GetContentTypeIncludingTrash(middleware.Context(c), bson.M{"typename": ctui.TypeName})
 		if checkTypeName != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Content Type already exists", "contenttype": nil})
+			return apierror.AlreadyExists("Content Type already exists")
 		}
 	}
 	if ctui.Collection != "" {
-		checkCollection, _ := controller.GetContentType(bson.M{"collection": ctui.Collection})
+		checkCollection, _ := h.ctrl.GetContentTypeIncludingTrash(middleware.Context(c), bson.M{"collection": ctui.Collection})
 		if checkCollection != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Content Type already exists", "contenttype": nil})
+			return apierror.AlreadyExists("Content Type already exists")
+		}
+	}
+
+	// Checks if declared indexes are valid
+	if ctui.Indexes != nil {
+		if err := model.ValidateIndexes(*ctui.Indexes); err != nil {
+			return apierror.ValidationFailed("Review your input: invalid index", apierror.Detail{Field: "indexes", Message: err.Error()})
 		}
 	}
 
 	// Checks, if all role are valid
 	if ctui.Permissions != nil {
-		for _, val := range ctui.Permissions {
+		for key, val := range ctui.Permissions {
 			for _, role := range val {
-				if !controller.IsValidRole(role) {
-					return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Role not found: %s", role), "result": nil})
+				if !h.ctrl.IsValidRole(middleware.Context(c), role) {
+					return apierror.ValidationFailed("Review your input", apierror.Detail{Field: "permissions." + key, Message: fmt.Sprintf("Role not found: %s", role)})
 				}
 			}
 		}
 	}
-	result, err := controller.UpdateContentType(id, ctui)
+	result, err := h.ctrl.UpdateContentType(middleware.Context(c), id, ctui)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not update Content Type", "result": err.Error()})
+		if err == controller.ErrReservedCollection {
+			return apierror.ValidationFailed("Review your input", apierror.Detail{Field: "collection", Message: err.Error()})
+		}
+		if err == controller.ErrMigrationRunning || errors.Is(err, controller.ErrCollectionMoving) || errors.Is(err, controller.ErrCollectionInUse) {
+			return apierror.Conflict("Could not move collection").Wrap(err)
+		}
+		if errors.Is(err, store.ErrDuplicateKey) {
+			return apierror.New(fiber.StatusConflict, apierror.CodeDuplicateKey, "Existing entries violate unique fields").Wrap(err)
+		}
+		return apierror.From(err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "Content Type successfully updated", "result": result})
 }
 
 // DeleteContentType
-func DeleteContentType(c *fiber.Ctx) error {
+func (h *Handler) DeleteContentType(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	// Check if content type with given id exists
-	if _, err := controller.GetContentTypeById(id); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Content Type not found", "result": err.Error()})
+	if _, err := h.ctrl.GetContentTypeById(middleware.Context(c), id); err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
 	}
 
 	// Delete in DB
-	result, err := controller.DeleteContentType(id)
+	result, err := h.ctrl.DeleteContentType(middleware.Context(c), id)
+	if err != nil {
+		return apierror.From(err)
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Content Type moved to trash", "result": result})
+}
+
+// GetTrashedContentTypes query all content types in the trash
+func (h *Handler) GetTrashedContentTypes(c *fiber.Ctx) error {
+	contentTypes, err := h.ctrl.GetTrashedContentTypes(middleware.Context(c), bson.M{})
+	if err != nil && err != store.ErrNotFound {
+		return apierror.From(err)
+	}
+
+	result := make([]contentTypeOutput, 0)
+	for _, ct := range contentTypes {
+		out, err := h.toContentTypeOutput(middleware.Context(c), ct)
+		if err != nil {
+			return apierror.Internal("Error on parsing permissions", err)
+		}
+		result = append(result, *out)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Content Types in trash", "contenttype": result})
+}
+
+// RestoreContentType restore content type from the trash
+func (h *Handler) RestoreContentType(c *fiber.Ctx) error {
+	id := c.Params("id")
+	result, err := h.ctrl.RestoreContentType(middleware.Context(c), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not delete Content Type", "result": err.Error()})
+		return apierror.From(err)
 	}
+	if result.MatchedCount == 0 {
+		return apierror.NotFound("Content Type not found in trash")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Content Type successfully restored", "result": result})
+}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Content Type successfully deleted", "result": result})
+// PurgeContentType permanently delete content type from the trash including all of its entries
+func (h *Handler) PurgeContentType(c *fiber.Ctx) error {
+	id := c.Params("id")
+	result, err := h.ctrl.PurgeContentType(middleware.Context(c), id)
+	if err == store.ErrNotFound {
+		return apierror.NotFound("Content Type not found in trash")
+	}
+	if err != nil {
+		return apierror.From(err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Content Type permanently deleted", "result": result})
 }
 
 // Fields that are returned on GET methods (password and metadata omitted)
 type contentTypeOutput struct {
-	ID          primitive.ObjectID     `bson:"_id" json:"_id" xml:"_id" form:"_id"`
-	TypeName    string                 `bson:"typename" json:"typename" xml:"typename" form:"typename"`
-	Collection  string                 `bson:"collection" json:"collection" xml:"collection" form:"collection"`
-	Permissions map[string][]string    `bson:"permissions" json:"permissions" xml:"permissions" form:"permissions"`
-	FieldSchema map[string]interface{} `bson:"field_schema" json:"field_schema" xml:"field_schema" form:"field_schema"`
+	ID          primitive.ObjectID      `bson:"_id" json:"_id" xml:"_id" form:"_id"`
+	TypeName    string                  `bson:"typename" json:"typename" xml:"typename" form:"typename"`
+	Collection  string                  `bson:"collection" json:"collection" xml:"collection" form:"collection"`
+	Permissions map[string][]string     `bson:"permissions" json:"permissions" xml:"permissions" form:"permissions"`
+	FieldSchema map[string]interface{}  `bson:"field_schema" json:"field_schema" xml:"field_schema" form:"field_schema"`
+	Indexes     []model.IndexDefinition `bson:"indexes" json:"indexes" xml:"indexes" form:"indexes"`
+	IndexState  *controller.IndexState  `bson:"-" json:"index_state,omitempty" xml:"index_state" form:"index_state"`
+	Aliases     []model.CollectionAlias `bson:"aliases" json:"aliases,omitempty" xml:"aliases" form:"aliases"`
+	DeletedAt   *time.Time              `bson:"deleted_at" json:"deleted_at,omitempty" xml:"deleted_at" form:"deleted_at"`
 }
 
 // Make ContentTypeOutput from ContentType
-func toContentTypeOutput(contentType *model.ContentType) (*contentTypeOutput, error) {
+func (h *Handler) toContentTypeOutput(ctx context.Context, contentType *model.ContentType) (*contentTypeOutput, error) {
 	ct := new(contentTypeOutput)
 	ct.ID = contentType.ID
 	ct.TypeName = contentType.TypeName
@@ -186,7 +280,7 @@ func toContentTypeOutput(contentType *model.ContentType) (*contentTypeOutput, er
 	// Parse role ObjectIDs to role name strings
 	permissions := make(map[string][]string)
 	for key, val := range contentType.Permissions {
-		roles, err := controller.GetRoleNames(val)
+		roles, err := h.ctrl.GetRoleNames(ctx, val)
 		if err != nil {
 			return nil, err
 		}
@@ -194,5 +288,8 @@ func toContentTypeOutput(contentType *model.ContentType) (*contentTypeOutput, er
 	}
 	ct.Permissions = permissions
 	ct.FieldSchema = contentType.FieldSchema
+	ct.Indexes = contentType.Indexes
+	ct.Aliases = contentType.Aliases
+	ct.DeletedAt = contentType.DeletedAt
 	return ct, nil
 }
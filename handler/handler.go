@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/D-Bald/fiber-backend/controller"
+)
+
+// Handler serves the API endpoints with the operations of the controller
+type Handler struct {
+	ctrl *controller.Controller
+	// Start of the server, to report its uptime
+	started time.Time
+}
+
+// Returns a handler, that uses the provided controller
+func New(ctrl *controller.Controller) *Handler {
+	return &Handler{ctrl: ctrl, started: time.Now()}
+}
@@ -1,9 +1,57 @@
 package handler
 
 import (
+	"time"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/middleware"
+	"github.com/D-Bald/fiber-backend/version"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-func Healthcheck(c *fiber.Ctx) error {
+func (h *Handler) Healthcheck(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "success", "message": "Fiber-Backend up and running"})
 }
+
+// Liveness probe: succeeds as long as the server handles requests
+func (h *Handler) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "success", "message": "Alive"})
+}
+
+// Readiness probe: fails with 503, until the seeding finished, or while a dependency is down.
+// The details name the failed checks, but not their errors.
+func (h *Handler) Ready(c *fiber.Ctx) error {
+	health := h.ctrl.CheckHealth(middleware.Context(c))
+	if health.Ready {
+		return c.JSON(fiber.Map{"status": "success", "message": "Ready"})
+	}
+	var details []apierror.Detail
+	for _, step := range controller.SeedSteps {
+		if !health.Seeding[step] {
+			details = append(details, apierror.Detail{Field: step, Message: "seeding not finished"})
+		}
+	}
+	for _, d := range health.Dependencies {
+		if !d.Up {
+			details = append(details, apierror.Detail{Field: d.Name, Message: "not reachable"})
+		}
+	}
+	return apierror.New(fiber.StatusServiceUnavailable, apierror.CodeUnavailable, "Not ready").WithDetails(details...)
+}
+
+// Detailed health for admins with the build, uptime, seeding and latency of the dependencies.
+// Unlike the readiness probe it succeeds, if the server is not ready.
+func (h *Handler) GetHealth(c *fiber.Ctx) error {
+	health := h.ctrl.CheckHealth(middleware.Context(c))
+	return c.JSON(fiber.Map{
+		"status":         "success",
+		"message":        "Health",
+		"health":         health,
+		"version":        version.Version,
+		"commit":         version.Commit,
+		"started_at":     h.started,
+		"uptime_seconds": int64(time.Since(h.started).Seconds()),
+	})
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/middleware"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateMigration starts a field migration on all entries of the content type.
+// With `dry_run` the affected entries are only previewed.
+func (h *Handler) CreateMigration(c *fiber.Ctx) error {
+	ct, err := h.ctrl.GetContentTypeById(middleware.Context(c), c.Params("id"))
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+
+	input := new(model.MigrationInput)
+	if err := c.BodyParser(input); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+	if err := controller.ValidateMigration(ct, input); err != nil {
+		return apierror.ValidationFailed("Review your input: invalid migration", apierror.Detail{Field: "field", Message: err.Error()})
+	}
+
+	if input.DryRun {
+		preview, err := h.ctrl.PreviewMigration(middleware.Context(c), ct, input)
+		if errors.Is(err, controller.ErrMigrationTargetExists) {
+			return migrationTargetError(err)
+		}
+		if err == store.ErrNotSupported {
+			return apierror.From(err)
+		}
+		if err != nil {
+			return apierror.Internal("Could not preview migration", err)
+		}
+		return c.JSON(fiber.Map{"status": "success", "message": "Migration preview", "migration": preview})
+	}
+
+	m, err := h.ctrl.StartMigration(middleware.Context(c), ct, input)
+	if err == controller.ErrMigrationRunning {
+		return apierror.Conflict("Could not start migration").Wrap(err)
+	}
+	if errors.Is(err, controller.ErrMigrationTargetExists) {
+		return migrationTargetError(err)
+	}
+	if err == store.ErrNotSupported {
+		return apierror.From(err)
+	}
+	if err != nil {
+		return apierror.Internal("Could not start migration", err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "success", "message": "Migration started", "migration": m})
+}
+
+func migrationTargetError(err error) error {
+	return apierror.ValidationFailed("Review your input: invalid migration", apierror.Detail{Field: "to", Message: err.Error()})
+}
+
+// GetMigrations query all migrations of the content type
+func (h *Handler) GetMigrations(c *fiber.Ctx) error {
+	ct, err := h.ctrl.GetContentTypeById(middleware.Context(c), c.Params("id"))
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+
+	result, err := h.ctrl.GetMigrations(middleware.Context(c), bson.M{"content_type_id": ct.ID})
+	if err != nil && err != store.ErrNotFound {
+		return apierror.From(err)
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "All Migrations", "migration": result})
+}
+
+// GetMigration query a single migration with progress and failure report
+func (h *Handler) GetMigration(c *fiber.Ctx) error {
+	m, err := h.ctrl.GetMigrationById(middleware.Context(c), c.Params("migrationId"))
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Migration not found")
+	}
+	if m.ContentTypeID.Hex() != c.Params("id") {
+		return apierror.NotFound("Migration not found")
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Migration found", "migration": m})
+}
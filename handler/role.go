@@ -3,7 +3,8 @@ package handler
 import (
 	"fmt"
 
-	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/middleware"
 	"github.com/D-Bald/fiber-backend/model"
 	"go.mongodb.org/mongo-driver/bson"
 
@@ -11,36 +12,39 @@ import (
 )
 
 // GetAll query all Roles
-func GetRoles(c *fiber.Ctx) error {
-	result, err := controller.GetRoles(bson.M{})
+func (h *Handler) GetRoles(c *fiber.Ctx) error {
+	result, err := h.ctrl.GetRoles(middleware.Context(c), bson.M{})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Internal Server Error", "role": err.Error()})
+		return apierror.From(err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "All Roles", "role": result})
 }
 
 // CreateRole
-func CreateRole(c *fiber.Ctx) error {
+func (h *Handler) CreateRole(c *fiber.Ctx) error {
 	role := new(model.Role)
 
 	// Parse input
-	if err := c.BodyParser(role); err != nil || role.Tag == "" || role.Name == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Review your input: 'tag' and 'name' required", "role": err.Error()})
+	if err := c.BodyParser(role); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+	if role.Tag == "" || role.Name == "" {
+		return apierror.InvalidInput("Review your input: 'tag' and 'name' required")
 	}
 
 	// Check if already exists
-	checkRoleTag, _ := controller.GetRoleByTag(role.Tag)
+	checkRoleTag, _ := h.ctrl.GetRoleByTag(middleware.Context(c), role.Tag)
 	if checkRoleTag != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Role tag already in use with role name: %s", checkRoleTag.Name), "role": nil})
+		return apierror.AlreadyExists(fmt.Sprintf("Role tag already in use with role name: %s", checkRoleTag.Name))
 	}
-	checkRoleName, _ := controller.GetRoleByName(role.Name)
+	checkRoleName, _ := h.ctrl.GetRoleByName(middleware.Context(c), role.Name)
 	if checkRoleName != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Role name already in use with role tag: %s", checkRoleName.Tag), "role": nil})
+		return apierror.AlreadyExists(fmt.Sprintf("Role name already in use with role tag: %s", checkRoleName.Tag))
 	}
 
 	// Insert in DB
-	if _, err := controller.CreateRole(role); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create role", "role": err.Error()})
+	if _, err := h.ctrl.CreateRole(middleware.Context(c), role); err != nil {
+		return apierror.From(err)
 	}
 
 	return c.JSON(fiber.Map{"status": "success", "message": "Created Role", "role": role})
@@ -49,44 +53,48 @@ func CreateRole(c *fiber.Ctx) error {
 // Update role with parameters from request body
 // lookup by: role
 // field to update: weight
-func UpdateRole(c *fiber.Ctx) error {
+func (h *Handler) UpdateRole(c *fiber.Ctx) error {
 	id := c.Params("id")
 	r := new(model.Role)
-	if err := c.BodyParser(r); err != nil || r == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Review your input", "result": err.Error()})
+	if err := c.BodyParser(r); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
 	}
 
 	// Check if role exists
+	if _, err := h.ctrl.GetRoleById(middleware.Context(c), id); err != nil {
+		return apierror.NotFoundFrom(err, "Role not found")
+	}
+
 	// Check if already exists
-	checkRoleTag, _ := controller.GetRoleByTag(r.Tag)
+	checkRoleTag, _ := h.ctrl.GetRoleByTag(middleware.Context(c), r.Tag)
 	if checkRoleTag != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Role tag already in use with role name: %s", checkRoleTag.Name), "result": nil})
+		return apierror.AlreadyExists(fmt.Sprintf("Role tag already in use with role name: %s", checkRoleTag.Name))
 	}
-	checkRoleName, _ := controller.GetRoleByName(r.Name)
+	checkRoleName, _ := h.ctrl.GetRoleByName(middleware.Context(c), r.Name)
 	if checkRoleName != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Role name already in use with role tag: %s", checkRoleName.Tag), "result": nil})
+		return apierror.AlreadyExists(fmt.Sprintf("Role name already in use with role tag: %s", checkRoleName.Tag))
 	}
 
-	result, err := controller.UpdateRole(id, r)
+	result, err := h.ctrl.UpdateRole(middleware.Context(c), id, r)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not update role", "result": err.Error()})
+		return apierror.From(err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "Role successfully updated", "result": result})
 }
 
 // DeleteRole delete role with provided role name
-func DeleteRole(c *fiber.Ctx) error {
+func (h *Handler) DeleteRole(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	// Check if role exists
-	if _, err := controller.GetRoleById(id); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Role not found", "result": err.Error()})
+	if _, err := h.ctrl.GetRoleById(middleware.Context(c), id); err != nil {
+		return apierror.NotFoundFrom(err, "Role not found")
 	}
 
 	// Delete in DB
-	result, err := controller.DeleteRole(id)
+	result, err := h.ctrl.DeleteRole(middleware.Context(c), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not delete role", "result": err.Error()})
+		return apierror.From(err)
 	}
 
 	return c.JSON(fiber.Map{"status": "success", "message": "Role successfully deleted", "result": result})
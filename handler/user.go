@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"context"
+
 	"fmt"
 	"reflect"
 	"time"
 
+	"github.com/D-Bald/fiber-backend/apierror"
 	"github.com/D-Bald/fiber-backend/config"
-	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/middleware"
 	"github.com/D-Bald/fiber-backend/model"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
@@ -16,7 +19,7 @@ import (
 )
 
 // Query users with filter provided in query params
-func GetUsers(c *fiber.Ctx) error {
+func (h *Handler) GetUsers(c *fiber.Ctx) error {
 	type userInput struct {
 		ID        string    `bson:"_id" json:"_id" xml:"_id" form:"_id" query:"_id"`
 		CreatedAt time.Time `bson:"created_at" json:"created_at" xml:"created_at" form:"created_at" query:"created_at"`
@@ -31,7 +34,7 @@ func GetUsers(c *fiber.Ctx) error {
 
 	// parse input
 	if err := c.QueryParser(parseObject); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No match found", "data": err.Error()})
+		return apierror.InvalidQuery("Invalid query").Wrap(err)
 	}
 
 	// set `nil` for empty values
@@ -46,16 +49,16 @@ func GetUsers(c *fiber.Ctx) error {
 			case "_id":
 				uID, err := primitive.ObjectIDFromHex(v.Field(i).String())
 				if err != nil {
-					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Invalid ID", "data": err.Error()})
+					return apierror.InvalidID(err)
 				}
 				filter["_id"] = uID
 			// Parses roles manually to ObjectIDs and add it to filter
 			case "roles":
 				var roleObjectIDs []primitive.ObjectID
 				for _, r := range v.Field(i).Interface().([]string) {
-					rObj, err := controller.GetRoleByName(r)
+					rObj, err := h.ctrl.GetRoleByName(middleware.Context(c), r)
 					if err != nil {
-						return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Invalid ID", "data": err.Error()})
+						return apierror.InvalidQuery(fmt.Sprintf("Role not found: %s", r))
 					}
 					roleObjectIDs = append(roleObjectIDs, rObj.ID)
 				}
@@ -78,17 +81,17 @@ func GetUsers(c *fiber.Ctx) error {
 	}
 
 	// get user from DB
-	users, err := controller.GetUsers(filter)
+	users, err := h.ctrl.GetUsers(middleware.Context(c), filter)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "No match found", "user": err.Error()})
+		return apierror.NotFoundFrom(err, "No match found")
 	}
 
 	// Return a subset of fields in readable format
 	result := make([]userOutput, 0)
 	for _, u := range users {
-		out, err := toUserOutput(u)
+		out, err := h.toUserOutput(middleware.Context(c), u)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error on parsing user roles", "data": err.Error()})
+			return apierror.Internal("Error on parsing user roles", err)
 		}
 		result = append(result, *out)
 	}
@@ -97,32 +100,35 @@ func GetUsers(c *fiber.Ctx) error {
 }
 
 // CreateUser new user
-func CreateUser(c *fiber.Ctx) error {
+func (h *Handler) CreateUser(c *fiber.Ctx) error {
 	user := new(model.User)
 
 	// Parse input
-	if err := c.BodyParser(user); err != nil || user.Username == "" || user.Email == "" || user.Password == "" {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "user": err.Error()})
+	if err := c.BodyParser(user); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+	if user.Username == "" || user.Email == "" || user.Password == "" {
+		return apierror.InvalidInput("Review your input: 'username', 'email' and 'password' required")
 	}
 
 	// Check if already exists
-	if u, _ := controller.GetUserByUsername(user.Username); u != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Username already taken", "user": nil})
+	if u, _ := h.ctrl.GetUserByUsername(middleware.Context(c), user.Username); u != nil {
+		return apierror.AlreadyExists("Username already taken")
 	}
-	if u, _ := controller.GetUserByEmail(user.Email); u != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "User with given Email already exists", "user": nil})
+	if u, _ := h.ctrl.GetUserByEmail(middleware.Context(c), user.Email); u != nil {
+		return apierror.AlreadyExists("User with given Email already exists")
 	}
 
 	// Add default role to roles
-	uRole, err := controller.GetRoleByTag("default")
+	uRole, err := h.ctrl.GetRoleByTag(middleware.Context(c), "default")
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create user", "user": err.Error()})
+		return apierror.Internal("Could not create user", err)
 	}
 	user.Roles = append(user.Roles, uRole.ID)
 
 	// Insert in DB
-	if _, err := controller.CreateUser(user); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create user", "user": err.Error()})
+	if _, err := h.ctrl.CreateUser(middleware.Context(c), user); err != nil {
+		return apierror.From(err)
 	}
 
 	// Token for response
@@ -135,89 +141,95 @@ func CreateUser(c *fiber.Ctx) error {
 	claims["roles"] = user.Roles
 	claims["exp"] = time.Now().Add(time.Hour * 72).Unix()
 
-	t, err := token.SignedString([]byte(config.Config("SECRET")))
+	t, err := token.SignedString([]byte(config.Get().Auth.Secret))
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return apierror.Internal("Could not sign token", err)
 	}
 
 	// Return a subset of fields in readable format
-	userOutput, err := toUserOutput(user)
+	userOutput, err := h.toUserOutput(middleware.Context(c), user)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create user", "user": err.Error()})
+		return apierror.Internal("Could not create user", err)
 	}
-	return c.JSON(fiber.Map{"status": "success", "message": "Created user", "token": t, "user ": userOutput})
+	return c.JSON(fiber.Map{"status": "success", "message": "Created user", "token": t, "user": userOutput})
 }
 
 // Update user with parameters from request body
-func UpdateUser(c *fiber.Ctx) error {
+func (h *Handler) UpdateUser(c *fiber.Ctx) error {
 	id := c.Params("id")
 	token := c.Locals("user").(*jwt.Token)
 
 	if !isValidToken(token, id) && !isAdminToken(token) {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "result": nil})
+		return apierror.Forbidden("Token does not belong to this user")
 	}
 
 	uui := new(model.UserUpdate)
 
-	if err := c.BodyParser(uui); err != nil || uui == nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "result": err.Error()})
+	if err := c.BodyParser(uui); err != nil {
+		return apierror.InvalidInput("Review your input").Wrap(err)
+	}
+	if _, err := h.ctrl.GetUserById(middleware.Context(c), id); err != nil {
+		return apierror.NotFoundFrom(err, "User not found")
 	}
 
 	if uui.Username != "" {
-		if u, _ := controller.GetUserByUsername(uui.Username); u != nil {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Username already taken", "result": nil})
+		if u, _ := h.ctrl.GetUserByUsername(middleware.Context(c), uui.Username); u != nil {
+			return apierror.AlreadyExists("Username already taken")
 		}
 	}
 	if uui.Email != "" {
-		if u, _ := controller.GetUserByEmail(uui.Email); u != nil {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "User with given Email already exists", "result": nil})
+		if u, _ := h.ctrl.GetUserByEmail(middleware.Context(c), uui.Email); u != nil {
+			return apierror.AlreadyExists("User with given Email already exists")
 		}
 	}
 
 	if uui.Roles != nil {
 		// Roles can only be updated by admins
 		if !isAdminToken(token) {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"status": "error", "message": "Admin rights required to update user roles", "result": nil})
+			return apierror.Forbidden("Admin rights required to update user roles")
 		}
 		// Checks, if all role are valid
 		for _, r := range uui.Roles {
-			if !controller.IsValidRole(r) {
-				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Role not found: %s", r), "result": nil})
+			if !h.ctrl.IsValidRole(middleware.Context(c), r) {
+				return apierror.ValidationFailed("Review your input", apierror.Detail{Field: "roles", Message: fmt.Sprintf("Role not found: %s", r)})
 			}
 		}
 	}
 
-	result, err := controller.UpdateUser(id, uui)
+	result, err := h.ctrl.UpdateUser(middleware.Context(c), id, uui)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not update User", "result": err.Error()})
+		return apierror.From(err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "User successfully updated", "result": result})
 }
 
 // DeleteUser delete user
-func DeleteUser(c *fiber.Ctx) error {
+func (h *Handler) DeleteUser(c *fiber.Ctx) error {
 	type PasswordInput struct {
 		Password string `json:"password" xml:"password" form:"password"`
 	}
 	var pi PasswordInput
 	if err := c.BodyParser(&pi); err != nil {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Review your input", "result": err.Error()})
+		return apierror.InvalidInput("Review your input").Wrap(err)
 	}
 
 	id := c.Params("id")
 	token := c.Locals("user").(*jwt.Token)
 
 	if !isValidToken(token, id) && !isAdminToken(token) {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid token id", "result": nil})
+		return apierror.Forbidden("Token does not belong to this user")
 	}
 
-	if !isValidUser(id, pi.Password) && !isAdminToken(token) {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Not valid user", "result": nil})
+	if !h.isValidUser(middleware.Context(c), id, pi.Password) && !isAdminToken(token) {
+		return apierror.Unauthorized("Invalid password")
 	}
 
-	result, err := controller.DeleteUser(id)
+	if _, err := h.ctrl.GetUserById(middleware.Context(c), id); err != nil {
+		return apierror.NotFoundFrom(err, "User not found")
+	}
+	result, err := h.ctrl.DeleteUser(middleware.Context(c), id)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not delete User", "result": err.Error()})
+		return apierror.From(err)
 	}
 	return c.JSON(fiber.Map{"status": "success", "message": "User successfully deleted", "result": result})
 }
@@ -231,16 +243,17 @@ func isValidToken(t *jwt.Token, id string) bool {
 
 // Checks if the role claim of the token is `admin`
 func isAdminToken(t *jwt.Token) bool {
-	return t.Claims.(jwt.MapClaims)["admin"].(bool)
+	admin, _ := t.Claims.(jwt.MapClaims)["admin"].(bool)
+	return admin
 }
 
 // Checks if the user exists in the DB and if the provided password matches the saved one
-func isValidUser(id string, p string) bool {
-	user, err := controller.GetUserById(id)
+func (h *Handler) isValidUser(ctx context.Context, id string, p string) bool {
+	user, err := h.ctrl.GetUserById(ctx, id)
 	if err != nil || user.Username == "" {
 		return false
 	}
-	pw, err := controller.GetUserPasswordHash(id)
+	pw, err := h.ctrl.GetUserPasswordHash(ctx, id)
 	if err != nil {
 		return false
 	}
@@ -266,14 +279,14 @@ type userOutput struct {
 }
 
 // Make UserOutput from User
-func toUserOutput(user *model.User) (*userOutput, error) {
+func (h *Handler) toUserOutput(ctx context.Context, user *model.User) (*userOutput, error) {
 	u := new(userOutput)
 	u.ID = user.ID
 	u.Username = user.Username
 	u.Email = user.Email
 	u.Names = user.Names
 	// Parse role ObjectIDs to role name strings
-	roles, err := controller.GetRoleNames(user.Roles)
+	roles, err := h.ctrl.GetRoleNames(ctx, user.Roles)
 	if err != nil {
 		return nil, err
 	}
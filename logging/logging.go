@@ -0,0 +1,61 @@
+// Package logging writes structured logs as JSON for log collectors or as readable lines for the console.
+//
+// Requests pass a logger with their request ID, trace and user in their context,
+// so that the log lines of the controller can be related to the request.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+
+	"github.com/rs/zerolog"
+)
+
+// Formats of the logs
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Logger of the process. It writes JSON at level info until Setup is called.
+var logger = zerolog.New(os.Stderr).Level(zerolog.InfoLevel).With().Timestamp().Logger()
+
+func init() {
+	// Contexts without a logger of a request, e.g. the ones of background jobs, log with the logger of the process
+	zerolog.DefaultContextLogger = &logger
+}
+
+// Sets the level and format of the logs. Lines of the log package, e.g. the ones of libraries, are written without a level.
+func Setup(cfg config.Logging) error {
+	level, err := zerolog.ParseLevel(strings.ToLower(cfg.Level))
+	if err != nil || level == zerolog.NoLevel {
+		return fmt.Errorf("unknown log level: %s", cfg.Level)
+	}
+	switch strings.ToLower(cfg.Format) {
+	case FormatJSON:
+		logger = zerolog.New(os.Stdout).Level(level).With().Timestamp().Logger()
+	case FormatConsole:
+		logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(level).With().Timestamp().Logger()
+	default:
+		return fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+	log.SetFlags(0)
+	log.SetOutput(logger)
+	return nil
+}
+
+// Returns the logger of the process
+func Logger() *zerolog.Logger {
+	return &logger
+}
+
+// Returns the logger of the request or background job, that ctx belongs to
+func Ctx(ctx context.Context) *zerolog.Logger {
+	return zerolog.Ctx(ctx)
+}
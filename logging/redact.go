@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Value, that replaces passwords, tokens and secrets in the logs
+const Redacted = "<redacted>"
+
+// Parts of the names of fields, whose values must not be logged
+var sensitive = []string{"password", "token", "secret", "authorization", "jwt"}
+
+// Returns true, if the values of the field must not be logged, e.g. for "password" or "access_token"
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitive {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the query string with the values of sensitive parameters replaced
+func RedactQuery(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return Redacted
+	}
+	for key := range values {
+		if IsSensitive(key) {
+			values[key] = []string{Redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// Returns the JSON document with the values of sensitive fields replaced at any depth.
+// Invalid documents are replaced completely, because they can not be searched for sensitive fields.
+func RedactJSON(body []byte) interface{} {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return Redacted
+	}
+	return redact(doc)
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if IsSensitive(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = redact(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,28 @@
+package logging
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	got := RedactJSON([]byte(`{"username":"admin","password":"hunter2","profile":{"api_token":"abc","names":["a"]},"users":[{"Password":"x"}]}`))
+	want := map[string]interface{}{
+		"username": "admin",
+		"password": Redacted,
+		"profile":  map[string]interface{}{"api_token": Redacted, "names": []interface{}{"a"}},
+		"users":    []interface{}{map[string]interface{}{"Password": Redacted}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v", got)
+	}
+	if got := RedactJSON([]byte(`{"password":`)); got != Redacted {
+		t.Errorf("invalid document logged as %v", got)
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	if got := RedactQuery("title=news&access_token=abc"); got != "access_token=%3Credacted%3E&title=news" {
+		t.Errorf("got %s", got)
+	}
+}
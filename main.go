@@ -1,48 +1,208 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/cli"
 	"github.com/D-Bald/fiber-backend/config"
 	"github.com/D-Bald/fiber-backend/controller"
 	"github.com/D-Bald/fiber-backend/database"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/metrics"
+	"github.com/D-Bald/fiber-backend/middleware"
+	"github.com/D-Bald/fiber-backend/responsecache"
 	"github.com/D-Bald/fiber-backend/router"
+	"github.com/D-Bald/fiber-backend/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
+// Time to close the connections of the storage backend after the shutdown
+const closeTimeout = 5 * time.Second
+
 func main() {
+	// Load the settings from the config file, .env file, environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Set(cfg)
+
+	// Run subcommands like backup or restore instead of the server
+	if len(args) > 0 {
+		if err := cli.Run(args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Refuse to start with missing settings, weak secrets or the sample admin password
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Write structured logs with the configured level and format
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatal(err)
+	}
+	logger := logging.Logger()
+
+	// Export the spans of the requests and database commands
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Could not set up tracing")
+	}
+
 	// Create a Fiber app
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: apierror.Handler,
+	})
 	app.Use(cors.New())
 
 	// prevent the server crash from panics like body-parsing invalid input data
 	app.Use(recover.New())
 
-	// Connect to the database
-	if err := database.Connect(); err != nil {
-		log.Fatal(err)
+	// Request IDs are returned in the `X-Request-ID` header and written to the logs and the audit log
+	app.Use(middleware.RequestID)
+
+	// Connect to the storage backend
+	backend, err := database.Open(cfg.Storage.Backend)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Could not open the storage backend")
+	}
+	ctrl := controller.New(backend.Store)
+	// The server is not ready, while the storage is not reachable
+	ctrl.AddDependency(backend.Name, backend.Ping)
+	// Drop cached content types and roles, when other servers change them
+	if backend.Watch != nil {
+		ctrl.WatchCache(backend.Watch)
+	}
+	// Cache the responses of anonymous content requests
+	responses, err := responsecache.Open(cfg.ResponseCache)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Could not open the response cache")
 	}
+	if responses != nil {
+		ctrl.UseResponseCache(responses)
+	}
+	ctx := context.Background()
 
 	// Initialize Role System
-	if err := controller.InitRoles(); err != nil {
-		log.Fatal(err)
+	if err := ctrl.InitRoles(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Could not initialize the roles")
 	}
 
 	// Initialize content types
-	if err := controller.InitContentTypes(); err != nil {
-		log.Fatal(err)
+	if err := ctrl.InitContentTypes(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Could not initialize the content types")
+	}
+
+	// Initialize indexes of the audit log
+	if err := ctrl.InitAuditLog(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Could not initialize the audit log")
+	}
+
+	// Initialize the unique index of the migration jobs
+	if err := ctrl.InitMigrations(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Could not initialize the migrations")
 	}
 
 	// Initialize admin user
-	if err := controller.InitAdminUser(); err != nil {
-		log.Fatal(err)
+	if err := ctrl.InitAdminUser(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Could not initialize the admin user")
+	}
+
+	// Finish deletions of roles and content types interrupted by a crash
+	if err := ctrl.ResumeCascades(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Could not finish the interrupted deletions")
+	}
+
+	// Resume field migrations interrupted by the last shutdown
+	if err := ctrl.ResumeMigrations(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Could not resume the field migrations")
 	}
 
+	// Purge expired content types and entries from the trash
+	ctrl.StartTrashPurge()
+
 	// Start app
-	router.SetupRoutes(app)
-	log.Fatal(app.Listen(fmt.Sprintf(":%v", config.Config("FIBER_PORT"))))
+	router.SetupRoutes(app, ctrl)
+	listening := make(chan error, 1)
+	go func() {
+		listening <- app.Listen(fmt.Sprintf(":%d", cfg.Server.Port))
+	}()
+
+	// Serve the metrics on a port of their own, e.g. one that is not exposed publicly
+	if m := cfg.Metrics; m.Enabled && m.Port != 0 {
+		metricsServer := metrics.NewServer(fmt.Sprintf(":%d", m.Port), m.Path, m.Token)
+		defer metricsServer.Close()
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				listening <- err
+			}
+		}()
+	}
+
+	// Shut down gracefully on SIGTERM or SIGINT
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err = <-listening:
+	case sig := <-signals:
+		logger.Info().Str("signal", sig.String()).Msg("Shutting down")
+		shutdown(app, ctrl, cfg.Server.ShutdownTimeout)
+	}
+
+	// Close the connections of the storage backend
+	closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	if err := backend.Close(closeCtx); err != nil {
+		logger.Error().Err(err).Msg("Could not close the storage backend")
+	}
+	if responses != nil {
+		if err := responses.Close(); err != nil {
+			logger.Error().Err(err).Msg("Could not close the response cache")
+		}
+	}
+	// Export the remaining spans
+	if err := shutdownTracing(closeCtx); err != nil {
+		logger.Error().Err(err).Msg("Could not export the remaining spans")
+	}
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Server stopped")
+	}
+}
+
+// Stops accepting connections, waits for the running requests and stops the background workers.
+// Requests and workers, that are still running after the timeout, are abandoned.
+func shutdown(app *fiber.App, ctrl *controller.Controller, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- app.Shutdown()
+	}()
+	select {
+	case err := <-drained:
+		if err != nil {
+			logging.Logger().Error().Err(err).Msg("Could not shut down the server")
+		}
+	case <-ctx.Done():
+		logging.Logger().Warn().Dur("timeout", timeout).Msg("Requests still running after the shutdown timeout")
+	}
+
+	if err := ctrl.Shutdown(ctx); err != nil {
+		logging.Logger().Warn().Dur("timeout", timeout).Msg("Background jobs still running after the shutdown timeout")
+	}
 }
@@ -0,0 +1,95 @@
+// Package metrics collects the Prometheus metrics of the server:
+// the HTTP requests by route, the MongoDB operations, the logins and the Go runtime.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prefix of the metrics of the server
+const namespace = "fiber_backend"
+
+// Registry of all metrics. The default registry of the Prometheus client is not used,
+// so that only these metrics are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by method, route template, content collection and status.",
+	}, []string{"method", "route", "collection", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route template, content collection and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "collection", "status"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Number of login attempts by result: success or failure.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		logins,
+		mongoDuration,
+		mongoErrors,
+	)
+}
+
+// Records a handled HTTP request. route is the template like "/api/:content/:id", not the path of the request.
+// collection is empty for routes without content collection.
+func ObserveRequest(method string, route string, collection string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, collection, code).Inc()
+	httpDuration.WithLabelValues(method, route, collection, code).Observe(d.Seconds())
+}
+
+// Records a login attempt
+func ObserveLogin(success bool) {
+	if success {
+		logins.WithLabelValues("success").Inc()
+	} else {
+		logins.WithLabelValues("failure").Inc()
+	}
+}
+
+// Returns the handler of the metrics endpoint. If token is set, scrapers have to send it as bearer token.
+func Handler(token string) http.Handler {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Returns a server, that only serves the metrics endpoint at path, e.g. on a port, that is not public
+func NewServer(addr string, path string, token string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(path, Handler(token))
+	return &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongodb_operation_duration_seconds",
+		Help:      "Latency of MongoDB commands by command and collection.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"command", "collection"})
+
+	mongoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongodb_operation_errors_total",
+		Help:      "Number of failed MongoDB commands by command and collection.",
+	}, []string{"command", "collection"})
+)
+
+// Returns the monitor of the MongoDB client, that records the latency and errors of all commands,
+// whether they are sent by the stores or the controller directly
+func CommandMonitor() *event.CommandMonitor {
+	// The collection is only part of the started event
+	var collections sync.Map
+	finished := func(e event.CommandFinishedEvent, failed bool) {
+		collection := ""
+		if c, ok := collections.LoadAndDelete(e.RequestID); ok {
+			collection = c.(string)
+		}
+		mongoDuration.WithLabelValues(e.CommandName, collection).Observe(time.Duration(e.DurationNanos).Seconds())
+		if failed {
+			mongoErrors.WithLabelValues(e.CommandName, collection).Inc()
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			// Commands like find or insert name their collection, others like ping the value 1
+			collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+			collections.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent, false)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.CommandFinishedEvent, true)
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestCommandMonitor(t *testing.T) {
+	m := CommandMonitor()
+	ctx := context.Background()
+	command := func(name string, collection interface{}) bson.Raw {
+		b, err := bson.Marshal(bson.M{name: collection})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	m.Started(ctx, &event.CommandStartedEvent{Command: command("find", "blogposts"), CommandName: "find", RequestID: 1})
+	m.Started(ctx, &event.CommandStartedEvent{Command: command("insert", "blogposts"), CommandName: "insert", RequestID: 2})
+	m.Started(ctx, &event.CommandStartedEvent{Command: command("ping", 1), CommandName: "ping", RequestID: 3})
+	finished := func(id int64, name string) event.CommandFinishedEvent {
+		return event.CommandFinishedEvent{DurationNanos: int64(2 * time.Millisecond), CommandName: name, RequestID: id}
+	}
+	m.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(1, "find")})
+	m.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished(2, "insert"), Failure: "duplicate key"})
+	m.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished(3, "ping")})
+
+	if n := testutil.ToFloat64(mongoErrors.WithLabelValues("insert", "blogposts")); n != 1 {
+		t.Errorf("%v insert errors", n)
+	}
+	if n := testutil.ToFloat64(mongoErrors.WithLabelValues("find", "blogposts")); n != 0 {
+		t.Errorf("%v find errors", n)
+	}
+	if n := testutil.CollectAndCount(mongoDuration); n != 3 {
+		t.Errorf("%d latency series, want find, insert and ping", n)
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"github.com/D-Bald/fiber-backend/apierror"
 	"github.com/form3tech-oss/jwt-go"
 
 	"github.com/gofiber/fiber/v2"
@@ -8,11 +9,12 @@ import (
 
 // AdminOnly checks for "admin" claim in jwt token.
 // This Middleware requires Protected() to be called in middleware chain before.
-func AdminOnly(c *fiber.Ctx) error {
+var AdminOnly = Traced("AdminOnly", adminOnly)
+
+func adminOnly(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
-	if token.Claims.(jwt.MapClaims)["admin"].(bool) {
+	if admin, _ := token.Claims.(jwt.MapClaims)["admin"].(bool); admin {
 		return c.Next()
 	}
-	return c.Status(fiber.StatusUnauthorized).
-		JSON(fiber.Map{"status": "error", "message": "Admin only", "data": nil})
+	return apierror.Forbidden("Admin only")
 }
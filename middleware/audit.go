@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/form3tech-oss/jwt-go"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Audit passes the client IP and request ID to the controller, that records them with the audited actions of the request.
+// The actor is anonymous until `Protected` validated the token of a user, so it has to follow `RequestContext` in the middleware chain.
+func Audit(c *fiber.Ctx) error {
+	requestID, _ := c.Locals("requestid").(string)
+	source := controller.AuditSource{
+		Actor:     model.AuditActor{Type: model.ActorAnonymous},
+		IP:        c.IP(),
+		RequestID: requestID,
+	}
+	deriveContext(c, func(ctx context.Context) context.Context {
+		return controller.WithAuditSource(ctx, source)
+	})
+	return c.Next()
+}
+
+// Sets the user of the validated token as actor of the audited actions of the request.
+// It is called by `Protected` after the token was validated.
+func auditUser(c *fiber.Ctx) {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return
+	}
+	deriveContext(c, func(ctx context.Context) context.Context {
+		source := controller.AuditSourceFrom(ctx)
+		source.Actor = model.AuditActor{Type: model.ActorUser}
+		source.Actor.ID, _ = claims["user_id"].(string)
+		source.Actor.Name, _ = claims["username"].(string)
+		return controller.WithAuditSource(ctx, source)
+	})
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"github.com/D-Bald/fiber-backend/apierror"
 	"github.com/D-Bald/fiber-backend/config"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,17 +10,22 @@ import (
 
 // Protected protect routes
 func Protected() fiber.Handler {
-	return jwtware.New(jwtware.Config{
-		SigningKey:   []byte(config.Config("SECRET")),
-		ErrorHandler: jwtError,
-	})
+	return Traced("Protected", jwtware.New(jwtware.Config{
+		SigningKey:     []byte(config.Get().Auth.Secret),
+		ErrorHandler:   jwtError,
+		SuccessHandler: authenticated,
+	}))
+}
+
+// Names the user of the validated token in the log lines and audit log entries of the request
+func authenticated(c *fiber.Ctx) error {
+	auditUser(c)
+	return logUser(c)
 }
 
 func jwtError(c *fiber.Ctx, err error) error {
 	if err.Error() == "Missing or malformed JWT" {
-		return c.Status(fiber.StatusBadRequest).
-			JSON(fiber.Map{"status": "error", "message": "Missing or malformed JWT", "data": nil})
+		return apierror.Unauthorized("Missing or malformed JWT")
 	}
-	return c.Status(fiber.StatusUnauthorized).
-		JSON(fiber.Map{"status": "error", "message": "Invalid or expired JWT", "data": nil})
+	return apierror.Unauthorized("Invalid or expired JWT")
 }
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/logging"
+	"github.com/D-Bald/fiber-backend/responsecache"
+	"github.com/D-Bald/fiber-backend/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Key of the surrogate keys of a response in the locals of a request
+const surrogateKeysKey = "middleware.surrogatekeys"
+
+// Longest Surrogate-Key header. CDNs limit its length, so larger responses only send the key of their collection.
+const maxSurrogateKeyHeader = 16 * 1024
+
+// Headers of cached responses
+const (
+	HeaderSurrogateKey = "Surrogate-Key"
+	HeaderXCache       = "X-Cache"
+)
+
+// CacheResponses serves anonymous GET requests of content from the response cache of the controller, if it has one.
+// Responses are cached by their path, their sorted query and the requested language, if their status is 200.
+// Their tags are the collection and the entries, that the handler added with `AddSurrogateKeys`, so that writes purge them precisely.
+// Requests with an Authorization header are neither served from nor stored in the cache.
+// Misses are read from the primary, so that no response of a replica, that lags behind a write, is cached.
+// It has to follow `ReplicaReads` in the middleware chain.
+func CacheResponses(ctrl *controller.Controller, cfg config.ResponseCache) fiber.Handler {
+	cacheControl := fmt.Sprintf("public, max-age=%d, s-maxage=%d", int(cfg.MaxAge.Seconds()), int(cfg.SharedMaxAge.Seconds()))
+	return func(c *fiber.Ctx) error {
+		cache := ctrl.ResponseCache()
+		if cache == nil || c.Method() != fiber.MethodGet {
+			return c.Next()
+		}
+		if c.Get(fiber.HeaderAuthorization) != "" {
+			c.Set(fiber.HeaderCacheControl, "private")
+			return c.Next()
+		}
+
+		ctx := Context(c)
+		coll := c.Params("content")
+		// The locale query param overrides the Accept-Language header
+		language := ""
+		if c.Query("locale") == "" {
+			language = c.Get(fiber.HeaderAcceptLanguage)
+		}
+		key := responsecache.Key(c.Path(), string(c.Request().URI().QueryString()), language)
+
+		r, ok, err := cache.Get(ctx, key)
+		if err != nil {
+			logging.Ctx(ctx).Warn().Err(err).Msg("Could not read the cached response")
+		} else if ok {
+			setCacheHeaders(c, cacheControl, r.Tags, "HIT")
+			c.Set(fiber.HeaderContentType, r.ContentType)
+			if r.ContentLanguage != "" {
+				c.Set(fiber.HeaderContentLanguage, r.ContentLanguage)
+			}
+			return c.Status(r.Status).Send(r.Body)
+		}
+
+		// Responses are only stored, if their collection was not purged while they were computed
+		gen, genErr := cache.Generation(ctx, coll)
+		if genErr != nil {
+			logging.Ctx(ctx).Warn().Err(genErr).Msg("Could not read the generation of the cached responses")
+		}
+		deriveContext(c, store.WithoutReplicaReads)
+		if err := c.Next(); err != nil || c.Response().StatusCode() != fiber.StatusOK {
+			return err
+		}
+
+		tags := append([]string{responsecache.CollectionTag(coll)}, surrogateKeys(c)...)
+		res := c.Response()
+		r = &responsecache.Response{
+			Status:          res.StatusCode(),
+			ContentType:     string(res.Header.ContentType()),
+			ContentLanguage: string(res.Header.Peek(fiber.HeaderContentLanguage)),
+			Body:            append([]byte(nil), res.Body()...),
+			Tags:            tags,
+		}
+		if genErr == nil {
+			if err := cache.Set(Context(c), key, r, gen, cfg.TTL); err != nil {
+				logging.Ctx(ctx).Warn().Err(err).Msg("Could not cache the response")
+			}
+		}
+		setCacheHeaders(c, cacheControl, tags, "MISS")
+		return nil
+	}
+}
+
+// Adds keys to the Surrogate-Key header of a cached response, so that it is purged together with them
+func AddSurrogateKeys(c *fiber.Ctx, keys ...string) {
+	c.Locals(surrogateKeysKey, append(surrogateKeys(c), keys...))
+}
+
+func surrogateKeys(c *fiber.Ctx) []string {
+	keys, _ := c.Locals(surrogateKeysKey).([]string)
+	return keys
+}
+
+// Sets the headers of a cacheable response, whose tags are sent as surrogate keys
+func setCacheHeaders(c *fiber.Ctx, cacheControl string, tags []string, status string) {
+	c.Set(fiber.HeaderCacheControl, cacheControl)
+	c.Vary(fiber.HeaderAcceptLanguage)
+	keys := strings.Join(tags, " ")
+	if len(keys) > maxSurrogateKeyHeader && len(tags) > 0 {
+		keys = tags[0]
+	}
+	c.Set(HeaderSurrogateKey, keys)
+	c.Set(HeaderXCache, status)
+}
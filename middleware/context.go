@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Key of the request context in the locals of a request
+const contextKey = "middleware.context"
+
+// Context of a request, that is passed to the controller and the storage
+type requestContext struct {
+	// Carries the values of the request, but is not cancelled with the handler, so that streamed responses can derive their context from it
+	values   context.Context
+	deadline time.Time
+	ctx      context.Context
+}
+
+// Timeout of the routes, that match the method and path
+type routeTimeout struct {
+	method   string // empty for all methods
+	segments []string
+	timeout  time.Duration
+}
+
+// RequestContext gives every request a context, that is cancelled when the request is done, the client closed the connection
+// or its timeout expired. The timeout of a route is looked up in routes by patterns like "GET /api/:content/export",
+// where parameters match a single path segment and "*" the rest of the path. Other requests use the fallback timeout.
+// The context is derived from the context of fasthttp without its cancellation, because that is cancelled
+// as soon as the server shuts down and would abort the requests, that are still drained.
+func RequestContext(fallback time.Duration, routes map[string]time.Duration) fiber.Handler {
+	timeouts := parseRouteTimeouts(routes)
+	return func(c *fiber.Ctx) error {
+		timeout := fallback
+		for _, rt := range timeouts {
+			if rt.matches(c.Method(), c.Path()) {
+				timeout = rt.timeout
+				break
+			}
+		}
+		rc := &requestContext{values: detachedContext{c.Context()}, deadline: time.Now().Add(timeout)}
+		ctx, cancel := context.WithDeadline(rc.values, rc.deadline)
+		defer cancel()
+		defer cancelOnDisconnect(c, cancel)()
+		rc.ctx = ctx
+		c.Locals(contextKey, rc)
+		return c.Next()
+	}
+}
+
+// Returns the context of the request, that has to be passed to every call of the controller
+func Context(c *fiber.Ctx) context.Context {
+	if rc, ok := c.Locals(contextKey).(*requestContext); ok {
+		return rc.ctx
+	}
+	return c.Context()
+}
+
+// Returns the context for responses, that are streamed after the handler returned.
+// It has the deadline of the request, is cancelled when the client closed the connection
+// and has to be cancelled, when the stream is written.
+func StreamContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(detachedContext{c.Context()})
+	if rc, ok := c.Locals(contextKey).(*requestContext); ok {
+		ctx, cancel = context.WithDeadline(rc.values, rc.deadline)
+	}
+	stop := cancelOnDisconnect(c, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Context, that carries the values of its parent, but is never cancelled and has no deadline
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Adds a value with derive to the context of the request
+func deriveContext(c *fiber.Ctx, derive func(ctx context.Context) context.Context) {
+	if rc, ok := c.Locals(contextKey).(*requestContext); ok {
+		rc.values = derive(rc.values)
+		rc.ctx = derive(rc.ctx)
+	}
+}
+
+// Returns the timeouts sorted by precedence: patterns with more literal segments first
+func parseRouteTimeouts(routes map[string]time.Duration) []routeTimeout {
+	var timeouts []routeTimeout
+	for pattern, timeout := range routes {
+		rt := routeTimeout{timeout: timeout}
+		path := strings.TrimSpace(pattern)
+		if i := strings.IndexByte(path, ' '); i >= 0 {
+			rt.method, path = strings.ToUpper(path[:i]), strings.TrimSpace(path[i+1:])
+		}
+		rt.segments = splitPath(path)
+		timeouts = append(timeouts, rt)
+	}
+	sort.Slice(timeouts, func(i, j int) bool { return timeouts[i].precedes(timeouts[j]) })
+	return timeouts
+}
+
+// Returns true, if the pattern is more specific than the other one:
+// it has more literal segments, more segments, a literal segment where the other has a parameter or a method.
+func (rt routeTimeout) precedes(other routeTimeout) bool {
+	if l, o := rt.literals(), other.literals(); l != o {
+		return l > o
+	}
+	if len(rt.segments) != len(other.segments) {
+		return len(rt.segments) > len(other.segments)
+	}
+	for i, s := range rt.segments {
+		if p, o := isParam(s), isParam(other.segments[i]); p != o {
+			return o
+		}
+	}
+	if rt.method != other.method {
+		return rt.method > other.method
+	}
+	return strings.Join(rt.segments, "/") < strings.Join(other.segments, "/")
+}
+
+// Number of segments, that are not parameters or wildcards
+func (rt routeTimeout) literals() int {
+	n := 0
+	for _, s := range rt.segments {
+		if !isParam(s) {
+			n++
+		}
+	}
+	return n
+}
+
+// Returns true for parameters and wildcards, that match any segment
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, ":") || segment == "*"
+}
+
+func (rt routeTimeout) matches(method string, path string) bool {
+	if rt.method != "" && rt.method != method {
+		return false
+	}
+	segments := splitPath(path)
+	for i, s := range rt.segments {
+		if s == "*" {
+			return true
+		}
+		if i >= len(segments) || (!isParam(s) && s != segments[i]) {
+			return false
+		}
+	}
+	return len(segments) == len(rt.segments)
+}
+
+// Splits the path into its segments and ignores leading and trailing slashes
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRouteTimeouts(t *testing.T) {
+	timeouts := parseRouteTimeouts(map[string]time.Duration{
+		"GET /api/:content/export": 1 * time.Minute,
+		"GET /api/audit/export":    2 * time.Minute,
+		"/api/audit/*":             3 * time.Minute,
+		"post /api/:content":       4 * time.Minute,
+	})
+	tests := []struct {
+		method string
+		path   string
+		want   time.Duration
+	}{
+		{"GET", "/api/posts/export", time.Minute},
+		{"GET", "/api/audit/export", 2 * time.Minute},
+		{"GET", "/api/audit/", 3 * time.Minute},
+		{"DELETE", "/api/audit/export/x", 3 * time.Minute},
+		{"POST", "/api/posts/", 4 * time.Minute},
+		{"GET", "/api/posts", 0},
+		{"POST", "/api/posts/1", 0},
+	}
+	for _, tt := range tests {
+		var got time.Duration
+		for _, rt := range timeouts {
+			if rt.matches(tt.method, tt.path) {
+				got = rt.timeout
+				break
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: got %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	var ctx, stream context.Context
+	app := fiber.New()
+	app.Use(RequestContext(time.Minute, map[string]time.Duration{"GET /export": time.Hour}), ReplicaReads)
+	app.Get("/*", func(c *fiber.Ctx) error {
+		ctx = Context(c)
+		var cancel context.CancelFunc
+		stream, cancel = StreamContext(c)
+		defer cancel()
+		return nil
+	})
+
+	for path, timeout := range map[string]time.Duration{"/": time.Minute, "/export": time.Hour} {
+		if _, err := app.Test(httptest.NewRequest("GET", path, nil)); err != nil {
+			t.Fatal(err)
+		}
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > timeout || time.Until(deadline) < timeout-time.Minute/2 {
+			t.Errorf("%s: deadline in %v, want %v", path, time.Until(deadline), timeout)
+		}
+		// The context ends with the request, streamed responses get their own with the same deadline
+		if ctx.Err() != context.Canceled {
+			t.Errorf("%s: context not cancelled after the request: %v", path, ctx.Err())
+		}
+		if d, _ := stream.Deadline(); !d.Equal(deadline) {
+			t.Errorf("%s: stream deadline %v, want %v", path, d, deadline)
+		}
+		if !store.ReplicaReadsAllowed(ctx) || !store.ReplicaReadsAllowed(stream) {
+			t.Errorf("%s: replica reads not allowed for GET requests", path)
+		}
+	}
+}
+
+func TestRequestContextDisconnect(t *testing.T) {
+	cancelled := make(chan error, 1)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(RequestContext(time.Minute, nil))
+	app.Get("/", func(c *fiber.Ctx) error {
+		select {
+		case <-Context(c).Done():
+			cancelled <- Context(c).Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+		return nil
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	conn.Close()
+
+	// The context of the request is cancelled, when the client closed the connection
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("context not cancelled after the disconnect: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Interval, in which the connection of a running request is checked for a disconnect of the client
+const disconnectPollInterval = 250 * time.Millisecond
+
+// Calls cancel, when the client closes the connection of the request, until stop is called.
+// fasthttp does not report disconnects, so the connection is polled. Disconnects of connections,
+// that do not expose their file descriptor like TLS connections, are not detected.
+func cancelOnDisconnect(c *fiber.Ctx, cancel context.CancelFunc) (stop func()) {
+	conn, ok := c.Context().Conn().(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(disconnectPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if peerClosed(raw) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package middleware
+
+import "syscall"
+
+// Disconnects are not detected on this platform
+func peerClosed(raw syscall.RawConn) bool {
+	return false
+}
@@ -0,0 +1,19 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package middleware
+
+import "syscall"
+
+// Returns true, if the peer closed the connection. The received data is only peeked,
+// so that it is still read by the server, e.g. the next request on a keep-alive connection.
+func peerClosed(raw syscall.RawConn) bool {
+	closed := false
+	buf := make([]byte, 1)
+	raw.Read(func(fd uintptr) bool {
+		n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = n == 0 && err == nil
+		return true
+	})
+	return closed
+}
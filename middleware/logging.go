@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/logging"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header of the request ID
+const requestIDHeader = fiber.HeaderXRequestID
+
+// Longest request ID, that is accepted from clients
+const maxRequestIDLength = 128
+
+// Largest request body, that is logged at level debug
+const maxLoggedBody = 64 * 1024
+
+// RequestID gives every request an ID, that is returned in the `X-Request-ID` header and written to the logs and the audit log.
+// IDs sent by clients or proxies are kept, if they are short and printable, so that their logs can be related to the ones of the server.
+func RequestID(c *fiber.Ctx) error {
+	id := c.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = utils.UUIDv4()
+	}
+	// The header is only valid during the request, but the ID is written to the audit log in the background
+	id = utils.CopyString(id)
+	c.Set(requestIDHeader, id)
+	c.Locals("requestid", id)
+	return c.Next()
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Logger writes a line for every request with its ID, user, route, collection, status and latency.
+// Server errors are logged at level error and client errors at level warn.
+// At level debug, the query and JSON body of the requests are logged with passwords and tokens redacted.
+// It passes a logger with the request ID and trace ID to the controller,
+// so it has to follow `RequestContext` and `Tracing` in the middleware chain.
+func Logger(c *fiber.Ctx) error {
+	start := time.Now()
+	requestID, _ := c.Locals("requestid").(string)
+	fields := logging.Logger().With().Str("request_id", requestID)
+	if sc := trace.SpanContextFromContext(Context(c)); sc.IsValid() {
+		fields = fields.Str("trace_id", sc.TraceID().String())
+	}
+	logger := fields.Logger()
+	deriveContext(c, logger.WithContext)
+
+	if e := logger.Debug(); e.Enabled() {
+		if q := c.Request().URI().QueryString(); len(q) > 0 {
+			e = e.Str("query", logging.RedactQuery(string(q)))
+		}
+		if body := c.Body(); len(body) > 0 && len(body) <= maxLoggedBody && strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+			e = e.Interface("body", logging.RedactJSON(body))
+		}
+		e.Str("method", c.Method()).Str("path", c.Path()).Msg("Request received")
+	}
+	defer func() {
+		// Panics are answered by the recover middleware, but would not be logged
+		if r := recover(); r != nil {
+			logger.Error().Str("method", c.Method()).Str("path", c.Path()).Interface("panic", r).Msg("Request panicked")
+			panic(r)
+		}
+	}()
+
+	err := c.Next()
+
+	// The error handler writes the status after the middlewares returned
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = apierror.From(err).Status
+	}
+	var e *zerolog.Event
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		e = logger.Error()
+	case status >= fiber.StatusBadRequest:
+		e = logger.Warn()
+	default:
+		e = logger.Info()
+	}
+	route := c.Route().Path
+	e = e.Str("method", c.Method()).Str("path", c.Path()).Str("route", route).Int("status", status).
+		Float64("latency_ms", float64(time.Since(start).Microseconds())/1000)
+	if strings.Contains(route, ":content") && status != fiber.StatusNotFound {
+		e = e.Str("collection", c.Params("content"))
+	}
+	if id := userID(c); id != "" {
+		e = e.Str("user_id", id)
+	}
+	if err != nil {
+		e = e.Err(err)
+	}
+	e.Msg("Request handled")
+	return err
+}
+
+// Adds the user of the token to the logger of the request, so that the log lines of the controller name it.
+// It is called by `Protected` after the token was validated.
+func logUser(c *fiber.Ctx) error {
+	if id := userID(c); id != "" {
+		deriveContext(c, func(ctx context.Context) context.Context {
+			logger := logging.Ctx(ctx).With().Str("user_id", id).Logger()
+			return logger.WithContext(ctx)
+		})
+	}
+	return c.Next()
+}
+
+// Returns the ID of the user, whose token was validated by `Protected`
+func userID(c *fiber.Ctx) string {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return ""
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	id, _ := claims["user_id"].(string)
+	return id
+}
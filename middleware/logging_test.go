@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/logging"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+)
+
+// Writes the logs to the returned buffer until the end of the test
+func captureLogs(t *testing.T, level zerolog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := logging.Logger()
+	old := *logger
+	*logger = zerolog.New(&buf).Level(level)
+	t.Cleanup(func() { *logger = old })
+	return &buf
+}
+
+func TestRequestID(t *testing.T) {
+	app := fiber.New()
+	app.Use(RequestID)
+	app.Get("/", func(c *fiber.Ctx) error { return nil })
+
+	for sent, kept := range map[string]bool{"proxy-1234": true, "": false, "with space": false, strings.Repeat("x", 200): false} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", sent)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := resp.Header.Get("X-Request-ID")
+		if kept && got != sent || !kept && (got == sent || len(got) != 36) {
+			t.Errorf("sent %q, got %q", sent, got)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	logs := captureLogs(t, zerolog.DebugLevel)
+	app := fiber.New()
+	app.Use(RequestID, RequestContext(time.Minute, nil), Logger)
+	app.Post("/api/:content", func(c *fiber.Ctx) error {
+		c.Locals("user", &jwt.Token{Claims: jwt.MapClaims{"user_id": "u1"}})
+		logging.Ctx(Context(c)).Info().Msg("from the controller")
+		return fiber.NewError(fiber.StatusInternalServerError, "broken")
+	})
+
+	req := httptest.NewRequest("POST", "/api/blogposts?token=abc", strings.NewReader(`{"title":"news","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "req-1")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(logs.String(), "hunter2") || strings.Contains(logs.String(), "abc") {
+		t.Errorf("secrets were logged: %s", logs)
+	}
+	var lines []map[string]interface{}
+	for _, l := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &line); err != nil {
+			t.Fatalf("%s: %v", l, err)
+		}
+		if line["request_id"] != "req-1" {
+			t.Errorf("line without the request ID: %s", l)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want the request, the controller and the response", len(lines))
+	}
+	handled := lines[2]
+	for key, want := range map[string]interface{}{"level": "error", "route": "/api/:content", "collection": "blogposts", "status": 500.0, "user_id": "u1", "error": "broken"} {
+		if handled[key] != want {
+			t.Errorf("%s is %v, want %v", key, handled[key], want)
+		}
+	}
+	if _, ok := handled["latency_ms"]; !ok {
+		t.Error("latency is missing")
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/metrics"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Metrics records the count and latency of the requests by their route template like "/api/:content/:id",
+// so that the paths of single documents do not create metrics of their own.
+// Only existing collections are recorded, because unknown ones are rejected with 404.
+func Metrics(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	// The error handler writes the status after the middlewares returned
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = apierror.From(err).Status
+	}
+	route := c.Route().Path
+	collection := ""
+	if strings.Contains(route, ":content") && status != fiber.StatusNotFound {
+		collection = utils.CopyString(c.Params("content"))
+	}
+	// The method is only valid during the request, but the metrics keep their labels
+	metrics.ObserveRequest(utils.CopyString(c.Method()), route, collection, status, time.Since(start))
+	return err
+}
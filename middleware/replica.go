@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/D-Bald/fiber-backend/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReplicaReads lets the storage serve the reads of GET requests from replicas, if a read preference is configured.
+// Their results may lag behind writes of previous requests.
+// It has to follow `RequestContext` in the middleware chain.
+func ReplicaReads(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodGet {
+		deriveContext(c, store.WithReplicaReads)
+	}
+	return c.Next()
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"github.com/D-Bald/fiber-backend/apierror"
 	"github.com/D-Bald/fiber-backend/controller"
 	"github.com/form3tech-oss/jwt-go"
 
@@ -10,20 +11,40 @@ import (
 // This Middleware requires Protected() to be called in middleware chain before.
 // Rolechecker checks "roles" claim in jwt token against permissions of the contenttype of the requested content
 // Checks also for "admin" claim and passes if it is true
-func ApplyPermissions(c *fiber.Ctx) error {
+func ApplyPermissions(ctrl *controller.Controller) fiber.Handler {
+	return Traced("ApplyPermissions", func(c *fiber.Ctx) error {
+		return applyPermissions(ctrl, c, c.Method())
+	})
+}
+
+// Like `ApplyPermissions`, but checks the permissions for the provided method instead of the request method.
+// Used for endpoints like the trash, that need the permission of another method.
+func ApplyPermissionsOf(ctrl *controller.Controller, method string) fiber.Handler {
+	return Traced("ApplyPermissions", func(c *fiber.Ctx) error {
+		return applyPermissions(ctrl, c, method)
+	})
+}
+
+func applyPermissions(ctrl *controller.Controller, c *fiber.Ctx, method string) error {
 	token := c.Locals("user").(*jwt.Token)
-	if token.Claims.(jwt.MapClaims)["admin"].(bool) {
+	claims := token.Claims.(jwt.MapClaims)
+	if admin, _ := claims["admin"].(bool); admin {
 		return c.Next()
 	}
-	ct, _ := controller.GetContentTypeByCollection(c.Params("content")) // Error check obsolet, because IsValidContentCollection is called before.
-	roles := ct.Permissions[c.Method()]
-	for _, rID := range roles {
-		if hasRole(rID.Hex(), token.Claims.(jwt.MapClaims)["roles"].([]interface{})) {
+	ct, err := ctrl.GetContentTypeByCollection(Context(c), c.Params("content"))
+	if err != nil {
+		return apierror.NotFoundFrom(err, "Content Type not found")
+	}
+	userRoles, ok := claims["roles"].([]interface{})
+	if !ok {
+		return apierror.Unauthorized("Invalid roles claim")
+	}
+	for _, rID := range ct.Permissions[method] {
+		if hasRole(rID.Hex(), userRoles) {
 			return c.Next()
 		}
 	}
-	return c.Status(fiber.StatusUnauthorized).
-		JSON(fiber.Map{"status": "error", "message": "Action not allowed", "data": nil})
+	return apierror.Forbidden("Action not allowed")
 }
 
 // return true, if a slice of roles contain the requested role ID (as in the jwt claims)
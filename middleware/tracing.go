@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing records a span for every request, that continues the trace of the W3C `traceparent` header.
+// The span is passed with the context of the request to the controller and the storage,
+// so that their spans and the ones of the MongoDB commands become its children.
+// It has to follow `RequestContext` in the middleware chain.
+func Tracing(c *fiber.Ctx) error {
+	parent := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{c})
+	// The method is only valid during the request, but the span is exported later
+	method := utils.CopyString(c.Method())
+	_, span := tracing.Tracer().Start(parent, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(method),
+			semconv.HTTPTargetKey.String(utils.CopyString(c.OriginalURL())),
+		),
+	)
+	defer span.End()
+	deriveContext(c, func(ctx context.Context) context.Context {
+		return baggage.ContextWithBaggage(trace.ContextWithSpan(ctx, span), baggage.FromContext(parent))
+	})
+
+	err := c.Next()
+
+	// The error handler writes the status after the middlewares returned
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = apierror.From(err).Status
+	}
+	route := c.Route().Path
+	span.SetName(method + " " + route)
+	span.SetAttributes(semconv.HTTPRouteKey.String(route), semconv.HTTPStatusCodeKey.Int(status))
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+	return err
+}
+
+// Traced records a span named after the middleware h. Middlewares call the rest of the chain with c.Next,
+// so their span contains the spans of the following middlewares and the handler.
+func Traced(name string, h fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rc, ok := c.Locals(contextKey).(*requestContext)
+		if !ok {
+			return h(c)
+		}
+		values, ctx := rc.values, rc.ctx
+		_, span := tracing.Tracer().Start(ctx, name)
+		defer span.End()
+		deriveContext(c, func(ctx context.Context) context.Context { return trace.ContextWithSpan(ctx, span) })
+
+		err := h(c)
+		rc.values, rc.ctx = values, ctx
+		if err != nil {
+			span.RecordError(err)
+			if apierror.From(err).Status >= fiber.StatusInternalServerError {
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}
+		return err
+	}
+}
+
+// Reads the trace context from the headers of the request
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+// The values are copied, because the baggage keeps them after the request
+func (h headerCarrier) Get(key string) string {
+	return utils.CopyString(h.c.Get(key))
+}
+
+func (h headerCarrier) Set(key string, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	app := fiber.New()
+	app.Use(RequestContext(time.Minute, nil), Tracing)
+	check := Traced("Check", func(c *fiber.Ctx) error { return c.Next() })
+	app.Get("/api/:content/:id", check, func(c *fiber.Ctx) error {
+		_, span := tracing.Tracer().Start(Context(c), "handler")
+		span.End()
+		return c.SendStatus(fiber.StatusTeapot)
+	})
+
+	req := httptest.NewRequest("GET", "/api/blogposts/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	request, ok := spans["GET /api/:content/:id"]
+	if !ok {
+		t.Fatalf("no span of the route in %v", spans)
+	}
+	if request.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || request.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("request span does not continue the trace of the traceparent header: %v", request.Parent())
+	}
+	for _, a := range request.Attributes() {
+		if a.Key == semconv.HTTPStatusCodeKey && a.Value.AsInt64() != fiber.StatusTeapot {
+			t.Errorf("status code %d", a.Value.AsInt64())
+		}
+	}
+	// The spans of the middlewares contain the ones of the following handlers
+	if s := spans["Check"]; s == nil || s.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("middleware span is not a child of the request span")
+	}
+	if s := spans["handler"]; s == nil || s.Parent().SpanID() != spans["Check"].SpanContext().SpanID() {
+		t.Errorf("handler span is not a child of the middleware span")
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited actions
+const (
+	AuditCreate      = "create"
+	AuditUpdate      = "update"
+	AuditDelete      = "delete"
+	AuditRestore     = "restore"
+	AuditPurge       = "purge"
+	AuditImport      = "import"
+	AuditMigrate     = "migrate"
+	AuditLogin       = "login"
+	AuditLoginFailed = "login_failed"
+)
+
+// Kinds of actors
+const (
+	ActorUser      = "user"
+	ActorAnonymous = "anonymous"
+	ActorCLI       = "cli"
+	ActorSystem    = "system" // background jobs like the trash purge
+)
+
+// Entry of the append-only audit log
+type AuditEntry struct {
+	ID         primitive.ObjectID     `bson:"_id" json:"_id"`
+	Time       time.Time              `bson:"time" json:"time"`
+	Actor      AuditActor             `bson:"actor" json:"actor"`
+	Action     string                 `bson:"action" json:"action"`
+	Collection string                 `bson:"collection" json:"collection"`
+	TargetID   string                 `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	Changes    map[string]AuditChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	IP         string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	RequestID  string                 `bson:"request_id,omitempty" json:"request_id,omitempty"`
+}
+
+// User or process, that caused the audited action
+type AuditActor struct {
+	Type string `bson:"type" json:"type"`
+	ID   string `bson:"id,omitempty" json:"id,omitempty"`
+	Name string `bson:"name,omitempty" json:"name,omitempty"`
+}
+
+// Value of a single field before and after the audited action
+type AuditChange struct {
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// Initialize metadata
+func (a *AuditEntry) Init() {
+	a.ID = primitive.NewObjectID()
+	a.Time = time.Now()
+}
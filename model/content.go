@@ -15,6 +15,7 @@ type Content struct {
 	Title         string                 `bson:"title" json:"title" xml:"title" form:"title" query:"title"`
 	Published     *bool                  `bson:"published" json:"published" xml:"published" form:"published" query:"published"`
 	Tags          []string               `bson:"tags" json:"tags" xml:"tags" form:"tags" query:"tags"`
+	DeletedAt     *time.Time             `bson:"deleted_at,omitempty" json:"deleted_at,omitempty" xml:"deleted_at" form:"-" query:"-"` // set while the entry is in the trash
 	Fields        map[string]interface{} `bson:"fields,inline" json:"fields" xml:"fields" form:"fields" query:"fields"`
 }
 
@@ -11,10 +11,20 @@ type ContentType struct {
 	ID          primitive.ObjectID              `bson:"_id" json:"_id" xml:"_id" form:"_id"`
 	CreatedAt   time.Time                       `bson:"created_at"`
 	UpdatedAt   time.Time                       `bson:"updated_at"`
+	DeletedAt   *time.Time                      `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // set while the content type is in the trash
+	DeletingAt  *time.Time                      `bson:"deleting_at,omitempty" json:"-"`                   // set while the content type is purged, so that the purge is finished after a crash
 	TypeName    string                          `bson:"typename" json:"typename" xml:"typename" form:"typename"`
 	Collection  string                          `bson:"collection" json:"collection" xml:"collection" form:"collection"`
 	Permissions map[string][]primitive.ObjectID `bson:"permissions" json:"permissions" xml:"permissions" form:"permissions"`
-	FieldSchema map[string]interface{}          `bson:"field_schema" json:"field_schema" xml:"field_schema" form:"field_schema"` // not used yet. Could be used for introducing a Validator on Collection Creation.
+	FieldSchema map[string]interface{}          `bson:"field_schema" json:"field_schema" xml:"field_schema" form:"field_schema"` // values are parsed by `FieldDefinitions()`
+	Indexes     []IndexDefinition               `bson:"indexes" json:"indexes" xml:"indexes" form:"indexes"`
+	Aliases     []CollectionAlias               `bson:"aliases,omitempty" json:"aliases,omitempty" xml:"aliases" form:"aliases"` // previous collections, that redirect to the current one
+}
+
+// Previous collection of a content type, that redirects to the current collection until it expires
+type CollectionAlias struct {
+	Collection string    `bson:"collection" json:"collection"`
+	ExpiresAt  time.Time `bson:"expires_at" json:"expires_at"`
 }
 
 // Initialize metadata
@@ -30,4 +40,5 @@ type ContentTypeUpdate struct {
 	Collection  string                 `bson:"collection,omitempty" json:"collection" xml:"collection" form:"collection"`
 	Permissions map[string][]string    `bson:"permissions,omitempty" json:"permissions" xml:"permissions" form:"permissions"`
 	FieldSchema map[string]interface{} `bson:"field_schema,omitempty" json:"field_schema" xml:"field_schema" form:"field_schema"`
+	Indexes     *[]IndexDefinition     `bson:"indexes,omitempty" json:"indexes" xml:"indexes" form:"indexes"` // `nil` keeps the indexes, an empty list removes all
 }
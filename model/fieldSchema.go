@@ -0,0 +1,104 @@
+package model
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field type of slugs that are generated from another field
+const FieldTypeSlug = "slug"
+
+// Definition of a single custom field of a content type.
+// An entry in `field_schema` is either the bare type name (e.g. `"string"`) or an object
+// like `{"type": "string", "localized": true, "unique": true}`.
+type FieldDefinition struct {
+	Type      string `bson:"type" json:"type"`
+	Localized bool   `bson:"localized" json:"localized"`
+	Unique    bool   `bson:"unique" json:"unique"` // slugs are always unique
+	From      string `bson:"from" json:"from"`     // source field of slugs: "title" (default) or the name of a custom field
+}
+
+// Parse a single `field_schema` entry into a FieldDefinition
+func ParseFieldDefinition(v interface{}) FieldDefinition {
+	var fd FieldDefinition
+	switch def := v.(type) {
+	case string:
+		fd.Type = def
+	case primitive.D:
+		return ParseFieldDefinition(def.Map())
+	case primitive.M:
+		return ParseFieldDefinition(map[string]interface{}(def))
+	case map[string]interface{}:
+		if t, ok := def["type"].(string); ok {
+			fd.Type = t
+		}
+		if l, ok := def["localized"].(bool); ok {
+			fd.Localized = l
+		}
+		if u, ok := def["unique"].(bool); ok {
+			fd.Unique = u
+		}
+		if f, ok := def["from"].(string); ok {
+			fd.From = f
+		}
+	}
+	if fd.Type == FieldTypeSlug {
+		fd.Unique = true
+		fd.Localized = false
+		if fd.From == "" {
+			fd.From = "title"
+		}
+	}
+	return fd
+}
+
+// Returns the parsed field definitions of the content type's `field_schema`
+func (ct *ContentType) FieldDefinitions() map[string]FieldDefinition {
+	defs := make(map[string]FieldDefinition)
+	for name, v := range ct.FieldSchema {
+		defs[name] = ParseFieldDefinition(v)
+	}
+	return defs
+}
+
+// Returns the names of all fields that are marked as localized
+func (ct *ContentType) LocalizedFields() []string {
+	var fields []string
+	for name, fd := range ct.FieldDefinitions() {
+		if fd.Localized {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Returns true if the field with provided name is marked as localized
+func (ct *ContentType) IsLocalized(field string) bool {
+	return ParseFieldDefinition(ct.FieldSchema[field]).Localized
+}
+
+// Returns the names of all fields that have to be unique within the collection.
+// Localized fields can not be unique.
+func (ct *ContentType) UniqueFields() []string {
+	var fields []string
+	for name, fd := range ct.FieldDefinitions() {
+		if fd.Unique && !fd.Localized {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Returns the names of all slug fields
+func (ct *ContentType) SlugFields() []string {
+	var fields []string
+	for name, fd := range ct.FieldDefinitions() {
+		if fd.Type == FieldTypeSlug {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Index key types
+const (
+	IndexAsc  = "asc"
+	IndexDesc = "desc"
+	IndexText = "text"
+)
+
+// Index on the collection of a content type, declared by admins through the contenttypes API.
+// Single field and compound indexes differ by the number of keys. An index with `expire_after_seconds` is a TTL index.
+type IndexDefinition struct {
+	Name               string                 `bson:"name" json:"name" xml:"name" form:"name"`
+	Keys               []IndexKey             `bson:"keys" json:"keys" xml:"keys" form:"keys"`
+	Unique             bool                   `bson:"unique,omitempty" json:"unique,omitempty" xml:"unique" form:"unique"`
+	Sparse             bool                   `bson:"sparse,omitempty" json:"sparse,omitempty" xml:"sparse" form:"sparse"`
+	ExpireAfterSeconds *int32                 `bson:"expire_after_seconds,omitempty" json:"expire_after_seconds,omitempty" xml:"expire_after_seconds" form:"expire_after_seconds"`
+	PartialFilter      map[string]interface{} `bson:"partial_filter,omitempty" json:"partial_filter,omitempty" xml:"partial_filter" form:"partial_filter"`
+}
+
+// Single key of an index. `type` is one of "asc" (default), "desc" or "text".
+type IndexKey struct {
+	Field string `bson:"field" json:"field" xml:"field" form:"field"`
+	Type  string `bson:"type,omitempty" json:"type,omitempty" xml:"type" form:"type"`
+}
+
+// Prefix of the names of indexes that are created for unique fields in the field schema.
+// Declared indexes must not use it.
+const UniqueIndexPrefix = "unique_"
+
+// Prefix of the names of all indexes on a collection, that are created for the declaration of its content type.
+// Indexes without it were created by hand and are never dropped.
+const ManagedIndexPrefix = "cms_"
+
+// Sets default values for missing index names and key types
+func (idx *IndexDefinition) Normalize() {
+	var parts []string
+	for i := range idx.Keys {
+		if idx.Keys[i].Type == "" {
+			idx.Keys[i].Type = IndexAsc
+		}
+		parts = append(parts, idx.Keys[i].Field, idx.Keys[i].Type)
+	}
+	if idx.Name == "" {
+		idx.Name = strings.Join(parts, "_")
+	}
+}
+
+// Checks if the index declaration can be created
+func (idx *IndexDefinition) Validate() error {
+	if len(idx.Keys) == 0 {
+		return fmt.Errorf("index %q: at least one key required", idx.Name)
+	}
+	if idx.Name == "_id_" || strings.HasPrefix(idx.Name, UniqueIndexPrefix) {
+		return fmt.Errorf("index %q: name is reserved", idx.Name)
+	}
+	for _, k := range idx.Keys {
+		if k.Field == "" {
+			return fmt.Errorf("index %q: key without field", idx.Name)
+		}
+		switch k.Type {
+		case IndexAsc, IndexDesc, IndexText:
+		default:
+			return fmt.Errorf("index %q: invalid key type %q for field %q", idx.Name, k.Type, k.Field)
+		}
+	}
+	if idx.ExpireAfterSeconds != nil {
+		if len(idx.Keys) != 1 || idx.Keys[0].Type == IndexText {
+			return fmt.Errorf("index %q: TTL indexes need exactly one ascending or descending key", idx.Name)
+		}
+		if *idx.ExpireAfterSeconds < 0 {
+			return fmt.Errorf("index %q: expire_after_seconds must not be negative", idx.Name)
+		}
+	}
+	return nil
+}
+
+// Normalizes and validates all index declarations and checks that their names are unique
+func ValidateIndexes(indexes []IndexDefinition) error {
+	names := make(map[string]bool)
+	for i := range indexes {
+		indexes[i].Normalize()
+		if err := indexes[i].Validate(); err != nil {
+			return err
+		}
+		if names[indexes[i].Name] {
+			return fmt.Errorf("index %q: name used more than once", indexes[i].Name)
+		}
+		names[indexes[i].Name] = true
+	}
+	return nil
+}
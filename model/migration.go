@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Migration operations on a field of all entries of a content type
+const (
+	MigrationRename     = "rename"
+	MigrationDrop       = "drop"
+	MigrationSetDefault = "set_default"
+	MigrationConvert    = "convert"
+)
+
+// Migration job states
+const (
+	MigrationPending   = "pending"
+	MigrationRunning   = "running"
+	MigrationCompleted = "completed"
+	MigrationFailed    = "failed"
+)
+
+// Maximum number of failures that are stored per migration job
+const MaxMigrationFailures = 100
+
+// Input of a field migration
+type MigrationInput struct {
+	Operation string      `json:"operation" xml:"operation" form:"operation"`
+	Field     string      `json:"field" xml:"field" form:"field"`
+	To        string      `json:"to" xml:"to" form:"to"`          // new field name for "rename"
+	Value     interface{} `json:"value" xml:"value" form:"value"` // default value for "set_default"
+	Type      string      `json:"type" xml:"type" form:"type"`    // target type for "convert" or type of a new field for "set_default"
+	DryRun    bool        `json:"dry_run" xml:"dry_run" form:"dry_run"`
+	BatchSize int         `json:"batch_size" xml:"batch_size" form:"batch_size"`
+}
+
+// Background job that migrates a field of all entries of a content type in batches
+type Migration struct {
+	ID            primitive.ObjectID `bson:"_id" json:"_id"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	StartedAt     *time.Time         `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt    *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	ContentTypeID primitive.ObjectID `bson:"content_type_id" json:"content_type_id"`
+	Collection    string             `bson:"collection" json:"collection"`
+	Operation     string             `bson:"operation" json:"operation"`
+	Field         string             `bson:"field" json:"field"`
+	To            string             `bson:"to,omitempty" json:"to,omitempty"`
+	Value         interface{}        `bson:"value,omitempty" json:"value,omitempty"`
+	Type          string             `bson:"type,omitempty" json:"type,omitempty"`
+	BatchSize     int                `bson:"batch_size" json:"batch_size"`
+	Status        string             `bson:"status" json:"status"`
+	Total         int64              `bson:"total" json:"total"`
+	Processed     int64              `bson:"processed" json:"processed"`
+	Modified      int64              `bson:"modified" json:"modified"`
+	FailedCount   int64              `bson:"failed" json:"failed"`
+	Failures      []MigrationFailure `bson:"failures" json:"failures"`
+	Error         string             `bson:"error,omitempty" json:"error,omitempty"`
+	LastID        primitive.ObjectID `bson:"last_id" json:"-"` // last processed entry, used to resume interrupted jobs
+	// ID of the content type while the job is pending or running. It has a unique index, so that only one job per content type is active.
+	Active *primitive.ObjectID `bson:"active,omitempty" json:"-"`
+}
+
+// Entry that could not be migrated
+type MigrationFailure struct {
+	ID    primitive.ObjectID `bson:"_id" json:"_id"`
+	Error string             `bson:"error" json:"error"`
+}
+
+// Initialize metadata
+func (m *Migration) Init() {
+	m.ID = primitive.NewObjectID()
+	m.CreatedAt = time.Now()
+	m.Status = MigrationPending
+	m.Failures = make([]MigrationFailure, 0)
+}
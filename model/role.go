@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -9,6 +11,8 @@ type Role struct {
 	ID   primitive.ObjectID `bson:"_id,omitempty" json:"_id" xml:"_id" form:"_id"`
 	Tag  string             `bson:"tag,omitempty" json:"tag" xml:"tag" form:"tag"`
 	Name string             `bson:"name,omitempty" json:"name" xml:"name" form:"name"`
+	// Set while the references to the role are removed, so that its deletion is finished after a crash
+	DeletingAt *time.Time `bson:"deleting_at,omitempty" json:"-" xml:"-" form:"-"`
 }
 
 // Initialize metadata
@@ -0,0 +1,100 @@
+// Package responsecache caches the responses of anonymous content requests.
+//
+// Responses are tagged with their collection and the entries they contain, so that they can be purged precisely,
+// when entries change. The tags are sent in the `Surrogate-Key` header as well, so that CDNs can purge their copies the same way.
+// Responses are kept in memory by every server or in Redis, which is shared by all servers.
+package responsecache
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/config"
+)
+
+// Names of the backends
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Tag of all responses. Purging it empties the cache.
+const TagAll = "all"
+
+// Cached response
+type Response struct {
+	Status          int      `json:"status"`
+	ContentType     string   `json:"content_type"`
+	ContentLanguage string   `json:"content_language,omitempty"`
+	Body            []byte   `json:"body"`
+	Tags            []string `json:"tags"`
+}
+
+// Generation of the responses of a collection. Every purge of the collection, one of its entries or all responses increases it.
+// It is read before a response is computed and checked, when it is stored, so that no response of data,
+// that was changed and purged in between, is cached.
+type Generation struct {
+	Collection string
+	Number     uint64
+}
+
+// Storage of the cached responses. Implementations have to be safe for concurrent use.
+type Cache interface {
+	// Returns the response with the key, false if it is not cached or expired
+	Get(ctx context.Context, key string) (*Response, bool, error)
+	// Returns the current generation of the responses of the collection
+	Generation(ctx context.Context, coll string) (Generation, error)
+	// Stores the response with the key for ttl, unless the generation of its collection is not gen anymore
+	Set(ctx context.Context, key string, r *Response, gen Generation, ttl time.Duration) error
+	// Removes all responses, that have one of the tags, and increases the generations of their collections
+	Purge(ctx context.Context, tags ...string) error
+	// Releases the connections of the backend
+	Close() error
+}
+
+// Returns the tag of all responses of the collection
+func CollectionTag(coll string) string {
+	return "collection:" + coll
+}
+
+// Returns the tag of the responses, that contain the entry
+func EntryTag(coll string, id string) string {
+	return "entry:" + coll + "/" + id
+}
+
+// Returns the collection of the tag, "" for the tag of all responses
+func tagCollection(tag string) string {
+	for _, prefix := range []string{"collection:", "entry:"} {
+		if strings.HasPrefix(tag, prefix) {
+			return strings.SplitN(strings.TrimPrefix(tag, prefix), "/", 2)[0]
+		}
+	}
+	return ""
+}
+
+// Returns the key of a request to path: its query params are sorted by name, so that their order does not matter.
+// The requested language is part of the key, because it selects the locale of the response.
+func Key(path string, query string, language string) string {
+	if values, err := url.ParseQuery(query); err == nil {
+		query = values.Encode()
+	}
+	return path + "?" + query + "#" + strings.ToLower(strings.ReplaceAll(language, " ", ""))
+}
+
+// Opens the cache of the settings, nil if it is disabled
+func Open(cfg config.ResponseCache) (Cache, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	switch cfg.Backend {
+	case BackendMemory:
+		return NewMemory(cfg.MaxEntries), nil
+	case BackendRedis:
+		return NewRedis(cfg.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown response cache backend: %s", cfg.Backend)
+	}
+}
@@ -0,0 +1,155 @@
+package responsecache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestKey(t *testing.T) {
+	if Key("/api/pages", "b=2&a=1", "de, EN;q=0.5") != Key("/api/pages", "a=1&b=2", "de,en;q=0.5") {
+		t.Error("keys of the same request differ")
+	}
+	if Key("/api/pages", "a=1", "de") == Key("/api/pages", "a=1", "en") {
+		t.Error("keys of different languages are equal")
+	}
+}
+
+// Checks the behaviour, that all backends share
+func testCache(t *testing.T, c Cache, expire func(time.Duration)) {
+	ctx := context.Background()
+	pages := &Response{Status: 200, Body: []byte(`{"content":[]}`), Tags: []string{CollectionTag("pages"), EntryTag("pages", "1")}}
+	posts := &Response{Status: 200, Body: []byte(`{"content":[]}`), Tags: []string{CollectionTag("posts")}}
+	generation := func(coll string) Generation {
+		gen, err := c.Generation(ctx, coll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gen
+	}
+	// The keys of the test are the collections of the responses
+	set := func(key string, r *Response) {
+		if err := c.Set(ctx, key, r, generation(key), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cached := func(key string) bool {
+		_, ok, err := c.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	set("pages", pages)
+	set("posts", posts)
+	r, ok, err := c.Get(ctx, "pages")
+	if err != nil || !ok || string(r.Body) != string(pages.Body) {
+		t.Fatalf("Get returned %v, %t, %v", r, ok, err)
+	}
+	if err := c.Purge(ctx, EntryTag("pages", "1")); err != nil {
+		t.Fatal(err)
+	}
+	if cached("pages") || !cached("posts") {
+		t.Error("purge of the entry did not remove exactly the responses of pages")
+	}
+
+	set("pages", pages)
+	if err := c.Purge(ctx, TagAll); err != nil {
+		t.Fatal(err)
+	}
+	if cached("pages") || cached("posts") {
+		t.Error("purge of all responses kept responses")
+	}
+
+	// Responses, whose collection was purged while they were computed, are not stored
+	pagesGen, postsGen := generation("pages"), generation("posts")
+	if err := c.Purge(ctx, EntryTag("pages", "2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "pages", pages, pagesGen, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "posts", posts, postsGen, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if cached("pages") || !cached("posts") {
+		t.Error("generation of the purged collection was not checked")
+	}
+	postsGen = generation("posts")
+	if err := c.Purge(ctx, TagAll); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "posts", posts, postsGen, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if cached("posts") {
+		t.Error("purge of all responses did not change the generation")
+	}
+
+	set("pages", pages)
+	expire(2 * time.Minute)
+	if cached("pages") {
+		t.Error("expired response returned")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(2)
+	testCache(t, m, func(d time.Duration) {
+		for _, el := range m.entries {
+			el.Value.(*memoryEntry).expires = time.Now().Add(-d)
+		}
+	})
+
+	// The least recently used response is dropped
+	ctx := context.Background()
+	for _, key := range []string{"a", "b"} {
+		m.Set(ctx, key, &Response{Tags: []string{CollectionTag(key)}}, m.generation(key), time.Minute)
+	}
+	m.Get(ctx, "a")
+	m.Set(ctx, "c", &Response{Tags: []string{CollectionTag("c")}}, m.generation("c"), time.Minute)
+	if _, ok, _ := m.Get(ctx, "b"); ok || len(m.entries) != 2 {
+		t.Errorf("b was not dropped: %d entries", len(m.entries))
+	}
+	if _, ok := m.tags[CollectionTag("b")]; ok {
+		t.Error("tag of the dropped response was kept")
+	}
+}
+
+func TestRedis(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	r, err := NewRedis("redis://" + s.Addr() + "/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	testCache(t, r, s.FastForward)
+
+	// The generations are shared by all servers
+	other, err := NewRedis("redis://" + s.Addr() + "/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	ctx := context.Background()
+	gen, err := r.Generation(ctx, "pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Purge(ctx, CollectionTag("pages")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set(ctx, "pages", &Response{Tags: []string{CollectionTag("pages")}}, gen, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := r.Get(ctx, "pages"); ok {
+		t.Error("response was stored after another server purged its collection")
+	}
+}
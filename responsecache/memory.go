@@ -0,0 +1,125 @@
+package responsecache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache in the memory of the server. Every server has a cache of its own,
+// so purges of one server do not reach the others, whose responses expire after their ttl.
+// The least recently used responses are dropped, when the cache is full.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	// Most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+	// Keys of the responses by tag
+	tags map[string]map[string]struct{}
+	// Number of purges by collection, "" counts the purges of all responses
+	purges map[string]uint64
+}
+
+type memoryEntry struct {
+	key      string
+	response *Response
+	expires  time.Time
+}
+
+// Returns an empty cache, that keeps at most maxEntries responses
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+		purges:     make(map[string]uint64),
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (*Response, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if time.Now().After(e.expires) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.lru.MoveToFront(el)
+	return e.response, true, nil
+}
+
+func (m *Memory) Generation(ctx context.Context, coll string) (Generation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generation(coll), nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, r *Response, gen Generation, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generation(gen.Collection) != gen {
+		return nil
+	}
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	m.entries[key] = m.lru.PushFront(&memoryEntry{key: key, response: r, expires: time.Now().Add(ttl)})
+	for _, tag := range r.Tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+	for m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *Memory) Purge(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		m.purges[tagCollection(tag)]++
+	}
+	for _, tag := range tags {
+		if tag == TagAll {
+			m.lru.Init()
+			m.entries = make(map[string]*list.Element)
+			m.tags = make(map[string]map[string]struct{})
+			return nil
+		}
+		for key := range m.tags[tag] {
+			m.remove(m.entries[key])
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Returns the generation of the collection. Purges of all responses count for every collection.
+func (m *Memory) generation(coll string) Generation {
+	return Generation{Collection: coll, Number: m.purges[coll] + m.purges[""]}
+}
+
+// Removes the response from the list, the map and its tags
+func (m *Memory) remove(el *list.Element) {
+	e := m.lru.Remove(el).(*memoryEntry)
+	delete(m.entries, e.key)
+	for _, tag := range e.response.Tags {
+		delete(m.tags[tag], e.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package router_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Returns the audit log entries, that match the query
+func (a *testApp) auditEntries(token string, query string) []map[string]interface{} {
+	a.t.Helper()
+	res := a.expect(fiber.StatusOK, "GET", "/api/audit?"+query, nil, token)
+	var entries []map[string]interface{}
+	for _, e := range list(a.t, res.body, "audit") {
+		entries = append(entries, e.(map[string]interface{}))
+	}
+	return entries
+}
+
+func TestAuditCascadesAndPurges(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.adminToken()
+	roleID := a.createRole(admin, "editor", "Editor")
+	ctID := a.createContentType(admin, pagesContentType("Editor"))
+	userID := a.createUser("alice", "secret")
+	a.expect(fiber.StatusOK, "PATCH", "/api/user/"+userID, map[string]interface{}{"roles": []string{"User", "Editor"}}, admin)
+
+	// The cascade of the role deletion is recorded with the actor of the request
+	a.expect(fiber.StatusOK, "DELETE", "/api/role/"+roleID, nil, admin)
+	for _, target := range []struct{ coll, id, action string }{
+		{"users", userID, model.AuditUpdate},
+		{"contenttypes", ctID, model.AuditUpdate},
+		{"roles", roleID, model.AuditDelete},
+	} {
+		entries := a.auditEntries(admin, "collection="+target.coll+"&target_id="+target.id+"&action="+target.action)
+		if len(entries) == 0 {
+			t.Errorf("no %s entry of %s %s", target.action, target.coll, target.id)
+			continue
+		}
+		if actor := entries[0]["actor"].(map[string]interface{}); actor["type"] != model.ActorUser || actor["name"] != "adminUser" {
+			t.Errorf("%s entry of %s recorded with actor %v", target.action, target.coll, actor)
+		}
+		if entries[0]["request_id"] == "" || entries[0]["ip"] == "" {
+			t.Errorf("%s entry of %s without request: %v", target.action, target.coll, entries[0])
+		}
+	}
+
+	// The automatic purge of the trash is recorded with the system as actor
+	entry := a.createContent(admin, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{}})
+	a.expect(fiber.StatusOK, "DELETE", "/api/pages/"+entry, nil, admin)
+	if err := a.ctrl.PurgeTrash(context.Background(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	a.expectError(fiber.StatusNotFound, "not_found", "GET", "/api/pages?id="+entry, nil, "")
+	entries := a.auditEntries(admin, "collection=pages&target_id="+entry+"&action="+model.AuditPurge)
+	if len(entries) != 1 || entries[0]["actor"].(map[string]interface{})["type"] != model.ActorSystem {
+		t.Errorf("unexpected purge entries %v", entries)
+	}
+}
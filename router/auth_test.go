@@ -0,0 +1,101 @@
+package router_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/form3tech-oss/jwt-go"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLogin(t *testing.T) {
+	a := newTestApp(t)
+
+	res := a.expect(fiber.StatusOK, "POST", "/api/auth/login", map[string]string{"identity": "adminUser", "password": adminPassword}, "")
+	if res.body["token"] == "" {
+		t.Fatal("no token returned")
+	}
+	user := object(t, res.body, "user")
+	if user["username"] != "adminUser" || !contains(list(t, user, "roles"), "Administrator") {
+		t.Errorf("unexpected user %v", user)
+	}
+
+	// Email works as identity as well
+	a.login("admin@sample.com", adminPassword)
+
+	a.expectError(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "POST", "/api/auth/login", map[string]string{"identity": "adminUser", "password": "wrong"}, "")
+	a.expectError(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "POST", "/api/auth/login", map[string]string{"identity": "nobody", "password": adminPassword}, "")
+	a.expectError(fiber.StatusBadRequest, apierror.CodeInvalidInput, "POST", "/api/auth/login", map[string]string{"password": adminPassword}, "")
+}
+
+func TestSignUpAndLogin(t *testing.T) {
+	a := newTestApp(t)
+
+	id := a.createUser("alice", "secret")
+	a.expectError(fiber.StatusConflict, apierror.CodeAlreadyExists, "POST", "/api/user", map[string]string{
+		"username": "alice",
+		"email":    "other@sample.com",
+		"password": "secret",
+	}, "")
+
+	token := a.login("alice", "secret")
+	res := a.expect(fiber.StatusOK, "GET", "/api/user?username=alice", nil, token)
+	users := list(t, res.body, "user")
+	if len(users) != 1 || users[0].(map[string]interface{})["id"] != id {
+		t.Fatalf("unexpected users %v", users)
+	}
+	if roles := list(t, users[0].(map[string]interface{}), "roles"); len(roles) != 1 || roles[0] != "User" {
+		t.Errorf("new user has roles %v, want only the default role", roles)
+	}
+}
+
+func TestProtectedRoutes(t *testing.T) {
+	a := newTestApp(t)
+
+	a.expectError(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "GET", "/api/user", nil, "")
+	a.expectError(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "GET", "/api/user", nil, "not-a-token")
+
+	// Admin only routes
+	a.createUser("bob", "secret")
+	token := a.login("bob", "secret")
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "POST", "/api/role", map[string]string{"tag": "moderator", "name": "Moderator"}, token)
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "POST", "/api/contenttypes", map[string]interface{}{"typename": "page", "collection": "pages"}, token)
+	a.expect(fiber.StatusOK, "POST", "/api/role", map[string]string{"tag": "moderator", "name": "Moderator"}, a.adminToken())
+}
+
+func TestTokenWithoutAdminClaim(t *testing.T) {
+	a := newTestApp(t)
+	alice := a.createUser("alice", "secret")
+	bob := a.createUser("bob", "secret")
+
+	// A valid token without the admin claim is not an admin token
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": "alice",
+		"user_id":  alice,
+		"roles":    []string{},
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(config.Get().Auth.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "POST", "/api/role", map[string]string{"tag": "moderator", "name": "Moderator"}, token)
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "PATCH", "/api/user/"+bob, map[string]string{"names": "Bob"}, token)
+}
+
+func TestUpdateOtherUser(t *testing.T) {
+	a := newTestApp(t)
+	alice := a.createUser("alice", "secret")
+	bob := a.createUser("bob", "secret")
+	token := a.login("alice", "secret")
+
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "PATCH", "/api/user/"+bob, map[string]string{"names": "Bob"}, token)
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "PATCH", "/api/user/"+alice, map[string]interface{}{"roles": []string{"Administrator"}}, token)
+
+	res := a.expect(fiber.StatusOK, "PATCH", "/api/user/"+alice, map[string]string{"names": "Alice"}, token)
+	if matched(t, res.body) != 1 {
+		t.Errorf("unexpected result %v", res.body["result"])
+	}
+}
@@ -0,0 +1,67 @@
+package router_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/memory"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Watch of the storage, that reports the changes sent on the channel
+func testWatch(changes chan string, seen chan struct{}) store.WatchFunc {
+	return func(ctx context.Context, changed func(collection string)) error {
+		for {
+			select {
+			case coll := <-changes:
+				changed(coll)
+				seen <- struct{}{}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+func TestCacheOfOtherServer(t *testing.T) {
+	s := memory.New()
+	a := newTestAppOn(t, s)
+	b := newTestAppOn(t, s)
+	changes, seen := make(chan string), make(chan struct{})
+	b.ctrl.WatchCache(testWatch(changes, seen))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		b.ctrl.Shutdown(ctx)
+	})
+	token := a.adminToken()
+
+	// b caches the content types with the first request. Collections without entries return not_found instead of route_not_found.
+	b.expectError(fiber.StatusNotFound, apierror.CodeRouteNotFound, "GET", "/api/pages", nil, "")
+	a.createContentType(token, pagesContentType("User"))
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/pages", nil, "")
+	b.expectError(fiber.StatusNotFound, apierror.CodeRouteNotFound, "GET", "/api/pages", nil, "")
+
+	changes <- store.CollectionContentTypes
+	<-seen
+	b.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/pages", nil, "")
+
+	// Renamed roles are shown with their new name, once the change is seen
+	roleID := a.createRole(token, "editor", "Editor")
+	changes <- store.CollectionRoles
+	<-seen
+	userID := a.createUser("alice", "correct horse")
+	a.expect(fiber.StatusOK, "PATCH", "/api/user/"+userID, map[string]interface{}{"roles": []string{"User", "Editor"}}, token)
+	a.expect(fiber.StatusOK, "PATCH", "/api/role/"+roleID, map[string]string{"name": "Author"}, token)
+	changes <- store.CollectionRoles
+	<-seen
+	res := b.expect(fiber.StatusOK, "GET", "/api/user?username=alice", nil, token)
+	user := list(t, res.body, "user")[0].(map[string]interface{})
+	if roles := list(t, user, "roles"); len(roles) != 2 || roles[0] != "User" || roles[1] != "Author" {
+		t.Errorf("user has roles %v", roles)
+	}
+}
@@ -0,0 +1,337 @@
+package router_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/memory"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Content type with the permission of all methods for the role
+func pagesContentType(role string) map[string]interface{} {
+	return map[string]interface{}{
+		"typename":   "page",
+		"collection": "pages",
+		"permissions": map[string][]string{
+			"POST":   {role},
+			"PATCH":  {role},
+			"DELETE": {role},
+		},
+		"field_schema": map[string]interface{}{"body": "string"},
+	}
+}
+
+func TestContentTypeCRUD(t *testing.T) {
+	a := newTestApp(t)
+	token := a.adminToken()
+
+	id := a.createContentType(token, pagesContentType("User"))
+	a.expectError(fiber.StatusConflict, apierror.CodeAlreadyExists, "POST", "/api/contenttypes", pagesContentType("User"), token)
+	invalid := pagesContentType("Nobody")
+	invalid["typename"], invalid["collection"] = "other", "others"
+	a.expectError(fiber.StatusUnprocessableEntity, apierror.CodeValidationFailed, "POST", "/api/contenttypes", invalid, token)
+
+	// Query
+	res := a.expect(fiber.StatusOK, "GET", "/api/contenttypes/"+id, nil, "")
+	ct := object(t, res.body, "contenttype")
+	if ct["typename"] != "page" || ct["collection"] != "pages" {
+		t.Fatalf("unexpected content type %v", ct)
+	}
+	if roles := list(t, object(t, ct, "permissions"), "POST"); len(roles) != 1 || roles[0] != "User" {
+		t.Errorf("permissions are returned with role IDs instead of names: %v", roles)
+	}
+	res = a.expect(fiber.StatusOK, "GET", "/api/contenttypes", nil, "")
+	if all := list(t, res.body, "contenttype"); len(all) != 3 {
+		t.Errorf("got %d content types, want the 2 presets and the new one", len(all))
+	}
+	a.expectError(fiber.StatusBadRequest, apierror.CodeInvalidID, "GET", "/api/contenttypes/not-an-id", nil, "")
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/contenttypes/000000000000000000000000", nil, "")
+
+	// Update
+	res = a.expect(fiber.StatusOK, "PATCH", "/api/contenttypes/"+id, map[string]interface{}{
+		"typename":     "webpage",
+		"field_schema": map[string]interface{}{"body": "string", "summary": "string"},
+	}, token)
+	if matched(t, res.body) != 1 {
+		t.Fatalf("unexpected result %v", res.body["result"])
+	}
+	ct = object(t, a.expect(fiber.StatusOK, "GET", "/api/contenttypes/"+id, nil, "").body, "contenttype")
+	if ct["typename"] != "webpage" || object(t, ct, "field_schema")["summary"] != "string" {
+		t.Errorf("content type not updated: %v", ct)
+	}
+
+	// Trash, restore and purge
+	a.expect(fiber.StatusOK, "DELETE", "/api/contenttypes/"+id, nil, token)
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/contenttypes/"+id, nil, "")
+	a.expectError(fiber.StatusNotFound, apierror.CodeRouteNotFound, "GET", "/api/pages", nil, "")
+	res = a.expect(fiber.StatusOK, "GET", "/api/contenttypes/trash", nil, token)
+	if trashed := list(t, res.body, "contenttype"); len(trashed) != 1 {
+		t.Fatalf("got %d content types in the trash, want 1", len(trashed))
+	}
+	a.expect(fiber.StatusOK, "POST", "/api/contenttypes/trash/"+id+"/restore", nil, token)
+	// The route is valid again, but there are no entries
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/pages", nil, "")
+
+	a.expect(fiber.StatusOK, "DELETE", "/api/contenttypes/"+id, nil, token)
+	a.expect(fiber.StatusOK, "DELETE", "/api/contenttypes/trash/"+id, nil, token)
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "POST", "/api/contenttypes/trash/"+id+"/restore", nil, token)
+}
+
+func TestMoveCollection(t *testing.T) {
+	a := newTestApp(t)
+	token := a.adminToken()
+	id := a.createContentType(token, pagesContentType("User"))
+	entry := a.createContent(token, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{"body": "Welcome"}})
+
+	a.expect(fiber.StatusOK, "PATCH", "/api/contenttypes/"+id, map[string]string{"collection": "sites"}, token)
+
+	res := a.expect(fiber.StatusOK, "GET", "/api/sites?id="+entry, nil, "")
+	if content := list(t, res.body, "content"); len(content) != 1 {
+		t.Fatalf("entry not moved: %v", content)
+	}
+	res = a.expect(fiber.StatusTemporaryRedirect, "GET", "/api/pages?id="+entry, nil, "")
+	if res.location != "/api/sites?id="+entry {
+		t.Errorf("old collection redirects to %q", res.location)
+	}
+}
+
+// Content store, that can not rename collections and calls onStream before the entries are streamed
+type copyingContent struct {
+	store.ContentStore
+	onStream func()
+}
+
+func (s copyingContent) Rename(ctx context.Context, from string, to string) error {
+	return store.ErrNotSupported
+}
+
+func (s copyingContent) Stream(ctx context.Context, coll string, filter interface{}, fn func(*model.Content) error) error {
+	if s.onStream != nil {
+		s.onStream()
+	}
+	return s.ContentStore.Stream(ctx, coll, filter, fn)
+}
+
+func TestReservedCollections(t *testing.T) {
+	a := newTestApp(t)
+	token := a.adminToken()
+	reserved := pagesContentType("User")
+	reserved["collection"] = "audit_log"
+	a.expectError(fiber.StatusUnprocessableEntity, apierror.CodeValidationFailed, "POST", "/api/contenttypes", reserved, token)
+
+	id := a.createContentType(token, pagesContentType("User"))
+	a.expectError(fiber.StatusUnprocessableEntity, apierror.CodeValidationFailed, "PATCH", "/api/contenttypes/"+id, map[string]string{"collection": "audit_log"}, token)
+	a.expect(fiber.StatusOK, "GET", "/api/audit", nil, token)
+}
+
+func TestMoveCollectionByCopy(t *testing.T) {
+	s := memory.New()
+	content := &copyingContent{ContentStore: s.Content}
+	s.Content = content
+	a := newTestAppOn(t, s)
+	token := a.adminToken()
+	id := a.createContentType(token, pagesContentType("User"))
+	kept := a.createContent(token, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{"body": "Welcome"}})
+	trashed := a.createContent(token, "pages", map[string]interface{}{"title": "About", "fields": map[string]string{"body": "Us"}})
+	a.expect(fiber.StatusOK, "DELETE", "/api/pages/"+trashed, nil, token)
+
+	// Writes while the entries are copied are rejected instead of being lost
+	var during []*response
+	content.onStream = func() {
+		content.onStream = nil
+		during = append(during,
+			a.request("POST", "/api/pages", map[string]interface{}{"title": "New", "fields": map[string]string{"body": "Lost?"}}, token),
+			a.request("PATCH", "/api/pages/"+kept, map[string]interface{}{"title": "Changed"}, token),
+			a.request("DELETE", "/api/pages/"+kept, nil, token),
+			a.request("DELETE", "/api/pages/trash/"+trashed, nil, token),
+		)
+	}
+	a.expect(fiber.StatusOK, "PATCH", "/api/contenttypes/"+id, map[string]string{"collection": "sites"}, token)
+	if len(during) != 4 {
+		t.Fatal("the entries were not copied")
+	}
+	for _, res := range during {
+		if res.status != fiber.StatusConflict || res.body["code"] != apierror.CodeConflict {
+			t.Errorf("write during the copy: status %d: %v", res.status, res.body)
+		}
+	}
+
+	res := a.expect(fiber.StatusOK, "GET", "/api/sites", nil, "")
+	if entries := list(t, res.body, "content"); len(entries) != 1 || entries[0].(map[string]interface{})["title"] != "Home" {
+		t.Errorf("unexpected entries after the move: %v", entries)
+	}
+	res = a.expect(fiber.StatusOK, "GET", "/api/sites/trash", nil, token)
+	if entries := list(t, res.body, "content"); len(entries) != 1 {
+		t.Errorf("trashed entry not moved: %v", entries)
+	}
+	a.createContent(token, "sites", map[string]interface{}{"title": "New", "fields": map[string]string{"body": "Written"}})
+}
+
+func TestMoveCollectionTarget(t *testing.T) {
+	a := newTestApp(t)
+	token := a.adminToken()
+	id := a.createContentType(token, pagesContentType("User"))
+	posts := a.createContentType(token, map[string]interface{}{"typename": "post", "collection": "posts"})
+	a.createContent(token, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{"body": "Welcome"}})
+
+	a.expectError(fiber.StatusConflict, apierror.CodeAlreadyExists, "PATCH", "/api/contenttypes/"+id, map[string]string{"collection": "posts"}, token)
+
+	// The previous collection of another content type redirects to its new one
+	a.expect(fiber.StatusOK, "PATCH", "/api/contenttypes/"+posts, map[string]string{"collection": "articles"}, token)
+	a.expectError(fiber.StatusConflict, apierror.CodeConflict, "PATCH", "/api/contenttypes/"+id, map[string]string{"collection": "posts"}, token)
+	a.expect(fiber.StatusTemporaryRedirect, "GET", "/api/posts", nil, "")
+	a.expect(fiber.StatusOK, "GET", "/api/pages", nil, "")
+}
+
+func TestIndexReconciliation(t *testing.T) {
+	s := memory.New()
+	a := newTestAppOn(t, s)
+	token := a.adminToken()
+	ct := pagesContentType("User")
+	ct["indexes"] = []map[string]interface{}{{"name": "by_title", "keys": []map[string]string{{"field": "title"}}}}
+	id := a.createContentType(token, ct)
+
+	// index created by hand
+	ctx := context.Background()
+	manual := model.IndexDefinition{Name: "by_body", Keys: []model.IndexKey{{Field: "fields.body"}}}
+	if err := s.Indexes.Create(ctx, "pages", manual); err != nil {
+		t.Fatal(err)
+	}
+
+	a.expect(fiber.StatusOK, "PATCH", "/api/contenttypes/"+id, map[string]interface{}{"indexes": []interface{}{}}, token)
+	indexes, err := s.Indexes.List(ctx, "pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(indexes))
+	for i, idx := range indexes {
+		names[i] = idx.Name
+	}
+	if len(names) != 1 || names[0] != "by_body" {
+		t.Errorf("got indexes %v, want only the index created by hand", names)
+	}
+
+	res := a.expect(fiber.StatusOK, "GET", "/api/contenttypes/"+id, nil, "")
+	state := list(t, object(t, object(t, res.body, "contenttype"), "index_state"), "indexes")
+	if len(state) != 1 || state[0].(map[string]interface{})["status"] != "unmanaged" {
+		t.Errorf("unexpected index state %v", state)
+	}
+}
+
+func TestFieldMigration(t *testing.T) {
+	s := memory.New()
+	a := newTestAppOn(t, s)
+	token := a.adminToken()
+	id := a.createContentType(token, pagesContentType("User"))
+	entry := a.createContent(token, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{"body": "Welcome"}})
+	rename := map[string]interface{}{"operation": "rename", "field": "body", "to": "text"}
+
+	dryRun := map[string]interface{}{"operation": "rename", "field": "body", "to": "text", "dry_run": true}
+	res := a.expect(fiber.StatusOK, "POST", "/api/contenttypes/"+id+"/migrations", dryRun, token)
+	if preview := object(t, res.body, "migration"); preview["affected"] != float64(1) {
+		t.Fatalf("unexpected preview %v", preview)
+	}
+
+	// Entries, that still have the target field from an older schema, would lose its value
+	about := &model.Content{ID: primitive.NewObjectID(), Title: "About", Fields: map[string]interface{}{"body": "About us", "text": "Old"}}
+	if _, err := s.Content.Insert(context.Background(), "pages", about); err != nil {
+		t.Fatal(err)
+	}
+	a.expectError(fiber.StatusUnprocessableEntity, apierror.CodeValidationFailed, "POST", "/api/contenttypes/"+id+"/migrations", dryRun, token)
+	a.expectError(fiber.StatusUnprocessableEntity, apierror.CodeValidationFailed, "POST", "/api/contenttypes/"+id+"/migrations", rename, token)
+	if _, err := s.Content.Delete(context.Background(), "pages", bson.M{"_id": about.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	res = a.expect(fiber.StatusAccepted, "POST", "/api/contenttypes/"+id+"/migrations", rename, token)
+	mID := object(t, res.body, "migration")["_id"].(string)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res = a.expect(fiber.StatusOK, "GET", "/api/contenttypes/"+id+"/migrations/"+mID, nil, token)
+		if status := object(t, res.body, "migration")["status"]; status == "completed" {
+			break
+		} else if status == "failed" || time.Now().After(deadline) {
+			t.Fatalf("migration not completed: %v", res.body["migration"])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	content := list(t, a.expect(fiber.StatusOK, "GET", "/api/pages?id="+entry, nil, "").body, "content")
+	if fields := object(t, content[0].(map[string]interface{}), "fields"); fields["text"] != "Welcome" || fields["body"] != nil {
+		t.Errorf("field not renamed: %v", fields)
+	}
+	res = a.expect(fiber.StatusOK, "GET", "/api/audit?action=migrate", nil, token)
+	if entries := list(t, res.body, "audit"); len(entries) != 1 {
+		t.Errorf("got %d audit entries of the migration, want 1", len(entries))
+	}
+}
+
+func TestFieldMigrationGuards(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			a := newTestAppOn(t, s)
+			token := a.adminToken()
+			id := a.createContentType(token, pagesContentType("User"))
+			ctID, _ := primitive.ObjectIDFromHex(id)
+
+			// The trash marker is not a field of the content type
+			a.expectError(fiber.StatusUnprocessableEntity, apierror.CodeValidationFailed, "POST", "/api/contenttypes/"+id+"/migrations", map[string]interface{}{"operation": "drop", "field": "deleted_at"}, token)
+
+			// A job, that is still active, rejects other jobs through the unique index, even if the check of the status misses it
+			active := &model.Migration{ContentTypeID: ctID, Collection: "pages", Active: &ctID}
+			active.Init()
+			active.Status = model.MigrationCompleted
+			if _, err := s.Migrations.Insert(context.Background(), active); err != nil {
+				t.Fatal(err)
+			}
+			drop := map[string]interface{}{"operation": "drop", "field": "body"}
+			a.expectError(fiber.StatusConflict, apierror.CodeConflict, "POST", "/api/contenttypes/"+id+"/migrations", drop, token)
+
+			active.Active = nil
+			if err := s.Migrations.Replace(context.Background(), active); err != nil {
+				t.Fatal(err)
+			}
+			a.expect(fiber.StatusAccepted, "POST", "/api/contenttypes/"+id+"/migrations", drop, token)
+		})
+	}
+}
+
+func TestApplyPermissions(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.adminToken()
+	a.createRole(admin, "editor", "Editor")
+	a.createContentType(admin, pagesContentType("Editor"))
+	entry := a.createContent(admin, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{"body": "Welcome"}})
+
+	userID := a.createUser("alice", "secret")
+	token := a.login("alice", "secret")
+
+	// Reading content needs no permission
+	a.expect(fiber.StatusOK, "GET", "/api/pages", nil, "")
+
+	a.expectError(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "POST", "/api/pages", map[string]interface{}{"title": "About", "fields": map[string]string{}}, "")
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "POST", "/api/pages", map[string]interface{}{"title": "About", "fields": map[string]string{}}, token)
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "PATCH", "/api/pages/"+entry, map[string]string{"title": "Start"}, token)
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "DELETE", "/api/pages/"+entry, nil, token)
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "GET", "/api/pages/trash", nil, token)
+
+	// The preset content types can be written by every user
+	a.createContent(token, "blogposts", map[string]interface{}{"title": "Hello", "fields": map[string]string{"description": "First post"}})
+
+	// Roles are part of the token, so the user has to log in again after the role was added
+	a.expect(fiber.StatusOK, "PATCH", "/api/user/"+userID, map[string]interface{}{"roles": []string{"User", "Editor"}}, admin)
+	token = a.login("alice", "secret")
+	a.createContent(token, "pages", map[string]interface{}{"title": "About", "fields": map[string]string{}})
+	a.expect(fiber.StatusOK, "PATCH", "/api/pages/"+entry, map[string]string{"title": "Start"}, token)
+	a.expect(fiber.StatusOK, "DELETE", "/api/pages/"+entry, nil, token)
+	a.expect(fiber.StatusOK, "GET", "/api/pages/trash", nil, token)
+}
@@ -0,0 +1,166 @@
+package router_test
+
+import (
+	"testing"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestContentCRUD(t *testing.T) {
+	a := newTestApp(t)
+	a.createUser("alice", "secret")
+	token := a.login("alice", "secret")
+
+	id := a.createContent(token, "blogposts", map[string]interface{}{
+		"title":     "Hello World",
+		"published": true,
+		"tags":      []string{"foo", "bar"},
+		"fields":    map[string]string{"description": "First post", "text": "Lorem ipsum"},
+	})
+	a.createContent(token, "blogposts", map[string]interface{}{
+		"title":     "Second",
+		"published": false,
+		"tags":      []string{"bar"},
+		"fields":    map[string]string{"description": "Second post", "text": "Dolor sit"},
+	})
+	a.expectError(fiber.StatusBadRequest, apierror.CodeInvalidInput, "POST", "/api/blogposts", map[string]interface{}{"fields": map[string]string{}}, token)
+
+	// Queries
+	for query, want := range map[string]int{
+		"":                         2,
+		"?title=Hello%20World":     1,
+		"?tags=bar":                2,
+		"?tags=foo":                1,
+		"?published=false":         1,
+		"?description=Second+post": 1,
+		"?id=" + id:                1,
+	} {
+		res := a.expect(fiber.StatusOK, "GET", "/api/blogposts"+query, nil, "")
+		if got := len(list(t, res.body, "content")); got != want {
+			t.Errorf("GET /api/blogposts%s returned %d entries, want %d", query, got, want)
+		}
+	}
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/blogposts?title=Missing", nil, "")
+	a.expectError(fiber.StatusNotFound, apierror.CodeRouteNotFound, "GET", "/api/unknown", nil, "")
+
+	// Update
+	res := a.expect(fiber.StatusOK, "PATCH", "/api/blogposts/"+id, map[string]interface{}{
+		"tags":   []string{"baz"},
+		"fields": map[string]string{"description": "Updated"},
+	}, token)
+	if matched(t, res.body) != 1 {
+		t.Fatalf("unexpected result %v", res.body["result"])
+	}
+	res = a.expect(fiber.StatusOK, "GET", "/api/blogposts?id="+id, nil, "")
+	entry := list(t, res.body, "content")[0].(map[string]interface{})
+	fields := object(t, entry, "fields")
+	if entry["title"] != "Hello World" || !contains(list(t, entry, "tags"), "baz") || fields["description"] != "Updated" || fields["text"] != "Lorem ipsum" {
+		t.Errorf("entry not updated: %v", entry)
+	}
+	a.expectError(fiber.StatusBadRequest, apierror.CodeInvalidID, "PATCH", "/api/blogposts/not-an-id", map[string]string{"title": "x"}, token)
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "PATCH", "/api/blogposts/000000000000000000000000", map[string]string{"title": "x"}, token)
+
+	// Trash, restore and purge
+	a.expect(fiber.StatusOK, "DELETE", "/api/blogposts/"+id, nil, token)
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/blogposts?id="+id, nil, "")
+	res = a.expect(fiber.StatusOK, "GET", "/api/blogposts/trash", nil, token)
+	if trashed := list(t, res.body, "content"); len(trashed) != 1 || trashed[0].(map[string]interface{})["_id"] != id {
+		t.Fatalf("unexpected trash %v", trashed)
+	}
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "DELETE", "/api/blogposts/"+id, nil, token)
+	a.expect(fiber.StatusOK, "POST", "/api/blogposts/trash/"+id+"/restore", nil, token)
+	a.expect(fiber.StatusOK, "GET", "/api/blogposts?id="+id, nil, "")
+
+	a.expect(fiber.StatusOK, "DELETE", "/api/blogposts/"+id, nil, token)
+	res = a.expect(fiber.StatusOK, "DELETE", "/api/blogposts/trash/"+id, nil, token)
+	if deleted := object(t, res.body, "result")["DeletedCount"]; deleted != 1.0 {
+		t.Errorf("purge deleted %v entries", deleted)
+	}
+	res = a.expect(fiber.StatusOK, "GET", "/api/blogposts/trash", nil, token)
+	if trashed, _ := res.body["content"].([]interface{}); len(trashed) != 0 {
+		t.Errorf("trash not empty after purge: %v", trashed)
+	}
+}
+
+func TestSlugsAndLocales(t *testing.T) {
+	a := newTestApp(t)
+	token := a.adminToken()
+	a.createContentType(token, map[string]interface{}{
+		"typename":   "page",
+		"collection": "pages",
+		"field_schema": map[string]interface{}{
+			"slug": map[string]interface{}{"type": "slug"},
+			"body": map[string]interface{}{"type": "string", "localized": true},
+		},
+	})
+
+	id := a.createContent(token, "pages?locale=en", map[string]interface{}{"title": "Hello World", "fields": map[string]string{"body": "Welcome"}})
+	a.expect(fiber.StatusOK, "PATCH", "/api/pages/"+id+"?locale=de", map[string]interface{}{"fields": map[string]string{"body": "Willkommen"}}, token)
+
+	// Slugs are generated from the title and can be used to get a single entry
+	res := a.expect(fiber.StatusOK, "GET", "/api/pages/by/slug/hello-world", nil, "")
+	if entry := object(t, res.body, "content"); entry["_id"] != id {
+		t.Errorf("got entry %v by slug", entry["_id"])
+	}
+	a.expectError(fiber.StatusBadRequest, apierror.CodeInvalidInput, "GET", "/api/pages/by/body/Welcome", nil, "")
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/pages/by/slug/missing", nil, "")
+
+	// Localized fields are matched and returned in the requested locale
+	for locale, want := range map[string]string{"en": "Welcome", "de": "Willkommen"} {
+		res := a.expect(fiber.StatusOK, "GET", "/api/pages?locale="+locale+"&body="+want, nil, "")
+		entry := list(t, res.body, "content")[0].(map[string]interface{})
+		if body := object(t, entry, "fields")["body"]; body != want {
+			t.Errorf("body in locale %s is %v, want %s", locale, body, want)
+		}
+	}
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "GET", "/api/pages?locale=en&body=Willkommen", nil, "")
+
+	// Requested locales are normalized and must be configured
+	res = a.expect(fiber.StatusOK, "GET", "/api/pages/by/slug/hello-world?locale=DE", nil, "")
+	if body := object(t, object(t, res.body, "content"), "fields")["body"]; body != "Willkommen" {
+		t.Errorf("body in locale DE is %v, want Willkommen", body)
+	}
+	a.expectError(fiber.StatusBadRequest, apierror.CodeInvalidQuery, "GET", "/api/pages?locale=xx", nil, "")
+	a.expectError(fiber.StatusBadRequest, apierror.CodeInvalidQuery, "PATCH", "/api/pages/"+id+"?locale=xx", map[string]interface{}{"fields": map[string]string{"body": "?"}}, token)
+}
+
+func TestUniqueFields(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			a := newTestAppOn(t, open(t))
+			token := a.adminToken()
+			a.createContentType(token, map[string]interface{}{
+				"typename":   "product",
+				"collection": "products",
+				"field_schema": map[string]interface{}{
+					"sku": map[string]interface{}{"type": "string", "unique": true},
+				},
+				"indexes": []map[string]interface{}{
+					{"keys": []map[string]string{{"field": "shelf"}, {"field": "row"}}, "unique": true, "partial_filter": map[string]bool{"published": true}},
+				},
+			})
+
+			first := a.createContent(token, "products", map[string]interface{}{"title": "First", "fields": map[string]interface{}{"sku": "A-1", "shelf": 1, "row": 1}})
+			second := a.createContent(token, "products", map[string]interface{}{"title": "Second", "fields": map[string]interface{}{"sku": "A-2", "shelf": 1, "row": 1}})
+			// entries without the unique field do not conflict
+			a.createContent(token, "products", map[string]interface{}{"title": "Third", "fields": map[string]interface{}{}})
+			a.createContent(token, "products", map[string]interface{}{"title": "Fourth", "fields": map[string]interface{}{}})
+
+			a.expectError(fiber.StatusConflict, apierror.CodeDuplicateKey, "POST", "/api/products", map[string]interface{}{"title": "Copy", "fields": map[string]interface{}{"sku": "A-1"}}, token)
+			a.expectError(fiber.StatusConflict, apierror.CodeDuplicateKey, "PATCH", "/api/products/"+second, map[string]interface{}{"fields": map[string]interface{}{"sku": "A-1"}}, token)
+			a.expect(fiber.StatusOK, "PATCH", "/api/products/"+first, map[string]interface{}{"fields": map[string]interface{}{"sku": "A-1"}}, token)
+
+			// the declared index only covers published entries
+			published := map[string]interface{}{"published": true}
+			a.expect(fiber.StatusOK, "PATCH", "/api/products/"+first, published, token)
+			a.expectError(fiber.StatusConflict, apierror.CodeDuplicateKey, "PATCH", "/api/products/"+second, published, token)
+
+			res := a.expect(fiber.StatusOK, "GET", "/api/products?id="+second, nil, "")
+			if entry := list(t, res.body, "content")[0].(map[string]interface{}); entry["published"] == true || object(t, entry, "fields")["sku"] != "A-2" {
+				t.Errorf("rejected update was stored: %v", entry)
+			}
+		})
+	}
+}
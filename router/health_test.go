@@ -0,0 +1,66 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/router"
+	"github.com/D-Bald/fiber-backend/store/memory"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHealth(t *testing.T) {
+	a := newTestApp(t)
+	a.expect(fiber.StatusOK, "GET", "/api/health/live", nil, "")
+	a.expect(fiber.StatusOK, "GET", "/api/health/ready", nil, "")
+
+	// The detailed health is only available to admins
+	a.expectError(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "GET", "/api/health", nil, "")
+	var down bool
+	a.ctrl.AddDependency("database", func(context.Context) error {
+		if down {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	res := a.expect(fiber.StatusOK, "GET", "/api/health", nil, a.adminToken())
+	if res.body["version"] == "" || res.body["uptime_seconds"] == nil {
+		t.Errorf("no build or uptime: %v", res.body)
+	}
+	health := object(t, res.body, "health")
+	deps := list(t, health, "dependencies")
+	if health["ready"] != true || len(deps) != 1 || deps[0].(map[string]interface{})["up"] != true {
+		t.Errorf("health %v", health)
+	}
+
+	down = true
+	res = a.expectError(fiber.StatusServiceUnavailable, apierror.CodeUnavailable, "GET", "/api/health/ready", nil, "")
+	if details := list(t, res.body, "details"); len(details) != 1 || details[0].(map[string]interface{})["field"] != "database" {
+		t.Errorf("details %v", res.body["details"])
+	}
+	a.expect(fiber.StatusOK, "GET", "/api/health/live", nil, "")
+	deps = list(t, object(t, a.expect(fiber.StatusOK, "GET", "/api/health", nil, a.adminToken()).body, "health"), "dependencies")
+	if dep := deps[0].(map[string]interface{}); dep["up"] != false || dep["error"] != "connection refused" {
+		t.Errorf("dependency %v", dep)
+	}
+}
+
+func TestReadyAfterSeeding(t *testing.T) {
+	ctrl := controller.New(memory.New())
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.Handler})
+	router.SetupRoutes(app, ctrl)
+	a := &testApp{t: t, app: app, ctrl: ctrl}
+
+	a.expectError(fiber.StatusServiceUnavailable, apierror.CodeUnavailable, "GET", "/api/health/ready", nil, "")
+	ctx := context.Background()
+	for _, init := range []func(context.Context) error{ctrl.InitRoles, ctrl.InitContentTypes, ctrl.InitAdminUser} {
+		if err := init(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.expect(fiber.StatusOK, "GET", "/api/health/ready", nil, "")
+}
@@ -0,0 +1,92 @@
+package router_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/router"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Returns the status and body of the metrics endpoint
+func (a *testApp) scrape(token string) (int, string) {
+	a.t.Helper()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	resp, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestMetrics(t *testing.T) {
+	a := newTestApp(t)
+	a.createUser("alice", "secret")
+	token := a.login("alice", "secret")
+	a.request("POST", "/api/auth/login", map[string]string{"identity": "alice", "password": "wrong"}, "")
+	id := a.createContent(token, "blogposts", map[string]interface{}{"title": "Hello World", "fields": map[string]string{}})
+	a.expect(fiber.StatusOK, "DELETE", "/api/blogposts/"+id, nil, token)
+	a.expectError(fiber.StatusNotFound, apierror.CodeRouteNotFound, "GET", "/api/unknown/"+id, nil, "")
+
+	status, body := a.scrape(metricsToken)
+	if status != fiber.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	for _, want := range []string{
+		// Routes are recorded by their template, errors with their status
+		`fiber_backend_http_requests_total{collection="blogposts",method="DELETE",route="/api/:content/:id",status="200"}`,
+		`fiber_backend_http_requests_total{collection="",method="GET",route="/api/:content",status="404"}`,
+		`fiber_backend_http_request_duration_seconds_count{collection="blogposts",method="POST",route="/api/:content",status="200"}`,
+		`fiber_backend_logins_total{result="failure"}`,
+		`fiber_backend_logins_total{result="success"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+	if strings.Contains(body, id) || strings.Contains(body, "unknown") {
+		t.Error("metrics contain the path of a request")
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	a := newTestApp(t)
+	if status, _ := a.scrape(""); status != fiber.StatusUnauthorized {
+		t.Errorf("status %d without token", status)
+	}
+	if status, _ := a.scrape("wrong"); status != fiber.StatusUnauthorized {
+		t.Errorf("status %d with a wrong token", status)
+	}
+	if status, body := a.scrape(metricsToken); status != fiber.StatusOK {
+		t.Errorf("status %d with token: %s", status, body)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	cfg := *config.Get()
+	cfg.Metrics.Enabled = false
+	previous := config.Get()
+	config.Set(&cfg)
+	defer config.Set(previous)
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.Handler})
+	router.SetupRoutes(app, newTestApp(t).ctrl)
+	a := &testApp{t: t, app: app}
+	if status, _ := a.scrape(metricsToken); status != fiber.StatusNotFound {
+		t.Errorf("status %d of the disabled metrics", status)
+	}
+}
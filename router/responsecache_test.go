@@ -0,0 +1,81 @@
+package router_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/middleware"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/responsecache"
+	"github.com/D-Bald/fiber-backend/store/memory"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Expects the X-Cache header of a response
+func expectCache(t *testing.T, res *response, status string) {
+	t.Helper()
+	if got := res.header.Get(middleware.HeaderXCache); got != status {
+		t.Fatalf("X-Cache %q, want %q", got, status)
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	s := memory.New()
+	a := newTestAppOn(t, s, func(ctrl *controller.Controller) {
+		ctrl.UseResponseCache(responsecache.NewMemory(100))
+	})
+	token := a.adminToken()
+	a.createContentType(token, pagesContentType("User"))
+	id := a.createContent(token, "pages", map[string]interface{}{"title": "Hello", "fields": map[string]string{"body": "Welcome"}})
+
+	res := a.expect(fiber.StatusOK, "GET", "/api/pages?title=Hello&body=Welcome", nil, "")
+	expectCache(t, res, "MISS")
+	if got := res.header.Get(middleware.HeaderSurrogateKey); got != "collection:pages entry:pages/"+id {
+		t.Errorf("Surrogate-Key %q", got)
+	}
+	if cc := res.header.Get(fiber.HeaderCacheControl); !strings.HasPrefix(cc, "public") || res.header.Get(fiber.HeaderVary) != fiber.HeaderAcceptLanguage {
+		t.Errorf("Cache-Control %q, Vary %q", cc, res.header.Get(fiber.HeaderVary))
+	}
+	// The order of the query params does not matter
+	res = a.expect(fiber.StatusOK, "GET", "/api/pages?body=Welcome&title=Hello", nil, "")
+	expectCache(t, res, "HIT")
+	if len(list(t, res.body, "content")) != 1 {
+		t.Errorf("cached response %v", res.body)
+	}
+	// Requests of users are not cached
+	res = a.expect(fiber.StatusOK, "GET", "/api/pages?title=Hello&body=Welcome", nil, token)
+	if res.header.Get(middleware.HeaderXCache) != "" || res.header.Get(fiber.HeaderCacheControl) != "private" {
+		t.Errorf("request with token: X-Cache %q, Cache-Control %q", res.header.Get(middleware.HeaderXCache), res.header.Get(fiber.HeaderCacheControl))
+	}
+
+	// Writes purge the responses of their collection
+	expectCache(t, a.expect(fiber.StatusOK, "GET", "/api/pages", nil, ""), "MISS")
+	expectCache(t, a.expect(fiber.StatusOK, "GET", "/api/pages", nil, ""), "HIT")
+	a.expect(fiber.StatusOK, "PATCH", "/api/pages/"+id, map[string]interface{}{"title": "Changed"}, token)
+	res = a.expect(fiber.StatusOK, "GET", "/api/pages", nil, "")
+	expectCache(t, res, "MISS")
+	if title := list(t, res.body, "content")[0].(map[string]interface{})["title"]; title != "Changed" {
+		t.Errorf("title %v after the update", title)
+	}
+
+	// Admins purge responses of changes, that did not go through the API
+	if _, err := s.Content.Insert(context.Background(), "pages", &model.Content{ID: primitive.NewObjectID(), Title: "Imported"}); err != nil {
+		t.Fatal(err)
+	}
+	expectCache(t, a.expect(fiber.StatusOK, "GET", "/api/pages", nil, ""), "HIT")
+	a.createUser("alice", "correct horse")
+	a.expect(fiber.StatusForbidden, "DELETE", "/api/cache/pages/"+id, nil, a.login("alice", "correct horse"))
+	a.expect(fiber.StatusOK, "DELETE", "/api/cache/pages/"+id, nil, token)
+	res = a.expect(fiber.StatusOK, "GET", "/api/pages", nil, "")
+	expectCache(t, res, "MISS")
+	if len(list(t, res.body, "content")) != 2 {
+		t.Errorf("response after the purge %v", res.body)
+	}
+	a.expect(fiber.StatusNotFound, "DELETE", "/api/cache/posts", nil, token)
+	a.expect(fiber.StatusOK, "DELETE", "/api/cache", nil, token)
+	expectCache(t, a.expect(fiber.StatusOK, "GET", "/api/pages", nil, ""), "MISS")
+}
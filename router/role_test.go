@@ -0,0 +1,152 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/memory"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDeleteRole(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.adminToken()
+	roleID := a.createRole(admin, "editor", "Editor")
+	ctID := a.createContentType(admin, pagesContentType("Editor"))
+	userID := a.createUser("alice", "secret")
+	a.expect(fiber.StatusOK, "PATCH", "/api/user/"+userID, map[string]interface{}{"roles": []string{"User", "Editor"}}, admin)
+
+	// A content type in the trash loses the role as well
+	trashed := pagesContentType("Editor")
+	trashed["typename"], trashed["collection"] = "draft", "drafts"
+	trashedID := a.createContentType(admin, trashed)
+	a.expect(fiber.StatusOK, "DELETE", "/api/contenttypes/"+trashedID, nil, admin)
+
+	res := a.expect(fiber.StatusOK, "DELETE", "/api/role/"+roleID, nil, admin)
+	if deleted := object(t, res.body, "result")["DeletedCount"]; deleted != 1.0 {
+		t.Fatalf("deleted %v roles", deleted)
+	}
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "DELETE", "/api/role/"+roleID, nil, admin)
+
+	res = a.expect(fiber.StatusOK, "GET", "/api/user?username=alice", nil, admin)
+	user := list(t, res.body, "user")[0].(map[string]interface{})
+	if roles := list(t, user, "roles"); len(roles) != 1 || roles[0] != "User" {
+		t.Errorf("user has roles %v after the role was deleted", roles)
+	}
+
+	ct := object(t, a.expect(fiber.StatusOK, "GET", "/api/contenttypes/"+ctID, nil, "").body, "contenttype")
+	for method, roles := range object(t, ct, "permissions") {
+		if contains(roles.([]interface{}), "Editor") {
+			t.Errorf("%s permission still contains the deleted role", method)
+		}
+	}
+	res = a.expect(fiber.StatusOK, "GET", "/api/contenttypes/trash", nil, admin)
+	for _, ct := range list(t, res.body, "contenttype") {
+		for method, roles := range object(t, ct.(map[string]interface{}), "permissions") {
+			if contains(roles.([]interface{}), "Editor") {
+				t.Errorf("%s permission of trashed content type still contains the deleted role", method)
+			}
+		}
+	}
+
+	// Only the administrator role is left to write the content type
+	a.expectError(fiber.StatusForbidden, apierror.CodeForbidden, "POST", "/api/pages", map[string]interface{}{"title": "Home", "fields": map[string]string{}}, a.login("alice", "secret"))
+	a.createContent(admin, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{}})
+}
+
+// Store of the users, whose update fails after `succeed` updates. A negative `succeed` never fails.
+type failingUsers struct {
+	store.UserStore
+	succeed *int
+}
+
+func (s failingUsers) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	if *s.succeed == 0 {
+		*s.succeed = -1
+		return nil, errors.New("storage unavailable")
+	}
+	*s.succeed--
+	return s.UserStore.Update(ctx, filter, update)
+}
+
+// Returns the role names of the user
+func userRoles(a *testApp, admin string, username string) []interface{} {
+	a.t.Helper()
+	res := a.expect(fiber.StatusOK, "GET", "/api/user?username="+username, nil, admin)
+	return list(a.t, list(a.t, res.body, "user")[0].(map[string]interface{}), "roles")
+}
+
+func TestDeleteRoleFailure(t *testing.T) {
+	s := memory.New()
+	succeed := -1
+	s.Users = failingUsers{s.Users, &succeed}
+	a := newTestAppOn(t, s)
+	admin := a.adminToken()
+	roleID := a.createRole(admin, "editor", "Editor")
+	for _, name := range []string{"alice", "bob"} {
+		id := a.createUser(name, "secret")
+		a.expect(fiber.StatusOK, "PATCH", "/api/user/"+id, map[string]interface{}{"roles": []string{"User", "Editor"}}, admin)
+	}
+
+	// The second user can not be updated, so the first one gets the role back
+	succeed = 1
+	a.expect(fiber.StatusInternalServerError, "DELETE", "/api/role/"+roleID, nil, admin)
+	for _, name := range []string{"alice", "bob"} {
+		if roles := userRoles(a, admin, name); !contains(roles, "Editor") {
+			t.Errorf("%s has roles %v after the failed deletion", name, roles)
+		}
+	}
+	oid, _ := primitive.ObjectIDFromHex(roleID)
+	if r, err := s.Roles.FindOne(context.Background(), bson.M{"_id": oid}); err != nil || r.DeletingAt != nil {
+		t.Fatalf("role after the failed deletion: %+v, %v", r, err)
+	}
+
+	a.expect(fiber.StatusOK, "DELETE", "/api/role/"+roleID, nil, admin)
+	if roles := userRoles(a, admin, "alice"); contains(roles, "Editor") {
+		t.Errorf("alice has roles %v after the deletion", roles)
+	}
+}
+
+func TestResumeCascades(t *testing.T) {
+	s := memory.New()
+	a := newTestAppOn(t, s)
+	admin := a.adminToken()
+	roleID := a.createRole(admin, "editor", "Editor")
+	userID := a.createUser("alice", "secret")
+	a.expect(fiber.StatusOK, "PATCH", "/api/user/"+userID, map[string]interface{}{"roles": []string{"User", "Editor"}}, admin)
+	ctID := a.createContentType(admin, pagesContentType("Editor"))
+	a.createContent(admin, "pages", map[string]interface{}{"title": "Home", "fields": map[string]string{}})
+	a.expect(fiber.StatusOK, "DELETE", "/api/contenttypes/"+ctID, nil, admin)
+
+	// Deletions, that were interrupted by a crash, are finished
+	ctx := context.Background()
+	mark := bson.M{"$currentDate": bson.M{"deleting_at": true}}
+	roleOID, _ := primitive.ObjectIDFromHex(roleID)
+	ctOID, _ := primitive.ObjectIDFromHex(ctID)
+	if _, err := s.Roles.Update(ctx, bson.M{"_id": roleOID}, mark); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ContentTypes.Update(ctx, bson.M{"_id": ctOID}, mark); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ctrl.ResumeCascades(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	a.expectError(fiber.StatusNotFound, apierror.CodeNotFound, "DELETE", "/api/role/"+roleID, nil, admin)
+	if roles := userRoles(a, admin, "alice"); len(roles) != 1 || roles[0] != "User" {
+		t.Errorf("alice has roles %v after the deletion was resumed", roles)
+	}
+	if _, err := s.ContentTypes.FindOne(ctx, bson.M{"_id": ctOID}); err != store.ErrNotFound {
+		t.Errorf("content type after the purge was resumed: %v", err)
+	}
+	if entries, err := s.Content.Find(ctx, "pages", bson.M{}); err != nil || len(entries) != 0 {
+		t.Errorf("entries after the purge was resumed: %v, %v", entries, err)
+	}
+}
@@ -1,60 +1,120 @@
 package router
 
 import (
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/config"
 	"github.com/D-Bald/fiber-backend/controller"
 	"github.com/D-Bald/fiber-backend/handler"
+	"github.com/D-Bald/fiber-backend/metrics"
 	"github.com/D-Bald/fiber-backend/middleware"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
 )
 
 // SetupRoutes setup router api
-func SetupRoutes(app *fiber.App) {
-	// API Route
-	api := app.Group("/api", logger.New())
+func SetupRoutes(app *fiber.App, ctrl *controller.Controller) {
+	h := handler.New(ctrl)
+
+	// Prometheus metrics, unless they are served on a port of their own
+	if m := config.Get().Metrics; m.Enabled && m.Port == 0 {
+		scrape := fasthttpadaptor.NewFastHTTPHandler(metrics.Handler(m.Token))
+		app.Get(m.Path, func(c *fiber.Ctx) error {
+			scrape(c.Context())
+			return nil
+		})
+	}
+
+	// API Route. Every request gets a context with the timeout of its route, the span of its trace, its logger
+	// and the source of its audited actions, that is passed to the controller.
+	server := config.Get().Server
+	api := app.Group("/api", middleware.Metrics, middleware.RequestContext(server.RequestTimeout, server.RouteTimeouts), middleware.Tracing, middleware.Logger, middleware.Audit, middleware.ReplicaReads)
 
 	// Healthcheck endpoint
-	api.Get("/", handler.Healthcheck)
+	api.Get("/", h.Healthcheck)
+
+	// Health endpoints for liveness and readiness probes and the detailed health for admins
+	health := api.Group("/health")
+	health.Get("/", middleware.Protected(), middleware.AdminOnly, h.GetHealth)
+	health.Get("/live", h.Live)
+	health.Get("/ready", h.Ready)
 
 	// Role endpoints
 	role := api.Group("/role")
-	role.Get("/", middleware.Protected(), handler.GetRoles)
-	role.Post("/", middleware.Protected(), middleware.AdminOnly, handler.CreateRole)
-	role.Patch("/:id", middleware.Protected(), middleware.AdminOnly, handler.UpdateRole)
-	role.Delete("/:id", middleware.Protected(), middleware.AdminOnly, handler.DeleteRole)
+	role.Get("/", middleware.Protected(), h.GetRoles)
+	role.Post("/", middleware.Protected(), middleware.AdminOnly, h.CreateRole)
+	role.Patch("/:id", middleware.Protected(), middleware.AdminOnly, h.UpdateRole)
+	role.Delete("/:id", middleware.Protected(), middleware.AdminOnly, h.DeleteRole)
+
+	// Audit log endpoints
+	audit := api.Group("/audit")
+	audit.Get("/", middleware.Protected(), middleware.AdminOnly, h.GetAuditLog)
+	audit.Get("/export", middleware.Protected(), middleware.AdminOnly, h.ExportAuditLog)
 
 	// Auth endpoints
 	auth := api.Group("/auth")
-	auth.Post("/login", handler.Login)
+	auth.Post("/login", h.Login)
 
 	// User endpoints
 	user := api.Group("/user")
 	// Query contents by different Paramters
-	user.Get("/", middleware.Protected(), handler.GetUsers)
-	user.Post("/", handler.CreateUser, handler.Login)
-	user.Patch("/:id", middleware.Protected(), handler.UpdateUser)
-	user.Delete("/:id", middleware.Protected(), handler.DeleteUser)
+	user.Get("/", middleware.Protected(), h.GetUsers)
+	user.Post("/", h.CreateUser, h.Login)
+	user.Patch("/:id", middleware.Protected(), h.UpdateUser)
+	user.Delete("/:id", middleware.Protected(), h.DeleteUser)
 
 	// ContentTypes endpoints
 	contentTypes := api.Group("/contenttypes")
-	contentTypes.Get("/", handler.GetAllContentTypes)
-	contentTypes.Post("/", middleware.Protected(), middleware.AdminOnly, handler.CreateContentType)
-	contentTypes.Get("/:id", handler.GetContentType)
-	contentTypes.Patch("/:id", middleware.Protected(), middleware.AdminOnly, handler.UpdateContentType)
-	contentTypes.Delete("/:id", middleware.Protected(), middleware.AdminOnly, handler.DeleteContentType)
+	contentTypes.Get("/", h.GetAllContentTypes)
+	contentTypes.Post("/", middleware.Protected(), middleware.AdminOnly, h.CreateContentType)
+	contentTypes.Get("/trash", middleware.Protected(), middleware.AdminOnly, h.GetTrashedContentTypes)
+	contentTypes.Post("/trash/:id/restore", middleware.Protected(), middleware.AdminOnly, h.RestoreContentType)
+	contentTypes.Delete("/trash/:id", middleware.Protected(), middleware.AdminOnly, h.PurgeContentType)
+	contentTypes.Get("/:id", h.GetContentType)
+	contentTypes.Patch("/:id", middleware.Protected(), middleware.AdminOnly, h.UpdateContentType)
+	contentTypes.Delete("/:id", middleware.Protected(), middleware.AdminOnly, h.DeleteContentType)
+	contentTypes.Get("/:id/migrations", middleware.Protected(), middleware.AdminOnly, h.GetMigrations)
+	contentTypes.Post("/:id/migrations", middleware.Protected(), middleware.AdminOnly, h.CreateMigration)
+	contentTypes.Get("/:id/migrations/:migrationId", middleware.Protected(), middleware.AdminOnly, h.GetMigration)
+
+	// Purge cached responses of all content, a collection or an entry
+	if ctrl.ResponseCache() != nil {
+		cache := api.Group("/cache")
+		cache.Delete("/", middleware.Protected(), middleware.AdminOnly, h.PurgeResponseCache)
+		cache.Delete("/:collection", middleware.Protected(), middleware.AdminOnly, h.PurgeResponseCache)
+		cache.Delete("/:collection/:id", middleware.Protected(), middleware.AdminOnly, h.PurgeResponseCache)
+	}
 
 	// Content endpoints
-	content := api.Group("/:content", func(c *fiber.Ctx) error { // `content` has to be a collection
-		if controller.IsValidContentCollection(c.Params("content")) {
+	content := api.Group("/:content", middleware.Traced("ContentCollection", func(c *fiber.Ctx) error { // `content` has to be a collection
+		if ctrl.IsValidContentCollection(middleware.Context(c), c.Params("content")) {
 			return c.Next()
-		} else {
-			return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Review your route for valid content type", "data": nil})
 		}
-	})
+		// Previous collections of content types redirect to the current one
+		if ct, err := ctrl.GetContentTypeByAlias(middleware.Context(c), c.Params("content")); err == nil {
+			location := "/api/" + ct.Collection + strings.TrimPrefix(c.Path(), "/api/"+c.Params("content"))
+			if q := c.Request().URI().QueryString(); len(q) > 0 {
+				location += "?" + string(q)
+			}
+			return c.Redirect(location, fiber.StatusTemporaryRedirect)
+		}
+		return apierror.New(fiber.StatusNotFound, apierror.CodeRouteNotFound, "Review your route for valid content type")
+	}))
 	// Query contents by different Paramters
-	content.Get("/", handler.GetContent)
-	content.Post("/", middleware.Protected(), middleware.ApplyPermissions, handler.CreateContent)
-	content.Patch("/:id", middleware.Protected(), middleware.ApplyPermissions, handler.UpdateContent)
-	content.Delete("/:id", middleware.Protected(), middleware.ApplyPermissions, handler.DeleteContent)
+	// Anonymous queries are served from the response cache, if it is enabled
+	cacheResponses := middleware.CacheResponses(ctrl, config.Get().ResponseCache)
+	content.Get("/", cacheResponses, h.GetContent)
+	content.Get("/export", h.ExportContent)
+	content.Post("/import", middleware.Protected(), middleware.ApplyPermissions(ctrl), h.ImportContent)
+	content.Get("/by/:field/:value", cacheResponses, h.GetContentByField)
+	content.Get("/locales/missing", h.GetMissingLocales)
+	content.Get("/trash", middleware.Protected(), middleware.ApplyPermissionsOf(ctrl, fiber.MethodDelete), h.GetTrashedContent)
+	content.Post("/trash/:id/restore", middleware.Protected(), middleware.ApplyPermissionsOf(ctrl, fiber.MethodDelete), h.RestoreContent)
+	content.Delete("/trash/:id", middleware.Protected(), middleware.ApplyPermissionsOf(ctrl, fiber.MethodDelete), h.PurgeContent)
+	content.Get("/:id/locales/missing", h.GetEntryMissingLocales)
+	content.Post("/", middleware.Protected(), middleware.ApplyPermissions(ctrl), h.CreateContent)
+	content.Patch("/:id", middleware.Protected(), middleware.ApplyPermissions(ctrl), h.UpdateContent)
+	content.Delete("/:id", middleware.Protected(), middleware.ApplyPermissions(ctrl), h.DeleteContent)
 }
@@ -0,0 +1,271 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/D-Bald/fiber-backend/apierror"
+	"github.com/D-Bald/fiber-backend/config"
+	"github.com/D-Bald/fiber-backend/controller"
+	"github.com/D-Bald/fiber-backend/database"
+	"github.com/D-Bald/fiber-backend/router"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/bolt"
+	"github.com/D-Bald/fiber-backend/store/memory"
+	"github.com/D-Bald/fiber-backend/store/postgres"
+
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/lib/pq"
+)
+
+// Password of the preset admin user
+const adminPassword = "admin-password"
+
+// Bearer token of the metrics endpoint
+const metricsToken = "scraper-token"
+
+func TestMain(m *testing.M) {
+	cfg := config.Default()
+	cfg.Auth.Secret = "test-secret-with-at-least-32-characters"
+	cfg.Auth.AdminPassword = adminPassword
+	cfg.Auth.PasswordHashCost = 4
+	cfg.Storage.Backend = "memory"
+	cfg.Content.Locales = []string{"en", "de"}
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Token = metricsToken
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+	config.Set(cfg)
+	os.Exit(m.Run())
+}
+
+// App with the routes of the API on an empty in-memory store
+type testApp struct {
+	t    *testing.T
+	app  *fiber.App
+	ctrl *controller.Controller
+}
+
+// Starts the app like `main` does with the preset roles, content types and admin user
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	return newTestAppOn(t, memory.New())
+}
+
+// Storage backends, that the tests of features implemented by each backend run on
+var backends = map[string]func(t *testing.T) *store.Store{
+	"memory": func(t *testing.T) *store.Store { return memory.New() },
+	"bolt": func(t *testing.T) *store.Store {
+		db, err := database.OpenBolt(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return bolt.New(db)
+	},
+	// All data in the database at `POSTGRES_TEST_URL` is deleted
+	"postgres": func(t *testing.T) *store.Store {
+		url := os.Getenv("POSTGRES_TEST_URL")
+		if url == "" {
+			t.Skip("POSTGRES_TEST_URL is not set")
+		}
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		ctx := context.Background()
+		if err := postgres.Migrate(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+		for _, stmt := range []string{
+			"TRUNCATE users, roles, content_types, content, migrations, audit_log, content_indexes",
+			// the unique indexes of content collections are indexes of the content table
+			`DO $$
+			DECLARE i record;
+			BEGIN
+				FOR i IN SELECT indexname FROM pg_indexes WHERE tablename = 'content' AND indexname LIKE 'content\_unique\_%' LOOP
+					EXECUTE 'DROP INDEX ' || quote_ident(i.indexname);
+				END LOOP;
+			END $$`,
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return postgres.New(db)
+	},
+}
+
+// Starts the app on the storage, e.g. to test servers, that share it.
+// configure is called with the controller before the routes are set up.
+func newTestAppOn(t *testing.T, s *store.Store, configure ...func(ctrl *controller.Controller)) *testApp {
+	t.Helper()
+	ctrl := controller.New(s)
+	// The background jobs are stopped, before the store is closed
+	t.Cleanup(func() { ctrl.Shutdown(context.Background()) })
+	ctx := context.Background()
+	for _, init := range []func(context.Context) error{ctrl.InitRoles, ctrl.InitContentTypes, ctrl.InitAuditLog, ctrl.InitMigrations, ctrl.InitAdminUser} {
+		if err := init(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, fn := range configure {
+		fn(ctrl)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.Handler})
+	router.SetupRoutes(app, ctrl)
+	return &testApp{t: t, app: app, ctrl: ctrl}
+}
+
+// Response of the API. The body is decoded into a generic map.
+type response struct {
+	status   int
+	location string
+	header   http.Header
+	body     map[string]interface{}
+}
+
+// Sends a request with a JSON body, if body is not `nil`, and the token, if it is not empty
+func (a *testApp) request(method string, path string, body interface{}, token string) *response {
+	a.t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+
+	resp, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	res := &response{status: resp.StatusCode, location: resp.Header.Get(fiber.HeaderLocation), header: resp.Header}
+	if err := json.NewDecoder(resp.Body).Decode(&res.body); err != nil && err != io.EOF {
+		a.t.Fatalf("%s %s: could not decode response: %s", method, path, err.Error())
+	}
+	return res
+}
+
+// Like `request`, but fails the test if the status differs
+func (a *testApp) expect(status int, method string, path string, body interface{}, token string) *response {
+	a.t.Helper()
+	res := a.request(method, path, body, token)
+	if res.status != status {
+		a.t.Fatalf("%s %s: status %d, want %d: %v", method, path, res.status, status, res.body)
+	}
+	return res
+}
+
+// Like `expect`, but checks the error code of the envelope as well
+func (a *testApp) expectError(status int, code string, method string, path string, body interface{}, token string) *response {
+	a.t.Helper()
+	res := a.expect(status, method, path, body, token)
+	if res.body["status"] != "error" || res.body["code"] != code {
+		a.t.Fatalf("%s %s: error %v %v, want code %s", method, path, res.body["code"], res.body["message"], code)
+	}
+	return res
+}
+
+// Returns a token of the user
+func (a *testApp) login(identity string, password string) string {
+	a.t.Helper()
+	res := a.expect(fiber.StatusOK, "POST", "/api/auth/login", map[string]string{"identity": identity, "password": password}, "")
+	return res.body["token"].(string)
+}
+
+// Returns a token of the preset admin user
+func (a *testApp) adminToken() string {
+	a.t.Helper()
+	return a.login("adminUser", adminPassword)
+}
+
+// Signs up a user with the default role and returns the user ID
+func (a *testApp) createUser(username string, password string) string {
+	a.t.Helper()
+	res := a.expect(fiber.StatusOK, "POST", "/api/user", map[string]string{
+		"username": username,
+		"email":    username + "@sample.com",
+		"password": password,
+	}, "")
+	return object(a.t, res.body, "user")["id"].(string)
+}
+
+// Creates a role and returns its ID
+func (a *testApp) createRole(token string, tag string, name string) string {
+	a.t.Helper()
+	res := a.expect(fiber.StatusOK, "POST", "/api/role", map[string]string{"tag": tag, "name": name}, token)
+	return object(a.t, res.body, "role")["_id"].(string)
+}
+
+// Creates a content type and returns its ID
+func (a *testApp) createContentType(token string, ct map[string]interface{}) string {
+	a.t.Helper()
+	res := a.expect(fiber.StatusOK, "POST", "/api/contenttypes", ct, token)
+	return object(a.t, res.body, "contenttype")["_id"].(string)
+}
+
+// Creates a content entry and returns its ID
+func (a *testApp) createContent(token string, coll string, entry map[string]interface{}) string {
+	a.t.Helper()
+	res := a.expect(fiber.StatusOK, "POST", "/api/"+coll, entry, token)
+	return object(a.t, res.body, "content")["_id"].(string)
+}
+
+// Returns the object at key
+func object(t *testing.T, body map[string]interface{}, key string) map[string]interface{} {
+	t.Helper()
+	o, ok := body[key].(map[string]interface{})
+	if !ok {
+		t.Fatalf("%s is not an object: %v", key, body[key])
+	}
+	return o
+}
+
+// Returns the list at key
+func list(t *testing.T, body map[string]interface{}, key string) []interface{} {
+	t.Helper()
+	l, ok := body[key].([]interface{})
+	if !ok {
+		t.Fatalf("%s is not a list: %v", key, body[key])
+	}
+	return l
+}
+
+// Returns the matched count of an update result
+func matched(t *testing.T, body map[string]interface{}) float64 {
+	t.Helper()
+	return object(t, body, "result")["MatchedCount"].(float64)
+}
+
+// Returns true, if the list contains the string
+func contains(l []interface{}, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
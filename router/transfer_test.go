@@ -0,0 +1,186 @@
+package router_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Content type with a unique field for the tests of import and export
+func productsContentType() map[string]interface{} {
+	return map[string]interface{}{
+		"typename":   "product",
+		"collection": "products",
+		"field_schema": map[string]interface{}{
+			"sku":   map[string]interface{}{"type": "string", "unique": true},
+			"price": "int",
+		},
+	}
+}
+
+// Sends body with the content type and returns the status and the body of the response
+func (a *testApp) send(method string, path string, contentType string, body string, token string) (int, string) {
+	a.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	resp, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+// Imports body into the products and returns the report
+func (a *testApp) importProducts(query string, contentType string, body string, token string) map[string]interface{} {
+	a.t.Helper()
+	status, res := a.send("POST", "/api/products/import"+query, contentType, body, token)
+	if status != fiber.StatusOK {
+		a.t.Fatalf("import%s: status %d: %s", query, status, res)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(res), &decoded); err != nil {
+		a.t.Fatal(err)
+	}
+	return object(a.t, decoded, "report")
+}
+
+// Checks the counts of the report
+func expectReport(t *testing.T, report map[string]interface{}, created float64, updated float64, failed float64) {
+	t.Helper()
+	if report["created"] != created || report["updated"] != updated || report["failed"] != failed {
+		t.Fatalf("report %v, want %v created, %v updated and %v failed", report, created, updated, failed)
+	}
+}
+
+// Returns the products with the title
+func (a *testApp) products(title string) []interface{} {
+	a.t.Helper()
+	status, res := a.send("GET", "/api/products?title="+title, "", "", "")
+	if status == fiber.StatusNotFound {
+		return nil
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(res), &decoded); err != nil {
+		a.t.Fatal(err)
+	}
+	return list(a.t, decoded, "content")
+}
+
+func TestExport(t *testing.T) {
+	a := newTestApp(t)
+	token := a.adminToken()
+	a.createContentType(token, productsContentType())
+	a.createContent(token, "products", map[string]interface{}{"title": "Chair", "tags": []string{"a", "b"}, "fields": map[string]interface{}{"sku": "C-1", "price": 40}})
+	a.createContent(token, "products", map[string]interface{}{"title": "Table", "fields": map[string]interface{}{"sku": "T-1", "price": 120}})
+
+	status, body := a.send("GET", "/api/products/export", "", "", "")
+	if lines := strings.Split(strings.TrimSpace(body), "\n"); status != fiber.StatusOK || len(lines) != 2 {
+		t.Fatalf("ndjson export: status %d: %s", status, body)
+	}
+
+	status, body = a.send("GET", "/api/products/export?format=json&title=Table", "", "", "")
+	var entries []map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &entries); status != fiber.StatusOK || err != nil {
+		t.Fatalf("json export: status %d: %s", status, body)
+	}
+	if len(entries) != 1 || entries[0]["title"] != "Table" {
+		t.Errorf("json export with filter: %v", entries)
+	}
+
+	status, body = a.send("GET", "/api/products/export?format=csv&title=Chair", "", "", "")
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if status != fiber.StatusOK || len(lines) != 2 {
+		t.Fatalf("csv export: status %d: %s", status, body)
+	}
+	if lines[0] != "_id,created_at,updated_at,title,published,tags,price,sku" {
+		t.Errorf("csv header: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], ",Chair,,a;b,40,C-1") {
+		t.Errorf("csv row: %s", lines[1])
+	}
+
+	a.expect(fiber.StatusBadRequest, "GET", "/api/products/export?format=xml", nil, "")
+}
+
+func TestImport(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			a := newTestAppOn(t, open(t))
+			token := a.adminToken()
+			a.createContentType(token, productsContentType())
+
+			// The malformed line and the entry without title are reported, the other rows are imported
+			ndjson := `{"title": "Chair", "fields": {"sku": "C-1", "price": 40}}
+{"title": "Broken",
+{"fields": {"sku": "X-1"}}
+{"title": "Table", "fields": {"sku": "T-1", "price": "expensive"}}
+{"title": "Lamp", "fields": {"sku": "L-1"}}
+`
+			report := a.importProducts("?dry_run=true", "application/x-ndjson", ndjson, token)
+			expectReport(t, report, 2, 0, 3)
+			if report["dry_run"] != true || len(a.products("Chair")) != 0 {
+				t.Fatalf("dry run wrote entries: %v", report)
+			}
+
+			report = a.importProducts("", "application/x-ndjson", ndjson, token)
+			expectReport(t, report, 2, 0, 3)
+			rows := list(t, report, "rows")
+			if broken := rows[1].(map[string]interface{}); broken["action"] != "failed" || !strings.HasPrefix(broken["error"].(string), "line 2:") {
+				t.Errorf("malformed line: %v", broken)
+			}
+			if invalid := rows[3].(map[string]interface{}); invalid["error"] != "validation failed" || invalid["fields"] == nil {
+				t.Errorf("invalid row: %v", invalid)
+			}
+			chair := a.products("Chair")
+			if len(chair) != 1 || len(a.products("Lamp")) != 1 {
+				t.Fatalf("imported entries missing")
+			}
+			chairID := chair[0].(map[string]interface{})["_id"].(string)
+
+			// Existing entries are updated by their ID
+			report = a.importProducts("?format=json&upsert=_id", "", `[{"_id": "`+chairID+`", "title": "Armchair", "fields": {"sku": "C-1"}}, {"title": 5}]`, token)
+			expectReport(t, report, 0, 1, 1)
+			if len(a.products("Armchair")) != 1 || len(a.products("Chair")) != 0 {
+				t.Errorf("entry not updated by _id")
+			}
+
+			// or the value of a unique field. The row with a missing column is reported.
+			csv := "title,sku,price\nStool,C-1,25\nBench,B-1\nShelf,S-1,80\n"
+			report = a.importProducts("?upsert=sku&dry_run=true", "text/csv", csv, token)
+			expectReport(t, report, 1, 1, 1)
+			if len(a.products("Stool")) != 0 {
+				t.Fatalf("dry run updated the entry")
+			}
+			report = a.importProducts("?upsert=sku", "text/csv", csv, token)
+			expectReport(t, report, 1, 1, 1)
+			if updated := list(t, report, "rows")[0].(map[string]interface{}); updated["_id"] != chairID {
+				t.Errorf("updated %v, want %s", updated["_id"], chairID)
+			}
+			stool := a.products("Stool")
+			if len(stool) != 1 || object(t, stool[0].(map[string]interface{}), "fields")["price"] != float64(25) || len(a.products("Shelf")) != 1 {
+				t.Errorf("entries not imported from csv: %v", stool)
+			}
+
+			// Upserts need a unique field and the body has to be readable as a whole
+			a.expect(fiber.StatusBadRequest, "POST", "/api/products/import?upsert=price", []interface{}{}, token)
+			status, body := a.send("POST", "/api/products/import", fiber.MIMEApplicationJSON, `{"title": "Not an array"}`, token)
+			if status != fiber.StatusBadRequest {
+				t.Errorf("json object: status %d: %s", status, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type auditStore struct {
+	*collection
+}
+
+func (s *auditStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.AuditEntry, error) {
+	docs, err := s.find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*model.AuditEntry, 0, len(docs))
+	for _, doc := range docs {
+		var e model.AuditEntry
+		if err := bson.Unmarshal(doc, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+// The matching entries are collected first, so that fn can write to the store
+func (s *auditStore) Stream(ctx context.Context, filter interface{}, fn func(*model.AuditEntry) error) error {
+	entries, err := s.Find(ctx, filter, store.SortBy("time", false))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *auditStore) Count(ctx context.Context, filter interface{}) (int64, error) {
+	return s.count(filter)
+}
+
+func (s *auditStore) Insert(ctx context.Context, entry *model.AuditEntry) (*store.InsertResult, error) {
+	return s.insert(entry)
+}
+
+// Filters scan all entries, there are no indexes to create
+func (s *auditStore) Init(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,303 @@
+// Package bolt implements the storage interfaces on an embedded bbolt database file.
+// Documents are stored as BSON with their `_id` as key, one bucket per collection.
+// Queries scan the bucket and are evaluated by the query package, so they return the same results as with MongoDB.
+// The backend is meant for small sites, that should run as a single binary without database server.
+package bolt
+
+import (
+	"bytes"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/query"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Buckets with one nested bucket per content collection for the entries and their indexes
+var (
+	contentBucket = []byte("content")
+	indexBucket   = []byte("indexes")
+)
+
+// Returns the storage backend on the opened database file
+func New(db *bbolt.DB) *store.Store {
+	return &store.Store{
+		Users:        &userStore{newCollection(db, []byte(store.CollectionUsers))},
+		Roles:        &roleStore{newCollection(db, []byte(store.CollectionRoles))},
+		ContentTypes: &contentTypeStore{newCollection(db, []byte(store.CollectionContentTypes))},
+		Content:      &contentStore{db: db},
+		Indexes:      &indexStore{db: db},
+		Migrations:   &migrationStore{&collection{db: db, path: [][]byte{[]byte(store.CollectionMigrations)}, indexes: []model.IndexDefinition{store.MigrationActiveIndex}}},
+		AuditLog:     &auditStore{newCollection(db, []byte(store.CollectionAuditLog))},
+		Transactions: store.NoTransactions{},
+	}
+}
+
+// Documents in the bucket at path. Buckets are created on the first insert.
+type collection struct {
+	db   *bbolt.DB
+	path [][]byte
+	// Unique indexes of collections, that are not content collections. Those keep their indexes in the index bucket.
+	indexes []model.IndexDefinition
+}
+
+func newCollection(db *bbolt.DB, path ...[]byte) *collection {
+	return &collection{db: db, path: path}
+}
+
+// Returns the bucket or `nil`, if it does not exist and create is false
+func (c *collection) bucket(tx *bbolt.Tx, create bool) (*bbolt.Bucket, error) {
+	var b *bbolt.Bucket
+	for i, name := range c.path {
+		var next *bbolt.Bucket
+		if i == 0 {
+			next = tx.Bucket(name)
+		} else {
+			next = b.Bucket(name)
+		}
+		if next == nil && create {
+			var err error
+			if i == 0 {
+				next, err = tx.CreateBucket(name)
+			} else {
+				next, err = b.CreateBucket(name)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+		b = next
+	}
+	return b, nil
+}
+
+// Returns all documents, that match the filter, sorted and paginated by the options
+func (c *collection) find(filter interface{}, opts []store.FindOption) ([]bson.Raw, error) {
+	f, err := query.Raw(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []bson.Raw
+	err = c.db.View(func(tx *bbolt.Tx) error {
+		b, err := c.bucket(tx, false)
+		if err != nil || b == nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			ok, err := query.Match(v, f)
+			if ok {
+				// values are only valid during the transaction
+				result = append(result, append(bson.Raw(nil), v...))
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.Select(result, store.NewFindOptions(opts...)), nil
+}
+
+// Decodes the first document, that matches the filter, into v
+func (c *collection) findOne(filter interface{}, v interface{}) error {
+	docs, err := c.find(filter, []store.FindOption{store.Limit(1)})
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return store.ErrNotFound
+	}
+	return bson.Unmarshal(docs[0], v)
+}
+
+func (c *collection) count(filter interface{}) (int64, error) {
+	docs, err := c.find(filter, nil)
+	return int64(len(docs)), err
+}
+
+// Inserts the document. Like MongoDB an ObjectID is generated, if the document has no `_id`.
+func (c *collection) insert(v interface{}) (*store.InsertResult, error) {
+	doc, id, err := query.WithID(v)
+	if err != nil {
+		return nil, err
+	}
+
+	key := idKey(id)
+	err = c.db.Update(func(tx *bbolt.Tx) error {
+		b, err := c.bucket(tx, true)
+		if err != nil {
+			return err
+		}
+		if b.Get(key) != nil {
+			return store.DuplicateKeyError("_id " + id.String())
+		}
+		if err := b.Put(key, doc); err != nil {
+			return err
+		}
+		return c.checkUnique(tx, b, doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &store.InsertResult{InsertedID: query.Value(id)}, nil
+}
+
+// Replaces the document with the same `_id`
+func (c *collection) replace(v interface{}) error {
+	doc, id, err := query.WithID(v)
+	if err != nil {
+		return err
+	}
+
+	key := idKey(id)
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b, err := c.bucket(tx, false)
+		if err != nil {
+			return err
+		}
+		if b == nil || b.Get(key) == nil {
+			return store.ErrNotFound
+		}
+		if err := b.Put(key, doc); err != nil {
+			return err
+		}
+		return c.checkUnique(tx, b, doc)
+	})
+}
+
+// Applies the update to the first or all documents, that match the filter
+func (c *collection) update(filter interface{}, update interface{}, many bool) (*store.UpdateResult, error) {
+	f, err := query.Raw(filter)
+	if err != nil {
+		return nil, err
+	}
+	u, err := query.Raw(update)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &store.UpdateResult{}
+	err = c.db.Update(func(tx *bbolt.Tx) error {
+		b, err := c.bucket(tx, false)
+		if err != nil || b == nil {
+			return err
+		}
+		// the bucket must not be changed while iterating over it
+		updated := make(map[string][]byte)
+		err = b.ForEach(func(k, v []byte) error {
+			if !many && result.MatchedCount > 0 {
+				return nil
+			}
+			ok, err := query.Match(v, f)
+			if err != nil || !ok {
+				return err
+			}
+			doc, err := query.Update(v, u)
+			if err != nil {
+				return err
+			}
+			result.MatchedCount++
+			if !bytes.Equal(v, doc) {
+				updated[string(k)] = doc
+				result.ModifiedCount++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, doc := range updated {
+			if err := b.Put([]byte(k), doc); err != nil {
+				return err
+			}
+		}
+		// the error rolls back all updates
+		for _, doc := range updated {
+			if err := c.checkUnique(tx, b, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Deletes the first or all documents, that match the filter
+func (c *collection) delete(filter interface{}, many bool) (*store.DeleteResult, error) {
+	f, err := query.Raw(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &store.DeleteResult{}
+	err = c.db.Update(func(tx *bbolt.Tx) error {
+		b, err := c.bucket(tx, false)
+		if err != nil || b == nil {
+			return err
+		}
+		// the bucket must not be changed while iterating over it
+		var keys [][]byte
+		err = b.ForEach(func(k, v []byte) error {
+			if !many && len(keys) > 0 {
+				return nil
+			}
+			ok, err := query.Match(v, f)
+			if ok {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		result.DeletedCount = int64(len(keys))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Returns a duplicate key error, if the document in the bucket violates one of the unique indexes of its collection
+func (c *collection) checkUnique(tx *bbolt.Tx, b *bbolt.Bucket, doc bson.Raw) error {
+	if len(c.path) != 2 || !bytes.Equal(c.path[0], contentBucket) {
+		return checkUnique(c.indexes, b, doc)
+	}
+	defs, err := listIndexes(tx, string(c.path[1]))
+	if err != nil {
+		return err
+	}
+	return checkUnique(defs, b, doc)
+}
+
+// Returns a duplicate key error, if the document has the same key as another document of the bucket in one of the unique indexes
+func checkUnique(defs []model.IndexDefinition, b *bbolt.Bucket, doc bson.Raw) error {
+	indexes, err := query.UniqueIndexes(defs)
+	if err != nil || len(indexes) == 0 {
+		return err
+	}
+	return query.CheckUnique(indexes, doc, func(fn func(bson.Raw) error) error {
+		return b.ForEach(func(k, v []byte) error {
+			return fn(v)
+		})
+	})
+}
+
+// Key of a document: the BSON type and value of its `_id`, so that IDs of different types do not collide
+func idKey(id bson.RawValue) []byte {
+	return append([]byte{byte(id.Type)}, id.Value...)
+}
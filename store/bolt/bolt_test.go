@@ -0,0 +1,136 @@
+package bolt_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/database"
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/bolt"
+	"github.com/D-Bald/fiber-backend/store/memory"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func open(t *testing.T) *store.Store {
+	t.Helper()
+	db, err := database.OpenBolt(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return bolt.New(db)
+}
+
+func TestContent(t *testing.T) {
+	s := open(t)
+	ctx := context.Background()
+	published := true
+	for i, title := range []string{"First", "Second", "Third"} {
+		entry := &model.Content{ID: primitive.NewObjectID(), CreatedAt: time.Now().Add(time.Duration(i) * time.Hour), Title: title, Tags: []string{"foo"}, Fields: map[string]interface{}{"position": i}}
+		if i == 1 {
+			entry.Published = &published
+		}
+		if _, err := s.Content.Insert(ctx, "posts", entry); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Content.Insert(ctx, "posts", entry); !errors.Is(err, store.ErrDuplicateKey) {
+			t.Fatalf("second insert of the same ID returned %v", err)
+		}
+	}
+
+	result, err := s.Content.Find(ctx, "posts", bson.M{"tags": "foo"}, store.SortBy("created_at", true), store.Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].Title != "Third" || result[1].Title != "Second" {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if n, err := s.Content.Count(ctx, "posts", bson.M{"published": true}); err != nil || n != 1 {
+		t.Errorf("count of published entries is %d, %v", n, err)
+	}
+
+	update := bson.M{"$set": bson.M{"title": "Updated"}, "$currentDate": bson.M{"updated_at": true}}
+	if res, err := s.Content.Update(ctx, "posts", bson.M{"position": bson.M{"$gte": 1}}, update); err != nil || res.MatchedCount != 1 || res.ModifiedCount != 1 {
+		t.Fatalf("update returned %v, %v", res, err)
+	}
+	if entry, err := s.Content.FindOne(ctx, "posts", bson.M{"title": "Updated"}); err != nil || entry.Fields["position"] != int32(1) || entry.UpdatedAt.IsZero() {
+		t.Errorf("updated entry is %v, %v", entry, err)
+	}
+
+	if res, err := s.Content.DeleteMany(ctx, "posts", bson.M{"title": bson.M{"$ne": "Updated"}}); err != nil || res.DeletedCount != 2 {
+		t.Fatalf("delete returned %v, %v", res, err)
+	}
+	if err := s.Content.Drop(ctx, "posts"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Content.FindOne(ctx, "posts", bson.M{}); err != store.ErrNotFound {
+		t.Errorf("find in dropped collection returned %v", err)
+	}
+	// Collections, that never existed, behave like empty ones
+	if err := s.Content.Drop(ctx, "unknown"); err != nil {
+		t.Error(err)
+	}
+	if res, err := s.Content.Update(ctx, "unknown", bson.M{}, update); err != nil || res.MatchedCount != 0 {
+		t.Errorf("update of unknown collection returned %v, %v", res, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	role := &model.Role{ID: primitive.NewObjectID(), Tag: "default", Name: "User"}
+	ct := &model.ContentType{ID: primitive.NewObjectID(), TypeName: "post", Collection: "posts"}
+	user := &model.User{ID: primitive.NewObjectID(), Username: "alice", Roles: []primitive.ObjectID{role.ID}}
+	entry := &model.Content{ID: primitive.NewObjectID(), ContentTypeID: ct.ID, Title: "Hello", Fields: map[string]interface{}{}}
+	migration := &model.Migration{ID: primitive.NewObjectID(), ContentTypeID: ct.ID, Collection: "posts"}
+	audit := &model.AuditEntry{ID: primitive.NewObjectID(), Action: "create", Collection: "posts", TargetID: entry.ID.Hex()}
+	for _, err := range []error{
+		insert(src.Roles.Insert(ctx, role)),
+		insert(src.ContentTypes.Insert(ctx, ct)),
+		insert(src.Users.Insert(ctx, user)),
+		insert(src.Content.Insert(ctx, "posts", entry)),
+		insert(src.Migrations.Insert(ctx, migration)),
+		insert(src.AuditLog.Insert(ctx, audit)),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := open(t)
+	stats, err := store.Copy(ctx, dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"users", "roles", "contenttypes", "posts", "migrations", "audit_log"}
+	if len(stats) != len(want) {
+		t.Fatalf("got stats %v for %v", stats, want)
+	}
+	for i, coll := range want {
+		if stats[i].Collection != coll || stats[i].Documents != 1 {
+			t.Errorf("got stats %v, want 1 document in %s", stats[i], coll)
+		}
+	}
+	if u, err := dst.Users.FindOne(ctx, bson.M{"roles": role.ID}); err != nil || u.Username != "alice" {
+		t.Errorf("copied user is %v, %v", u, err)
+	}
+	if e, err := dst.Content.FindOne(ctx, "posts", bson.M{"_id": entry.ID}); err != nil || e.Title != "Hello" {
+		t.Errorf("copied entry is %v, %v", e, err)
+	}
+	if entries, err := dst.AuditLog.Find(ctx, bson.M{"target_id": entry.ID.Hex()}); err != nil || len(entries) != 1 {
+		t.Errorf("copied audit log is %v, %v", entries, err)
+	}
+
+	if _, err := store.Copy(ctx, dst, src); err == nil {
+		t.Error("expected an error when copying to a storage, that is not empty")
+	}
+}
+
+func insert(_ *store.InsertResult, err error) error {
+	return err
+}
@@ -0,0 +1,145 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/query"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Content entries are stored in one nested bucket per content type
+type contentStore struct {
+	db *bbolt.DB
+}
+
+// Returns the collection. Like in MongoDB collections are created on the first insert.
+func (s *contentStore) collection(coll string) *collection {
+	return newCollection(s.db, contentBucket, []byte(coll))
+}
+
+func (s *contentStore) Find(ctx context.Context, coll string, filter interface{}, opts ...store.FindOption) ([]*model.Content, error) {
+	docs, err := s.collection(coll).find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Content, 0, len(docs))
+	for _, doc := range docs {
+		var con model.Content
+		if err := bson.Unmarshal(doc, &con); err != nil {
+			return nil, err
+		}
+		result = append(result, &con)
+	}
+	return result, nil
+}
+
+func (s *contentStore) FindOne(ctx context.Context, coll string, filter interface{}) (*model.Content, error) {
+	var con model.Content
+	if err := s.collection(coll).findOne(filter, &con); err != nil {
+		return nil, err
+	}
+	return &con, nil
+}
+
+// The matching entries are collected in a read transaction first, so that fn can write to the store
+func (s *contentStore) Stream(ctx context.Context, coll string, filter interface{}, fn func(*model.Content) error) error {
+	docs, err := s.collection(coll).find(filter, nil)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var con model.Content
+		if err := bson.Unmarshal(doc, &con); err != nil {
+			return err
+		}
+		if err := fn(&con); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *contentStore) Count(ctx context.Context, coll string, filter interface{}) (int64, error) {
+	return s.collection(coll).count(filter)
+}
+
+func (s *contentStore) Insert(ctx context.Context, coll string, content *model.Content) (*store.InsertResult, error) {
+	return s.collection(coll).insert(content)
+}
+
+func (s *contentStore) Update(ctx context.Context, coll string, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.collection(coll).update(filter, update, false)
+}
+
+func (s *contentStore) Delete(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return s.collection(coll).delete(filter, false)
+}
+
+func (s *contentStore) DeleteMany(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return s.collection(coll).delete(filter, true)
+}
+
+func (s *contentStore) BulkUpdate(ctx context.Context, coll string, updates []store.EntryUpdate) (*store.UpdateResult, error) {
+	c := s.collection(coll)
+	return query.UpdateEach(updates, func(filter interface{}, update interface{}) (*store.UpdateResult, error) {
+		return c.update(filter, update, false)
+	})
+}
+
+// The entries are dropped with their indexes
+func (s *contentStore) Drop(ctx context.Context, coll string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{contentBucket, indexBucket} {
+			b := tx.Bucket(name)
+			if b == nil {
+				continue
+			}
+			if err := b.DeleteBucket([]byte(coll)); err != nil && err != bbolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Buckets can not be renamed, so the entries and indexes are copied to new buckets in one transaction
+func (s *contentStore) Rename(ctx context.Context, from string, to string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{contentBucket, indexBucket} {
+			parent := tx.Bucket(name)
+			if parent == nil {
+				continue
+			}
+			src := parent.Bucket([]byte(from))
+			if src == nil {
+				continue
+			}
+			if dst := parent.Bucket([]byte(to)); dst != nil {
+				if k, _ := dst.Cursor().First(); k != nil {
+					return fmt.Errorf("collection %s already exists", to)
+				}
+				if err := parent.DeleteBucket([]byte(to)); err != nil {
+					return err
+				}
+			}
+			dst, err := parent.CreateBucket([]byte(to))
+			if err != nil {
+				return err
+			}
+			if err := src.ForEach(dst.Put); err != nil {
+				return err
+			}
+			if err := parent.DeleteBucket([]byte(from)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
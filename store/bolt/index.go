@@ -0,0 +1,92 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Indexes are stored as BSON with their name as key, one nested bucket of the index bucket per collection
+type indexStore struct {
+	db *bbolt.DB
+}
+
+func (s *indexStore) List(ctx context.Context, coll string) ([]model.IndexDefinition, error) {
+	var indexes []model.IndexDefinition
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		indexes, err = listIndexes(tx, coll)
+		return err
+	})
+	return indexes, err
+}
+
+func (s *indexStore) Create(ctx context.Context, coll string, idx model.IndexDefinition) error {
+	doc, err := bson.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		parent, err := tx.CreateBucketIfNotExists(indexBucket)
+		if err != nil {
+			return err
+		}
+		b, err := parent.CreateBucketIfNotExists([]byte(coll))
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(idx.Name)) != nil {
+			return fmt.Errorf("index %s already exists", idx.Name)
+		}
+		// like MongoDB the index is not created, if the entries violate it
+		if entries := nestedBucket(tx, contentBucket, coll); entries != nil {
+			err := entries.ForEach(func(k, v []byte) error {
+				return checkUnique([]model.IndexDefinition{idx}, entries, v)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(idx.Name), doc)
+	})
+}
+
+func (s *indexStore) Drop(ctx context.Context, coll string, name string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := nestedBucket(tx, indexBucket, coll)
+		if b == nil || b.Get([]byte(name)) == nil {
+			return fmt.Errorf("index %s not found", name)
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// Returns the indexes of the collection
+func listIndexes(tx *bbolt.Tx, coll string) ([]model.IndexDefinition, error) {
+	var indexes []model.IndexDefinition
+	b := nestedBucket(tx, indexBucket, coll)
+	if b == nil {
+		return nil, nil
+	}
+	err := b.ForEach(func(k, v []byte) error {
+		var idx model.IndexDefinition
+		if err := bson.Unmarshal(v, &idx); err != nil {
+			return err
+		}
+		indexes = append(indexes, idx)
+		return nil
+	})
+	return indexes, err
+}
+
+// Returns the nested bucket of the collection or `nil`, if it does not exist
+func nestedBucket(tx *bbolt.Tx, parent []byte, coll string) *bbolt.Bucket {
+	b := tx.Bucket(parent)
+	if b == nil {
+		return nil
+	}
+	return b.Bucket([]byte(coll))
+}
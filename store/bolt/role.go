@@ -0,0 +1,49 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type roleStore struct {
+	*collection
+}
+
+func (s *roleStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.Role, error) {
+	docs, err := s.find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*model.Role, 0, len(docs))
+	for _, doc := range docs {
+		var r model.Role
+		if err := bson.Unmarshal(doc, &r); err != nil {
+			return nil, err
+		}
+		roles = append(roles, &r)
+	}
+	return roles, nil
+}
+
+func (s *roleStore) FindOne(ctx context.Context, filter interface{}) (*model.Role, error) {
+	var r model.Role
+	if err := s.findOne(filter, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *roleStore) Insert(ctx context.Context, role *model.Role) (*store.InsertResult, error) {
+	return s.insert(role)
+}
+
+func (s *roleStore) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.update(filter, update, false)
+}
+
+func (s *roleStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(filter, false)
+}
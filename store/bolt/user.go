@@ -0,0 +1,49 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type userStore struct {
+	*collection
+}
+
+func (s *userStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.User, error) {
+	docs, err := s.find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*model.User, 0, len(docs))
+	for _, doc := range docs {
+		var u model.User
+		if err := bson.Unmarshal(doc, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, &u)
+	}
+	return users, nil
+}
+
+func (s *userStore) FindOne(ctx context.Context, filter interface{}) (*model.User, error) {
+	var u model.User
+	if err := s.findOne(filter, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *userStore) Insert(ctx context.Context, user *model.User) (*store.InsertResult, error) {
+	return s.insert(user)
+}
+
+func (s *userStore) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.update(filter, update, false)
+}
+
+func (s *userStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(filter, false)
+}
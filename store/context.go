@@ -0,0 +1,23 @@
+package store
+
+import "context"
+
+// Key of the context value, that allows reads from replicas
+type replicaReadsKey struct{}
+
+// Returns a context, whose reads may be served by replicas, which may lag behind the primary
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadsKey{}, true)
+}
+
+// Returns true, if the reads of the context may be served by replicas.
+// Backends without replicas ignore it.
+func ReplicaReadsAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(replicaReadsKey{}).(bool)
+	return allowed
+}
+
+// Returns a context, whose reads are served by the primary again
+func WithoutReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadsKey{}, false)
+}
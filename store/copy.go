@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Number of documents copied to a collection
+type CopyStats struct {
+	Collection string
+	Documents  int
+}
+
+// Copies the users, roles, content types, all content entries, the migration jobs and the audit log from src to dst.
+// Entries and content types in the trash are copied as well. dst has to be empty, so that no documents are mixed up.
+func Copy(ctx context.Context, dst *Store, src *Store) ([]CopyStats, error) {
+	if err := checkEmpty(ctx, dst); err != nil {
+		return nil, err
+	}
+
+	var stats []CopyStats
+	users, err := src.Users.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if _, err := dst.Users.Insert(ctx, u); err != nil {
+			return stats, err
+		}
+	}
+	stats = append(stats, CopyStats{Collection: "users", Documents: len(users)})
+
+	roles, err := src.Roles.Find(ctx, bson.M{})
+	if err != nil {
+		return stats, err
+	}
+	for _, r := range roles {
+		if _, err := dst.Roles.Insert(ctx, r); err != nil {
+			return stats, err
+		}
+	}
+	stats = append(stats, CopyStats{Collection: "roles", Documents: len(roles)})
+
+	contentTypes, err := src.ContentTypes.Find(ctx, bson.M{})
+	if err != nil {
+		return stats, err
+	}
+	for _, ct := range contentTypes {
+		if _, err := dst.ContentTypes.Insert(ctx, ct); err != nil {
+			return stats, err
+		}
+	}
+	stats = append(stats, CopyStats{Collection: "contenttypes", Documents: len(contentTypes)})
+
+	for _, ct := range contentTypes {
+		n := 0
+		err := src.Content.Stream(ctx, ct.Collection, bson.M{}, func(entry *model.Content) error {
+			if _, err := dst.Content.Insert(ctx, ct.Collection, entry); err != nil {
+				return err
+			}
+			n++
+			return nil
+		})
+		stats = append(stats, CopyStats{Collection: ct.Collection, Documents: n})
+		if err != nil {
+			return stats, fmt.Errorf("content of %s: %s", ct.Collection, err.Error())
+		}
+	}
+
+	migrations, err := src.Migrations.Find(ctx, bson.M{})
+	if err != nil {
+		return stats, err
+	}
+	for _, m := range migrations {
+		if _, err := dst.Migrations.Insert(ctx, m); err != nil {
+			return stats, err
+		}
+	}
+	stats = append(stats, CopyStats{Collection: CollectionMigrations, Documents: len(migrations)})
+
+	n := 0
+	err = src.AuditLog.Stream(ctx, bson.M{}, func(entry *model.AuditEntry) error {
+		if _, err := dst.AuditLog.Insert(ctx, entry); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	stats = append(stats, CopyStats{Collection: CollectionAuditLog, Documents: n})
+	if err != nil {
+		return stats, fmt.Errorf("audit log: %s", err.Error())
+	}
+	return stats, dst.AuditLog.Init(ctx)
+}
+
+// Returns an error, if the storage contains users, roles or content types
+func checkEmpty(ctx context.Context, s *Store) error {
+	users, err := s.Users.Find(ctx, bson.M{}, Limit(1))
+	if err != nil {
+		return err
+	}
+	roles, err := s.Roles.Find(ctx, bson.M{}, Limit(1))
+	if err != nil {
+		return err
+	}
+	contentTypes, err := s.ContentTypes.Find(ctx, bson.M{}, Limit(1))
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 || len(roles) > 0 || len(contentTypes) > 0 {
+		return fmt.Errorf("target storage is not empty")
+	}
+	return nil
+}
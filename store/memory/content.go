@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Content entries are stored in one collection per content type
+type contentStore struct {
+	mu          sync.Mutex
+	collections map[string]*collection
+}
+
+// Returns the collection. Like in MongoDB collections are created on first use.
+// The name is copied, because fiber returns route parameters that point into a reused buffer.
+func (s *contentStore) collection(coll string) *collection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.collections[coll]
+	if !ok {
+		c = newCollection()
+		s.collections[string(append([]byte(nil), coll...))] = c
+	}
+	return c
+}
+
+func (s *contentStore) Find(ctx context.Context, coll string, filter interface{}, opts ...store.FindOption) ([]*model.Content, error) {
+	docs, err := s.collection(coll).find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Content, 0, len(docs))
+	for _, doc := range docs {
+		var con model.Content
+		if err := bson.Unmarshal(doc, &con); err != nil {
+			return nil, err
+		}
+		result = append(result, &con)
+	}
+	return result, nil
+}
+
+func (s *contentStore) FindOne(ctx context.Context, coll string, filter interface{}) (*model.Content, error) {
+	var con model.Content
+	if err := s.collection(coll).findOne(filter, &con); err != nil {
+		return nil, err
+	}
+	return &con, nil
+}
+
+// The matching entries are collected first, so that fn can write to the store
+func (s *contentStore) Stream(ctx context.Context, coll string, filter interface{}, fn func(*model.Content) error) error {
+	docs, err := s.collection(coll).find(filter, nil)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var con model.Content
+		if err := bson.Unmarshal(doc, &con); err != nil {
+			return err
+		}
+		if err := fn(&con); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *contentStore) Count(ctx context.Context, coll string, filter interface{}) (int64, error) {
+	return s.collection(coll).count(filter)
+}
+
+func (s *contentStore) Insert(ctx context.Context, coll string, content *model.Content) (*store.InsertResult, error) {
+	return s.collection(coll).insert(content)
+}
+
+func (s *contentStore) Update(ctx context.Context, coll string, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.collection(coll).update(filter, update, false)
+}
+
+func (s *contentStore) Delete(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return s.collection(coll).delete(filter, false)
+}
+
+func (s *contentStore) DeleteMany(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return s.collection(coll).delete(filter, true)
+}
+
+func (s *contentStore) BulkUpdate(ctx context.Context, coll string, updates []store.EntryUpdate) (*store.UpdateResult, error) {
+	c := s.collection(coll)
+	return query.UpdateEach(updates, func(filter interface{}, update interface{}) (*store.UpdateResult, error) {
+		return c.update(filter, update, false)
+	})
+}
+
+func (s *contentStore) Drop(ctx context.Context, coll string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collections, coll)
+	return nil
+}
+
+// The collection is moved with its indexes under the lock of the store
+func (s *contentStore) Rename(ctx context.Context, from string, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.collections[from]
+	if !ok {
+		return nil
+	}
+	if target, ok := s.collections[to]; ok {
+		target.mu.RLock()
+		empty := len(target.docs) == 0 && len(target.indexes) == 0
+		target.mu.RUnlock()
+		if !empty {
+			return fmt.Errorf("collection %s already exists", to)
+		}
+	}
+	s.collections[string(append([]byte(nil), to...))] = c
+	delete(s.collections, from)
+	return nil
+}
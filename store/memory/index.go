@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/model"
+)
+
+// Indexes are kept with the documents of their collection
+type indexStore struct {
+	content *contentStore
+}
+
+func (s *indexStore) List(ctx context.Context, coll string) ([]model.IndexDefinition, error) {
+	c := s.content.collection(coll)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]model.IndexDefinition(nil), c.indexes...), nil
+}
+
+func (s *indexStore) Create(ctx context.Context, coll string, idx model.IndexDefinition) error {
+	c := s.content.collection(coll)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, i := range c.indexes {
+		if i.Name == idx.Name {
+			return fmt.Errorf("index %s already exists", idx.Name)
+		}
+	}
+	// like MongoDB the index is not created, if the documents violate it
+	for _, doc := range c.docs {
+		if err := c.checkUnique([]model.IndexDefinition{idx}, doc); err != nil {
+			return err
+		}
+	}
+	c.indexes = append(c.indexes, idx)
+	return nil
+}
+
+func (s *indexStore) Drop(ctx context.Context, coll string, name string) error {
+	c := s.content.collection(coll)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, idx := range c.indexes {
+		if idx.Name == name {
+			c.indexes = append(c.indexes[:i:i], c.indexes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("index %s not found", name)
+}
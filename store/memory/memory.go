@@ -0,0 +1,226 @@
+// Package memory implements the storage interfaces in memory.
+// Documents are kept as BSON, so they are encoded and decoded exactly like with MongoDB.
+// Data is lost when the process exits; the backend is meant for development and tests.
+package memory
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Returns an empty storage backend
+func New() *store.Store {
+	content := &contentStore{collections: make(map[string]*collection)}
+	return &store.Store{
+		Users:        &userStore{newCollection()},
+		Roles:        &roleStore{newCollection()},
+		ContentTypes: &contentTypeStore{newCollection()},
+		Content:      content,
+		Indexes:      &indexStore{content},
+		Migrations:   &migrationStore{&collection{indexes: []model.IndexDefinition{store.MigrationActiveIndex}}},
+		AuditLog:     &auditStore{newCollection()},
+		Transactions: store.NoTransactions{},
+	}
+}
+
+// Documents of a collection in insertion order
+type collection struct {
+	mu   sync.RWMutex
+	docs []bson.Raw
+	// Unique indexes of the migration jobs and indexes of content collections.
+	// The latter are kept with the documents, so that they are dropped and renamed with them.
+	indexes []model.IndexDefinition
+}
+
+func newCollection() *collection {
+	return &collection{}
+}
+
+// Returns all documents, that match the filter, sorted and paginated by the options
+func (c *collection) find(filter interface{}, opts []store.FindOption) ([]bson.Raw, error) {
+	f, err := query.Raw(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	var result []bson.Raw
+	for _, doc := range c.docs {
+		ok, err := query.Match(doc, f)
+		if err != nil {
+			c.mu.RUnlock()
+			return nil, err
+		}
+		if ok {
+			result = append(result, doc)
+		}
+	}
+	c.mu.RUnlock()
+
+	return query.Select(result, store.NewFindOptions(opts...)), nil
+}
+
+// Decodes the first document, that matches the filter, into v
+func (c *collection) findOne(filter interface{}, v interface{}) error {
+	docs, err := c.find(filter, []store.FindOption{store.Limit(1)})
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return store.ErrNotFound
+	}
+	return bson.Unmarshal(docs[0], v)
+}
+
+func (c *collection) count(filter interface{}) (int64, error) {
+	docs, err := c.find(filter, nil)
+	return int64(len(docs)), err
+}
+
+// Inserts the document. Like MongoDB an ObjectID is generated, if the document has no `_id`.
+func (c *collection) insert(v interface{}) (*store.InsertResult, error) {
+	doc, id, err := query.WithID(v)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.docs {
+		if query.Compare(d.Lookup("_id"), id) == 0 {
+			return nil, store.DuplicateKeyError("_id " + id.String())
+		}
+	}
+	if err := c.checkUnique(c.indexes, doc); err != nil {
+		return nil, err
+	}
+	c.docs = append(c.docs, doc)
+	return &store.InsertResult{InsertedID: query.Value(id)}, nil
+}
+
+// Replaces the document with the same `_id`
+func (c *collection) replace(v interface{}) error {
+	doc, id, err := query.WithID(v)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, d := range c.docs {
+		if query.Compare(d.Lookup("_id"), id) == 0 {
+			if err := c.checkUnique(c.indexes, doc); err != nil {
+				return err
+			}
+			c.docs[i] = doc
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+// Applies the update to the first or all documents, that match the filter
+func (c *collection) update(filter interface{}, update interface{}, many bool) (*store.UpdateResult, error) {
+	f, err := query.Raw(filter)
+	if err != nil {
+		return nil, err
+	}
+	u, err := query.Raw(update)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := &store.UpdateResult{}
+	// the updates are applied together, so that they can be reverted, if they violate a unique index
+	updated := make(map[int]bson.Raw)
+	for i, doc := range c.docs {
+		ok, err := query.Match(doc, f)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		changed, err := query.Update(doc, u)
+		if err != nil {
+			return nil, err
+		}
+		result.MatchedCount++
+		if !bytes.Equal(doc, changed) {
+			updated[i] = changed
+			result.ModifiedCount++
+		}
+		if !many {
+			break
+		}
+	}
+
+	previous := make(map[int]bson.Raw)
+	for i, doc := range updated {
+		previous[i], c.docs[i] = c.docs[i], doc
+	}
+	for _, doc := range updated {
+		if err := c.checkUnique(c.indexes, doc); err != nil {
+			for i, doc := range previous {
+				c.docs[i] = doc
+			}
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Deletes the first or all documents, that match the filter
+func (c *collection) delete(filter interface{}, many bool) (*store.DeleteResult, error) {
+	f, err := query.Raw(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := &store.DeleteResult{}
+	kept := c.docs[:0]
+	for _, doc := range c.docs {
+		if many || result.DeletedCount == 0 {
+			ok, err := query.Match(doc, f)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result.DeletedCount++
+				continue
+			}
+		}
+		kept = append(kept, doc)
+	}
+	// clear the tail, so that deleted documents can be collected
+	for i := len(kept); i < len(c.docs); i++ {
+		c.docs[i] = nil
+	}
+	c.docs = kept
+	return result, nil
+}
+
+// Returns a duplicate key error, if the document violates one of the unique indexes. The lock has to be held.
+func (c *collection) checkUnique(defs []model.IndexDefinition, doc bson.Raw) error {
+	indexes, err := query.UniqueIndexes(defs)
+	if err != nil || len(indexes) == 0 {
+		return err
+	}
+	return query.CheckUnique(indexes, doc, func(fn func(bson.Raw) error) error {
+		for _, d := range c.docs {
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type migrationStore struct {
+	*collection
+}
+
+func (s *migrationStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.Migration, error) {
+	docs, err := s.find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]*model.Migration, 0, len(docs))
+	for _, doc := range docs {
+		var m model.Migration
+		if err := bson.Unmarshal(doc, &m); err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, &m)
+	}
+	return migrations, nil
+}
+
+func (s *migrationStore) FindOne(ctx context.Context, filter interface{}) (*model.Migration, error) {
+	var m model.Migration
+	if err := s.findOne(filter, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *migrationStore) Insert(ctx context.Context, m *model.Migration) (*store.InsertResult, error) {
+	return s.insert(m)
+}
+
+func (s *migrationStore) Replace(ctx context.Context, m *model.Migration) error {
+	return s.replace(m)
+}
+
+// The unique index is created by `New`
+func (s *migrationStore) Init(ctx context.Context) error {
+	return nil
+}
+
+func (s *migrationStore) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.update(filter, update, true)
+}
+
+func (s *migrationStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(filter, false)
+}
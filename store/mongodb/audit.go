@@ -0,0 +1,55 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type auditStore struct {
+	collection
+}
+
+func (s *auditStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.AuditEntry, error) {
+	entries := make([]*model.AuditEntry, 0)
+	err := s.find(ctx, filter, opts, func(cursor *mongo.Cursor) error {
+		var e model.AuditEntry
+		if err := cursor.Decode(&e); err != nil {
+			return err
+		}
+		entries = append(entries, &e)
+		return nil
+	})
+	return entries, err
+}
+
+func (s *auditStore) Stream(ctx context.Context, filter interface{}, fn func(*model.AuditEntry) error) error {
+	return s.stream(ctx, filter, []store.FindOption{store.SortBy("time", false)}, func(cursor *mongo.Cursor) error {
+		var e model.AuditEntry
+		if err := cursor.Decode(&e); err != nil {
+			return err
+		}
+		return fn(&e)
+	})
+}
+
+func (s *auditStore) Count(ctx context.Context, filter interface{}) (int64, error) {
+	return s.count(ctx, filter)
+}
+
+func (s *auditStore) Insert(ctx context.Context, entry *model.AuditEntry) (*store.InsertResult, error) {
+	return s.insert(ctx, entry)
+}
+
+// Creates the indexes used by the filters of the audit log
+func (s *auditStore) Init(ctx context.Context) error {
+	_, err := s.c.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "actor.id", Value: 1}, {Key: "time", Value: -1}}},
+		{Keys: bson.D{{Key: "collection", Value: 1}, {Key: "target_id", Value: 1}, {Key: "time", Value: -1}}},
+	})
+	return convertError(err)
+}
@@ -0,0 +1,128 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Error codes of `renameCollection`, that are not caused by the deployment
+const (
+	errCodeNamespaceNotFound = 26
+	errCodeNamespaceExists   = 48
+)
+
+// Timeout of `renameCollection`, which has to wait for a lock of the collection
+const renameTimeout = 30 * time.Second
+
+// Content entries are stored in one collection per content type
+type contentStore struct {
+	*backend
+}
+
+func (s *contentStore) Find(ctx context.Context, coll string, filter interface{}, opts ...store.FindOption) ([]*model.Content, error) {
+	result := make([]*model.Content, 0)
+	err := s.collection(coll).find(ctx, filter, opts, func(cursor *mongo.Cursor) error {
+		var con model.Content
+		if err := cursor.Decode(&con); err != nil {
+			return err
+		}
+		result = append(result, &con)
+		return nil
+	})
+	return result, err
+}
+
+func (s *contentStore) FindOne(ctx context.Context, coll string, filter interface{}) (*model.Content, error) {
+	var con *model.Content
+	if err := s.collection(coll).findOne(ctx, filter, &con); err != nil {
+		return nil, err
+	}
+	return con, nil
+}
+
+// Streams are not limited by the operation timeout, because exports of large collections can take a while
+func (s *contentStore) Stream(ctx context.Context, coll string, filter interface{}, fn func(*model.Content) error) error {
+	return s.collection(coll).stream(ctx, filter, nil, func(cursor *mongo.Cursor) error {
+		var con model.Content
+		if err := cursor.Decode(&con); err != nil {
+			return err
+		}
+		return fn(&con)
+	})
+}
+
+func (s *contentStore) Count(ctx context.Context, coll string, filter interface{}) (int64, error) {
+	return s.collection(coll).count(ctx, filter)
+}
+
+func (s *contentStore) Insert(ctx context.Context, coll string, content *model.Content) (*store.InsertResult, error) {
+	return s.collection(coll).insert(ctx, content)
+}
+
+func (s *contentStore) Update(ctx context.Context, coll string, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.collection(coll).update(ctx, filter, update)
+}
+
+func (s *contentStore) Delete(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return s.collection(coll).delete(ctx, filter)
+}
+
+// Deleting many entries is not limited by the operation timeout
+func (s *contentStore) DeleteMany(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return deleteResult(s.db.Collection(coll).DeleteMany(ctx, nonNil(filter)))
+}
+
+// The updates are sent in one unordered bulk write, that is limited by the operation timeout
+func (s *contentStore) BulkUpdate(ctx context.Context, coll string, updates []store.EntryUpdate) (*store.UpdateResult, error) {
+	if len(updates) == 0 {
+		return new(store.UpdateResult), nil
+	}
+	models := make([]mongo.WriteModel, len(updates))
+	for i, u := range updates {
+		models[i] = mongo.NewUpdateOneModel().SetFilter(nonNil(u.Filter)).SetUpdate(u.Update)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.opts.OperationTimeout)
+	defer cancel()
+	res, err := s.db.Collection(coll).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &store.UpdateResult{MatchedCount: res.MatchedCount, ModifiedCount: res.ModifiedCount}, nil
+}
+
+func (s *contentStore) Drop(ctx context.Context, coll string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.OperationTimeout)
+	defer cancel()
+	return s.db.Collection(coll).Drop(ctx)
+}
+
+// Renames the collection with `renameCollection`. Errors of the command, e.g. on sharded clusters or because of missing privileges,
+// return `store.ErrNotSupported`, so that the entries can be copied instead.
+func (s *contentStore) Rename(ctx context.Context, from string, to string) error {
+	ctx, cancel := context.WithTimeout(ctx, renameTimeout)
+	defer cancel()
+	db := s.db.Name()
+	cmd := bson.D{
+		{Key: "renameCollection", Value: db + "." + from},
+		{Key: "to", Value: db + "." + to},
+	}
+	err := s.db.Client().Database("admin").RunCommand(ctx, cmd).Err()
+	cmdErr, ok := err.(mongo.CommandError)
+	switch {
+	case ok && cmdErr.Code == errCodeNamespaceNotFound:
+		// the collection has no entries yet
+		return nil
+	case ok && cmdErr.Code == errCodeNamespaceExists:
+		return fmt.Errorf("collection %s already exists", to)
+	case ok:
+		return fmt.Errorf("%w: %s", store.ErrNotSupported, err.Error())
+	}
+	return err
+}
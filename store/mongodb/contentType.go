@@ -0,0 +1,46 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type contentTypeStore struct {
+	collection
+}
+
+func (s *contentTypeStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.ContentType, error) {
+	result := make([]*model.ContentType, 0)
+	err := s.find(ctx, filter, opts, func(cursor *mongo.Cursor) error {
+		var ct model.ContentType
+		if err := cursor.Decode(&ct); err != nil {
+			return err
+		}
+		result = append(result, &ct)
+		return nil
+	})
+	return result, err
+}
+
+func (s *contentTypeStore) FindOne(ctx context.Context, filter interface{}) (*model.ContentType, error) {
+	var ct *model.ContentType
+	if err := s.findOne(ctx, filter, &ct); err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+func (s *contentTypeStore) Insert(ctx context.Context, ct *model.ContentType) (*store.InsertResult, error) {
+	return s.insert(ctx, ct)
+}
+
+func (s *contentTypeStore) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.update(ctx, filter, update)
+}
+
+func (s *contentTypeStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(ctx, filter)
+}
@@ -0,0 +1,137 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Indexes of the content collections are indexes of MongoDB
+type indexStore struct {
+	*backend
+}
+
+// Index as it is returned by `listIndexes`
+type listedIndex struct {
+	Name                    string `bson:"name"`
+	Key                     bson.D `bson:"key"`
+	Unique                  bool   `bson:"unique,omitempty"`
+	Sparse                  bool   `bson:"sparse,omitempty"`
+	ExpireAfterSeconds      *int32 `bson:"expireAfterSeconds,omitempty"`
+	PartialFilterExpression bson.M `bson:"partialFilterExpression,omitempty"`
+	Weights                 bson.M `bson:"weights,omitempty"`
+}
+
+func (s *indexStore) List(ctx context.Context, coll string) ([]model.IndexDefinition, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.OperationTimeout)
+	defer cancel()
+
+	// Missing collections have no indexes, the driver returns an empty cursor for them
+	cursor, err := s.db.Collection(coll).Indexes().List(ctx)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	var list []listedIndex
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, convertError(err)
+	}
+	var indexes []model.IndexDefinition
+	for _, idx := range list {
+		if idx.Name != "_id_" {
+			indexes = append(indexes, idx.definition())
+		}
+	}
+	return indexes, nil
+}
+
+func (s *indexStore) Create(ctx context.Context, coll string, idx model.IndexDefinition) error {
+	_, err := s.db.Collection(coll).Indexes().CreateOne(ctx, indexModel(idx))
+	return convertError(err)
+}
+
+func (s *indexStore) Drop(ctx context.Context, coll string, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.OperationTimeout)
+	defer cancel()
+	_, err := s.db.Collection(coll).Indexes().DropOne(ctx, name)
+	return convertError(err)
+}
+
+// Returns the definition of a listed index. The fields of a text index are sorted by name.
+func (idx listedIndex) definition() model.IndexDefinition {
+	d := model.IndexDefinition{
+		Name:               idx.Name,
+		Unique:             idx.Unique,
+		Sparse:             idx.Sparse,
+		ExpireAfterSeconds: idx.ExpireAfterSeconds,
+	}
+	if len(idx.PartialFilterExpression) > 0 {
+		d.PartialFilter = map[string]interface{}(idx.PartialFilterExpression)
+	}
+	for _, k := range idx.Key {
+		switch k.Key {
+		case "_fts":
+			// text keys are combined into one `_fts` key with weights per field
+			for _, f := range utils.SortedKeys(idx.Weights) {
+				d.Keys = append(d.Keys, model.IndexKey{Field: f, Type: model.IndexText})
+			}
+		case "_ftsx":
+		default:
+			d.Keys = append(d.Keys, model.IndexKey{Field: k.Key, Type: keyType(k.Value)})
+		}
+	}
+	return d
+}
+
+// Returns the key type of a listed index key. Special types like "2dsphere" or "hashed" are returned as they are.
+func keyType(v interface{}) string {
+	switch n := v.(type) {
+	case string:
+		return n
+	case int32:
+		if n < 0 {
+			return model.IndexDesc
+		}
+	case int64:
+		if n < 0 {
+			return model.IndexDesc
+		}
+	case float64:
+		if n < 0 {
+			return model.IndexDesc
+		}
+	}
+	return model.IndexAsc
+}
+
+// Returns the model to create the index
+func indexModel(idx model.IndexDefinition) mongo.IndexModel {
+	keys := bson.D{}
+	for _, k := range idx.Keys {
+		switch k.Type {
+		case model.IndexDesc:
+			keys = append(keys, bson.E{Key: k.Field, Value: int32(-1)})
+		case model.IndexText:
+			keys = append(keys, bson.E{Key: k.Field, Value: "text"})
+		default:
+			keys = append(keys, bson.E{Key: k.Field, Value: int32(1)})
+		}
+	}
+	opts := options.Index().SetName(idx.Name)
+	if idx.Unique {
+		opts.SetUnique(true)
+	}
+	if idx.Sparse {
+		opts.SetSparse(true)
+	}
+	if idx.ExpireAfterSeconds != nil {
+		opts.SetExpireAfterSeconds(*idx.ExpireAfterSeconds)
+	}
+	if idx.PartialFilter != nil {
+		opts.SetPartialFilterExpression(idx.PartialFilter)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}
+}
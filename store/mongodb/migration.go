@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type migrationStore struct {
+	collection
+}
+
+func (s *migrationStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.Migration, error) {
+	migrations := make([]*model.Migration, 0)
+	err := s.find(ctx, filter, opts, func(cursor *mongo.Cursor) error {
+		var m model.Migration
+		if err := cursor.Decode(&m); err != nil {
+			return err
+		}
+		migrations = append(migrations, &m)
+		return nil
+	})
+	return migrations, err
+}
+
+func (s *migrationStore) FindOne(ctx context.Context, filter interface{}) (*model.Migration, error) {
+	var m *model.Migration
+	if err := s.findOne(ctx, filter, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *migrationStore) Insert(ctx context.Context, m *model.Migration) (*store.InsertResult, error) {
+	return s.insert(ctx, m)
+}
+
+func (s *migrationStore) Replace(ctx context.Context, m *model.Migration) error {
+	return s.replace(ctx, m.ID, m)
+}
+
+func (s *migrationStore) UpdateMany(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.updateMany(ctx, filter, update)
+}
+
+func (s *migrationStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(ctx, filter)
+}
+
+// Creates the unique index, that allows only one active job per content type
+func (s *migrationStore) Init(ctx context.Context) error {
+	_, err := s.c.Indexes().CreateOne(ctx, indexModel(store.MigrationActiveIndex))
+	return convertError(err)
+}
@@ -0,0 +1,231 @@
+// Package mongodb implements the storage interfaces with MongoDB
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Timeout of a single database operation, if Options do not set one
+const defaultOpTimeout = 5 * time.Second
+
+// Options of the storage backend
+type Options struct {
+	// Timeout of a single database operation, unless the context ends earlier
+	OperationTimeout time.Duration
+	// Read preference of the contexts, that allow replica reads. The primary is used, if it is nil.
+	ReadPreference *readpref.ReadPref
+}
+
+// Returns the storage backend on the database
+func New(db *mongo.Database, opts Options) *store.Store {
+	if opts.OperationTimeout <= 0 {
+		opts.OperationTimeout = defaultOpTimeout
+	}
+	b := &backend{db: db, opts: opts}
+	return &store.Store{
+		Users:        &userStore{b.collection(store.CollectionUsers)},
+		Roles:        &roleStore{b.collection(store.CollectionRoles)},
+		ContentTypes: &contentTypeStore{b.collection(store.CollectionContentTypes)},
+		Content:      &contentStore{b},
+		Indexes:      &indexStore{b},
+		Migrations:   &migrationStore{b.collection(store.CollectionMigrations)},
+		AuditLog:     &auditStore{b.collection(store.CollectionAuditLog)},
+		Transactions: &transactor{db: db},
+	}
+}
+
+type backend struct {
+	db   *mongo.Database
+	opts Options
+}
+
+func (b *backend) collection(name string) collection {
+	c := collection{c: b.db.Collection(name), timeout: b.opts.OperationTimeout}
+	if b.opts.ReadPreference != nil {
+		c.replica = b.db.Collection(name, options.Collection().SetReadPreference(b.opts.ReadPreference))
+	}
+	return c
+}
+
+// Operations shared by all stores
+type collection struct {
+	c *mongo.Collection
+	// Same collection with the read preference for replica reads, nil if there is none
+	replica *mongo.Collection
+	timeout time.Duration
+}
+
+// Returns the collection to read from in the context
+func (c collection) reader(ctx context.Context) *mongo.Collection {
+	if c.replica != nil && store.ReplicaReadsAllowed(ctx) {
+		return c.replica
+	}
+	return c.c
+}
+
+// Calls decode for every document, that matches the filter
+func (c collection) find(ctx context.Context, filter interface{}, opts []store.FindOption, decode func(*mongo.Cursor) error) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	cursor, err := c.reader(ctx).Find(ctx, nonNil(filter), findOptions(opts))
+	if err != nil {
+		return convertError(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := decode(cursor); err != nil {
+			return err
+		}
+	}
+	return convertError(cursor.Err())
+}
+
+// Calls decode for every document, that matches the filter, in the order of the options.
+// Streams are not limited by the operation timeout, because they can take a while.
+func (c collection) stream(ctx context.Context, filter interface{}, opts []store.FindOption, decode func(*mongo.Cursor) error) error {
+	cursor, err := c.reader(ctx).Find(ctx, nonNil(filter), findOptions(opts))
+	if err != nil {
+		return convertError(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := decode(cursor); err != nil {
+			return err
+		}
+	}
+	return convertError(cursor.Err())
+}
+
+func (c collection) findOne(ctx context.Context, filter interface{}, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return convertError(c.reader(ctx).FindOne(ctx, nonNil(filter)).Decode(v))
+}
+
+func (c collection) count(ctx context.Context, filter interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	n, err := c.reader(ctx).CountDocuments(ctx, nonNil(filter))
+	return n, convertError(err)
+}
+
+func (c collection) insert(ctx context.Context, doc interface{}) (*store.InsertResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.c.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &store.InsertResult{InsertedID: res.InsertedID}, nil
+}
+
+// Replaces the document with the same `_id`
+func (c collection) replace(ctx context.Context, id interface{}, doc interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	res, err := c.c.ReplaceOne(ctx, bson.M{"_id": id}, doc)
+	if err != nil {
+		return convertError(err)
+	}
+	if res.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (c collection) update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return updateResult(c.c.UpdateOne(ctx, nonNil(filter), update))
+}
+
+func (c collection) updateMany(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return updateResult(c.c.UpdateMany(ctx, nonNil(filter), update))
+}
+
+func (c collection) delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return deleteResult(c.c.DeleteOne(ctx, nonNil(filter)))
+}
+
+func updateResult(res *mongo.UpdateResult, err error) (*store.UpdateResult, error) {
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &store.UpdateResult{
+		MatchedCount:  res.MatchedCount,
+		ModifiedCount: res.ModifiedCount,
+		UpsertedCount: res.UpsertedCount,
+		UpsertedID:    res.UpsertedID,
+	}, nil
+}
+
+func deleteResult(res *mongo.DeleteResult, err error) (*store.DeleteResult, error) {
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &store.DeleteResult{DeletedCount: res.DeletedCount}, nil
+}
+
+// Returns the errors of the store for missing documents, duplicate keys, timeouts and network errors.
+// Other errors of the driver are returned as they are.
+func convertError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return store.ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return store.DuplicateKeyError(err.Error())
+	case mongo.IsTimeout(err):
+		return store.TimeoutError(err)
+	case mongo.IsNetworkError(err), errors.Is(err, mongo.ErrClientDisconnected):
+		return store.UnavailableError(err)
+	}
+	return err
+}
+
+// The driver rejects `nil` filters, an empty filter matches all documents
+func nonNil(filter interface{}) interface{} {
+	if filter == nil {
+		return bson.M{}
+	}
+	return filter
+}
+
+func findOptions(opts []store.FindOption) *options.FindOptions {
+	o := store.NewFindOptions(opts...)
+	fo := options.Find()
+	if len(o.Sort) > 0 {
+		sort := bson.D{}
+		for _, s := range o.Sort {
+			dir := 1
+			if s.Descending {
+				dir = -1
+			}
+			sort = append(sort, bson.E{Key: s.Field, Value: dir})
+		}
+		fo.SetSort(sort)
+	}
+	if o.Skip > 0 {
+		fo.SetSkip(o.Skip)
+	}
+	if o.Limit > 0 {
+		fo.SetLimit(o.Limit)
+	}
+	return fo
+}
@@ -0,0 +1,46 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type roleStore struct {
+	collection
+}
+
+func (s *roleStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.Role, error) {
+	roles := make([]*model.Role, 0)
+	err := s.find(ctx, filter, opts, func(cursor *mongo.Cursor) error {
+		var r model.Role
+		if err := cursor.Decode(&r); err != nil {
+			return err
+		}
+		roles = append(roles, &r)
+		return nil
+	})
+	return roles, err
+}
+
+func (s *roleStore) FindOne(ctx context.Context, filter interface{}) (*model.Role, error) {
+	var r *model.Role
+	if err := s.findOne(ctx, filter, &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *roleStore) Insert(ctx context.Context, role *model.Role) (*store.InsertResult, error) {
+	return s.insert(ctx, role)
+}
+
+func (s *roleStore) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.update(ctx, filter, update)
+}
+
+func (s *roleStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(ctx, filter)
+}
@@ -0,0 +1,62 @@
+package mongodb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Code of MongoDB for unknown commands, returned for `hello` by servers before 4.4.2
+const errCodeCommandNotFound = 59
+
+// Runs transactions on replica sets and sharded clusters
+type transactor struct {
+	db *mongo.Database
+
+	mu        sync.Mutex
+	detected  bool
+	supported bool
+}
+
+// Support is detected with `hello` on the first call and cached.
+// If the server can not be reached, it is detected again on the next call.
+func (t *transactor) SupportsTransactions(ctx context.Context) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.detected {
+		return t.supported
+	}
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := t.db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Code == errCodeCommandNotFound {
+		err = t.db.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+	}
+	if err != nil {
+		return false
+	}
+	t.detected = true
+	t.supported = hello.SetName != "" || hello.Msg == "isdbgrid"
+	return t.supported
+}
+
+func (t *transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !t.SupportsTransactions(ctx) {
+		return store.ErrNotSupported
+	}
+	session, err := t.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return convertError(err)
+}
@@ -0,0 +1,46 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type userStore struct {
+	collection
+}
+
+func (s *userStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.User, error) {
+	users := make([]*model.User, 0)
+	err := s.find(ctx, filter, opts, func(cursor *mongo.Cursor) error {
+		var u model.User
+		if err := cursor.Decode(&u); err != nil {
+			return err
+		}
+		users = append(users, &u)
+		return nil
+	})
+	return users, err
+}
+
+func (s *userStore) FindOne(ctx context.Context, filter interface{}) (*model.User, error) {
+	var u *model.User
+	if err := s.findOne(ctx, filter, &u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *userStore) Insert(ctx context.Context, user *model.User) (*store.InsertResult, error) {
+	return s.insert(ctx, user)
+}
+
+func (s *userStore) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.update(ctx, filter, update)
+}
+
+func (s *userStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(ctx, filter)
+}
@@ -0,0 +1,49 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Code of MongoDB for change streams on servers, that are not part of a replica set
+const errCodeChangeStreamNotSupported = 40573
+
+// Returns the watch of the changes of roles and content types with a change stream on the database.
+// Change streams need a replica set or sharded cluster; standalone servers return `store.ErrNotSupported`.
+func Watch(db *mongo.Database) store.WatchFunc {
+	return func(ctx context.Context, changed func(collection string)) error {
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+			"ns.coll": bson.M{"$in": []string{store.CollectionRoles, store.CollectionContentTypes}},
+		}}}}
+		cs, err := db.Watch(ctx, pipeline)
+		if err != nil {
+			if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Code == errCodeChangeStreamNotSupported {
+				return store.ErrNotSupported
+			}
+			return err
+		}
+		defer cs.Close(context.Background())
+		// Changes before the stream was opened are not seen
+		changed("")
+		for cs.Next(ctx) {
+			var event struct {
+				NS struct {
+					Coll string `bson:"coll"`
+				} `bson:"ns"`
+			}
+			if err := cs.Decode(&event); err != nil {
+				return err
+			}
+			changed(event.NS.Coll)
+		}
+		if err := cs.Err(); err != nil || ctx.Err() != nil {
+			return err
+		}
+		// The stream is invalidated, e.g. when the database was dropped
+		return errors.New("change stream closed")
+	}
+}
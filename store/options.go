@@ -0,0 +1,47 @@
+package store
+
+// Options of queries, that return multiple documents
+type FindOptions struct {
+	Sort  []SortField
+	Skip  int64
+	Limit int64 // 0 returns all documents
+}
+
+// Field to sort by
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Sets an option of a query
+type FindOption func(*FindOptions)
+
+// Sorts the documents by the field. Multiple sort options are applied in order.
+func SortBy(field string, descending bool) FindOption {
+	return func(o *FindOptions) {
+		o.Sort = append(o.Sort, SortField{Field: field, Descending: descending})
+	}
+}
+
+// Skips the first n documents
+func Skip(n int64) FindOption {
+	return func(o *FindOptions) {
+		o.Skip = n
+	}
+}
+
+// Returns at most n documents
+func Limit(n int64) FindOption {
+	return func(o *FindOptions) {
+		o.Limit = n
+	}
+}
+
+// Applies all options
+func NewFindOptions(opts ...FindOption) *FindOptions {
+	o := new(FindOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
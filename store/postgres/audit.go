@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type auditStore struct {
+	*collection
+}
+
+func (s *auditStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.AuditEntry, error) {
+	docs, err := s.find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*model.AuditEntry, 0, len(docs))
+	for _, doc := range docs {
+		var e model.AuditEntry
+		if err := bson.Unmarshal(doc, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+// Entries are appended with the current time, so the order of insertion is the order of their time
+func (s *auditStore) Stream(ctx context.Context, filter interface{}, fn func(*model.AuditEntry) error) error {
+	return s.stream(ctx, filter, func(doc bson.Raw) error {
+		var e model.AuditEntry
+		if err := bson.Unmarshal(doc, &e); err != nil {
+			return err
+		}
+		return fn(&e)
+	})
+}
+
+func (s *auditStore) Count(ctx context.Context, filter interface{}) (int64, error) {
+	return s.count(ctx, filter)
+}
+
+func (s *auditStore) Insert(ctx context.Context, entry *model.AuditEntry) (*store.InsertResult, error) {
+	return s.insert(ctx, entry)
+}
+
+// The indexes are created by the schema migrations
+func (s *auditStore) Init(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/query"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Content entries of all collections are rows of the content table
+type contentStore struct {
+	db *sql.DB
+}
+
+func (s *contentStore) collection(coll string) *collection {
+	return &collection{db: s.db, table: contentTable, coll: coll}
+}
+
+func (s *contentStore) Find(ctx context.Context, coll string, filter interface{}, opts ...store.FindOption) ([]*model.Content, error) {
+	docs, err := s.collection(coll).find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*model.Content, 0, len(docs))
+	for _, doc := range docs {
+		var con model.Content
+		if err := bson.Unmarshal(doc, &con); err != nil {
+			return nil, err
+		}
+		result = append(result, &con)
+	}
+	return result, nil
+}
+
+func (s *contentStore) FindOne(ctx context.Context, coll string, filter interface{}) (*model.Content, error) {
+	var con model.Content
+	if err := s.collection(coll).findOne(ctx, filter, &con); err != nil {
+		return nil, err
+	}
+	return &con, nil
+}
+
+// Rows are decoded while they are read. fn can write to the store, because the database pool has other connections for it.
+func (s *contentStore) Stream(ctx context.Context, coll string, filter interface{}, fn func(*model.Content) error) error {
+	return s.collection(coll).stream(ctx, filter, func(doc bson.Raw) error {
+		var con model.Content
+		if err := bson.Unmarshal(doc, &con); err != nil {
+			return err
+		}
+		return fn(&con)
+	})
+}
+
+func (s *contentStore) Count(ctx context.Context, coll string, filter interface{}) (int64, error) {
+	return s.collection(coll).count(ctx, filter)
+}
+
+func (s *contentStore) Insert(ctx context.Context, coll string, content *model.Content) (*store.InsertResult, error) {
+	return s.collection(coll).insert(ctx, content)
+}
+
+func (s *contentStore) Update(ctx context.Context, coll string, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.collection(coll).update(ctx, filter, update, false)
+}
+
+func (s *contentStore) Delete(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return s.collection(coll).delete(ctx, filter, false)
+}
+
+func (s *contentStore) DeleteMany(ctx context.Context, coll string, filter interface{}) (*store.DeleteResult, error) {
+	return s.collection(coll).delete(ctx, filter, true)
+}
+
+func (s *contentStore) BulkUpdate(ctx context.Context, coll string, updates []store.EntryUpdate) (*store.UpdateResult, error) {
+	c := s.collection(coll)
+	return query.UpdateEach(updates, func(filter interface{}, update interface{}) (*store.UpdateResult, error) {
+		return c.update(ctx, filter, update, false)
+	})
+}
+
+// The entries are deleted with the indexes of the collection in one transaction
+func (s *contentStore) Drop(ctx context.Context, coll string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return convertError(err)
+	}
+	defer tx.Rollback()
+	if err := dropIndexes(ctx, tx, coll); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+contentTable+" WHERE collection = $1", coll); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// The entries and indexes are moved to the new collection in one transaction
+func (s *contentStore) Rename(ctx context.Context, from string, to string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return convertError(err)
+	}
+	defer tx.Rollback()
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+contentTable+" WHERE collection = $1) OR EXISTS (SELECT 1 FROM content_indexes WHERE collection = $1)", to).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("collection %s already exists", to)
+	}
+	indexes, err := renameIndexes(ctx, tx, from, to)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE "+contentTable+" SET collection = $2 WHERE collection = $1", from, to); err != nil {
+		return convertError(err)
+	}
+	if err := createUniqueIndexes(ctx, tx, to, indexes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
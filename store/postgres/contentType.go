@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type contentTypeStore struct {
+	*collection
+}
+
+func (s *contentTypeStore) Find(ctx context.Context, filter interface{}, opts ...store.FindOption) ([]*model.ContentType, error) {
+	docs, err := s.find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	contentTypes := make([]*model.ContentType, 0, len(docs))
+	for _, doc := range docs {
+		var ct model.ContentType
+		if err := bson.Unmarshal(doc, &ct); err != nil {
+			return nil, err
+		}
+		contentTypes = append(contentTypes, &ct)
+	}
+	return contentTypes, nil
+}
+
+func (s *contentTypeStore) FindOne(ctx context.Context, filter interface{}) (*model.ContentType, error) {
+	var ct model.ContentType
+	if err := s.findOne(ctx, filter, &ct); err != nil {
+		return nil, err
+	}
+	return &ct, nil
+}
+
+func (s *contentTypeStore) Insert(ctx context.Context, ct *model.ContentType) (*store.InsertResult, error) {
+	return s.insert(ctx, ct)
+}
+
+func (s *contentTypeStore) Update(ctx context.Context, filter interface{}, update interface{}) (*store.UpdateResult, error) {
+	return s.update(ctx, filter, update, false)
+}
+
+func (s *contentTypeStore) Delete(ctx context.Context, filter interface{}) (*store.DeleteResult, error) {
+	return s.delete(ctx, filter, false)
+}
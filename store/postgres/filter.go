@@ -0,0 +1,414 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Builds an SQL statement with numbered arguments
+type builder struct {
+	args []interface{}
+}
+
+// Adds an argument and returns its placeholder
+func (b *builder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// Returns the statement with the arguments as literals in place of their placeholders, e.g. for DDL statements,
+// which have no parameters
+func (b *builder) inline(stmt string) (string, error) {
+	literals := make([]string, len(b.args))
+	for i, v := range b.args {
+		if valuer, ok := v.(driver.Valuer); ok {
+			var err error
+			if v, err = valuer.Value(); err != nil {
+				return "", err
+			}
+		}
+		s, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("postgres: argument of type %T can not be inlined", v)
+		}
+		literals[i] = pq.QuoteLiteral(s)
+	}
+	return placeholder.ReplaceAllStringFunc(stmt, func(p string) string {
+		n, _ := strconv.Atoi(p[1:])
+		return literals[n-1]
+	}), nil
+}
+
+var placeholder = regexp.MustCompile(`\$[0-9]+`)
+
+// Translates a filter into an SQL condition on the `doc` column.
+//
+// Conditions on fields are translated into jsonpath predicates, that are matched with `doc @@ predicate`,
+// so they can use the GIN index of the table. Paths are evaluated in the lax mode of jsonpath,
+// which unwraps arrays like MongoDB does: A condition on an array matches, if one of its elements matches.
+// Documents and arrays can not be compared in jsonpath, so equality with them is translated into a comparison of jsonb values.
+func (b *builder) where(filter bson.Raw) (string, error) {
+	elems, err := filter.Elements()
+	if err != nil {
+		return "", err
+	}
+	var conds []string
+	for _, e := range elems {
+		key := e.Key()
+		var cond string
+		switch key {
+		case "$and", "$or", "$nor":
+			subs, err := subFilters(key, e.Value())
+			if err != nil {
+				return "", err
+			}
+			parts := make([]string, len(subs))
+			for i, sub := range subs {
+				c, err := b.where(sub)
+				if err != nil {
+					return "", err
+				}
+				parts[i] = "(" + c + ")"
+			}
+			switch {
+			case len(parts) == 0:
+				cond = strconv.FormatBool(key == "$and")
+			case key == "$and":
+				cond = strings.Join(parts, " AND ")
+			case key == "$or":
+				cond = strings.Join(parts, " OR ")
+			default:
+				cond = "NOT (" + strings.Join(parts, " OR ") + ")"
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return "", fmt.Errorf("postgres: unsupported filter operator %s", key)
+			}
+			if cond, err = b.field(key, e.Value()); err != nil {
+				return "", err
+			}
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 0 {
+		return "true", nil
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+// Translates the condition on the field at the dotted path
+func (b *builder) field(path string, cond bson.RawValue) (string, error) {
+	if !isOperatorDoc(cond) && (cond.Type == bsontype.EmbeddedDocument || cond.Type == bsontype.Array) {
+		value, err := jsonValue(cond)
+		if err != nil {
+			return "", err
+		}
+		j, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("doc #> %s::text[] = %s::jsonb", b.arg(pq.Array(strings.Split(path, "."))), b.arg(string(j))), nil
+	}
+	p, err := condition(pathExpr("$", path), cond)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("doc @@ %s::jsonpath", b.arg(p)), nil
+}
+
+// Translates the sort options into an ORDER BY clause. Missing fields are sorted first like `null` in MongoDB.
+// Documents with equal values stay in the order of insertion.
+func (b *builder) orderBy(sort []store.SortField) string {
+	var fields []string
+	for _, s := range sort {
+		path := b.arg(pq.Array(strings.Split(s.Field, ".")))
+		if s.Descending {
+			fields = append(fields, fmt.Sprintf("doc #> %s::text[] DESC NULLS LAST", path))
+		} else {
+			fields = append(fields, fmt.Sprintf("doc #> %s::text[] ASC NULLS FIRST", path))
+		}
+	}
+	return strings.Join(append(fields, "seq"), ", ")
+}
+
+// Returns a jsonpath predicate, that is true if the values at path match the condition
+func condition(path string, cond bson.RawValue) (string, error) {
+	if !isOperatorDoc(cond) {
+		return equal(path, cond)
+	}
+	ops := cond.Document()
+	elems, err := ops.Elements()
+	if err != nil {
+		return "", err
+	}
+	var preds []string
+	for _, e := range elems {
+		if e.Key() == "$options" {
+			// used by $regex
+			continue
+		}
+		p, err := operator(path, e.Key(), e.Value(), ops)
+		if err != nil {
+			return "", err
+		}
+		preds = append(preds, p)
+	}
+	return strings.Join(preds, " && "), nil
+}
+
+func operator(path string, op string, v bson.RawValue, ops bson.Raw) (string, error) {
+	switch op {
+	case "$eq":
+		return equal(path, v)
+	case "$ne":
+		p, err := equal(path, v)
+		return "!(" + p + ")", err
+	case "$in", "$nin":
+		arr, ok := v.ArrayOK()
+		if !ok {
+			return "", fmt.Errorf("postgres: %s needs an array", op)
+		}
+		values, err := arr.Values()
+		if err != nil {
+			return "", err
+		}
+		preds := make([]string, len(values))
+		for i, x := range values {
+			if preds[i], err = equal(path, x); err != nil {
+				return "", err
+			}
+		}
+		p := predFalse
+		if len(preds) > 0 {
+			p = "(" + strings.Join(preds, " || ") + ")"
+		}
+		if op == "$nin" {
+			return "!" + p, nil
+		}
+		return p, nil
+	case "$exists":
+		if truthy(v) {
+			return "exists(" + path + ")", nil
+		}
+		return "!exists(" + path + ")", nil
+	case "$gt", "$gte", "$lt", "$lte":
+		path, lit, err := literal(path, v)
+		if err != nil {
+			return "", err
+		}
+		cmp := map[string]string{"$gt": ">", "$gte": ">=", "$lt": "<", "$lte": "<="}[op]
+		return fmt.Sprintf("exists(%s ? (@ %s %s))", path, cmp, lit), nil
+	case "$regex":
+		options, _ := ops.Lookup("$options").StringValueOK()
+		return regex(path, v, options)
+	case "$elemMatch":
+		sub, ok := v.DocumentOK()
+		if !ok {
+			return "", fmt.Errorf("postgres: $elemMatch needs a document")
+		}
+		var p string
+		var err error
+		if isOperatorDoc(v) {
+			p, err = condition("@", v)
+		} else {
+			p, err = predicate("@", sub)
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("exists(%s[*] ? (%s))", path, p), nil
+	case "$not":
+		p, err := condition(path, v)
+		return "!(" + p + ")", err
+	}
+	return "", fmt.Errorf("postgres: unsupported filter operator %s", op)
+}
+
+// Translates a filter on the documents at base into a jsonpath predicate, e.g. the filter of `$elemMatch`
+func predicate(base string, filter bson.Raw) (string, error) {
+	elems, err := filter.Elements()
+	if err != nil {
+		return "", err
+	}
+	var preds []string
+	for _, e := range elems {
+		key := e.Key()
+		var p string
+		switch key {
+		case "$and", "$or", "$nor":
+			subs, err := subFilters(key, e.Value())
+			if err != nil {
+				return "", err
+			}
+			parts := make([]string, len(subs))
+			for i, sub := range subs {
+				if parts[i], err = predicate(base, sub); err != nil {
+					return "", err
+				}
+				parts[i] = "(" + parts[i] + ")"
+			}
+			switch {
+			case len(parts) == 0 && key == "$and":
+				p = predTrue
+			case len(parts) == 0:
+				p = predFalse
+			case key == "$and":
+				p = strings.Join(parts, " && ")
+			case key == "$or":
+				p = strings.Join(parts, " || ")
+			default:
+				p = "!(" + strings.Join(parts, " || ") + ")"
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return "", fmt.Errorf("postgres: unsupported filter operator %s", key)
+			}
+			if p, err = condition(pathExpr(base, key), e.Value()); err != nil {
+				return "", err
+			}
+		}
+		preds = append(preds, p)
+	}
+	if len(preds) == 0 {
+		return predTrue, nil
+	}
+	return strings.Join(preds, " && "), nil
+}
+
+// Predicates with a constant result
+const (
+	predTrue  = "(1 == 1)"
+	predFalse = "(1 == 0)"
+)
+
+// Matches, if one of the values at path equals v. `null` matches missing fields as well.
+func equal(path string, v bson.RawValue) (string, error) {
+	switch v.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return fmt.Sprintf("(!exists(%s) || exists(%s ? (@ == null)))", path, path), nil
+	case bsontype.Regex:
+		return regex(path, v, "")
+	}
+	path, lit, err := literal(path, v)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("exists(%s ? (@ == %s))", path, lit), nil
+}
+
+func regex(path string, v bson.RawValue, options string) (string, error) {
+	var pattern string
+	switch v.Type {
+	case bsontype.String:
+		pattern = v.StringValue()
+	case bsontype.Regex:
+		var o string
+		pattern, o = v.Regex()
+		options += o
+	default:
+		return "", fmt.Errorf("postgres: $regex needs a string")
+	}
+	for _, o := range options {
+		if !strings.ContainsRune("ims", o) {
+			return "", fmt.Errorf("postgres: unsupported regex option %c", o)
+		}
+	}
+	p := fmt.Sprintf("exists(%s ? (@ like_regex %s", path, quote(pattern))
+	if options != "" {
+		p += " flag " + quote(options)
+	}
+	return p + "))", nil
+}
+
+// Returns the jsonpath literal of a scalar value and the path to compare it with.
+// ObjectIDs and dates are compared with the value inside of their JSON objects.
+func literal(path string, v bson.RawValue) (string, string, error) {
+	switch v.Type {
+	case bsontype.String:
+		return path, quote(v.StringValue()), nil
+	case bsontype.Int32:
+		return path, strconv.FormatInt(int64(v.Int32()), 10), nil
+	case bsontype.Int64:
+		return path, strconv.FormatInt(v.Int64(), 10), nil
+	case bsontype.Double:
+		return path, strconv.FormatFloat(v.Double(), 'f', -1, 64), nil
+	case bsontype.Boolean:
+		return path, strconv.FormatBool(v.Boolean()), nil
+	case bsontype.ObjectID:
+		return pathExpr(path, keyObjectID), quote(v.ObjectID().Hex()), nil
+	case bsontype.DateTime:
+		return pathExpr(path, keyDate), strconv.FormatInt(v.DateTime(), 10), nil
+	}
+	return "", "", fmt.Errorf("postgres: values of type %s can not be compared here", v.Type)
+}
+
+// Returns the jsonpath of the dotted path relative to base, e.g. `$."fields"."title"`
+func pathExpr(base string, path string) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, key := range strings.Split(path, ".") {
+		b.WriteString(".")
+		b.WriteString(quote(key))
+	}
+	return b.String()
+}
+
+// Quotes a string for jsonpath, whose string literals are escaped like in JSON
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// Returns the filters of `$and`, `$or` and `$nor`
+func subFilters(op string, v bson.RawValue) ([]bson.Raw, error) {
+	arr, ok := v.ArrayOK()
+	if !ok {
+		return nil, fmt.Errorf("postgres: %s needs an array", op)
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]bson.Raw, len(values))
+	for i, sub := range values {
+		if subs[i], ok = sub.DocumentOK(); !ok {
+			return nil, fmt.Errorf("postgres: elements of %s have to be documents", op)
+		}
+	}
+	return subs, nil
+}
+
+// Returns true, if v is a document whose first key is an operator
+func isOperatorDoc(v bson.RawValue) bool {
+	d, ok := v.DocumentOK()
+	if !ok {
+		return false
+	}
+	elems, err := d.Elements()
+	return err == nil && len(elems) > 0 && strings.HasPrefix(elems[0].Key(), "$")
+}
+
+func truthy(v bson.RawValue) bool {
+	switch v.Type {
+	case bsontype.Boolean:
+		return v.Boolean()
+	case bsontype.Null, bsontype.Undefined:
+		return false
+	case bsontype.Int32:
+		return v.Int32() != 0
+	case bsontype.Int64:
+		return v.Int64() != 0
+	case bsontype.Double:
+		return v.Double() != 0
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+		args   []interface{}
+	}{
+		{"empty filter", `{}`, "true", nil},
+		{"equal string", `{"title": "Hello"}`, "doc @@ $1::jsonpath", []interface{}{`exists($."title" ? (@ == "Hello"))`}},
+		{"nested field", `{"fields.title": 3}`, "doc @@ $1::jsonpath", []interface{}{`exists($."fields"."title" ? (@ == 3))`}},
+		{"object ID", `{"_id": {"$oid": "60a7f2c1e4b0a1b2c3d4e5f6"}}`, "doc @@ $1::jsonpath", []interface{}{`exists($."_id"."$oid" ? (@ == "60a7f2c1e4b0a1b2c3d4e5f6"))`}},
+		{"null", `{"deleted_at": null}`, "doc @@ $1::jsonpath", []interface{}{`(!exists($."deleted_at") || exists($."deleted_at" ? (@ == null)))`}},
+		{"range", `{"created_at": {"$gte": {"$date": 1600000000000}, "$lt": 2.5}}`, "doc @@ $1::jsonpath", []interface{}{
+			`exists($."created_at"."$date" ? (@ >= 1600000000000)) && exists($."created_at" ? (@ < 2.5))`,
+		}},
+		{"in", `{"tags": {"$in": ["a", "b"]}}`, "doc @@ $1::jsonpath", []interface{}{
+			`(exists($."tags" ? (@ == "a")) || exists($."tags" ? (@ == "b")))`,
+		}},
+		{"empty nin", `{"tags": {"$nin": []}}`, "doc @@ $1::jsonpath", []interface{}{"!(1 == 0)"}},
+		{"regex", `{"title": {"$regex": "^he", "$options": "i"}}`, "doc @@ $1::jsonpath", []interface{}{
+			`exists($."title" ? (@ like_regex "^he" flag "i"))`,
+		}},
+		{"elemMatch", `{"aliases": {"$elemMatch": {"collection": "old", "expires": {"$exists": false}}}}`, "doc @@ $1::jsonpath", []interface{}{
+			`exists($."aliases"[*] ? (exists(@."collection" ? (@ == "old")) && !exists(@."expires")))`,
+		}},
+		{"or", `{"$or": [{"a": true}, {"b": false}]}`, "(doc @@ $1::jsonpath) OR (doc @@ $2::jsonpath)", []interface{}{
+			`exists($."a" ? (@ == true))`, `exists($."b" ? (@ == false))`,
+		}},
+		{"empty or", `{"$or": []}`, "false", nil},
+		{"nor", `{"$nor": [{"a": true}]}`, "NOT ((doc @@ $1::jsonpath))", []interface{}{`exists($."a" ? (@ == true))`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := new(builder)
+			got, err := b.where(parse(t, tt.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || !reflect.DeepEqual(b.args, tt.args) {
+				t.Errorf("got %s with %q, want %s with %q", got, b.args, tt.want, tt.args)
+			}
+		})
+	}
+
+	// Documents and arrays are compared as jsonb
+	b := new(builder)
+	got, err := b.where(parse(t, `{"fields.tags": ["a"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "doc #> $1::text[] = $2::jsonb" || b.args[1] != `["a"]` {
+		t.Errorf("got %s with %v", got, b.args)
+	}
+
+	for _, filter := range []string{
+		`{"$where": "true"}`,
+		`{"title": {"$size": 1}}`,
+		`{"title": {"$regex": "a", "$options": "x"}}`,
+		`{"$and": "not an array"}`,
+	} {
+		if _, err := new(builder).where(parse(t, filter)); err == nil {
+			t.Errorf("expected an error for filter %s", filter)
+		}
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	b := new(builder)
+	got := b.orderBy([]store.SortField{{Field: "created_at", Descending: true}, {Field: "fields.title"}})
+	want := "doc #> $1::text[] DESC NULLS LAST, doc #> $2::text[] ASC NULLS FIRST, seq"
+	if got != want || len(b.args) != 2 {
+		t.Errorf("got %s with %v, want %s", got, b.args, want)
+	}
+}
+
+func TestUniqueIndexStatement(t *testing.T) {
+	idx := model.IndexDefinition{
+		Name:          "shelf_row",
+		Keys:          []model.IndexKey{{Field: "shelf"}, {Field: "place.row"}},
+		Unique:        true,
+		PartialFilter: map[string]interface{}{"published": true},
+	}
+	got, err := uniqueIndexStatement("it's", idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE UNIQUE INDEX " + uniqueIndexName("it's", "shelf_row") + ` ON content (collection, (COALESCE(doc #> '{"shelf"}', 'null'::jsonb)), (COALESCE(doc #> '{"place","row"}', 'null'::jsonb))) ` +
+		`WHERE collection = 'it''s' AND (doc @@ 'exists($."published" ? (@ == true))'::jsonpath)`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	idx.Sparse, idx.PartialFilter = true, nil
+	if got, _ := uniqueIndexStatement("shop", idx); !strings.Contains(got, `(doc #> '{"shelf"}')`) || !strings.HasSuffix(got, "WHERE collection = 'shop'") {
+		t.Errorf("sparse index without filter: %s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	oid := primitive.NewObjectID()
+	date := primitive.NewDateTimeFromTime(time.Now().Truncate(time.Millisecond))
+	doc := mustRaw(t, bson.M{
+		"_id":        oid,
+		"created_at": date,
+		"count":      int32(3),
+		"big":        int64(1) << 40,
+		"ratio":      0.5,
+		"tags":       bson.A{"a", nil, true},
+		"fields":     bson.M{"title": "Hello"},
+	})
+
+	j, err := toJSON(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := fromJSON(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got bson.M
+	if err := bson.Unmarshal(doc, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := bson.Unmarshal(back, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after round trip, want %v", got, want)
+	}
+
+	// Keys are sorted, so equal documents have equal JSON
+	j2, err := toJSON(parse(t, `{"b": 1, "a": 2}`))
+	if err != nil || string(j2) != `{"a":2,"b":1}` {
+		t.Errorf("got %s, %v", j2, err)
+	}
+}
+
+// Parses a filter in extended JSON, which keeps the order of the keys
+func parse(t *testing.T, filter string) bson.Raw {
+	t.Helper()
+	var doc bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(filter), false, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func mustRaw(t *testing.T, v interface{}) bson.Raw {
+	t.Helper()
+	b, err := bson.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
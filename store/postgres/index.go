@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store/query"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Indexes of the content collections are rows of the table `content_indexes` with their definition as JSONB.
+// Other indexes only need the GIN index of the content table, but unique indexes are enforced by a unique index
+// of PostgreSQL on the JSONB values of their keys, that only covers the entries of the collection.
+type indexStore struct {
+	db *sql.DB
+}
+
+func (s *indexStore) List(ctx context.Context, coll string) ([]model.IndexDefinition, error) {
+	return listIndexes(ctx, s.db, coll)
+}
+
+func (s *indexStore) Create(ctx context.Context, coll string, idx model.IndexDefinition) error {
+	doc, err := bson.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	j, err := toJSON(doc)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return convertError(err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, "INSERT INTO content_indexes (collection, name, definition) VALUES ($1, $2, $3::jsonb) ON CONFLICT DO NOTHING", coll, idx.Name, string(j))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("index %s already exists", idx.Name)
+	}
+	if err := createUniqueIndexes(ctx, tx, coll, []model.IndexDefinition{idx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *indexStore) Drop(ctx context.Context, coll string, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return convertError(err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, "DELETE FROM content_indexes WHERE collection = $1 AND name = $2", coll, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("index %s not found", name)
+	}
+	if _, err := tx.ExecContext(ctx, "DROP INDEX IF EXISTS "+uniqueIndexName(coll, name)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Runs queries on the database or in a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Returns the indexes of the collection
+func listIndexes(ctx context.Context, q queryer, coll string) ([]model.IndexDefinition, error) {
+	rows, err := q.QueryContext(ctx, "SELECT definition FROM content_indexes WHERE collection = $1 ORDER BY seq", coll)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var indexes []model.IndexDefinition
+	for rows.Next() {
+		var j []byte
+		if err := rows.Scan(&j); err != nil {
+			return nil, err
+		}
+		doc, err := fromJSON(j)
+		if err != nil {
+			return nil, err
+		}
+		var idx model.IndexDefinition
+		if err := bson.Unmarshal(doc, &idx); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+// Deletes the indexes of the collection in the transaction
+func dropIndexes(ctx context.Context, tx *sql.Tx, coll string) error {
+	indexes, err := listIndexes(ctx, tx, coll)
+	if err != nil {
+		return err
+	}
+	if err := dropUniqueIndexes(ctx, tx, coll, indexes); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM content_indexes WHERE collection = $1", coll)
+	return err
+}
+
+// Moves the indexes of the collection to the collection `to` in the transaction.
+// The unique indexes are only recreated by `createUniqueIndexes`, after the entries were moved.
+func renameIndexes(ctx context.Context, tx *sql.Tx, from string, to string) ([]model.IndexDefinition, error) {
+	indexes, err := listIndexes(ctx, tx, from)
+	if err != nil {
+		return nil, err
+	}
+	if err := dropUniqueIndexes(ctx, tx, from, indexes); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE content_indexes SET collection = $2 WHERE collection = $1", from, to)
+	return indexes, err
+}
+
+// Creates the PostgreSQL indexes of the unique indexes of the collection
+func createUniqueIndexes(ctx context.Context, tx *sql.Tx, coll string, indexes []model.IndexDefinition) error {
+	for _, idx := range indexes {
+		if !idx.Unique {
+			continue
+		}
+		q, err := uniqueIndexStatement(coll, idx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return convertError(err)
+		}
+	}
+	return nil
+}
+
+func dropUniqueIndexes(ctx context.Context, tx *sql.Tx, coll string, indexes []model.IndexDefinition) error {
+	for _, idx := range indexes {
+		if !idx.Unique {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "DROP INDEX IF EXISTS "+uniqueIndexName(coll, idx.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the name of the PostgreSQL index of a unique index. Names are hashed to fit into the 63 bytes of an identifier.
+func uniqueIndexName(coll string, name string) string {
+	sum := sha1.Sum([]byte(coll + "\x00" + name))
+	return pq.QuoteIdentifier(uniqueIndexPrefix + hex.EncodeToString(sum[:10]))
+}
+
+// Prefix of the names of the PostgreSQL indexes of unique indexes
+const uniqueIndexPrefix = "content_unique_"
+
+// Returns the statement, that creates the PostgreSQL index of a unique index.
+// DDL statements have no parameters, so the collection and the partial filter are inlined as literals.
+// Like in MongoDB missing keys are `null`, only sparse indexes skip them.
+func uniqueIndexStatement(coll string, idx model.IndexDefinition) (string, error) {
+	keys := []string{"collection"}
+	for _, k := range idx.Keys {
+		key := fmt.Sprintf("doc #> %s", pq.QuoteLiteral("{"+strings.Join(quoteElements(strings.Split(k.Field, ".")), ",")+"}"))
+		if !idx.Sparse {
+			key = fmt.Sprintf("COALESCE(%s, 'null'::jsonb)", key)
+		}
+		keys = append(keys, "("+key+")")
+	}
+	cond := "collection = " + pq.QuoteLiteral(coll)
+	if idx.PartialFilter != nil {
+		f, err := query.Raw(idx.PartialFilter)
+		if err != nil {
+			return "", err
+		}
+		b := new(builder)
+		partial, err := b.where(f)
+		if err != nil {
+			return "", err
+		}
+		if partial, err = b.inline(partial); err != nil {
+			return "", err
+		}
+		cond += " AND (" + partial + ")"
+	}
+	return fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s) WHERE %s", uniqueIndexName(coll, idx.Name), contentTable, strings.Join(keys, ", "), cond), nil
+}
+
+// Quotes the elements of a text array literal
+func quoteElements(elems []string) []string {
+	quoted := make([]string, len(elems))
+	for i, e := range elems {
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(e) + `"`
+	}
+	return quoted
+}
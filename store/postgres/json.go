@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Documents are stored as JSON. The BSON types, that JSON does not have, are stored as objects with a single key:
+// ObjectIDs as `{"$oid": "<hex>"}` and dates as `{"$date": <milliseconds since epoch>}`.
+// Filters compare the value inside these objects, so ObjectIDs and dates keep their order.
+const (
+	keyObjectID = "$oid"
+	keyDate     = "$date"
+)
+
+// Converts a BSON document into JSON. Keys are sorted, so equal documents have equal JSON.
+func toJSON(doc bson.Raw) ([]byte, error) {
+	v, err := jsonValue(bson.RawValue{Type: bsontype.EmbeddedDocument, Value: doc})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func jsonValue(v bson.RawValue) (interface{}, error) {
+	switch v.Type {
+	case bsontype.EmbeddedDocument:
+		elems, err := v.Document().Elements()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(elems))
+		for _, e := range elems {
+			if m[e.Key()], err = jsonValue(e.Value()); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case bsontype.Array:
+		values, err := v.Array().Values()
+		if err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, len(values))
+		for i, x := range values {
+			if a[i], err = jsonValue(x); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case bsontype.ObjectID:
+		return map[string]interface{}{keyObjectID: v.ObjectID().Hex()}, nil
+	case bsontype.DateTime:
+		return map[string]interface{}{keyDate: v.DateTime()}, nil
+	case bsontype.Double:
+		f := v.Double()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("postgres: %v can not be stored", f)
+		}
+		return f, nil
+	case bsontype.Int32:
+		return v.Int32(), nil
+	case bsontype.Int64:
+		return v.Int64(), nil
+	case bsontype.String:
+		return v.StringValue(), nil
+	case bsontype.Boolean:
+		return v.Boolean(), nil
+	case bsontype.Null, bsontype.Undefined:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("postgres: unsupported BSON type %s", v.Type)
+}
+
+// Converts a stored JSON document back into BSON.
+// Integers are decoded as int32 or int64 like the mongo driver does, all other numbers as double.
+func fromJSON(b []byte) (bson.Raw, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	doc, err := bson.Marshal(bsonValue(m))
+	return bson.Raw(doc), err
+}
+
+func bsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		if len(x) == 1 {
+			if s, ok := x[keyObjectID].(string); ok {
+				if oid, err := primitive.ObjectIDFromHex(s); err == nil {
+					return oid
+				}
+			}
+			if n, ok := x[keyDate].(json.Number); ok {
+				if ms, err := n.Int64(); err == nil {
+					return primitive.DateTime(ms)
+				}
+			}
+		}
+		m := make(bson.M, len(x))
+		for k, e := range x {
+			m[k] = bsonValue(e)
+		}
+		return m
+	case []interface{}:
+		a := make(bson.A, len(x))
+		for i, e := range x {
+			a[i] = bsonValue(e)
+		}
+		return a
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return int32(i)
+			}
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
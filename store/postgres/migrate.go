@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/logging"
+)
+
+// Schema migrations. The file names start with the version, e.g. `0002_add_column.sql`.
+// Applied migrations must not be changed; every change of the schema needs a new file.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Key of the advisory lock, that is held while the schema is migrated
+const migrationLock = 7311
+
+// Migration of the schema
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// Applies the migrations, that are missing in the database, in order of their version.
+// Every migration runs in a transaction of its own and is recorded in the table `schema_migrations`.
+// An advisory lock makes servers, that start at the same time, wait for each other.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	all, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	// session locks belong to a connection, so all statements use the same one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLock)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    integer PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`); err != nil {
+		return err
+	}
+	var current int
+	if err := conn.QueryRowContext(ctx, "SELECT coalesce(max(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		if m.version <= current {
+			continue
+		}
+		if err := apply(ctx, conn, m); err != nil {
+			return fmt.Errorf("schema migration %s: %s", m.name, err.Error())
+		}
+		logging.Ctx(ctx).Info().Str("migration", m.name).Msg("Applied schema migration")
+	}
+	return nil
+}
+
+func apply(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// statements without arguments may contain multiple commands
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns the embedded migrations sorted by version
+func loadMigrations() ([]migration, error) {
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var all []migration
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".sql")
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("schema migration %s has no version", f.Name())
+		}
+		b, err := migrations.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, migration{version: version, name: name, sql: string(b)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].version < all[j].version })
+	for i := 1; i < len(all); i++ {
+		if all[i].version == all[i-1].version {
+			return nil, fmt.Errorf("schema migrations %s and %s have the same version", all[i-1].name, all[i].name)
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,50 @@
+-- Every table stores the whole document in `doc`. `seq` keeps the order of insertion.
+-- The GIN indexes with `jsonb_path_ops` are used by the jsonpath predicates of the filters.
+
+CREATE TABLE users (
+    id       text PRIMARY KEY,
+    seq      bigserial NOT NULL,
+    doc      jsonb NOT NULL,
+    username text GENERATED ALWAYS AS (doc ->> 'username') STORED UNIQUE,
+    email    text GENERATED ALWAYS AS (doc ->> 'email') STORED UNIQUE
+);
+CREATE INDEX users_doc_idx ON users USING gin (doc jsonb_path_ops);
+
+CREATE TABLE roles (
+    id   text PRIMARY KEY,
+    seq  bigserial NOT NULL,
+    doc  jsonb NOT NULL,
+    tag  text GENERATED ALWAYS AS (doc ->> 'tag') STORED UNIQUE,
+    name text GENERATED ALWAYS AS (doc ->> 'name') STORED UNIQUE
+);
+CREATE INDEX roles_doc_idx ON roles USING gin (doc jsonb_path_ops);
+
+-- Type names and collections are not unique, because content types in the trash keep them
+CREATE TABLE content_types (
+    id         text PRIMARY KEY,
+    seq        bigserial NOT NULL,
+    doc        jsonb NOT NULL,
+    typename   text GENERATED ALWAYS AS (doc ->> 'typename') STORED,
+    collection text GENERATED ALWAYS AS (doc ->> 'collection') STORED
+);
+CREATE INDEX content_types_collection_idx ON content_types (collection);
+CREATE INDEX content_types_doc_idx ON content_types USING gin (doc jsonb_path_ops);
+
+-- Content entries of all content types, partitioned by the name of their collection
+CREATE TABLE content (
+    collection text NOT NULL,
+    id         text NOT NULL,
+    seq        bigserial NOT NULL,
+    doc        jsonb NOT NULL,
+    PRIMARY KEY (collection, id)
+) PARTITION BY HASH (collection);
+CREATE TABLE content_0 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 0);
+CREATE TABLE content_1 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 1);
+CREATE TABLE content_2 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 2);
+CREATE TABLE content_3 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 3);
+CREATE TABLE content_4 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 4);
+CREATE TABLE content_5 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 5);
+CREATE TABLE content_6 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 6);
+CREATE TABLE content_7 PARTITION OF content FOR VALUES WITH (MODULUS 8, REMAINDER 7);
+CREATE INDEX content_seq_idx ON content (collection, seq);
+CREATE INDEX content_doc_idx ON content USING gin (doc jsonb_path_ops);
@@ -0,0 +1,14 @@
+-- Notifies the servers of changes of roles and content types, so that they drop them from their caches.
+-- The payload is the name of the collection, that changed.
+
+CREATE FUNCTION notify_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('fiber_backend_changes', TG_ARGV[0]);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER roles_changed AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON roles
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_change('roles');
+CREATE TRIGGER content_types_changed AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON content_types
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_change('contenttypes');
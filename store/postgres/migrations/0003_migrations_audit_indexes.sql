@@ -0,0 +1,25 @@
+-- Jobs of field migrations and the append-only audit log, stored like the other documents.
+
+CREATE TABLE migrations (
+    id  text PRIMARY KEY,
+    seq bigserial NOT NULL,
+    doc jsonb NOT NULL
+);
+CREATE INDEX migrations_doc_idx ON migrations USING gin (doc jsonb_path_ops);
+
+CREATE TABLE audit_log (
+    id  text PRIMARY KEY,
+    seq bigserial NOT NULL,
+    doc jsonb NOT NULL
+);
+CREATE INDEX audit_log_time_idx ON audit_log ((doc #> '{time}'));
+CREATE INDEX audit_log_doc_idx ON audit_log USING gin (doc jsonb_path_ops);
+
+-- Indexes declared on the content collections
+CREATE TABLE content_indexes (
+    collection text NOT NULL,
+    name       text NOT NULL,
+    seq        bigserial NOT NULL,
+    definition jsonb NOT NULL,
+    PRIMARY KEY (collection, name)
+);
@@ -0,0 +1,3 @@
+-- Only one migration job per content type may be pending or running. Active jobs hold the ID of their content type in `active`.
+
+CREATE UNIQUE INDEX migrations_active_idx ON migrations ((doc ->> 'active')) WHERE doc ? 'active';
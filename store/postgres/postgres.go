@@ -0,0 +1,332 @@
+// Package postgres implements the storage interfaces on PostgreSQL 12 or newer.
+//
+// Users, roles and content types are kept in tables of their own, content entries of all content types
+// in the table `content`, which is partitioned by the name of the collection.
+// Every row stores the whole document as JSONB in the column `doc`. Fields, that identify users, roles and content types,
+// are generated columns of their tables, so usernames, emails and role names are unique.
+// Filters are translated into jsonpath predicates, that use the GIN index on `doc`.
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/query"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Table of the content entries of all collections
+const contentTable = "content"
+
+// Codes of PostgreSQL for violations of unique constraints and canceled statements, e.g. by `statement_timeout`
+const (
+	errCodeUniqueViolation = "23505"
+	errCodeQueryCanceled   = "57014"
+)
+
+// Returns the storage backend on the database. The schema has to be migrated with `Migrate` first.
+func New(db *sql.DB) *store.Store {
+	return &store.Store{
+		Users:        &userStore{&collection{db: db, table: "users"}},
+		Roles:        &roleStore{&collection{db: db, table: "roles"}},
+		ContentTypes: &contentTypeStore{&collection{db: db, table: "content_types"}},
+		Content:      &contentStore{db: db},
+		Indexes:      &indexStore{db: db},
+		Migrations:   &migrationStore{&collection{db: db, table: "migrations"}},
+		AuditLog:     &auditStore{&collection{db: db, table: "audit_log"}},
+		Transactions: store.NoTransactions{},
+	}
+}
+
+// Rows of a table. Content entries are rows of the content table with the name of their collection.
+type collection struct {
+	db    *sql.DB
+	table string
+	coll  string
+}
+
+// Returns the condition, that selects the rows matching the filter
+func (c *collection) where(b *builder, filter interface{}) (string, error) {
+	f, err := query.Raw(filter)
+	if err != nil {
+		return "", err
+	}
+	cond, err := b.where(f)
+	if err != nil {
+		return "", err
+	}
+	if c.table == contentTable {
+		cond = fmt.Sprintf("collection = %s AND (%s)", b.arg(c.coll), cond)
+	}
+	return cond, nil
+}
+
+// Columns of the primary key
+func (c *collection) key() string {
+	if c.table == contentTable {
+		return "collection, id"
+	}
+	return "id"
+}
+
+// Returns all documents, that match the filter, sorted and paginated by the options
+func (c *collection) find(ctx context.Context, filter interface{}, opts []store.FindOption) ([]bson.Raw, error) {
+	b := new(builder)
+	cond, err := c.where(b, filter)
+	if err != nil {
+		return nil, err
+	}
+	o := store.NewFindOptions(opts...)
+	q := fmt.Sprintf("SELECT doc FROM %s WHERE %s ORDER BY %s", c.table, cond, b.orderBy(o.Sort))
+	if o.Limit > 0 {
+		q += " LIMIT " + b.arg(o.Limit)
+	}
+	if o.Skip > 0 {
+		q += " OFFSET " + b.arg(o.Skip)
+	}
+
+	var docs []bson.Raw
+	err = c.query(ctx, q, b.args, func(doc bson.Raw) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	return docs, err
+}
+
+// Calls fn for every document of the query
+func (c *collection) query(ctx context.Context, q string, args []interface{}, fn func(bson.Raw) error) error {
+	rows, err := c.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return convertError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var j []byte
+		if err := rows.Scan(&j); err != nil {
+			return err
+		}
+		doc, err := fromJSON(j)
+		if err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return convertError(rows.Err())
+}
+
+// Calls fn for every document, that matches the filter, in the order of insertion
+func (c *collection) stream(ctx context.Context, filter interface{}, fn func(bson.Raw) error) error {
+	b := new(builder)
+	cond, err := c.where(b, filter)
+	if err != nil {
+		return err
+	}
+	return c.query(ctx, fmt.Sprintf("SELECT doc FROM %s WHERE %s ORDER BY seq", c.table, cond), b.args, fn)
+}
+
+// Decodes the first document, that matches the filter, into v
+func (c *collection) findOne(ctx context.Context, filter interface{}, v interface{}) error {
+	docs, err := c.find(ctx, filter, []store.FindOption{store.Limit(1)})
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		return store.ErrNotFound
+	}
+	return bson.Unmarshal(docs[0], v)
+}
+
+func (c *collection) count(ctx context.Context, filter interface{}) (int64, error) {
+	b := new(builder)
+	cond, err := c.where(b, filter)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", c.table, cond), b.args...).Scan(&n)
+	return n, convertError(err)
+}
+
+// Inserts the document. Like MongoDB an ObjectID is generated, if the document has no `_id`.
+func (c *collection) insert(ctx context.Context, v interface{}) (*store.InsertResult, error) {
+	doc, id, err := query.WithID(v)
+	if err != nil {
+		return nil, err
+	}
+	j, err := toJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(builder)
+	columns, values := "id, doc", b.arg(idKey(id))+", "+b.arg(string(j))+"::jsonb"
+	if c.table == contentTable {
+		columns, values = columns+", collection", values+", "+b.arg(c.coll)
+	}
+	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", c.table, columns, values)
+	if _, err := c.db.ExecContext(ctx, q, b.args...); err != nil {
+		return nil, convertError(err)
+	}
+	return &store.InsertResult{InsertedID: query.Value(id)}, nil
+}
+
+// Replaces the document with the same `_id`. Content entries are not replaced.
+func (c *collection) replace(ctx context.Context, v interface{}) error {
+	doc, id, err := query.WithID(v)
+	if err != nil {
+		return err
+	}
+	j, err := toJSON(doc)
+	if err != nil {
+		return err
+	}
+	b := new(builder)
+	q := fmt.Sprintf("UPDATE %s SET doc = %s::jsonb WHERE id = %s", c.table, b.arg(string(j)), b.arg(idKey(id)))
+	res, err := c.db.ExecContext(ctx, q, b.args...)
+	if err != nil {
+		return convertError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// Applies the update to the first or all documents, that match the filter.
+// The matching rows are locked, updated by the query package and written back in one transaction.
+func (c *collection) update(ctx context.Context, filter interface{}, update interface{}, many bool) (*store.UpdateResult, error) {
+	u, err := query.Raw(update)
+	if err != nil {
+		return nil, err
+	}
+	b := new(builder)
+	cond, err := c.where(b, filter)
+	if err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf("SELECT id, doc FROM %s WHERE %s ORDER BY seq", c.table, cond)
+	if !many {
+		q += " LIMIT 1"
+	}
+	q += " FOR UPDATE"
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	defer tx.Rollback()
+
+	result := &store.UpdateResult{}
+	updated := make(map[string][]byte)
+	rows, err := tx.QueryContext(ctx, q, b.args...)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	for rows.Next() {
+		var id string
+		var j []byte
+		if err := rows.Scan(&id, &j); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		doc, err := fromJSON(j)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		before, err := toJSON(doc)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		after, err := query.Update(doc, u)
+		if err == nil {
+			j, err = toJSON(after)
+		}
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.MatchedCount++
+		if !bytes.Equal(before, j) {
+			updated[id] = j
+			result.ModifiedCount++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, convertError(err)
+	}
+
+	for id, j := range updated {
+		b := new(builder)
+		q := fmt.Sprintf("UPDATE %s SET doc = %s::jsonb WHERE id = %s", c.table, b.arg(string(j)), b.arg(id))
+		if c.table == contentTable {
+			q += " AND collection = " + b.arg(c.coll)
+		}
+		if _, err := tx.ExecContext(ctx, q, b.args...); err != nil {
+			return nil, convertError(err)
+		}
+	}
+	return result, convertError(tx.Commit())
+}
+
+// Deletes the first or all documents, that match the filter
+func (c *collection) delete(ctx context.Context, filter interface{}, many bool) (*store.DeleteResult, error) {
+	b := new(builder)
+	cond, err := c.where(b, filter)
+	if err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf("DELETE FROM %s WHERE %s", c.table, cond)
+	if !many {
+		q = fmt.Sprintf("DELETE FROM %s WHERE (%s) IN (SELECT %s FROM %s WHERE %s ORDER BY seq LIMIT 1)", c.table, c.key(), c.key(), c.table, cond)
+	}
+	res, err := c.db.ExecContext(ctx, q, b.args...)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	return &store.DeleteResult{DeletedCount: n}, nil
+}
+
+// Primary key of a document: the hex string of ObjectIDs and the extended JSON of all other types
+func idKey(id bson.RawValue) string {
+	if id.Type == bsontype.ObjectID {
+		return id.ObjectID().Hex()
+	}
+	return id.String()
+}
+
+// Returns the errors of the store for violations of unique constraints, timeouts and connection errors
+func convertError(err error) error {
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &pqErr) && pqErr.Code == errCodeUniqueViolation:
+		return store.DuplicateKeyError(pqErr.Detail)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &pqErr) && pqErr.Code == errCodeQueryCanceled,
+		errors.As(err, &netErr) && netErr.Timeout():
+		return store.TimeoutError(err)
+	case errors.Is(err, driver.ErrBadConn), errors.As(err, &netErr):
+		return store.UnavailableError(err)
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/D-Bald/fiber-backend/store/postgres"
+	_ "github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Opens the database at `POSTGRES_TEST_URL` with empty tables. All data in the database is deleted.
+func open(t *testing.T) *store.Store {
+	t.Helper()
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	if err := postgres.Migrate(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	// a second run finds nothing to do
+	if err := postgres.Migrate(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "TRUNCATE users, roles, content_types, content, migrations, audit_log, content_indexes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, dropUniqueIndexes); err != nil {
+		t.Fatal(err)
+	}
+	return postgres.New(db)
+}
+
+// Drops the PostgreSQL indexes of the unique indexes of content collections
+const dropUniqueIndexes = `DO $$
+DECLARE i record;
+BEGIN
+    FOR i IN SELECT indexname FROM pg_indexes WHERE tablename = 'content' AND indexname LIKE 'content\_unique\_%' LOOP
+        EXECUTE 'DROP INDEX ' || quote_ident(i.indexname);
+    END LOOP;
+END $$`
+
+func TestContent(t *testing.T) {
+	s := open(t)
+	ctx := context.Background()
+	published := true
+	start := time.Now().Truncate(time.Millisecond)
+	for i, title := range []string{"First", "Second", "Third"} {
+		entry := &model.Content{ID: primitive.NewObjectID(), CreatedAt: start.Add(time.Duration(i) * time.Hour), Title: title, Tags: []string{"foo"}, Fields: map[string]interface{}{"position": i}}
+		if i == 1 {
+			entry.Published = &published
+		}
+		if _, err := s.Content.Insert(ctx, "posts", entry); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Content.Insert(ctx, "posts", entry); !errors.Is(err, store.ErrDuplicateKey) {
+			t.Fatalf("second insert of the same ID returned %v", err)
+		}
+		// IDs are unique per collection
+		if _, err := s.Content.Insert(ctx, "pages", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := s.Content.Find(ctx, "posts", bson.M{"tags": "foo"}, store.SortBy("created_at", true), store.Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].Title != "Third" || result[1].Title != "Second" {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if n, err := s.Content.Count(ctx, "posts", bson.M{"published": true}); err != nil || n != 1 {
+		t.Errorf("count of published entries is %d, %v", n, err)
+	}
+	if n, err := s.Content.Count(ctx, "posts", bson.M{"created_at": bson.M{"$gt": start}}); err != nil || n != 2 {
+		t.Errorf("count of later entries is %d, %v", n, err)
+	}
+
+	update := bson.M{"$set": bson.M{"title": "Updated"}, "$currentDate": bson.M{"updated_at": true}}
+	if res, err := s.Content.Update(ctx, "posts", bson.M{"position": bson.M{"$gte": 1}}, update); err != nil || res.MatchedCount != 1 || res.ModifiedCount != 1 {
+		t.Fatalf("update returned %v, %v", res, err)
+	}
+	if entry, err := s.Content.FindOne(ctx, "posts", bson.M{"title": "Updated"}); err != nil || entry.Fields["position"] != int32(1) || entry.UpdatedAt.IsZero() {
+		t.Errorf("updated entry is %v, %v", entry, err)
+	}
+
+	if res, err := s.Content.DeleteMany(ctx, "posts", bson.M{"title": bson.M{"$ne": "Updated"}}); err != nil || res.DeletedCount != 2 {
+		t.Fatalf("delete returned %v, %v", res, err)
+	}
+	if err := s.Content.Drop(ctx, "posts"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Content.FindOne(ctx, "posts", bson.M{}); err != store.ErrNotFound {
+		t.Errorf("find in dropped collection returned %v", err)
+	}
+	if n, err := s.Content.Count(ctx, "pages", bson.M{}); err != nil || n != 3 {
+		t.Errorf("drop changed other collections: %d, %v", n, err)
+	}
+}
+
+func TestUniqueColumns(t *testing.T) {
+	s := open(t)
+	ctx := context.Background()
+	if _, err := s.Users.Insert(ctx, &model.User{ID: primitive.NewObjectID(), Username: "alice", Email: "alice@sample.com"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Users.Insert(ctx, &model.User{ID: primitive.NewObjectID(), Username: "bob", Email: "alice@sample.com"})
+	if !errors.Is(err, store.ErrDuplicateKey) {
+		t.Errorf("insert of a used email returned %v", err)
+	}
+	if u, err := s.Users.FindOne(ctx, bson.M{"$or": bson.A{bson.M{"username": "alice@sample.com"}, bson.M{"email": "alice@sample.com"}}}); err != nil || u.Username != "alice" {
+		t.Errorf("found user %v, %v", u, err)
+	}
+}
+
+func TestUniqueIndexes(t *testing.T) {
+	s := open(t)
+	ctx := context.Background()
+	idx := model.IndexDefinition{Name: "unique_sku", Keys: []model.IndexKey{{Field: "sku"}}, Unique: true, PartialFilter: map[string]interface{}{"sku": bson.M{"$exists": true}}}
+	if err := s.Indexes.Create(ctx, "products", idx); err != nil {
+		t.Fatal(err)
+	}
+	insert := func(coll string, fields map[string]interface{}) error {
+		_, err := s.Content.Insert(ctx, coll, &model.Content{ID: primitive.NewObjectID(), Title: "Product", Fields: fields})
+		return err
+	}
+	for _, fields := range []map[string]interface{}{{"sku": "A-1"}, {}, {}} {
+		if err := insert("products", fields); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := insert("products", map[string]interface{}{"sku": "A-1"}); !errors.Is(err, store.ErrDuplicateKey) {
+		t.Errorf("insert of a used value returned %v", err)
+	}
+	// other collections are not covered
+	if err := insert("others", map[string]interface{}{"sku": "A-1"}); err != nil {
+		t.Error(err)
+	}
+
+	// the index moves with the collection
+	if err := s.Content.Rename(ctx, "products", "items"); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert("items", map[string]interface{}{"sku": "A-1"}); !errors.Is(err, store.ErrDuplicateKey) {
+		t.Errorf("insert into the renamed collection returned %v", err)
+	}
+	if err := insert("products", map[string]interface{}{"sku": "A-1"}); err != nil {
+		t.Errorf("insert into the old collection returned %v", err)
+	}
+	// indexes, that the entries violate, are not created
+	if err := s.Indexes.Create(ctx, "products", idx); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert("products", map[string]interface{}{"sku": "A-1"}); !errors.Is(err, store.ErrDuplicateKey) {
+		t.Fatalf("insert of a used value returned %v", err)
+	}
+	if err := s.Indexes.Drop(ctx, "products", idx.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert("products", map[string]interface{}{"sku": "A-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Indexes.Create(ctx, "products", idx); !errors.Is(err, store.ErrDuplicateKey) {
+		t.Errorf("creation of a violated index returned %v", err)
+	}
+	if indexes, err := s.Indexes.List(ctx, "products"); err != nil || len(indexes) != 0 {
+		t.Errorf("failed creation left indexes %v, %v", indexes, err)
+	}
+}
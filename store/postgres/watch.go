@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"github.com/lib/pq"
+)
+
+// Channel, on which the triggers of the schema notify the changes of roles and content types
+const changesChannel = "fiber_backend_changes"
+
+// Returns the watch of the changes of roles and content types in the database at url.
+// Triggers notify every change with the name of its collection. Notifications are not received by
+// the connections of the store, so the watch listens on a connection of its own, that is re-established, if it breaks.
+func Watch(url string) store.WatchFunc {
+	return func(ctx context.Context, changed func(collection string)) error {
+		listener := pq.NewListener(url, time.Second, time.Minute, nil)
+		defer listener.Close()
+		if err := listener.Listen(changesChannel); err != nil {
+			return err
+		}
+		// Changes before the listener started are not notified
+		changed("")
+		for {
+			select {
+			case n := <-listener.Notify:
+				// nil after the connection was re-established, notifications may have been missed in between
+				if n == nil {
+					changed("")
+				} else {
+					changed(n.Extra)
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package query
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Returns true, if the document matches the filter.
+// Supports the operators listed in the documentation of the store package with the semantics of MongoDB:
+// Conditions on arrays match, if the array itself or one of its elements matches.
+func Match(doc bson.Raw, filter bson.Raw) (bool, error) {
+	elems, err := filter.Elements()
+	if err != nil {
+		return false, err
+	}
+	for _, e := range elems {
+		key := e.Key()
+		var ok bool
+		switch key {
+		case "$and", "$or", "$nor":
+			ok, err = matchLogical(doc, key, e.Value())
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("query: unsupported filter operator %s", key)
+			}
+			ok, err = matchField(lookup(doc, key), e.Value())
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(doc bson.Raw, op string, v bson.RawValue) (bool, error) {
+	arr, ok := v.ArrayOK()
+	if !ok {
+		return false, fmt.Errorf("query: %s needs an array", op)
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return false, err
+	}
+	for _, sub := range values {
+		subDoc, ok := sub.DocumentOK()
+		if !ok {
+			return false, fmt.Errorf("query: elements of %s have to be documents", op)
+		}
+		matched, err := Match(doc, subDoc)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case op == "$and" && !matched:
+			return false, nil
+		case op == "$or" && matched:
+			return true, nil
+		case op == "$nor" && matched:
+			return false, nil
+		}
+	}
+	return op != "$or", nil
+}
+
+// Matches the values of a field against a condition, that is either a value or a document of operators
+func matchField(values []bson.RawValue, cond bson.RawValue) (bool, error) {
+	if isOperatorDoc(cond) {
+		elems, err := cond.Document().Elements()
+		if err != nil {
+			return false, err
+		}
+		for _, e := range elems {
+			ok, err := matchOperator(values, e.Key(), e.Value(), cond.Document())
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return matchEqual(values, cond)
+}
+
+func matchOperator(values []bson.RawValue, op string, v bson.RawValue, cond bson.Raw) (bool, error) {
+	switch op {
+	case "$eq":
+		return matchEqual(values, v)
+	case "$ne":
+		ok, err := matchEqual(values, v)
+		return !ok, err
+	case "$in", "$nin":
+		arr, ok := v.ArrayOK()
+		if !ok {
+			return false, fmt.Errorf("query: %s needs an array", op)
+		}
+		candidates, err := arr.Values()
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, c := range candidates {
+			if found, err = matchEqual(values, c); err != nil {
+				return false, err
+			} else if found {
+				break
+			}
+		}
+		return found == (op == "$in"), nil
+	case "$exists":
+		return (len(values) > 0) == truthy(v), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, x := range expand(values) {
+			if typeRank(x.Type) != typeRank(v.Type) {
+				continue
+			}
+			cmp := Compare(x, v)
+			if (op == "$gt" && cmp > 0) || (op == "$gte" && cmp >= 0) || (op == "$lt" && cmp < 0) || (op == "$lte" && cmp <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$regex":
+		options, _ := cond.Lookup("$options").StringValueOK()
+		re, err := compileRegex(v, options)
+		if err != nil {
+			return false, err
+		}
+		return matchRegex(values, re), nil
+	case "$options":
+		// used by $regex
+		return true, nil
+	case "$elemMatch":
+		sub, ok := v.DocumentOK()
+		if !ok {
+			return false, fmt.Errorf("query: $elemMatch needs a document")
+		}
+		for _, x := range values {
+			arr, ok := x.ArrayOK()
+			if !ok {
+				continue
+			}
+			elems, err := arr.Values()
+			if err != nil {
+				return false, err
+			}
+			for _, e := range elems {
+				var matched bool
+				if isOperatorDoc(v) {
+					matched, err = matchField([]bson.RawValue{e}, v)
+				} else if d, ok := e.DocumentOK(); ok {
+					matched, err = Match(d, sub)
+				}
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case "$not":
+		ok, err := matchField(values, v)
+		return !ok, err
+	}
+	return false, fmt.Errorf("query: unsupported filter operator %s", op)
+}
+
+// Matches, if one of the values or one of their array elements equals v. Regular expressions are matched against strings.
+// `null` matches missing fields as well.
+func matchEqual(values []bson.RawValue, v bson.RawValue) (bool, error) {
+	switch v.Type {
+	case bsontype.Regex:
+		re, err := compileRegex(v, "")
+		if err != nil {
+			return false, err
+		}
+		return matchRegex(values, re), nil
+	case bsontype.Null:
+		if len(values) == 0 {
+			return true, nil
+		}
+	}
+	for _, x := range values {
+		if equalValues(x, v) {
+			return true, nil
+		}
+		if arr, ok := x.ArrayOK(); ok {
+			elems, err := arr.Values()
+			if err != nil {
+				return false, err
+			}
+			for _, e := range elems {
+				if equalValues(e, v) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+func matchRegex(values []bson.RawValue, re *regexp.Regexp) bool {
+	for _, x := range expand(values) {
+		if s, ok := x.StringValueOK(); ok && re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compiles the pattern of a `$regex` operator or a regex value with the options of MongoDB, that Go supports
+func compileRegex(v bson.RawValue, options string) (*regexp.Regexp, error) {
+	var pattern string
+	switch v.Type {
+	case bsontype.String:
+		pattern = v.StringValue()
+	case bsontype.Regex:
+		var o string
+		pattern, o = v.Regex()
+		options += o
+	default:
+		return nil, fmt.Errorf("query: $regex needs a string")
+	}
+	var flags string
+	for _, o := range options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		default:
+			return nil, fmt.Errorf("query: unsupported regex option %c", o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// Returns true, if v is a document whose first key is an operator
+func isOperatorDoc(v bson.RawValue) bool {
+	d, ok := v.DocumentOK()
+	if !ok {
+		return false
+	}
+	elems, err := d.Elements()
+	return err == nil && len(elems) > 0 && strings.HasPrefix(elems[0].Key(), "$")
+}
+
+// Returns all values at the dotted path. Arrays on the way are traversed, so there can be more than one value.
+func lookup(doc bson.Raw, path string) []bson.RawValue {
+	return lookupPath(doc, strings.Split(path, "."))
+}
+
+func lookupPath(doc bson.Raw, path []string) []bson.RawValue {
+	v, err := doc.LookupErr(path[0])
+	if err != nil {
+		return nil
+	}
+	if len(path) == 1 {
+		return []bson.RawValue{v}
+	}
+	if d, ok := v.DocumentOK(); ok {
+		return lookupPath(d, path[1:])
+	}
+	arr, ok := v.ArrayOK()
+	if !ok {
+		return nil
+	}
+	var values []bson.RawValue
+	if _, err := strconv.Atoi(path[1]); err == nil {
+		// index of an array element
+		values = append(values, lookupPath(arr, path[1:])...)
+	}
+	elems, _ := arr.Values()
+	for _, e := range elems {
+		if d, ok := e.DocumentOK(); ok {
+			values = append(values, lookupPath(d, path[1:])...)
+		}
+	}
+	return values
+}
+
+// Returns the values with the elements of arrays in place of the arrays
+func expand(values []bson.RawValue) []bson.RawValue {
+	var result []bson.RawValue
+	for _, v := range values {
+		if arr, ok := v.ArrayOK(); ok {
+			elems, _ := arr.Values()
+			result = append(result, elems...)
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// Returns the first value or `null` for sorting
+func first(values []bson.RawValue) bson.RawValue {
+	if len(values) == 0 {
+		return bson.RawValue{Type: bsontype.Null}
+	}
+	return values[0]
+}
+
+func truthy(v bson.RawValue) bool {
+	switch v.Type {
+	case bsontype.Boolean:
+		return v.Boolean()
+	case bsontype.Null, bsontype.Undefined:
+		return false
+	}
+	if f, ok := number(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+func equalValues(a, b bson.RawValue) bool {
+	if typeRank(a.Type) != typeRank(b.Type) {
+		return false
+	}
+	return Compare(a, b) == 0
+}
+
+// Compares two values in the sort order of MongoDB: Values of different types are ordered by their type.
+func Compare(a, b bson.RawValue) int {
+	if ra, rb := typeRank(a.Type), typeRank(b.Type); ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch typeRank(a.Type) {
+	case rankNull:
+		return 0
+	case rankNumber:
+		x, _ := number(a)
+		y, _ := number(b)
+		return compareFloats(x, y)
+	case rankString:
+		return strings.Compare(a.StringValue(), b.StringValue())
+	case rankObjectID:
+		x, y := a.ObjectID(), b.ObjectID()
+		return bytes.Compare(x[:], y[:])
+	case rankBoolean:
+		x, y := a.Boolean(), b.Boolean()
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case rankDateTime:
+		x, y := a.DateTime(), b.DateTime()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return bytes.Compare(a.Value, b.Value)
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func number(v bson.RawValue) (float64, bool) {
+	switch v.Type {
+	case bsontype.Double:
+		return v.Double(), true
+	case bsontype.Int32:
+		return float64(v.Int32()), true
+	case bsontype.Int64:
+		return float64(v.Int64()), true
+	}
+	return 0, false
+}
+
+// Sort order of the types
+const (
+	rankNull = iota
+	rankNumber
+	rankString
+	rankDocument
+	rankArray
+	rankBinary
+	rankObjectID
+	rankBoolean
+	rankDateTime
+	rankTimestamp
+	rankRegex
+	rankOther
+)
+
+func typeRank(t bsontype.Type) int {
+	switch t {
+	case bsontype.Null, bsontype.Undefined:
+		return rankNull
+	case bsontype.Double, bsontype.Int32, bsontype.Int64, bsontype.Decimal128:
+		return rankNumber
+	case bsontype.String, bsontype.Symbol:
+		return rankString
+	case bsontype.EmbeddedDocument:
+		return rankDocument
+	case bsontype.Array:
+		return rankArray
+	case bsontype.Binary:
+		return rankBinary
+	case bsontype.ObjectID:
+		return rankObjectID
+	case bsontype.Boolean:
+		return rankBoolean
+	case bsontype.DateTime:
+		return rankDateTime
+	case bsontype.Timestamp:
+		return rankTimestamp
+	case bsontype.Regex:
+		return rankRegex
+	}
+	return rankOther
+}
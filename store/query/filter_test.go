@@ -0,0 +1,190 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMatch(t *testing.T) {
+	roleA, roleB := primitive.NewObjectID(), primitive.NewObjectID()
+	now := time.Now()
+	doc := mustRaw(t, bson.M{
+		"title":     "Hello World",
+		"count":     int32(3),
+		"published": true,
+		"tags":      bson.A{"foo", "bar"},
+		"roles":     bson.A{roleA},
+		"created":   now,
+		"fields":    bson.M{"title": bson.M{"en": "Hello", "de": "Hallo"}},
+		"aliases": bson.A{
+			bson.M{"collection": "old", "expires_at": now.Add(time.Hour)},
+			bson.M{"collection": "older", "expires_at": now.Add(-time.Hour)},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		filter interface{}
+		want   bool
+	}{
+		{"empty filter", bson.M{}, true},
+		{"nil filter", nil, true},
+		{"equal string", bson.M{"title": "Hello World"}, true},
+		{"different string", bson.M{"title": "Hello"}, false},
+		{"equal number of other type", bson.M{"count": 3.0}, true},
+		{"equal bool", bson.M{"published": true}, true},
+		{"array element", bson.M{"tags": "foo"}, true},
+		{"missing array element", bson.M{"tags": "baz"}, false},
+		{"whole array", bson.M{"tags": bson.A{"foo", "bar"}}, true},
+		{"object ID in array", bson.M{"roles": roleA}, true},
+		{"other object ID", bson.M{"roles": roleB}, false},
+		{"dotted path", bson.M{"fields.title.de": "Hallo"}, true},
+		{"dotted path into array", bson.M{"aliases.collection": "older"}, true},
+		{"null matches missing field", bson.M{"deleted_at": nil}, true},
+		{"exists", bson.M{"title": bson.M{"$exists": true}}, true},
+		{"not exists", bson.M{"deleted_at": bson.M{"$exists": false}}, true},
+		{"exists on missing field", bson.M{"deleted_at": bson.M{"$exists": true}}, false},
+		{"ne", bson.M{"title": bson.M{"$ne": "Hello"}}, true},
+		{"ne on array element", bson.M{"tags": bson.M{"$ne": "foo"}}, false},
+		{"in", bson.M{"tags": bson.M{"$in": bson.A{"baz", "bar"}}}, true},
+		{"nin", bson.M{"tags": bson.M{"$nin": bson.A{"baz", "bar"}}}, false},
+		{"gt number", bson.M{"count": bson.M{"$gt": 2}}, true},
+		{"lte number", bson.M{"count": bson.M{"$lte": 2}}, false},
+		{"gte date", bson.M{"created": bson.M{"$gte": now.Add(-time.Minute)}}, true},
+		{"lt date", bson.M{"created": bson.M{"$lt": now.Add(-time.Minute)}}, false},
+		{"gt of other type", bson.M{"title": bson.M{"$gt": 1}}, false},
+		{"regex", bson.M{"title": bson.M{"$regex": "^hello", "$options": "i"}}, true},
+		{"regex value", bson.M{"title": primitive.Regex{Pattern: "World$"}}, true},
+		{"regex on array", bson.M{"tags": bson.M{"$regex": "^ba"}}, true},
+		{"and", bson.M{"$and": bson.A{bson.M{"title": "Hello World"}, bson.M{"count": 3}}}, true},
+		{"and with one mismatch", bson.M{"$and": bson.A{bson.M{"title": "Hello World"}, bson.M{"count": 4}}}, false},
+		{"or", bson.M{"$or": bson.A{bson.M{"title": "Hello"}, bson.M{"count": 3}}}, true},
+		{"elemMatch", bson.M{"aliases": bson.M{"$elemMatch": bson.M{
+			"collection": "older",
+			"expires_at": bson.M{"$gt": now},
+		}}}, false},
+		{"elemMatch of same element", bson.M{"aliases": bson.M{"$elemMatch": bson.M{
+			"collection": "old",
+			"expires_at": bson.M{"$gt": now},
+		}}}, true},
+		{"elemMatch with operators", bson.M{"tags": bson.M{"$elemMatch": bson.M{"$in": bson.A{"bar"}}}}, true},
+		{"bson.D filter", bson.D{{Key: "title", Value: "Hello World"}, {Key: "count", Value: 3}}, true},
+		{"map filter", map[string]interface{}{"title": "Hello World"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(doc, mustRaw(t, tt.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Match(%v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchUnsupportedOperator(t *testing.T) {
+	doc := mustRaw(t, bson.M{"tags": bson.A{"foo"}})
+	if _, err := Match(doc, mustRaw(t, bson.M{"tags": bson.M{"$size": 1}})); err == nil {
+		t.Error("expected an error for an unsupported operator")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	doc := mustRaw(t, bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "title", Value: "Hello"},
+		{Key: "deleted_at", Value: time.Now()},
+		{Key: "fields", Value: bson.M{"title": bson.M{"en": "Hello"}}},
+		{Key: "subtitle", Value: "World"},
+	})
+	update := mustRaw(t, bson.D{
+		{Key: "$set", Value: bson.M{"title": "Hallo", "fields.title.de": "Hallo", "tags": bson.A{"foo"}}},
+		{Key: "$unset", Value: bson.M{"deleted_at": ""}},
+		{Key: "$currentDate", Value: bson.M{"updated_at": true}},
+		{Key: "$rename", Value: bson.M{"subtitle": "fields.subtitle", "missing": "other"}},
+	})
+
+	updated, err := Update(doc, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for filter, want := range map[string]bool{
+		`{"title": "Hallo"}`:               true,
+		`{"fields.title.en": "Hello"}`:     true,
+		`{"fields.title.de": "Hallo"}`:     true,
+		`{"tags": "foo"}`:                  true,
+		`{"deleted_at": {"$exists": 1}}`:   false,
+		`{"updated_at": {"$exists": 1}}`:   true,
+		`{"_id": {"$exists": true}}`:       true,
+		`{"created_at": {"$exists": 1}}`:   false,
+		`{"fields.title": {"$exists": 1}}`: true,
+		`{"subtitle": {"$exists": 1}}`:     false,
+		`{"fields.subtitle": "World"}`:     true,
+		`{"other": {"$exists": 1}}`:        false,
+	} {
+		var f bson.M
+		if err := bson.UnmarshalExtJSON([]byte(filter), false, &f); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Match(updated, mustRaw(t, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("after update Match(%s) = %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestUpdateAddToSet(t *testing.T) {
+	a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	doc := mustRaw(t, bson.M{"_id": primitive.NewObjectID(), "roles": bson.A{a}, "permissions": bson.M{"GET": bson.A{a}}})
+	update := mustRaw(t, bson.D{{Key: "$addToSet", Value: bson.D{
+		{Key: "roles", Value: bson.M{"$each": bson.A{a, b}}},
+		{Key: "permissions.GET", Value: a},
+		{Key: "permissions.POST", Value: c},
+	}}})
+
+	updated, err := Update(doc, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Roles       []primitive.ObjectID            `bson:"roles"`
+		Permissions map[string][]primitive.ObjectID `bson:"permissions"`
+	}
+	if err := bson.Unmarshal(updated, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Roles) != 2 || got.Roles[0] != a || got.Roles[1] != b {
+		t.Errorf("roles = %v, want [%v %v]", got.Roles, a, b)
+	}
+	if len(got.Permissions["GET"]) != 1 || len(got.Permissions["POST"]) != 1 || got.Permissions["POST"][0] != c {
+		t.Errorf("permissions = %v", got.Permissions)
+	}
+
+	if _, err := Update(updated, mustRaw(t, bson.M{"$addToSet": bson.M{"_id.x": a}})); err == nil {
+		t.Error("expected an error when adding to a field, that is not an array")
+	}
+}
+
+func TestUpdateRejectsID(t *testing.T) {
+	doc := mustRaw(t, bson.M{"_id": primitive.NewObjectID()})
+	if _, err := Update(doc, mustRaw(t, bson.M{"$set": bson.M{"_id": primitive.NewObjectID()}})); err == nil {
+		t.Error("expected an error when updating _id")
+	}
+}
+
+func mustRaw(t *testing.T, v interface{}) bson.Raw {
+	t.Helper()
+	raw, err := Raw(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
@@ -0,0 +1,95 @@
+// Package query evaluates filters and updates in the MongoDB query language on BSON documents.
+// It is used by the storage backends without MongoDB, so that all backends return the same results.
+package query
+
+import (
+	"sort"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Marshals filters and updates. `nil` is an empty document, that matches all documents.
+func Raw(v interface{}) (bson.Raw, error) {
+	if v == nil {
+		return bson.Raw(bsonEmpty), nil
+	}
+	if raw, ok := v.(bson.Raw); ok {
+		return raw, nil
+	}
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bson.Raw(b), nil
+}
+
+// Empty BSON document
+var bsonEmpty = []byte{5, 0, 0, 0, 0}
+
+// Returns the documents sorted and paginated by the options. The slice is sorted in place.
+func Select(docs []bson.Raw, o *store.FindOptions) []bson.Raw {
+	if len(o.Sort) > 0 {
+		sort.SliceStable(docs, func(i, j int) bool {
+			for _, s := range o.Sort {
+				cmp := Compare(first(lookup(docs[i], s.Field)), first(lookup(docs[j], s.Field)))
+				if cmp == 0 {
+					continue
+				}
+				if s.Descending {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+	if o.Skip > 0 {
+		if o.Skip >= int64(len(docs)) {
+			return nil
+		}
+		docs = docs[o.Skip:]
+	}
+	if o.Limit > 0 && o.Limit < int64(len(docs)) {
+		docs = docs[:o.Limit]
+	}
+	return docs
+}
+
+// Marshals the document and returns it with its `_id`. Like MongoDB an ObjectID is generated, if the document has no `_id`.
+func WithID(v interface{}) (bson.Raw, bson.RawValue, error) {
+	doc, err := bson.Marshal(v)
+	if err != nil {
+		return nil, bson.RawValue{}, err
+	}
+	if id, err := bson.Raw(doc).LookupErr("_id"); err == nil {
+		return doc, id, nil
+	}
+
+	// prepend the generated ID like MongoDB does
+	var d bson.D
+	if err := bson.Unmarshal(doc, &d); err != nil {
+		return nil, bson.RawValue{}, err
+	}
+	if doc, err = bson.Marshal(append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, d...)); err != nil {
+		return nil, bson.RawValue{}, err
+	}
+	return doc, bson.Raw(doc).Lookup("_id"), nil
+}
+
+// Decodes a single value into the types, that the mongo driver returns for it
+func Value(v bson.RawValue) interface{} {
+	switch v.Type {
+	case bsontype.ObjectID:
+		return v.ObjectID()
+	case bsontype.String:
+		return v.StringValue()
+	}
+	var i interface{}
+	if err := v.Unmarshal(&i); err != nil {
+		return primitive.Null{}
+	}
+	return i
+}
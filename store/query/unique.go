@@ -0,0 +1,99 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/D-Bald/fiber-backend/model"
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Unique index of a collection, that is enforced by the backends without MongoDB
+type UniqueIndex struct {
+	def    model.IndexDefinition
+	filter bson.Raw
+}
+
+// Returns the unique indexes of the definitions
+func UniqueIndexes(defs []model.IndexDefinition) ([]UniqueIndex, error) {
+	var indexes []UniqueIndex
+	for _, d := range defs {
+		if !d.Unique {
+			continue
+		}
+		idx := UniqueIndex{def: d}
+		if d.PartialFilter != nil {
+			f, err := Raw(d.PartialFilter)
+			if err != nil {
+				return nil, err
+			}
+			idx.filter = f
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
+// Returns the values of the index keys of the document and false, if the document is not covered by the index:
+// It does not match the partial filter or, for sparse indexes, has none of the keys. Like in MongoDB missing keys are `null`.
+func (idx UniqueIndex) key(doc bson.Raw) ([]bson.RawValue, bool, error) {
+	if idx.filter != nil {
+		ok, err := Match(doc, idx.filter)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+	}
+	key := make([]bson.RawValue, len(idx.def.Keys))
+	found := false
+	for i, k := range idx.def.Keys {
+		values := lookup(doc, k.Field)
+		key[i] = first(values)
+		found = found || len(values) > 0
+	}
+	if idx.def.Sparse && !found {
+		return nil, false, nil
+	}
+	return key, true, nil
+}
+
+// Returns a duplicate key error, if the document has the same key as another document in one of the indexes.
+// each has to call fn with all documents of the collection. The document itself is recognized by its `_id` and skipped.
+func CheckUnique(indexes []UniqueIndex, doc bson.Raw, each func(fn func(bson.Raw) error) error) error {
+	id := doc.Lookup("_id")
+	for _, idx := range indexes {
+		key, ok, err := idx.key(doc)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		err = each(func(other bson.Raw) error {
+			if equalValues(other.Lookup("_id"), id) {
+				return nil
+			}
+			otherKey, ok, err := idx.key(other)
+			if err != nil || !ok {
+				return err
+			}
+			for i := range key {
+				if !equalValues(key[i], otherKey[i]) {
+					return nil
+				}
+			}
+			return store.DuplicateKeyError(idx.def.Name + " " + keyString(key))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func keyString(key []bson.RawValue) string {
+	values := make([]string, len(key))
+	for i, v := range key {
+		values[i] = v.String()
+	}
+	return "{" + strings.Join(values, ", ") + "}"
+}
@@ -0,0 +1,184 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/D-Bald/fiber-backend/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Returns the document with the update applied. Supports `$set`, `$unset`, `$rename`, `$currentDate` and `$addToSet` on dotted fields.
+func Update(doc bson.Raw, update bson.Raw) (bson.Raw, error) {
+	var d bson.D
+	if err := bson.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	var u bson.D
+	if err := bson.Unmarshal(update, &u); err != nil {
+		return nil, err
+	}
+
+	for _, op := range u {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("query: %s needs a document", op.Key)
+		}
+		for _, f := range fields {
+			if f.Key == "_id" {
+				return nil, fmt.Errorf("query: _id can not be updated")
+			}
+			var err error
+			switch op.Key {
+			case "$set":
+				d, err = setPath(d, strings.Split(f.Key, "."), f.Value)
+			case "$unset":
+				d = unsetPath(d, strings.Split(f.Key, "."))
+			case "$rename":
+				to, ok := f.Value.(string)
+				if !ok || to == "" || to == "_id" {
+					return nil, fmt.Errorf("query: invalid target of $rename for %s", f.Key)
+				}
+				if v, ok := getPath(d, strings.Split(f.Key, ".")); ok {
+					d = unsetPath(d, strings.Split(f.Key, "."))
+					d, err = setPath(d, strings.Split(to, "."), v)
+				}
+			case "$currentDate":
+				// `true` and `{$type: "date"}` both set a date
+				d, err = setPath(d, strings.Split(f.Key, "."), primitive.NewDateTimeFromTime(time.Now()))
+			case "$addToSet":
+				d, err = addToSet(d, strings.Split(f.Key, "."), f.Value)
+			default:
+				return nil, fmt.Errorf("query: unsupported update operator %s", op.Key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return bson.Marshal(d)
+}
+
+// Sets the value at the path. Missing documents on the way are created.
+func setPath(d bson.D, path []string, value interface{}) (bson.D, error) {
+	for i, e := range d {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			d[i].Value = value
+			return d, nil
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("query: can not set %s in a %T", strings.Join(path[1:], "."), e.Value)
+		}
+		sub, err := setPath(sub, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		d[i].Value = sub
+		return d, nil
+	}
+	if len(path) == 1 {
+		return append(d, bson.E{Key: path[0], Value: value}), nil
+	}
+	sub, err := setPath(bson.D{}, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	return append(d, bson.E{Key: path[0], Value: sub}), nil
+}
+
+// Appends the value, or with `{$each: [...]}` each of the values, to the array at the path, if it is not in it yet.
+// A missing array is created.
+func addToSet(d bson.D, path []string, value interface{}) (bson.D, error) {
+	values := bson.A{value}
+	if each, ok := value.(bson.D); ok && len(each) == 1 && each[0].Key == "$each" {
+		if values, ok = each[0].Value.(bson.A); !ok {
+			return nil, fmt.Errorf("query: $each needs an array")
+		}
+	}
+	set := bson.A{}
+	if v, ok := getPath(d, path); ok {
+		if set, ok = v.(bson.A); !ok {
+			return nil, fmt.Errorf("query: can not $addToSet to %s of type %T", strings.Join(path, "."), v)
+		}
+	}
+	for _, v := range values {
+		raw, err := rawValue(v)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, e := range set {
+			other, err := rawValue(e)
+			if err != nil {
+				return nil, err
+			}
+			if equalValues(raw, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			set = append(set, v)
+		}
+	}
+	return setPath(d, path, set)
+}
+
+func rawValue(v interface{}) (bson.RawValue, error) {
+	t, data, err := bson.MarshalValue(v)
+	return bson.RawValue{Type: t, Value: data}, err
+}
+
+// Returns the value at the path and true, if it exists
+func getPath(d bson.D, path []string) (interface{}, bool) {
+	for _, e := range d {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return e.Value, true
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			return getPath(sub, path[1:])
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// Removes the field at the path, if it exists
+func unsetPath(d bson.D, path []string) bson.D {
+	for i, e := range d {
+		if e.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return append(d[:i:i], d[i+1:]...)
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			d[i].Value = unsetPath(sub, path[1:])
+		}
+		return d
+	}
+	return d
+}
+
+// Applies the updates one by one with update and sums up their results. Stops at the first error.
+func UpdateEach(updates []store.EntryUpdate, update func(filter interface{}, update interface{}) (*store.UpdateResult, error)) (*store.UpdateResult, error) {
+	result := new(store.UpdateResult)
+	for _, u := range updates {
+		r, err := update(u.Filter, u.Update)
+		if err != nil {
+			return result, err
+		}
+		result.MatchedCount += r.MatchedCount
+		result.ModifiedCount += r.ModifiedCount
+	}
+	return result, nil
+}
@@ -0,0 +1,234 @@
+// Package store defines the interfaces of the storage backends.
+//
+// Filters and updates are written in the MongoDB query language, because the API builds its filters from query params.
+// Every backend has to support the operators, that are used by the controllers:
+//   - filters: equality on (dotted) fields, `$and`, `$or`, `$in`, `$nin`, `$ne`, `$exists`, `$gt`, `$gte`, `$lt`, `$lte`, `$regex` and `$elemMatch`
+//   - updates: `$set`, `$unset`, `$rename`, `$currentDate` and `$addToSet`
+//
+// Methods, that return a single document, return `ErrNotFound` if no document matches the filter.
+// Methods, that return multiple documents, return an empty slice instead.
+// Writes, that violate a unique index, return an error, that wraps `ErrDuplicateKey`.
+// Operations, that time out or can not reach the database, return errors, that wrap `ErrTimeout` or `ErrUnavailable`.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/D-Bald/fiber-backend/model"
+)
+
+// Returned if no document matches the filter
+var ErrNotFound = errors.New("no document found")
+
+// Wrapped by the errors of writes, that violate a unique index
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// Returned by features, that the storage backend or its deployment does not support, like transactions
+var ErrNotSupported = errors.New("not supported by the storage backend")
+
+// Wrapped by the errors of operations, that did not finish in time
+var ErrTimeout = errors.New("database timeout")
+
+// Wrapped by the errors of operations, that could not reach the database
+var ErrUnavailable = errors.New("database not reachable")
+
+// Returns the error of a write, that violates a unique index. key describes the index and the duplicate value.
+func DuplicateKeyError(key string) error {
+	return fmt.Errorf("%w: %s", ErrDuplicateKey, key)
+}
+
+// Returns err of the backend as an error, that wraps ErrTimeout
+func TimeoutError(err error) error {
+	return fmt.Errorf("%w: %s", ErrTimeout, err.Error())
+}
+
+// Returns err of the backend as an error, that wraps ErrUnavailable
+func UnavailableError(err error) error {
+	return fmt.Errorf("%w: %s", ErrUnavailable, err.Error())
+}
+
+// Unique index of the migration jobs on the field `active`, that allows only one pending or running job per content type
+var MigrationActiveIndex = model.IndexDefinition{
+	Name:          "active_migration",
+	Keys:          []model.IndexKey{{Field: "active", Type: model.IndexAsc}},
+	Unique:        true,
+	PartialFilter: map[string]interface{}{"active": map[string]interface{}{"$exists": true}},
+}
+
+// Names of the collections of the users, roles, content types, migration jobs and audit log
+const (
+	CollectionUsers        = "users"
+	CollectionRoles        = "roles"
+	CollectionContentTypes = "contenttypes"
+	CollectionMigrations   = "migrations"
+	CollectionAuditLog     = "audit_log"
+)
+
+// Collections of the system. Content types must not use them.
+var SystemCollections = []string{CollectionRoles, CollectionUsers, CollectionContentTypes, CollectionMigrations, CollectionAuditLog}
+
+// Returns true, if coll is one of the SystemCollections
+func IsSystemCollection(coll string) bool {
+	for _, c := range SystemCollections {
+		if c == coll {
+			return true
+		}
+	}
+	return false
+}
+
+// Result of an insert
+type InsertResult struct {
+	InsertedID interface{}
+}
+
+// Result of an update
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+	UpsertedCount int64
+	UpsertedID    interface{}
+}
+
+// Result of a delete
+type DeleteResult struct {
+	DeletedCount int64
+}
+
+// Update of the entries, that match the filter, in a bulk write
+type EntryUpdate struct {
+	Filter interface{}
+	Update interface{}
+}
+
+// Watches the changes of the users, roles and content types, that may have been made by other servers,
+// and calls changed with the collection of every change. An empty collection means, that changes may have been missed,
+// e.g. while the connection was interrupted. It blocks until ctx is done or the watch fails.
+// Backends, whose changes can not be watched, return ErrNotSupported.
+type WatchFunc func(ctx context.Context, changed func(collection string)) error
+
+// Storage of the users
+type UserStore interface {
+	Find(ctx context.Context, filter interface{}, opts ...FindOption) ([]*model.User, error)
+	FindOne(ctx context.Context, filter interface{}) (*model.User, error)
+	Insert(ctx context.Context, user *model.User) (*InsertResult, error)
+	// Updates the first user, that matches the filter
+	Update(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error)
+	// Deletes the first user, that matches the filter
+	Delete(ctx context.Context, filter interface{}) (*DeleteResult, error)
+}
+
+// Storage of the roles
+type RoleStore interface {
+	Find(ctx context.Context, filter interface{}, opts ...FindOption) ([]*model.Role, error)
+	FindOne(ctx context.Context, filter interface{}) (*model.Role, error)
+	Insert(ctx context.Context, role *model.Role) (*InsertResult, error)
+	// Updates the first role, that matches the filter
+	Update(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error)
+	// Deletes the first role, that matches the filter
+	Delete(ctx context.Context, filter interface{}) (*DeleteResult, error)
+}
+
+// Storage of the content types
+type ContentTypeStore interface {
+	Find(ctx context.Context, filter interface{}, opts ...FindOption) ([]*model.ContentType, error)
+	FindOne(ctx context.Context, filter interface{}) (*model.ContentType, error)
+	Insert(ctx context.Context, ct *model.ContentType) (*InsertResult, error)
+	// Updates the first content type, that matches the filter
+	Update(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error)
+	// Deletes the first content type, that matches the filter
+	Delete(ctx context.Context, filter interface{}) (*DeleteResult, error)
+}
+
+// Storage of the content entries. Entries are schemaless and stored per collection of their content type.
+// Collections are created on the first insert.
+type ContentStore interface {
+	Find(ctx context.Context, coll string, filter interface{}, opts ...FindOption) ([]*model.Content, error)
+	FindOne(ctx context.Context, coll string, filter interface{}) (*model.Content, error)
+	// Calls fn for every entry, that matches the filter. Entries are decoded one by one, so that large collections can be streamed.
+	Stream(ctx context.Context, coll string, filter interface{}, fn func(*model.Content) error) error
+	Count(ctx context.Context, coll string, filter interface{}) (int64, error)
+	Insert(ctx context.Context, coll string, content *model.Content) (*InsertResult, error)
+	// Updates the first entry, that matches the filter
+	Update(ctx context.Context, coll string, filter interface{}, update interface{}) (*UpdateResult, error)
+	// Deletes the first entry, that matches the filter
+	Delete(ctx context.Context, coll string, filter interface{}) (*DeleteResult, error)
+	// Deletes all entries, that match the filter
+	DeleteMany(ctx context.Context, coll string, filter interface{}) (*DeleteResult, error)
+	// Applies every update to the first entry, that matches its filter. The updates are not applied atomically.
+	BulkUpdate(ctx context.Context, coll string, updates []EntryUpdate) (*UpdateResult, error)
+	// Deletes the collection with all of its entries and indexes
+	Drop(ctx context.Context, coll string) error
+	// Moves all entries and indexes of the collection to the collection `to`, which must not exist, in one step.
+	// Returns ErrNotSupported, if the collection can not be renamed, e.g. on sharded MongoDB clusters.
+	Rename(ctx context.Context, from string, to string) error
+}
+
+// Indexes of the content collections. The index of `_id` is not listed and can not be changed.
+type IndexStore interface {
+	// Returns the indexes of the collection
+	List(ctx context.Context, coll string) ([]model.IndexDefinition, error)
+	// Creates the index. The name must not be used by another index of the collection.
+	Create(ctx context.Context, coll string, idx model.IndexDefinition) error
+	Drop(ctx context.Context, coll string, name string) error
+}
+
+// Storage of the field migration jobs
+type MigrationStore interface {
+	Find(ctx context.Context, filter interface{}, opts ...FindOption) ([]*model.Migration, error)
+	FindOne(ctx context.Context, filter interface{}) (*model.Migration, error)
+	Insert(ctx context.Context, m *model.Migration) (*InsertResult, error)
+	// Replaces the job with the same ID
+	Replace(ctx context.Context, m *model.Migration) error
+	// Updates all jobs, that match the filter
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}) (*UpdateResult, error)
+	// Deletes the first job, that matches the filter
+	Delete(ctx context.Context, filter interface{}) (*DeleteResult, error)
+	// Prepares the storage, e.g. creates the MigrationActiveIndex
+	Init(ctx context.Context) error
+}
+
+// Storage of the append-only audit log
+type AuditStore interface {
+	Find(ctx context.Context, filter interface{}, opts ...FindOption) ([]*model.AuditEntry, error)
+	// Calls fn for every entry, that matches the filter, oldest first
+	Stream(ctx context.Context, filter interface{}, fn func(*model.AuditEntry) error) error
+	Count(ctx context.Context, filter interface{}) (int64, error)
+	Insert(ctx context.Context, entry *model.AuditEntry) (*InsertResult, error)
+	// Prepares the storage, e.g. creates the indexes used by the filters of the audit log
+	Init(ctx context.Context) error
+}
+
+// Runs writes of multiple documents in transactions
+type Transactor interface {
+	// Returns true, if the backend supports transactions
+	SupportsTransactions(ctx context.Context) bool
+	// Runs fn in a transaction. The stores join it through the context passed to fn.
+	// Returns ErrNotSupported without calling fn, if the backend does not support transactions.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Transactor of the backends without transactions
+type NoTransactions struct{}
+
+func (NoTransactions) SupportsTransactions(ctx context.Context) bool {
+	return false
+}
+
+func (NoTransactions) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return ErrNotSupported
+}
+
+// Storage backend
+type Store struct {
+	Users        UserStore
+	Roles        RoleStore
+	ContentTypes ContentTypeStore
+	Content      ContentStore
+	Indexes      IndexStore
+	Migrations   MigrationStore
+	AuditLog     AuditStore
+	Transactions Transactor
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Returns the monitor of the MongoDB client, that records a span for every command.
+// The spans are children of the span in the context of the operation, e.g. the one of the request.
+// Commands outside of a trace, like the ones of background jobs and change streams, are not recorded,
+// so that they do not start a trace of their own every time.
+// The commands are not recorded, because they contain the values of the documents.
+func CommandMonitor() *event.CommandMonitor {
+	var spans sync.Map
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+			// Commands like find or insert name their collection, others like ping the value 1
+			collection, _ := e.Command.Lookup(e.CommandName).StringValueOK()
+			name := e.CommandName
+			if collection != "" {
+				name += " " + collection
+			}
+			_, span := Tracer().Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMongoDB,
+					semconv.DBNameKey.String(e.DatabaseName),
+					semconv.DBOperationKey.String(e.CommandName),
+					semconv.DBMongoDBCollectionKey.String(collection),
+				),
+			)
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			if span, ok := spans.LoadAndDelete(e.RequestID); ok {
+				span.(trace.Span).End()
+			}
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			if span, ok := spans.LoadAndDelete(e.RequestID); ok {
+				span.(trace.Span).SetStatus(codes.Error, e.Failure)
+				span.(trace.Span).End()
+			}
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCommandMonitor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	m := CommandMonitor()
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	command := func(name string, collection interface{}) bson.Raw {
+		b, err := bson.Marshal(bson.M{name: collection})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	m.Started(ctx, &event.CommandStartedEvent{Command: command("find", "blogposts"), DatabaseName: "FiberBackend", CommandName: "find", RequestID: 1})
+	m.Started(ctx, &event.CommandStartedEvent{Command: command("insert", "blogposts"), DatabaseName: "FiberBackend", CommandName: "insert", RequestID: 2})
+	m.Started(ctx, &event.CommandStartedEvent{Command: command("ping", 1), DatabaseName: "admin", CommandName: "ping", RequestID: 3})
+	m.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1}})
+	m.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "insert", RequestID: 2}, Failure: "duplicate key"})
+	m.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "ping", RequestID: 3}})
+	m.Started(context.Background(), &event.CommandStartedEvent{Command: command("getMore", 1), DatabaseName: "FiberBackend", CommandName: "getMore", RequestID: 4})
+	m.Succeeded(context.Background(), &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "getMore", RequestID: 4}})
+	parent.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	for _, name := range []string{"find blogposts", "insert blogposts", "ping"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("no span %q in %v", name, spans)
+		}
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the request", name)
+		}
+	}
+	if _, ok := spans["getMore"]; ok {
+		t.Error("command outside of a trace was recorded")
+	}
+	if s := spans["insert blogposts"].Status(); s.Code != codes.Error || s.Description != "duplicate key" {
+		t.Errorf("status of the failed insert is %v", s)
+	}
+	if s := spans["find blogposts"].Status(); s.Code == codes.Error {
+		t.Errorf("status of the find is %v", s)
+	}
+}
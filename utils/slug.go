@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Letters that are not decomposed into a base letter and a diacritic by unicode normalization
+var transliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss",
+	'æ': "ae", 'œ': "oe", 'ø': "o", 'å': "a",
+	'ð': "d", 'đ': "d", 'þ': "th", 'ł': "l", 'ı': "i",
+	'&': " and ",
+}
+
+// Makes a URL friendly slug from the input string:
+// Transliterates special letters, removes diacritics, lowercases and replaces any other character by a single hyphen.
+func Slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFD.String(replaceTransliterations(strings.ToLower(s))) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// skip diacritics that are left after decomposition
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			hyphen = false
+		case !hyphen && b.Len() > 0:
+			b.WriteRune('-')
+			hyphen = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Replaces all letters with a transliteration by it. Takes a lowercase string as input.
+func replaceTransliterations(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFC.String(s) {
+		if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
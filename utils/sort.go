@@ -0,0 +1,13 @@
+package utils
+
+import "sort"
+
+// Returns the keys of the map in ascending order
+func SortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
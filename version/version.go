@@ -0,0 +1,10 @@
+// Package version holds the build information of the binary. It is set by the linker, e.g.
+//
+//	go build -ldflags "-X github.com/D-Bald/fiber-backend/version.Version=v1.2.0 -X github.com/D-Bald/fiber-backend/version.Commit=$(git rev-parse --short HEAD)"
+package version
+
+// Release of the build
+var Version = "dev"
+
+// Git commit of the build
+var Commit = "unknown"